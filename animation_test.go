@@ -91,7 +91,7 @@ func TestIncomingMQTTMessageStartsHistoryPulse(t *testing.T) {
 	m.mqttClient = &MQTTClient{MessageChan: make(chan MQTTMessage)}
 	m.history.SetItems([]history.Item{})
 
-	m.handleMQTTMessage(MQTTMessage{Topic: "sensors/temp", Payload: "42"})
+	m.handleMQTTMessage(MQTTMessage{Topic: "sensors/temp", Payload: []byte("42")})
 
 	if got := m.historyPulseMarker(); got == " " {
 		t.Fatalf("expected incoming message history pulse")
@@ -0,0 +1,435 @@
+package emqutiti
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/mqttclient"
+	"github.com/marang/emqutiti/properties"
+)
+
+// Retain handling of forwarded messages.
+const (
+	bridgeRetainKeep   = "keep"
+	bridgeRetainAlways = "always"
+	bridgeRetainNever  = "never"
+)
+
+const (
+	// bridgeQueueSize bounds the messages waiting to be forwarded in each
+	// direction. Messages beyond it are dropped and counted.
+	bridgeQueueSize = 1024
+	// bridgeEchoTTL is how long a forwarded message is remembered so it
+	// is not sent back in bidirectional mode.
+	bridgeEchoTTL = time.Minute
+)
+
+// bridgeConfig describes which messages a bridge forwards and how.
+type bridgeConfig struct {
+	// Topics are the filters subscribed on the source.
+	Topics []string
+	// FromPrefix is replaced with ToPrefix in forwarded topics and
+	// filters. The way back swaps them.
+	FromPrefix string
+	ToPrefix   string
+	// QoS of forwarded messages; negative keeps the received QoS.
+	QoS int
+	// Retain is one of the bridgeRetain values; empty keeps the flag.
+	Retain string
+	// Bidirectional also forwards from the target to the source.
+	Bidirectional bool
+}
+
+// validate checks the settings before any connection is made.
+func (c bridgeConfig) validate() error {
+	if len(c.Topics) == 0 {
+		return fmt.Errorf("bridge: no topics to forward")
+	}
+	if c.QoS > 2 {
+		return fmt.Errorf("bridge: invalid QoS %d", c.QoS)
+	}
+	switch c.Retain {
+	case "", bridgeRetainKeep, bridgeRetainAlways, bridgeRetainNever:
+	default:
+		return fmt.Errorf("bridge: invalid retain mode %q (want keep, always or never)", c.Retain)
+	}
+	return nil
+}
+
+// parseBridgeRewrite splits a "from=to" prefix rewrite.
+func parseBridgeRewrite(s string) (from, to string, err error) {
+	if strings.TrimSpace(s) == "" {
+		return "", "", nil
+	}
+	from, to, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", "", fmt.Errorf("bridge: invalid rewrite %q (want FROM=TO)", s)
+	}
+	return strings.TrimSpace(from), strings.TrimSpace(to), nil
+}
+
+// rewritePrefix replaces the prefix from of topic with to. Topics without
+// the prefix are returned unchanged.
+func rewritePrefix(topic, from, to string) string {
+	if rest, ok := strings.CutPrefix(topic, from); ok {
+		return to + rest
+	}
+	return topic
+}
+
+// bridgeClient is the part of MQTTClient used by a bridge.
+type bridgeClient interface {
+	Subscribe(topic string, qos byte, callback mqtt.MessageHandler) error
+	Unsubscribe(topic string) error
+	PublishWithProperties(topic string, qos byte, retained bool, payload interface{}, props *properties.Properties) error
+	Reconnect() error
+	Disconnect()
+}
+
+// bridgeMessage is a received message waiting to be forwarded.
+type bridgeMessage struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+	props    *properties.Properties
+}
+
+// bridgeLeg forwards one direction of a bridge.
+type bridgeLeg struct {
+	from, to bridgeClient
+	// side names the receiving client for echo tracking.
+	side, toSide string
+	filters      []string
+	rewrite      func(string) string
+	queue        chan bridgeMessage
+}
+
+// bridgeStats counts what a bridge did.
+type bridgeStats struct {
+	Forwarded int64
+	Echoes    int64
+	Dropped   int64
+	Failed    int64
+}
+
+func (s bridgeStats) String() string {
+	return fmt.Sprintf("%d forwarded, %d loops prevented, %d dropped, %d failed", s.Forwarded, s.Echoes, s.Dropped, s.Failed)
+}
+
+// bridge forwards matching messages from one client to another and, in
+// bidirectional mode, back. Subscriptions are queued per direction so a
+// slow publish never blocks the receiving client.
+type bridge struct {
+	cfg  bridgeConfig
+	legs []*bridgeLeg
+
+	mu        sync.Mutex
+	echoes    map[string][]time.Time
+	lastSweep time.Time
+	lastErr   error
+	now       func() time.Time
+
+	forwarded, echoed, dropped, failed atomic.Int64
+
+	startOnce sync.Once
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// newBridge prepares a bridge from src to dst. Start subscribes.
+func newBridge(cfg bridgeConfig, src, dst bridgeClient) *bridge {
+	b := &bridge{cfg: cfg, echoes: map[string][]time.Time{}, now: time.Now, stop: make(chan struct{})}
+	from, to := cfg.FromPrefix, cfg.ToPrefix
+	b.legs = append(b.legs, &bridgeLeg{
+		from: src, to: dst, side: "source", toSide: "target",
+		filters: cfg.Topics,
+		rewrite: func(t string) string { return rewritePrefix(t, from, to) },
+		queue:   make(chan bridgeMessage, bridgeQueueSize),
+	})
+	if cfg.Bidirectional {
+		filters := make([]string, len(cfg.Topics))
+		for i, f := range cfg.Topics {
+			filters[i] = rewritePrefix(f, from, to)
+		}
+		b.legs = append(b.legs, &bridgeLeg{
+			from: dst, to: src, side: "target", toSide: "source",
+			filters: filters,
+			rewrite: func(t string) string { return rewritePrefix(t, to, from) },
+			queue:   make(chan bridgeMessage, bridgeQueueSize),
+		})
+	}
+	return b
+}
+
+// subscribeQoS is requested for the filters so kept QoS levels survive.
+func (b *bridge) subscribeQoS() byte {
+	if b.cfg.QoS >= 0 {
+		return byte(b.cfg.QoS)
+	}
+	return 2
+}
+
+// Start subscribes the filters of every direction. It is also used to
+// restore the subscriptions after a reconnect.
+func (b *bridge) Start() error {
+	b.startOnce.Do(func() {
+		for _, leg := range b.legs {
+			b.wg.Add(1)
+			go b.pump(leg)
+		}
+	})
+	for _, leg := range b.legs {
+		for _, f := range leg.filters {
+			if err := leg.from.Subscribe(f, b.subscribeQoS(), b.receive(leg)); err != nil {
+				return fmt.Errorf("bridge: subscribe %s on %s: %w", f, leg.side, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Stop unsubscribes and waits for queued messages to be handed over.
+func (b *bridge) Stop() {
+	for _, leg := range b.legs {
+		for _, f := range leg.filters {
+			_ = leg.from.Unsubscribe(f)
+		}
+	}
+	select {
+	case <-b.stop:
+	default:
+		close(b.stop)
+	}
+	b.wg.Wait()
+}
+
+// receive returns the subscription handler of leg.
+func (b *bridge) receive(leg *bridgeLeg) mqtt.MessageHandler {
+	return func(_ mqtt.Client, msg mqtt.Message) {
+		if b.cfg.Bidirectional && b.isEcho(leg.side, msg.Topic(), msg.Payload()) {
+			b.echoed.Add(1)
+			return
+		}
+		m := bridgeMessage{
+			topic:    msg.Topic(),
+			qos:      msg.Qos(),
+			retained: msg.Retained(),
+			payload:  append([]byte(nil), msg.Payload()...),
+			props:    mqttclient.MessageProperties(msg),
+		}
+		select {
+		case leg.queue <- m:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// pump publishes the queued messages of leg until the bridge stops.
+func (b *bridge) pump(leg *bridgeLeg) {
+	defer b.wg.Done()
+	for {
+		select {
+		case <-b.stop:
+			for {
+				select {
+				case m := <-leg.queue:
+					b.forward(leg, m)
+				default:
+					return
+				}
+			}
+		case m := <-leg.queue:
+			b.forward(leg, m)
+		}
+	}
+}
+
+func (b *bridge) forward(leg *bridgeLeg, m bridgeMessage) {
+	topic := leg.rewrite(m.topic)
+	qos := m.qos
+	if b.cfg.QoS >= 0 {
+		qos = byte(b.cfg.QoS)
+	}
+	retained := m.retained
+	switch b.cfg.Retain {
+	case bridgeRetainAlways:
+		retained = true
+	case bridgeRetainNever:
+		retained = false
+	}
+	if b.cfg.Bidirectional {
+		// Remember before publishing; the copy may arrive before the
+		// publish returns.
+		b.recordEcho(leg.toSide, topic, m.payload)
+	}
+	if err := leg.to.PublishWithProperties(topic, qos, retained, m.payload, m.props); err != nil {
+		b.failed.Add(1)
+		b.mu.Lock()
+		b.lastErr = err
+		b.mu.Unlock()
+		log.Printf("Bridge publish to %s %s failed: %v", leg.toSide, topic, err)
+		return
+	}
+	b.forwarded.Add(1)
+}
+
+func echoKey(side, topic string, payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return side + "\x00" + topic + "\x00" + string(sum[:])
+}
+
+// recordEcho remembers a message published to side.
+func (b *bridge) recordEcho(side, topic string, payload []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if now.Sub(b.lastSweep) > bridgeEchoTTL {
+		for k, ts := range b.echoes {
+			if ts = liveEchoes(ts, now); len(ts) == 0 {
+				delete(b.echoes, k)
+			} else {
+				b.echoes[k] = ts
+			}
+		}
+		b.lastSweep = now
+	}
+	k := echoKey(side, topic, payload)
+	b.echoes[k] = append(b.echoes[k], now)
+}
+
+// isEcho reports whether a message received on side was forwarded there by
+// the bridge itself and consumes the record.
+func (b *bridge) isEcho(side, topic string, payload []byte) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k := echoKey(side, topic, payload)
+	ts := liveEchoes(b.echoes[k], b.now())
+	if len(ts) == 0 {
+		delete(b.echoes, k)
+		return false
+	}
+	if ts = ts[1:]; len(ts) == 0 {
+		delete(b.echoes, k)
+	} else {
+		b.echoes[k] = ts
+	}
+	return true
+}
+
+// liveEchoes drops the records older than bridgeEchoTTL.
+func liveEchoes(ts []time.Time, now time.Time) []time.Time {
+	for len(ts) > 0 && now.Sub(ts[0]) > bridgeEchoTTL {
+		ts = ts[1:]
+	}
+	return ts
+}
+
+// Stats returns the counters of the bridge.
+func (b *bridge) Stats() bridgeStats {
+	return bridgeStats{
+		Forwarded: b.forwarded.Load(),
+		Echoes:    b.echoed.Load(),
+		Dropped:   b.dropped.Load(),
+		Failed:    b.failed.Load(),
+	}
+}
+
+// LastError returns the last failed publish, if any.
+func (b *bridge) LastError() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastErr
+}
+
+// bridgeConnect opens the client of one side of a bridge.
+type bridgeConnect func(connections.Profile, statusFunc) (bridgeClient, error)
+
+// connectBridgeClient is the default bridgeConnect.
+func connectBridgeClient(p connections.Profile, fn statusFunc) (bridgeClient, error) {
+	return NewMQTTClient(p, fn)
+}
+
+// runBridgeSession connects src and dst and forwards messages until ctx is
+// done. Lost connections are restored with the profile's reconnect backoff
+// and resubscribed. started receives the running bridge.
+func runBridgeSession(ctx context.Context, src, dst connections.Profile, cfg bridgeConfig, connect bridgeConnect, started func(*bridge)) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	profiles := []connections.Profile{src, dst}
+	lost := []chan struct{}{make(chan struct{}, 1), make(chan struct{}, 1)}
+	clients := make([]bridgeClient, 2)
+	for i, p := range profiles {
+		lostCh, name := lost[i], p.Name
+		status := func(s string) {
+			log.Printf("Bridge %s: %s", name, s)
+			if strings.HasPrefix(s, "Connection lost") {
+				select {
+				case lostCh <- struct{}{}:
+				default:
+				}
+			}
+		}
+		c, err := connect(p, status)
+		if err != nil {
+			return fmt.Errorf("bridge: connect %s: %w", p.Name, err)
+		}
+		defer c.Disconnect()
+		clients[i] = c
+	}
+	b := newBridge(cfg, clients[0], clients[1])
+	defer b.Stop()
+	if err := b.Start(); err != nil {
+		return err
+	}
+	if started != nil {
+		started(b)
+	}
+	for {
+		var i int
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-lost[0]:
+			i = 0
+		case <-lost[1]:
+			i = 1
+		}
+		if !reconnectBridgeClient(ctx, clients[i], profiles[i]) {
+			return nil
+		}
+		if err := b.Start(); err != nil {
+			log.Printf("Bridge %s: %v", profiles[i].Name, err)
+		}
+	}
+}
+
+// reconnectBridgeClient retries c until it connects or ctx is done.
+func reconnectBridgeClient(ctx context.Context, c bridgeClient, p connections.Profile) bool {
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(reconnectBackoff(p, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		err := c.Reconnect()
+		if err == nil {
+			log.Printf("Bridge %s: reconnected", p.Name)
+			return true
+		}
+		log.Printf("Bridge %s: reconnect attempt %d failed: %v", p.Name, attempt, err)
+	}
+}
@@ -0,0 +1,306 @@
+package emqutiti
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/properties"
+	"github.com/marang/emqutiti/ui"
+)
+
+type bridgePublish struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  string
+}
+
+// fakeBridgeClient stands in for a broker with one client. With echo set,
+// publishes are delivered back to matching subscriptions like a broker
+// would.
+type fakeBridgeClient struct {
+	mu           sync.Mutex
+	echo         bool
+	subs         map[string]mqtt.MessageHandler
+	published    []bridgePublish
+	disconnected bool
+}
+
+func (c *fakeBridgeClient) Subscribe(topic string, _ byte, cb mqtt.MessageHandler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subs == nil {
+		c.subs = map[string]mqtt.MessageHandler{}
+	}
+	c.subs[topic] = cb
+	return nil
+}
+
+func (c *fakeBridgeClient) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subs, topic)
+	return nil
+}
+
+func (c *fakeBridgeClient) PublishWithProperties(topic string, qos byte, retained bool, payload interface{}, _ *properties.Properties) error {
+	c.mu.Lock()
+	c.published = append(c.published, bridgePublish{topic, qos, retained, string(payload.([]byte))})
+	c.mu.Unlock()
+	if c.echo {
+		c.deliver(topic, string(payload.([]byte)))
+	}
+	return nil
+}
+
+func (c *fakeBridgeClient) Reconnect() error { return nil }
+
+func (c *fakeBridgeClient) Disconnect() {
+	c.mu.Lock()
+	c.disconnected = true
+	c.mu.Unlock()
+}
+
+// deliver hands a message to the subscriptions matching topic.
+func (c *fakeBridgeClient) deliver(topic, payload string) {
+	c.mu.Lock()
+	var handlers []mqtt.MessageHandler
+	for f, cb := range c.subs {
+		if f == topic || (strings.HasSuffix(f, "#") && strings.HasPrefix(topic, strings.TrimSuffix(f, "#"))) {
+			handlers = append(handlers, cb)
+		}
+	}
+	c.mu.Unlock()
+	for _, cb := range handlers {
+		cb(nil, fakeMessage{topic: topic, payload: []byte(payload)})
+	}
+}
+
+func (c *fakeBridgeClient) publishes() []bridgePublish {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]bridgePublish(nil), c.published...)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRewritePrefix(t *testing.T) {
+	if got := rewritePrefix("prod/telemetry/1", "prod/", "staging/"); got != "staging/telemetry/1" {
+		t.Fatalf("got %q", got)
+	}
+	if got := rewritePrefix("other/1", "prod/", "staging/"); got != "other/1" {
+		t.Fatalf("unmatched topic changed to %q", got)
+	}
+	if got := rewritePrefix("telemetry/#", "", "mirror/"); got != "mirror/telemetry/#" {
+		t.Fatalf("got %q", got)
+	}
+	if _, _, err := parseBridgeRewrite("prod/"); err == nil {
+		t.Fatalf("expected error without =")
+	}
+}
+
+func TestBridgeForwardsWithRewriteAndMapping(t *testing.T) {
+	src, dst := &fakeBridgeClient{}, &fakeBridgeClient{}
+	cfg := bridgeConfig{Topics: []string{"prod/#"}, FromPrefix: "prod/", ToPrefix: "staging/", QoS: 1, Retain: bridgeRetainAlways}
+	b := newBridge(cfg, src, dst)
+	if err := b.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	src.deliver("prod/telemetry/1", "21.5")
+	b.Stop()
+	want := []bridgePublish{{"staging/telemetry/1", 1, true, "21.5"}}
+	if got := dst.publishes(); len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if len(src.subs) != 0 {
+		t.Fatalf("expected filters unsubscribed on stop, got %v", src.subs)
+	}
+	if s := b.Stats(); s.Forwarded != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestBridgeBidirectionalPreventsLoops(t *testing.T) {
+	src, dst := &fakeBridgeClient{echo: true}, &fakeBridgeClient{echo: true}
+	cfg := bridgeConfig{Topics: []string{"prod/#"}, FromPrefix: "prod/", ToPrefix: "staging/", QoS: -1, Bidirectional: true}
+	b := newBridge(cfg, src, dst)
+	if err := b.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, ok := dst.subs["staging/#"]; !ok {
+		t.Fatalf("expected rewritten filter on the target, got %v", dst.subs)
+	}
+	src.deliver("prod/a", "1")
+	dst.deliver("staging/b", "2")
+	waitFor(t, "echoes", func() bool { return b.Stats().Echoes == 2 })
+	b.Stop()
+	if got := dst.publishes(); len(got) != 1 || got[0].topic != "staging/a" {
+		t.Fatalf("expected one publish to the target, got %+v", got)
+	}
+	if got := src.publishes(); len(got) != 1 || got[0].topic != "prod/b" {
+		t.Fatalf("expected one publish to the source, got %+v", got)
+	}
+	if s := b.Stats(); s.Forwarded != 2 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestBridgeEchoExpires(t *testing.T) {
+	b := newBridge(bridgeConfig{Topics: []string{"#"}, Bidirectional: true}, &fakeBridgeClient{}, &fakeBridgeClient{})
+	now := time.Unix(1700000000, 0)
+	b.now = func() time.Time { return now }
+	b.recordEcho("target", "a", []byte("1"))
+	now = now.Add(bridgeEchoTTL + time.Second)
+	if b.isEcho("target", "a", []byte("1")) {
+		t.Fatalf("expected stale echo to be forgotten")
+	}
+}
+
+func TestBridgeConfigValidate(t *testing.T) {
+	if err := (bridgeConfig{}).validate(); err == nil {
+		t.Fatalf("expected error without topics")
+	}
+	if err := (bridgeConfig{Topics: []string{"#"}, Retain: "sometimes"}).validate(); err == nil {
+		t.Fatalf("expected error for retain mode")
+	}
+}
+
+func TestRunBridgeSessionBetweenSandboxes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	sandbox := func() connections.Profile {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("reserve port: %v", err)
+		}
+		p := connections.SandboxProfile()
+		p.Port = ln.Addr().(*net.TCPAddr).Port
+		ln.Close()
+		return p
+	}
+	prod, staging := sandbox(), sandbox()
+	prod.Name, staging.Name = "prod", "staging"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan *bridge, 1)
+	done := make(chan error, 1)
+	cfg := bridgeConfig{Topics: []string{"prod/#"}, FromPrefix: "prod/", ToPrefix: "staging/", QoS: -1}
+	go func() {
+		done <- runBridgeSession(ctx, prod, staging, cfg, connectBridgeClient, func(b *bridge) { started <- b })
+	}()
+	var b *bridge
+	select {
+	case b = <-started:
+	case err := <-done:
+		t.Fatalf("bridge failed: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("bridge did not start")
+	}
+
+	sub, err := NewMQTTClient(staging, nil)
+	if err != nil {
+		t.Fatalf("connect staging: %v", err)
+	}
+	defer sub.Disconnect()
+	if err := sub.Subscribe("staging/#", 1, nil); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	pub, err := NewMQTTClient(prod, nil)
+	if err != nil {
+		t.Fatalf("connect prod: %v", err)
+	}
+	defer pub.Disconnect()
+	if err := pub.Publish("prod/line/1", 1, false, "ok"); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	select {
+	case m := <-sub.MessageChan:
+		if m.Topic != "staging/line/1" || string(m.Payload) != "ok" {
+			t.Fatalf("unexpected message %+v", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message not bridged")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("bridge: %v", err)
+	}
+	if s := b.Stats(); s.Forwarded != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestBridgeViewStartsAndStops(t *testing.T) {
+	m := clientModel(t, connections.Profile{}, &fakeClient{})
+	m.connections.Manager.Profiles = append(m.connections.Manager.Profiles, connections.Profile{Name: "staging"})
+	src, dst := &fakeBridgeClient{}, &fakeBridgeClient{}
+	m.bridge.connect = func(p connections.Profile, _ statusFunc) (bridgeClient, error) {
+		if p.Name == "p" {
+			return src, nil
+		}
+		return dst, nil
+	}
+
+	m.BeginBridge(0)
+	_ = m.SetMode(constants.ModeBridge)
+	if v := m.viewBridge(); !strings.Contains(v, "Source") || !strings.Contains(v, "staging") {
+		t.Fatalf("expected form with target preselected, got %q", v)
+	}
+	m.bridge.form.Fields[idxBridgeTopics].(*ui.TextField).SetValue("sensors/#")
+	if cmd := m.updateBridge(tea.KeyMsg{Type: tea.KeyEnter}); cmd == nil {
+		t.Fatalf("expected refresh tick after start")
+	}
+	if m.bridge.run == nil {
+		t.Fatalf("bridge not started: %s", m.bridge.err)
+	}
+	waitFor(t, "bridge start", func() bool {
+		m.bridge.run.mu.Lock()
+		defer m.bridge.run.mu.Unlock()
+		return m.bridge.run.bridge != nil
+	})
+	src.deliver("sensors/1", "on")
+	waitFor(t, "forward", func() bool { return len(dst.publishes()) == 1 })
+	if v := m.viewBridge(); !strings.Contains(v, "p -> staging") || !strings.Contains(v, "1 forwarded") {
+		t.Fatalf("expected running bridge status, got %q", v)
+	}
+
+	m.updateBridge(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	if m.bridge.run != nil {
+		t.Fatalf("expected bridge stopped")
+	}
+	if !strings.HasPrefix(lastLog(m), "Bridge p -> staging stopped") {
+		t.Fatalf("unexpected log %q", lastLog(m))
+	}
+	waitFor(t, "disconnect", func() bool {
+		src.mu.Lock()
+		defer src.mu.Unlock()
+		return src.disconnected
+	})
+}
+
+func TestBridgeFormRejectsSameProfile(t *testing.T) {
+	f := newBridgeForm([]connections.Profile{{Name: "only"}}, 0)
+	f.Fields[idxBridgeTopics].(*ui.TextField).SetValue("#")
+	if _, _, _, err := bridgeFormConfig(f); err == nil {
+		t.Fatalf("expected error when source equals target")
+	}
+}
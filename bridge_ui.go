@@ -0,0 +1,282 @@
+package emqutiti
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/ui"
+)
+
+const (
+	idxBridgeSource = iota
+	idxBridgeTarget
+	idxBridgeTopics
+	idxBridgeRewrite
+	idxBridgeQoS
+	idxBridgeRetain
+	idxBridgeBoth
+)
+
+var bridgeLabels = []string{"Source", "Target", "Topics", "Rewrite (from=to)", "QoS", "Retain", "Bidirectional"}
+
+// bridgeState backs the bridge view opened from the broker manager. Only
+// one bridge runs at a time; it keeps running when the view is closed.
+type bridgeState struct {
+	form ui.Form
+	err  string
+	run  *bridgeRun
+	// connect opens the bridge clients; tests replace it.
+	connect bridgeConnect
+}
+
+// bridgeRun is a bridge running in the background of the UI.
+type bridgeRun struct {
+	source, target string
+	cfg            bridgeConfig
+	cancel         context.CancelFunc
+
+	mu     sync.Mutex
+	bridge *bridge
+	done   bool
+	err    error
+}
+
+func (r *bridgeRun) started(b *bridge) {
+	r.mu.Lock()
+	r.bridge = b
+	r.mu.Unlock()
+}
+
+func (r *bridgeRun) finish(err error) {
+	r.mu.Lock()
+	r.done, r.err = true, err
+	r.mu.Unlock()
+}
+
+// bridgeTickMsg refreshes the counters of the bridge view.
+type bridgeTickMsg struct{}
+
+func bridgeTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return bridgeTickMsg{} })
+}
+
+// BeginBridge opens the bridge view with the profile at index as source.
+func (m *model) BeginBridge(index int) {
+	if m.bridge.run == nil {
+		m.bridge.form = newBridgeForm(m.connections.Manager.Profiles, index)
+		m.bridge.err = ""
+	}
+}
+
+// newBridgeForm builds the bridge settings form. The target defaults to
+// the profile after the source.
+func newBridgeForm(profiles []connections.Profile, index int) ui.Form {
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	var source, target string
+	if len(names) > 0 {
+		if index < 0 || index >= len(names) {
+			index = 0
+		}
+		source, target = names[index], names[(index+1)%len(names)]
+	}
+	sourceField, err := ui.NewSelectField(source, names)
+	if err != nil {
+		sourceField = &ui.SelectField{}
+	}
+	targetField, err := ui.NewSelectField(target, names)
+	if err != nil {
+		targetField = &ui.SelectField{}
+	}
+	qosField, _ := ui.NewSelectField("keep", []string{"keep", "0", "1", "2"})
+	retainField, _ := ui.NewSelectField(bridgeRetainKeep, []string{bridgeRetainKeep, bridgeRetainAlways, bridgeRetainNever})
+	f := ui.Form{Fields: []ui.Field{
+		sourceField,
+		targetField,
+		ui.NewTextField("", "e.g. prod/telemetry/#"),
+		ui.NewTextField("", "e.g. prod/=staging/"),
+		qosField,
+		retainField,
+		ui.NewCheckField(false),
+	}}
+	f.ApplyFocus()
+	return f
+}
+
+// bridgeFormConfig reads the bridge settings from the form.
+func bridgeFormConfig(f ui.Form) (source, target string, cfg bridgeConfig, err error) {
+	source = f.Fields[idxBridgeSource].Value()
+	target = f.Fields[idxBridgeTarget].Value()
+	if source == "" || target == "" {
+		return "", "", cfg, fmt.Errorf("bridge: select a source and a target profile")
+	}
+	if source == target {
+		return "", "", cfg, fmt.Errorf("bridge: source and target must differ")
+	}
+	cfg.Topics = splitList(f.Fields[idxBridgeTopics].Value())
+	if cfg.FromPrefix, cfg.ToPrefix, err = parseBridgeRewrite(f.Fields[idxBridgeRewrite].Value()); err != nil {
+		return "", "", cfg, err
+	}
+	cfg.QoS = -1
+	if q, err := strconv.Atoi(f.Fields[idxBridgeQoS].Value()); err == nil {
+		cfg.QoS = q
+	}
+	cfg.Retain = f.Fields[idxBridgeRetain].Value()
+	cfg.Bidirectional = f.Fields[idxBridgeBoth].Value() == "true"
+	return source, target, cfg, cfg.validate()
+}
+
+// startBridge connects the selected profiles in the background.
+func (m *model) startBridge() tea.Cmd {
+	source, target, cfg, err := bridgeFormConfig(m.bridge.form)
+	if err != nil {
+		m.bridge.err = err.Error()
+		return nil
+	}
+	var profiles [2]connections.Profile
+	for i, name := range []string{source, target} {
+		p, ok := m.profileByName(name)
+		if !ok {
+			m.bridge.err = fmt.Sprintf("bridge: profile %q not found", name)
+			return nil
+		}
+		if p.FromEnv {
+			connections.ApplyEnvVars(&p)
+		}
+		connections.ApplyDefaultPassword(&p)
+		profiles[i] = p
+	}
+	connect := m.bridge.connect
+	if connect == nil {
+		connect = connectBridgeClient
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &bridgeRun{source: source, target: target, cfg: cfg, cancel: cancel}
+	m.bridge.run, m.bridge.err = run, ""
+	go func() {
+		run.finish(runBridgeSession(ctx, profiles[0], profiles[1], cfg, connect, run.started))
+	}()
+	m.history.Append("", "", "log", false, fmt.Sprintf("Bridge %s -> %s started for %s", source, target, strings.Join(cfg.Topics, ", ")))
+	return bridgeTick()
+}
+
+// stopBridge ends the running bridge and keeps its settings in the form.
+func (m *model) stopBridge() {
+	run := m.bridge.run
+	if run == nil {
+		return
+	}
+	run.cancel()
+	m.bridge.run = nil
+	msg := fmt.Sprintf("Bridge %s -> %s stopped", run.source, run.target)
+	run.mu.Lock()
+	if run.bridge != nil {
+		msg += ": " + run.bridge.Stats().String()
+	}
+	run.mu.Unlock()
+	m.history.Append("", "", "log", false, msg)
+}
+
+func (m *model) profileByName(name string) (connections.Profile, bool) {
+	for _, p := range m.connections.Manager.Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return connections.Profile{}, false
+}
+
+// updateBridge edits the bridge settings or controls the running bridge.
+func (m *model) updateBridge(msg tea.Msg) tea.Cmd {
+	bs := &m.bridge
+	switch msg := msg.(type) {
+	case bridgeTickMsg:
+		if bs.run == nil {
+			return nil
+		}
+		return bridgeTick()
+	case tea.KeyMsg:
+		switch msg.String() {
+		case constants.KeyEsc:
+			return m.SetMode(constants.ModeConnections)
+		case constants.KeyCtrlD:
+			return tea.Quit
+		}
+		if bs.run != nil {
+			if msg.String() == constants.KeyX {
+				m.stopBridge()
+			}
+			return nil
+		}
+		if msg.String() == constants.KeyEnter {
+			return m.startBridge()
+		}
+		bs.form.CycleFocus(msg)
+	}
+	if bs.run != nil || len(bs.form.Fields) == 0 {
+		return nil
+	}
+	bs.form.ApplyFocus()
+	return bs.form.Fields[bs.form.Focus].Update(msg)
+}
+
+// viewBridge renders the bridge form or the status of the running bridge.
+func (m *model) viewBridge() string {
+	bs := m.bridge
+	var b strings.Builder
+	if run := bs.run; run != nil {
+		run.mu.Lock()
+		arrow := "->"
+		if run.cfg.Bidirectional {
+			arrow = "<->"
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", run.source, arrow, run.target)
+		fmt.Fprintf(&b, "Topics: %s\n", strings.Join(run.cfg.Topics, ", "))
+		if run.cfg.FromPrefix != "" || run.cfg.ToPrefix != "" {
+			fmt.Fprintf(&b, "Rewrite: %q -> %q\n", run.cfg.FromPrefix, run.cfg.ToPrefix)
+		}
+		switch {
+		case run.done && run.err != nil:
+			b.WriteString("\n" + ui.ErrorStyle.Render(run.err.Error()))
+		case run.bridge == nil:
+			b.WriteString("\nConnecting…")
+		default:
+			b.WriteString("\n" + run.bridge.Stats().String())
+			if err := run.bridge.LastError(); err != nil {
+				b.WriteString("\n" + ui.ErrorStyle.Render("Last error: "+err.Error()))
+			}
+		}
+		run.mu.Unlock()
+		b.WriteString("\n\n" + ui.InfoSubtleStyle.Render("[x] stop  [esc] back (keeps running)"))
+	} else {
+		for i, fld := range bs.form.Fields {
+			label := bridgeLabels[i]
+			if i == bs.form.Focus {
+				label = ui.FocusedStyle.Render(label)
+			}
+			b.WriteString(label + ": " + fld.View() + "\n")
+			if sf, ok := fld.(*ui.SelectField); ok && bs.form.IsFocused(i) {
+				if opts := sf.OptionsView(); opts != "" {
+					b.WriteString(opts + "\n")
+				}
+			}
+		}
+		if bs.err != "" {
+			b.WriteString("\n" + ui.ErrorStyle.Render(bs.err))
+		}
+		b.WriteString("\n" + ui.InfoSubtleStyle.Render("[enter] start  [esc] cancel"))
+	}
+	content := lipgloss.NewStyle().Padding(1, 2).Render(b.String())
+	return ui.LegendBox(content, "Bridge", m.ui.width-2, m.ui.height-2, ui.ColBlue, true, -1)
+}
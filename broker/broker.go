@@ -0,0 +1,131 @@
+// Package broker runs an in-process MQTT 3.1.1/5 broker for offline use.
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"syscall"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// Config describes the listeners and credentials of an embedded broker.
+type Config struct {
+	// TCPAddr and WSAddr are listen addresses. Empty disables the listener
+	// and port 0 picks a free one.
+	TCPAddr string
+	WSAddr  string
+	// Username and Password, if set, are required from every client.
+	// Otherwise anonymous clients are accepted.
+	Username string
+	Password string
+	// Logger receives broker logs; nil discards them.
+	Logger *slog.Logger
+}
+
+// Server is a running embedded broker. Retained messages are kept in
+// memory for its lifetime.
+type Server struct {
+	srv     *mqtt.Server
+	tcpAddr string
+	wsAddr  string
+}
+
+// Start launches a broker with the listeners in cfg.
+func Start(cfg Config) (*Server, error) {
+	if cfg.TCPAddr == "" && cfg.WSAddr == "" {
+		return nil, errors.New("broker: no listener configured")
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	srv := mqtt.New(&mqtt.Options{Logger: logger})
+	if cfg.Username != "" || cfg.Password != "" {
+		ledger := &auth.Ledger{Auth: auth.AuthRules{{Username: auth.RString(cfg.Username), Password: auth.RString(cfg.Password), Allow: true}}}
+		if err := srv.AddHook(new(auth.Hook), &auth.Options{Ledger: ledger}); err != nil {
+			return nil, fmt.Errorf("broker: %w", err)
+		}
+	} else if err := srv.AddHook(new(auth.AllowHook), nil); err != nil {
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	s := &Server{srv: srv}
+	if cfg.TCPAddr != "" {
+		tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: cfg.TCPAddr})
+		if err := srv.AddListener(tcp); err != nil {
+			srv.Close()
+			return nil, fmt.Errorf("broker: listen tcp %s: %w", cfg.TCPAddr, err)
+		}
+		s.tcpAddr = tcp.Address()
+	}
+	if cfg.WSAddr != "" {
+		// The websocket listener binds in the background, so check the
+		// address and resolve port 0 up front.
+		addr, err := reserve(cfg.WSAddr)
+		if err != nil {
+			srv.Close()
+			return nil, fmt.Errorf("broker: listen ws %s: %w", cfg.WSAddr, err)
+		}
+		if err := srv.AddListener(listeners.NewWebsocket(listeners.Config{ID: "ws", Address: addr})); err != nil {
+			srv.Close()
+			return nil, fmt.Errorf("broker: listen ws %s: %w", cfg.WSAddr, err)
+		}
+		s.wsAddr = addr
+	}
+	if err := srv.Serve(); err != nil {
+		srv.Close()
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	return s, nil
+}
+
+// reserve checks that addr is free and returns it with port 0 resolved.
+func reserve(addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer ln.Close()
+	return ln.Addr().String(), nil
+}
+
+// TCPAddr returns the host:port of the TCP listener, if any.
+func (s *Server) TCPAddr() string { return s.tcpAddr }
+
+// WSAddr returns the host:port of the websocket listener, if any.
+func (s *Server) WSAddr() string { return s.wsAddr }
+
+// Close stops the listeners and disconnects all clients.
+func (s *Server) Close() error { return s.srv.Close() }
+
+var (
+	localMu sync.Mutex
+	local   = map[string]*Server{}
+)
+
+// EnsureLocal makes sure a broker listens on the TCP address addr, starting
+// one in this process if needed. It stays up until the process exits. When
+// another program already holds the address, e.g. "emqutiti --serve", that
+// broker is used instead.
+func EnsureLocal(addr, username, password string) error {
+	localMu.Lock()
+	defer localMu.Unlock()
+	if _, ok := local[addr]; ok {
+		return nil
+	}
+	s, err := Start(Config{TCPAddr: addr, Username: username, Password: password})
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	local[addr] = s
+	return nil
+}
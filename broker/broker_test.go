@@ -0,0 +1,106 @@
+package broker
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/marang/emqutiti/mqttclient"
+)
+
+func connect(t *testing.T, url, version, user, pass string) (mqtt.Client, error) {
+	t.Helper()
+	opts := mqtt.NewClientOptions()
+	mqttclient.WithBroker(url)(opts)
+	mqttclient.WithClientID("c-"+version, true)(opts)
+	mqttclient.WithAuth(user, pass)(opts)
+	mqttclient.WithTimeouts(2, 30)(opts)
+	mqttclient.WithSession(false, true)(opts)
+	opt, err := mqttclient.WithVersion(version)
+	if err != nil {
+		t.Fatalf("version: %v", err)
+	}
+	opt(opts)
+	c := mqttclient.NewClient(opts, version, mqttclient.V5Properties{})
+	tok := c.Connect()
+	if !tok.WaitTimeout(5 * time.Second) {
+		t.Fatalf("connect to %s timed out", url)
+	}
+	return c, tok.Error()
+}
+
+func TestServerRetainsAcrossListeners(t *testing.T) {
+	srv, err := Start(Config{TCPAddr: "127.0.0.1:0", WSAddr: "127.0.0.1:0", Username: "u", Password: "p"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer srv.Close()
+
+	if _, err := connect(t, "tcp://"+srv.TCPAddr(), "4", "u", "wrong"); err == nil {
+		t.Fatalf("expected bad credentials to be rejected")
+	}
+	pub, err := connect(t, "tcp://"+srv.TCPAddr(), "4", "u", "p")
+	if err != nil {
+		t.Fatalf("connect tcp: %v", err)
+	}
+	defer pub.Disconnect(0)
+	if tok := pub.Publish("sandbox/state", 1, true, "on"); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("publish: %v", tok.Error())
+	}
+
+	sub, err := connect(t, "ws://"+srv.WSAddr(), "5", "u", "p")
+	if err != nil {
+		t.Fatalf("connect ws: %v", err)
+	}
+	defer sub.Disconnect(0)
+	got := make(chan mqtt.Message, 1)
+	if tok := sub.Subscribe("sandbox/#", 1, func(_ mqtt.Client, m mqtt.Message) { got <- m }); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("subscribe: %v", tok.Error())
+	}
+	select {
+	case m := <-got:
+		if m.Topic() != "sandbox/state" || string(m.Payload()) != "on" || !m.Retained() {
+			t.Fatalf("unexpected message %s %q retained=%v", m.Topic(), m.Payload(), m.Retained())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("retained message not delivered")
+	}
+}
+
+func TestStartRequiresListener(t *testing.T) {
+	if _, err := Start(Config{}); err == nil {
+		t.Fatalf("expected error without listeners")
+	}
+}
+
+func TestEnsureLocal(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	if err := EnsureLocal(addr, "", ""); err != nil {
+		t.Fatalf("EnsureLocal: %v", err)
+	}
+	if err := EnsureLocal(addr, "", ""); err != nil {
+		t.Fatalf("second EnsureLocal: %v", err)
+	}
+	c, err := connect(t, "tcp://"+addr, "5", "", "")
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	c.Disconnect(0)
+
+	// A broker run by another process is reused.
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer busy.Close()
+	if err := EnsureLocal(busy.Addr().String(), "", ""); err != nil {
+		t.Fatalf("EnsureLocal with the address in use: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package emqutiti
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/marang/emqutiti/connections"
+)
+
+const defaultMessageBuffer = 20
+
+// bufferStats counts inbound messages on their way to the UI.
+type bufferStats struct {
+	received atomic.Uint64
+	rendered atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+// BufferStats is a snapshot of the inbound message counters.
+type BufferStats struct {
+	Received uint64
+	Rendered uint64
+	Dropped  uint64
+	// Queued counts messages waiting in the buffer or on disk.
+	Queued int
+	// OnDisk counts the queued messages spilled to disk.
+	OnDisk int
+}
+
+// formatBufferStats renders the counters for the status bar.
+func formatBufferStats(s BufferStats) string {
+	queued := fmt.Sprintf("queued %d", s.Queued)
+	if s.OnDisk > 0 {
+		queued += fmt.Sprintf(" (%d on disk)", s.OnDisk)
+	}
+	return fmt.Sprintf("rx %d · shown %d · %s · dropped %d", s.Received, s.Rendered, queued, s.Dropped)
+}
+
+// messageBufferSize returns the profile's inbound buffer size.
+func messageBufferSize(p connections.Profile) int {
+	if p.MessageBuffer > 0 {
+		return p.MessageBuffer
+	}
+	return defaultMessageBuffer
+}
+
+// Stats returns the current inbound message counters.
+func (m *MQTTClient) Stats() BufferStats {
+	if m == nil {
+		return BufferStats{}
+	}
+	ch := m.safeMessageChan()
+	disk := m.spill.len()
+	return BufferStats{
+		Received: m.stats.received.Load(),
+		Rendered: m.stats.rendered.Load(),
+		Dropped:  m.stats.dropped.Load(),
+		Queued:   len(ch) + disk,
+		OnDisk:   disk,
+	}
+}
+
+// markRendered records that the UI displayed a message and lets spilled
+// messages move into the freed buffer slot.
+func (m *MQTTClient) markRendered() {
+	if m == nil {
+		return
+	}
+	m.stats.rendered.Add(1)
+	m.wakeSpill()
+}
+
+func (m *MQTTClient) wakeSpill() {
+	if m.spillReady == nil {
+		return
+	}
+	select {
+	case m.spillReady <- struct{}{}:
+	default:
+	}
+}
+
+// spillMessage writes msg to the spill queue, counting it as dropped when
+// the queue cannot be written.
+func (m *MQTTClient) spillMessage(msg MQTTMessage, fn statusFunc) error {
+	if err := m.spill.push(msg); err != nil {
+		m.stats.dropped.Add(1)
+		if fn != nil {
+			fn(fmt.Sprintf("Dropped MQTT message on %s: spill failed: %v", msg.Topic, err))
+		}
+		return err
+	}
+	m.wakeSpill()
+	return nil
+}
+
+// offer delivers msg if the buffer has room.
+func (m *MQTTClient) offer(msg MQTTMessage) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.MessageChan == nil {
+		return false
+	}
+	select {
+	case m.MessageChan <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// drainSpill moves spilled messages back into the buffer, oldest first, as
+// the UI frees space.
+func (m *MQTTClient) drainSpill() {
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-m.spillReady:
+		}
+		for {
+			msg, ok, err := m.spill.peek()
+			if err != nil {
+				// Skip unreadable entries so the queue keeps moving.
+				m.stats.dropped.Add(1)
+				if m.spill.advance() != nil {
+					break
+				}
+				continue
+			}
+			if !ok || !m.offer(msg) {
+				break
+			}
+			if err := m.spill.advance(); err != nil {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package emqutiti
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/proxy"
+)
+
+func TestBufferStatsCountDrops(t *testing.T) {
+	c := &MQTTClient{MessageChan: make(chan MQTTMessage, 1), done: make(chan struct{})}
+	for i := 0; i < 3; i++ {
+		_ = c.enqueueMessage(fakeMessage{topic: "t", payload: []byte("p")}, nil)
+	}
+	<-c.MessageChan
+	c.markRendered()
+	s := c.Stats()
+	if s.Received != 3 || s.Rendered != 1 || s.Dropped != 2 || s.Queued != 0 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if got := formatBufferStats(s); got != "rx 3 · shown 1 · queued 0 · dropped 2" {
+		t.Fatalf("unexpected status text %q", got)
+	}
+	if n := messageBufferSize(connections.Profile{MessageBuffer: 500}); n != 500 {
+		t.Fatalf("expected profile buffer size, got %d", n)
+	}
+}
+
+func TestSpillToDiskDeliversInOrder(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	t.Cleanup(p.Stop)
+	q, err := openSpillQueue(p.Addr(), "test")
+	if err != nil {
+		t.Fatalf("open spill: %v", err)
+	}
+	c := &MQTTClient{MessageChan: make(chan MQTTMessage, 2), done: make(chan struct{}), spill: q, spillReady: make(chan struct{}, 1)}
+	defer c.Disconnect()
+	go c.drainSpill()
+
+	const n = 6
+	for i := 0; i < n; i++ {
+		if err := c.enqueueMessage(fakeMessage{topic: "t", payload: []byte(fmt.Sprint(i))}, nil); err != nil {
+			t.Fatalf("enqueue %d: %v", i, err)
+		}
+	}
+	if s := c.Stats(); s.Dropped != 0 || s.Queued != n || s.OnDisk != n-2 {
+		t.Fatalf("expected overflow on disk, got %+v", s)
+	}
+	if !strings.Contains(formatBufferStats(c.Stats()), "(4 on disk)") {
+		t.Fatalf("expected on-disk count in status")
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case msg := <-c.MessageChan:
+			if string(msg.Payload) != fmt.Sprint(i) {
+				t.Fatalf("message %d out of order: %q", i, msg.Payload)
+			}
+			c.markRendered()
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+	if s := c.Stats(); s.Received != n || s.Rendered != n || s.Queued != 0 {
+		t.Fatalf("unexpected final stats %+v", s)
+	}
+}
+
+func TestSpillQueuesShareProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	t.Cleanup(p.Stop)
+	a, err := openSpillQueue(p.Addr(), "test")
+	if err != nil {
+		t.Fatalf("open spill: %v", err)
+	}
+	defer a.close()
+	if err := a.push(MQTTMessage{Topic: "t", Payload: []byte("a")}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	b, err := openSpillQueue(p.Addr(), "test")
+	if err != nil {
+		t.Fatalf("open second spill: %v", err)
+	}
+	if err := b.push(MQTTMessage{Topic: "t", Payload: []byte("b")}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	b.close()
+	msg, ok, err := a.peek()
+	if err != nil || !ok || string(msg.Payload) != "a" {
+		t.Fatalf("expected message of first queue, got %q %v %v", msg.Payload, ok, err)
+	}
+}
+
+func TestSpillQueueSweepsAbandonedSessions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	t.Cleanup(p.Stop)
+	live, err := openSpillQueue(p.Addr(), "test")
+	if err != nil {
+		t.Fatalf("open spill: %v", err)
+	}
+	defer live.close()
+	crashed, err := openSpillQueue(p.Addr(), "test")
+	if err != nil {
+		t.Fatalf("open spill: %v", err)
+	}
+	for _, q := range []*spillQueue{live, crashed} {
+		if err := q.push(MQTTMessage{Topic: "t", Payload: []byte(q.session)}); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+	// The second client dies without closing its queue and its lease
+	// runs out.
+	close(crashed.stop)
+	val, _ := json.Marshal(spillLease{Session: crashed.session, Renewed: time.Now().Add(-2 * spillLeaseTTL)})
+	if _, err := crashed.cl.Write(context.Background(), &proxy.WriteRequest{Profile: "test", Bucket: spillBucket, Key: crashed.leaseKey(), Value: val}); err != nil {
+		t.Fatalf("expire lease: %v", err)
+	}
+	crashed.conn.Close()
+
+	next, err := openSpillQueue(p.Addr(), "test")
+	if err != nil {
+		t.Fatalf("open spill: %v", err)
+	}
+	defer next.close()
+	left := func(key string) int {
+		resp, err := next.cl.Read(context.Background(), &proxy.ReadRequest{Profile: "test", Bucket: spillBucket, Key: key})
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return len(resp.Values)
+	}
+	if n := left(crashed.prefix()) + left(crashed.leaseKey()); n != 0 {
+		t.Fatalf("abandoned queue not swept, %d keys left", n)
+	}
+	if msg, ok, err := live.peek(); err != nil || !ok || string(msg.Payload) != live.session {
+		t.Fatalf("live queue swept: %q %v %v", msg.Payload, ok, err)
+	}
+}
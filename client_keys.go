@@ -6,6 +6,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/history"
 )
 
 type reconnectPromptMsg string
@@ -70,8 +71,20 @@ func (m *model) HandleClientKey(msg tea.KeyMsg) tea.Cmd {
 		return m.handleEnterKey()
 	case constants.KeyP:
 		return m.handleTogglePublishKey()
+	case constants.KeyQ:
+		return m.handleTopicQoSKey()
+	case constants.KeyCtrlQ:
+		return m.handleMessageQoSKey()
+	case constants.KeyAltP:
+		return m.handleMessagePropsKey()
+	case constants.KeyAltE:
+		return m.handleMessageEncodingKey()
+	case constants.KeyCtrlR:
+		return m.sendRequest()
 	case constants.KeyA:
 		return m.handleArchiveKey()
+	case constants.KeyE:
+		return m.handleExportKey()
 	case constants.KeyDelete:
 		return m.handleDeleteKey()
 	default:
@@ -103,8 +116,10 @@ func (m *model) handleDisconnectKey() tea.Cmd {
 		"You'll return to the broker manager where you can reconnect.",
 		nil,
 		func() tea.Cmd {
+			m.stopReconnect()
 			m.mqttClient.Disconnect()
 			m.connections.SetDisconnected(name, "")
+			m.syncTunnel(name)
 			m.connections.RefreshConnectionItems()
 			m.connections.Connection = ""
 			m.connections.Active = ""
@@ -137,16 +152,26 @@ func (m *model) publishMessage(retained bool) {
 		return
 	}
 	payload := m.message.Input().Value()
+	data, err := m.message.Payload()
+	if err != nil {
+		m.history.Append("", "", "log", false, fmt.Sprintf("Publish failed: %v", err))
+		return
+	}
+	props := m.message.Properties()
+	if !props.IsEmpty() && m.mqttClient != nil && !m.mqttClient.SupportsProperties() {
+		m.history.Append("", "", "log", false, "MQTT 5 properties ignored: profile does not use MQTT 5")
+		props = nil
+	}
 	targets := m.publishTargets()
 	for _, topic := range targets {
 		m.payloads.Add(topic, payload)
-		msg := fmt.Sprintf("Published to %s: %s", topic, payload)
+		msg := fmt.Sprintf("Published to %s: %s", topic, history.PayloadText(data))
 		if retained {
-			msg = fmt.Sprintf("Published retained to %s: %s", topic, payload)
+			msg = fmt.Sprintf("Published retained to %s: %s", topic, history.PayloadText(data))
 		}
-		m.history.Append(topic, payload, "pub", retained, msg)
+		m.history.AppendMessage(history.Message{Topic: topic, Payload: data, Kind: "pub", Retained: retained, Properties: props}, msg)
 		if m.mqttClient != nil {
-			m.mqttClient.Publish(topic, 0, retained, payload)
+			m.mqttClient.PublishWithProperties(topic, m.publishQoS(topic), retained, data, props)
 		}
 	}
 }
@@ -177,6 +202,32 @@ func (m *model) handlePublishRetainKey() tea.Cmd {
 	return tea.Batch(m.startTopicPulses(targets), m.startHistoryPulse())
 }
 
+// handleMessageQoSKey cycles the per-publish QoS override of the editor.
+func (m *model) handleMessageQoSKey() tea.Cmd {
+	if m.ui.focusOrder[m.ui.focusIndex] == idMessage {
+		m.message.CycleQoS()
+	}
+	return nil
+}
+
+// handleMessageEncodingKey cycles the payload encoding of the editor
+// between text, hex and base64.
+func (m *model) handleMessageEncodingKey() tea.Cmd {
+	if m.ui.focusOrder[m.ui.focusIndex] == idMessage {
+		m.message.CycleEncoding()
+	}
+	return nil
+}
+
+// handleMessagePropsKey opens the MQTT 5 properties editor for the message.
+func (m *model) handleMessagePropsKey() tea.Cmd {
+	if m.ui.focusOrder[m.ui.focusIndex] != idMessage {
+		return nil
+	}
+	m.message.StartPropertiesEditor()
+	return m.SetMode(constants.ModeMessageProps)
+}
+
 // handleDeleteKey dispatches deletion based on focus.
 func (m *model) handleDeleteKey() tea.Cmd {
 	switch m.ui.focusOrder[m.ui.focusIndex] {
@@ -3,7 +3,6 @@ package emqutiti
 import (
 	"fmt"
 	"strings"
-	"time"
 	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -21,7 +20,7 @@ func (m *model) copyHistoryItems(items []history.Item) (int, error) {
 	for _, hi := range items {
 		text := hi.Payload
 		if hi.Kind != "log" {
-			text = fmt.Sprintf("%s: %s", hi.Topic, hi.Payload)
+			text = fmt.Sprintf("%s: %s", hi.Topic, hi.Text())
 		}
 		parts = append(parts, text)
 	}
@@ -77,18 +76,9 @@ func (m *model) handleClearFilterKey() tea.Cmd {
 	if m.ui.focusOrder[m.ui.focusIndex] != idHistory {
 		return nil
 	}
-	m.history.SetFilterQuery("")
 	m.history.List().FilterInput.SetValue("")
 	m.history.List().SetFilterState(list.Unfiltered)
-	var msgs []history.Message
-	if m.history.ShowArchived() {
-		msgs = m.history.Store().Search(true, nil, time.Time{}, time.Time{}, "")
-	} else {
-		msgs = m.history.Store().Search(false, nil, time.Time{}, time.Time{}, "")
-	}
-	hitems, items := history.MessagesToItems(msgs)
-	m.history.SetItems(hitems)
-	m.history.List().SetItems(items)
+	m.history.Load("")
 	return nil
 }
 
@@ -102,11 +92,41 @@ func (m *model) handleHistoryViewKey() tea.Cmd {
 		return nil
 	}
 	hi := m.history.List().Items()[idx].(history.Item)
-	if utf8.RuneCountInString(hi.Payload) <= historyPreviewLimit {
+	binary := !utf8.ValidString(hi.Payload)
+	if !binary && utf8.RuneCountInString(hi.Payload) <= historyPreviewLimit && hi.Properties.IsEmpty() {
 		return nil
 	}
 	m.history.SetDetailItem(hi)
-	m.history.Detail().SetContent(history.FormatDetailPayload(hi.Payload))
+	m.history.Detail().SetContent(history.FormatDetail(hi))
 	m.history.Detail().SetYOffset(0)
 	return m.SetMode(constants.ModeHistoryDetail)
 }
+
+// handleExportKey exports the selected history items, or all items of the
+// current filter result when none are selected.
+func (m *model) handleExportKey() tea.Cmd {
+	if m.ui.focusOrder[m.ui.focusIndex] != idHistory {
+		return nil
+	}
+	var selected, all []history.Record
+	for _, it := range m.history.Items() {
+		r := history.ItemRecord(it)
+		if it.IsSelected != nil && *it.IsSelected {
+			selected = append(selected, r)
+		}
+		all = append(all, r)
+	}
+	records, rest := all, m.history.RemainingRecords()
+	if len(selected) > 0 {
+		records, rest = selected, nil
+	}
+	name := m.connections.Active
+	title := "History"
+	if name != "" {
+		title += " of " + name
+	}
+	if q := m.history.FilterQuery(); q != "" {
+		title += " (" + q + ")"
+	}
+	return m.startExport(title, name, records, rest)
+}
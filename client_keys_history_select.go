@@ -62,6 +62,7 @@ func (m *model) handleShiftDownKey() tea.Cmd {
 			idx := m.history.List().Index()
 			m.history.UpdateSelectionRange(idx)
 		}
+		m.history.LoadMore()
 		return m.startHistoryScrollAnimation(oldScroll, m.rawHistoryScrollPercent())
 	}
 	return nil
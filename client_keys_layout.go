@@ -121,6 +121,8 @@ func (m *model) handleModeSwitchKey(msg tea.KeyMsg) tea.Cmd {
 		m.logs.SetSize(m.ui.width, m.ui.height)
 		m.logs.Focus()
 		return m.SetMode(constants.ModeLogs)
+	case constants.KeyAltO:
+		return m.handlePendingKey()
 	default:
 		return nil
 	}
@@ -82,6 +82,20 @@ func (m *model) handleDeleteTopicKey() tea.Cmd {
 	return nil
 }
 
+// handleTopicQoSKey cycles the QoS override on the selected topic.
+func (m *model) handleTopicQoSKey() tea.Cmd {
+	if m.ui.focusOrder[m.ui.focusIndex] != idTopics {
+		return nil
+	}
+	sel := m.topics.Selected()
+	if sel < 0 || sel >= len(m.topics.Items) {
+		return nil
+	}
+	cmd := m.topics.CycleQoS(sel)
+	m.topics.EnsureVisible(m.ui.width - 4)
+	return cmd
+}
+
 // handleTogglePublishKey toggles the publish flag on the selected topic.
 func (m *model) handleTogglePublishKey() tea.Cmd {
 	if m.ui.focusOrder[m.ui.focusIndex] == idTopics {
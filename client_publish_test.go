@@ -1,6 +1,8 @@
 package emqutiti
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 	"time"
 
@@ -53,7 +55,10 @@ func (stubToken) WaitTimeout(time.Duration) bool { return true }
 func (stubToken) Done() <-chan struct{}          { ch := make(chan struct{}); close(ch); return ch }
 func (stubToken) Error() error                   { return nil }
 
-type mockClient struct{ retained bool }
+type mockClient struct {
+	retained bool
+	payload  interface{}
+}
 
 func (m *mockClient) IsConnected() bool      { return true }
 func (m *mockClient) IsConnectionOpen() bool { return true }
@@ -61,6 +66,7 @@ func (m *mockClient) Connect() mqtt.Token    { return stubToken{} }
 func (m *mockClient) Disconnect(uint)        {}
 func (m *mockClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
 	m.retained = retained
+	m.payload = payload
 	return stubToken{}
 }
 func (m *mockClient) Subscribe(string, byte, mqtt.MessageHandler) mqtt.Token { return stubToken{} }
@@ -88,3 +94,36 @@ func TestHandlePublishRetainKey(t *testing.T) {
 		t.Fatalf("expected history item marked retained")
 	}
 }
+
+func TestPublishDecodesHexPayload(t *testing.T) {
+	m, _ := initialModel(nil)
+	fc := &mockClient{}
+	m.mqttClient = &MQTTClient{Client: fc}
+	m.topics.Items = []topics.Item{{Name: "a"}}
+	m.topics.SetSelected(0)
+	m.SetFocus(idMessage)
+	m.handleMessageEncodingKey()
+	if !strings.Contains(m.message.View(), "[hex]") {
+		t.Fatalf("expected editor label to show hex encoding")
+	}
+	m.message.SetPayload("ff 00 10")
+	m.handlePublishKey()
+	if b, _ := fc.payload.([]byte); !bytes.Equal(b, []byte{0xff, 0x00, 0x10}) {
+		t.Fatalf("expected decoded bytes, got %#v", fc.payload)
+	}
+	items := m.history.Items()
+	if last := items[len(items)-1]; last.Payload != "\xff\x00\x10" || last.Text() != "0xff0010" {
+		t.Fatalf("unexpected history item %+v", last)
+	}
+
+	fc.payload = nil
+	m.message.SetPayload("zz")
+	m.handlePublishKey()
+	if fc.payload != nil {
+		t.Fatalf("invalid hex should not be published")
+	}
+	items = m.history.Items()
+	if !strings.Contains(items[len(items)-1].Payload, "invalid hex payload") {
+		t.Fatalf("expected decode error log, got %+v", items[len(items)-1])
+	}
+}
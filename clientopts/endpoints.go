@@ -0,0 +1,93 @@
+package clientopts
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/connections"
+)
+
+// BrokerEndpoints orders the broker URLs of a profile for connection
+// attempts and records the one in use.
+type BrokerEndpoints struct {
+	opts *mqtt.ClientOptions
+	// servers keeps the profile order; opts.Servers holds the order of the
+	// next attempt.
+	servers  []*url.URL
+	strategy string
+
+	mu             sync.Mutex
+	trying, active string
+}
+
+func newBrokerEndpoints(opts *mqtt.ClientOptions, strategy string) *BrokerEndpoints {
+	return &BrokerEndpoints{
+		opts:     opts,
+		servers:  append([]*url.URL(nil), opts.Servers...),
+		strategy: strategy,
+	}
+}
+
+// notify records the broker being dialled and reports failed endpoints
+// when there are others to fall back to.
+func (e *BrokerEndpoints) notify(fn func(string)) func(mqtt.Client, mqtt.ConnectionNotification) {
+	return func(_ mqtt.Client, n mqtt.ConnectionNotification) {
+		switch n := n.(type) {
+		case mqtt.ConnectionNotificationBroker:
+			e.mu.Lock()
+			e.trying = n.Broker.String()
+			e.mu.Unlock()
+		case mqtt.ConnectionNotificationBrokerFailed:
+			if fn != nil && len(e.servers) > 1 {
+				fn(fmt.Sprintf("Broker %s unavailable: %v", n.Broker, n.Reason))
+			}
+		}
+	}
+}
+
+// Connected marks the broker of the last attempt as active.
+func (e *BrokerEndpoints) Connected() string {
+	if e == nil {
+		return ""
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.active = e.trying
+	return e.active
+}
+
+// Current returns the active broker URL.
+func (e *BrokerEndpoints) Current() string {
+	if e == nil {
+		return ""
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.active
+}
+
+// Rotate arranges the brokers for the next attempt. Failover always starts
+// with the first endpoint while round robin starts after the one used last.
+// paho copies the client options but shares the Servers slice, so reordering
+// it in place affects the next attempt of both protocol clients.
+func (e *BrokerEndpoints) Rotate() {
+	if e == nil || len(e.servers) < 2 {
+		return
+	}
+	n := len(e.servers)
+	start := 0
+	if e.strategy == connections.StrategyRoundRobin {
+		active := e.Current()
+		for i, u := range e.servers {
+			if u.String() == active {
+				start = (i + 1) % n
+			}
+		}
+	}
+	for i := range e.servers {
+		e.opts.Servers[i] = e.servers[(start+i)%n]
+	}
+}
@@ -0,0 +1,52 @@
+package clientopts
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/connections"
+)
+
+func serverList(o *mqtt.ClientOptions) string {
+	var s []string
+	for _, u := range o.Servers {
+		s = append(s, u.Host)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestBrokerEndpointsRotate(t *testing.T) {
+	opts := mqtt.NewClientOptions().AddBroker("tcp://a:1").AddBroker("tcp://b:1").AddBroker("tcp://c:1")
+	e := newBrokerEndpoints(opts, connections.StrategyRoundRobin)
+	notify := e.notify(nil)
+	b, _ := url.Parse("tcp://b:1")
+	notify(nil, mqtt.ConnectionNotificationBroker{Broker: b})
+	if got := e.Connected(); got != "tcp://b:1" {
+		t.Fatalf("expected b active, got %q", got)
+	}
+	e.Rotate()
+	if got := serverList(opts); got != "c:1,a:1,b:1" {
+		t.Fatalf("round robin should start after b, got %s", got)
+	}
+
+	e.strategy = connections.StrategyFailover
+	e.Rotate()
+	if got := serverList(opts); got != "a:1,b:1,c:1" {
+		t.Fatalf("failover should start with the first broker, got %s", got)
+	}
+}
+
+func TestBrokerEndpointsReportFailures(t *testing.T) {
+	var got []string
+	opts := mqtt.NewClientOptions().AddBroker("tcp://a:1").AddBroker("tcp://b:1")
+	e := newBrokerEndpoints(opts, "")
+	a, _ := url.Parse("tcp://a:1")
+	e.notify(func(s string) { got = append(got, s) })(nil, mqtt.ConnectionNotificationBrokerFailed{Broker: a, Reason: errors.New("refused")})
+	if len(got) != 1 || got[0] != "Broker tcp://a:1 unavailable: refused" {
+		t.Fatalf("unexpected status %v", got)
+	}
+}
@@ -0,0 +1,199 @@
+// Package clientopts turns a connection profile into MQTT client options.
+package clientopts
+
+import (
+	"context"
+	"crypto/tls"
+	"path/filepath"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/internal/files"
+	"github.com/marang/emqutiti/mqttclient"
+)
+
+// Options are the MQTT client options of a profile together with the
+// resources they use. The UI and headless traces both connect through them.
+type Options struct {
+	Options *mqtt.ClientOptions
+	// Endpoints orders the profile's brokers for reconnect attempts.
+	Endpoints *BrokerEndpoints
+	// Tunnel carries broker connections when the profile uses SSH.
+	Tunnel *mqttclient.SSHTunnel
+	// Credentials runs the profile's password command, if any.
+	Credentials *connections.Credentials
+	// Inflight persists unacknowledged packets when enabled on the profile.
+	Inflight *mqttclient.InflightStore
+
+	version string
+	v5      mqttclient.V5Properties
+}
+
+// Build translates p into client options. Dynamic credentials are fetched
+// once so that failures surface before connecting; a sandbox broker must
+// already be running. status, if set, receives progress
+// such as the SSH tunnel state, unavailable endpoints and, on connect, the
+// broker in use, the TLS handshake and the MQTT 5 CONNACK. Automatic
+// reconnects follow p; they rotate the brokers like Endpoints.Rotate.
+func Build(p connections.Profile, status func(string)) (*Options, error) {
+	opts := mqtt.NewClientOptions()
+	optionFns := []mqttclient.ClientOption{
+		mqttclient.WithBrokers(p.BrokerURLs()),
+		mqttclient.WithClientID(p.ClientID, p.RandomIDSuffix),
+		mqttclient.WithAuth(p.Username, p.Password),
+		mqttclient.WithTimeouts(p.ConnectTimeout, p.KeepAlive),
+		mqttclient.WithSession(p.AutoReconnect, p.CleanStart),
+		mqttclient.WithWill(p.LastWillEnabled, p.LastWillTopic, p.LastWillPayload, p.LastWillQos, p.LastWillRetain),
+	}
+
+	if opt, err := mqttclient.WithVersion(p.MQTTVersion); err != nil {
+		return nil, err
+	} else {
+		optionFns = append(optionFns, opt)
+	}
+
+	headers, err := p.WSHeader()
+	if err != nil {
+		return nil, err
+	}
+	if opt, err := mqttclient.WithWebsocket(headers, p.WSProxy); err != nil {
+		return nil, err
+	} else {
+		optionFns = append(optionFns, opt)
+	}
+
+	if opt, err := mqttclient.WithTLSOptions(p.SSL, tlsOptions(p)); err != nil {
+		return nil, err
+	} else {
+		optionFns = append(optionFns, opt)
+	}
+
+	o := &Options{Options: opts, version: p.MQTTVersion, v5: v5Properties(p)}
+	if p.SSHHost != "" {
+		t, err := mqttclient.NewSSHTunnel(sshConfig(p))
+		if err != nil {
+			return nil, err
+		}
+		if status != nil {
+			t.OnStatus = func(s string) { status("SSH tunnel " + s) }
+		}
+		o.Tunnel = t
+		optionFns = append(optionFns, mqttclient.WithSSHTunnel(t))
+	}
+
+	if c := connections.CredentialsFor(p); c != nil {
+		if _, err := c.Resolve(context.Background()); err != nil {
+			o.Close()
+			return nil, err
+		}
+		o.Credentials = c
+		optionFns = append(optionFns, mqttclient.WithCredentials(c.Provider(p.Username)))
+	}
+
+	if p.PersistInflight {
+		if err := p.CheckInflight(); err != nil {
+			o.Close()
+			return nil, err
+		}
+		store, err := mqttclient.OpenInflightStore(InflightDir(p.Name), p.MQTTVersion)
+		if err != nil {
+			o.Close()
+			return nil, err
+		}
+		o.Inflight = store
+		optionFns = append(optionFns, mqttclient.WithInflightStore(store))
+	}
+
+	for _, opt := range optionFns {
+		opt(opts)
+	}
+	if p.ReconnectMaxInterval > 0 {
+		opts.SetMaxReconnectInterval(time.Duration(p.ReconnectMaxInterval) * time.Second)
+	}
+
+	// The TLS state is read once connected, when the broker has accepted
+	// the handshake.
+	tlsState := func() (tls.ConnectionState, bool) { return tls.ConnectionState{}, false }
+	if p.SSL {
+		tlsState = mqttclient.RecordTLS(opts).State
+	}
+
+	o.Endpoints = newBrokerEndpoints(opts, p.BrokerStrategy)
+	opts.OnConnectionNotification = o.Endpoints.notify(status)
+	opts.SetReconnectingHandler(func(mqtt.Client, *mqtt.ClientOptions) { o.Endpoints.Rotate() })
+	opts.OnConnect = func(client mqtt.Client) {
+		ep := o.Endpoints.Connected()
+		if status == nil {
+			return
+		}
+		status("Connected to " + ep)
+		if cs, ok := tlsState(); ok {
+			status(mqttclient.DescribeTLS(cs))
+		}
+		if v5, ok := client.(*mqttclient.V5Client); ok {
+			status(mqttclient.DescribeConnack(v5.Connack()))
+		}
+	}
+	return o, nil
+}
+
+// NewClient creates the client of the profile's MQTT version.
+func (o *Options) NewClient() mqtt.Client {
+	return mqttclient.NewClient(o.Options, o.version, o.v5)
+}
+
+// Close closes the SSH tunnel. The options must not be used afterwards.
+func (o *Options) Close() {
+	if o != nil && o.Tunnel != nil {
+		o.Tunnel.Close()
+	}
+}
+
+// InflightDir returns the directory of the persisted in-flight packets of
+// profile.
+func InflightDir(profile string) string {
+	return filepath.Join(files.DataDir(profile), "inflight")
+}
+
+// tlsOptions extracts the TLS settings from the profile.
+func tlsOptions(p connections.Profile) mqttclient.TLSOptions {
+	return mqttclient.TLSOptions{
+		SkipVerify:     p.SkipTLSVerify,
+		CACertPath:     p.CACertPath,
+		SystemRoots:    p.TLSSystemRoots,
+		ClientCertPath: p.ClientCertPath,
+		ClientKeyPath:  p.ClientKeyPath,
+		PKCS12Path:     p.PKCS12Path,
+		KeyPassphrase:  p.ClientKeyPassphrase,
+		ServerName:     p.TLSServerName,
+		ALPN:           p.TLSALPN,
+		MinVersion:     p.TLSMinVersion,
+		CipherSuites:   p.TLSCipherSuites,
+	}
+}
+
+// sshConfig extracts the SSH tunnel settings from the profile.
+func sshConfig(p connections.Profile) mqttclient.SSHConfig {
+	return mqttclient.SSHConfig{
+		Addr:            p.SSHHost,
+		User:            p.SSHUser,
+		KeyPath:         p.SSHKeyPath,
+		Password:        p.SSHPassword,
+		KnownHosts:      p.SSHKnownHosts,
+		InsecureHostKey: p.SSHSkipHostKeyCheck,
+	}
+}
+
+// v5Properties extracts the MQTT 5 CONNECT properties from the profile.
+func v5Properties(p connections.Profile) mqttclient.V5Properties {
+	return mqttclient.V5Properties{
+		SessionExpiry:       p.SessionExpiry,
+		ReceiveMaximum:      p.ReceiveMaximum,
+		MaximumPacketSize:   p.MaximumPacketSize,
+		TopicAliasMaximum:   p.TopicAliasMaximum,
+		RequestResponseInfo: p.RequestResponseInfo,
+		RequestProblemInfo:  p.RequestProblemInfo,
+	}
+}
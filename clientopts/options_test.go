@@ -0,0 +1,79 @@
+package clientopts
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/mqttclient"
+)
+
+func TestBuild(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	p := connections.Profile{
+		Name: "p", Schema: "tcp", Host: "a", Port: 1, Brokers: []string{"b"}, ClientID: "id",
+		BrokerStrategy: connections.StrategyRoundRobin, AutoReconnect: true, ReconnectMaxInterval: 7,
+		PersistInflight: true, MQTTVersion: "5", SessionExpiry: 60,
+	}
+	o, err := Build(p, nil)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	defer o.Close()
+	opts := o.Options
+	if !opts.AutoReconnect || opts.MaxReconnectInterval != 7*time.Second {
+		t.Fatalf("reconnect not applied: %+v", opts)
+	}
+	if o.Inflight == nil || o.Inflight.Dir() != InflightDir("p") {
+		t.Fatalf("inflight store not opened")
+	}
+	if _, ok := o.NewClient().(*mqttclient.V5Client); !ok {
+		t.Fatalf("expected an MQTT 5 client")
+	}
+	// Automatic reconnects rotate the brokers like the reconnect manager.
+	a, _ := url.Parse("tcp://a:1")
+	opts.OnConnectionNotification(nil, mqtt.ConnectionNotificationBroker{Broker: a})
+	o.Endpoints.Connected()
+	opts.OnReconnecting(nil, opts)
+	if got := serverList(opts); got != "b:1,a:1" {
+		t.Fatalf("servers after reconnect = %s", got)
+	}
+
+	// Without a session expiry the broker would drop the persisted packets.
+	p.SessionExpiry = 0
+	if _, err := Build(p, nil); err == nil {
+		t.Fatalf("expected an error for in-flight persistence without session expiry")
+	}
+}
+
+func TestV5PropertiesFromProfile(t *testing.T) {
+	p := connections.Profile{
+		SessionExpiry:       60,
+		ReceiveMaximum:      5,
+		MaximumPacketSize:   1024,
+		TopicAliasMaximum:   3,
+		RequestResponseInfo: true,
+		RequestProblemInfo:  true,
+	}
+	got := v5Properties(p)
+	want := mqttclient.V5Properties{
+		SessionExpiry:       60,
+		ReceiveMaximum:      5,
+		MaximumPacketSize:   1024,
+		TopicAliasMaximum:   3,
+		RequestResponseInfo: true,
+		RequestProblemInfo:  true,
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if _, ok := mqttclient.NewClient(mqtt.NewClientOptions(), "5", got).(*mqttclient.V5Client); !ok {
+		t.Fatalf("expected v5 client for MQTT version 5")
+	}
+	if _, ok := mqttclient.NewClient(mqtt.NewClientOptions(), "4", got).(*mqttclient.V5Client); ok {
+		t.Fatalf("expected paho v3 client for MQTT version 4")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -17,6 +18,98 @@ type AppConfig struct {
 	Timeout      time.Duration
 	ListProfiles bool
 	ShowVersion  bool
+
+	// Outputs of headless traces besides the store.
+	TraceFormat     string
+	TraceFile       string
+	TraceFileFormat string
+	TraceFileMaxMB  int
+	TraceFileKeep   int
+	TraceProgress   time.Duration
+
+	// Serve runs the embedded MQTT broker instead of the UI.
+	Serve         bool
+	ServeTCP      string
+	ServeWS       string
+	ServeUser     string
+	ServePassword string
+	// Bridge names the "SOURCE,TARGET" profiles to forward messages
+	// between; the filters come from TraceTopics.
+	Bridge        string
+	BridgeRewrite string
+	BridgeQoS     int
+	BridgeRetain  string
+	BridgeBoth    bool
+	// Command is the "pub", "sub", "export", "load" or "proxy"
+	// subcommand, if one was given.
+	Command string
+	PubSub  PubSubConfig
+	Export  ExportConfig
+	Capture CaptureConfig
+	Proxy   ProxyConfig
+}
+
+// ProxyConfig holds the options of the proxy subcommand.
+type ProxyConfig struct {
+	// Addr is the listen address; the configured proxy_addr when empty.
+	Addr string
+	// IdleTimeout stops the daemon after this long without clients. Zero
+	// keeps it running.
+	IdleTimeout time.Duration
+	// Stop asks the running daemon to shut down instead of starting one.
+	Stop bool
+	// PruneInterval is how often retention limits are enforced.
+	PruneInterval time.Duration
+}
+
+// CaptureConfig holds the options of the load subcommand.
+type CaptureConfig struct {
+	// File is the capture to load; "-" reads stdin.
+	File string
+	// Format is ndjson, mosquitto or mqtt-explorer; empty detects it.
+	Format string
+	// Trace loads the capture into this new trace key instead of the
+	// history.
+	Trace string
+}
+
+// ExportConfig holds the options of the export subcommand.
+type ExportConfig struct {
+	// Query filters messages using the history filter syntax.
+	Query string
+	// Trace exports the messages of this trace key instead of the history.
+	Trace    string
+	Archived bool
+	Format   string
+	Columns  string
+	// Output is the file to write; stdout when empty.
+	Output string
+}
+
+// PubSubConfig holds the options of the pub and sub subcommands.
+type PubSubConfig struct {
+	Topics []string
+	QoS    int
+	// Payload sources of pub; exactly one is used.
+	Message string
+	File    string
+	Stdin   bool
+	Lines   bool
+	Null    bool
+	Retain  bool
+	// Format and Count control the output of sub.
+	Format string
+	Count  int
+}
+
+// stringList collects a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
 }
 
 var version = "dev"
@@ -27,6 +120,18 @@ func Version() string {
 }
 
 func ParseFlags() AppConfig {
+	if len(os.Args) > 1 && (os.Args[1] == "pub" || os.Args[1] == "sub") {
+		return parseSubcommand(os.Args[1], os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		return parseExport(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "load" {
+		return parseLoad(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "proxy" {
+		return parseProxy(os.Args[2:])
+	}
 	var cfg AppConfig
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	fs.StringVar(&cfg.ImportFile, "import", "", "Launch import wizard with optional file path")
@@ -34,14 +139,30 @@ func ParseFlags() AppConfig {
 	fs.StringVar(&cfg.ProfileName, "profile", "", "Connection profile name to use")
 	fs.StringVar(&cfg.ProfileName, "p", "", "(shorthand)")
 	fs.StringVar(&cfg.TraceKey, "trace", "", "Trace key name to store messages")
-	fs.StringVar(&cfg.TraceTopics, "topics", "", "Comma-separated topics to trace")
+	fs.StringVar(&cfg.TraceTopics, "topics", "", "Comma-separated topics to trace or bridge")
 	fs.StringVar(&cfg.TraceStart, "start", "", "Optional RFC3339 trace start time")
 	fs.StringVar(&cfg.TraceEnd, "end", "", "Optional RFC3339 trace end time")
+	fs.StringVar(&cfg.TraceFormat, "trace-format", "", "Also write traced messages to stdout as ndjson or csv")
+	fs.StringVar(&cfg.TraceFile, "trace-file", "", "Also append traced messages to FILE")
+	fs.StringVar(&cfg.TraceFileFormat, "trace-file-format", "ndjson", "Format of --trace-file: ndjson or csv")
+	fs.IntVar(&cfg.TraceFileMaxMB, "trace-file-max-mb", 100, "Rotate --trace-file at this size in MiB (0 disables)")
+	fs.IntVar(&cfg.TraceFileKeep, "trace-file-keep", 5, "Rotated trace files to keep")
+	fs.DurationVar(&cfg.TraceProgress, "trace-progress", 0, "Write a JSON progress line to stderr at this interval (e.g., 10s)")
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "Optional overall runtime limit (e.g., 30s)")
 	fs.BoolVar(&cfg.ListProfiles, "list-profiles", false, "List available connection profiles and exit")
 	fs.BoolVar(&cfg.ListProfiles, "l", false, "(shorthand)")
 	fs.BoolVar(&cfg.ShowVersion, "version", false, "Print version and exit")
 	fs.BoolVar(&cfg.ShowVersion, "v", false, "(shorthand)")
+	fs.BoolVar(&cfg.Serve, "serve", false, "Run a local MQTT broker")
+	fs.StringVar(&cfg.ServeTCP, "serve-tcp", "127.0.0.1:1883", "TCP listen address of the local broker (empty disables)")
+	fs.StringVar(&cfg.ServeWS, "serve-ws", "127.0.0.1:8083", "WebSocket listen address of the local broker (empty disables)")
+	fs.StringVar(&cfg.ServeUser, "serve-user", "", "Username required by the local broker")
+	fs.StringVar(&cfg.ServePassword, "serve-password", "", "Password required by the local broker")
+	fs.StringVar(&cfg.Bridge, "bridge", "", "Forward messages between two profiles given as SOURCE,TARGET")
+	fs.StringVar(&cfg.BridgeRewrite, "bridge-rewrite", "", "Replace topic prefix FROM with TO when forwarding (FROM=TO)")
+	fs.IntVar(&cfg.BridgeQoS, "bridge-qos", -1, "QoS of forwarded messages (-1 keeps the received QoS)")
+	fs.StringVar(&cfg.BridgeRetain, "bridge-retain", "keep", "Retain flag of forwarded messages: keep, always or never")
+	fs.BoolVar(&cfg.BridgeBoth, "bridge-both", false, "Also forward from the target back to the source")
 	fs.Usage = func() {
 		w := fs.Output()
 		fmt.Fprintf(w, "Usage: %s [flags]\n\n", os.Args[0])
@@ -56,7 +177,176 @@ func ParseFlags() AppConfig {
 		fmt.Fprintln(w, "      --topics LIST     Comma-separated topics to trace (e.g., --topics \"sensors/#\")")
 		fmt.Fprintln(w, "      --start TIME      Optional RFC3339 trace start time (e.g., --start \"2025-08-05T11:47:00Z\")")
 		fmt.Fprintln(w, "      --end TIME        Optional RFC3339 trace end time (e.g., --end \"2025-08-05T11:49:00Z\")")
+		fmt.Fprintln(w, "      --trace-format F  Also write messages to stdout as ndjson or csv")
+		fmt.Fprintln(w, "      --trace-file FILE Also append messages to FILE (--trace-file-format ndjson|csv)")
+		fmt.Fprintln(w, "      --trace-file-max-mb N, --trace-file-keep N")
+		fmt.Fprintln(w, "                        Rotate the file at N MiB (default 100) and keep N old files (default 5)")
+		fmt.Fprintln(w, "      --trace-progress D")
+		fmt.Fprintln(w, "                        Write per-topic counts as JSON to stderr every D (e.g., 10s)")
+		fmt.Fprintln(w, "                        Exit status: 2 no messages, 3 subscribe failed, 4 connection lost")
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Local broker:")
+		fmt.Fprintln(w, "      --serve           Run an MQTT 3.1.1/5 broker until interrupted")
+		fmt.Fprintln(w, "      --serve-tcp ADDR  TCP listener (default 127.0.0.1:1883, empty disables)")
+		fmt.Fprintln(w, "      --serve-ws ADDR   WebSocket listener (default 127.0.0.1:8083, empty disables)")
+		fmt.Fprintln(w, "      --serve-user USER, --serve-password PASS")
+		fmt.Fprintln(w, "                        Require these credentials instead of allowing anonymous clients")
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Bridge:")
+		fmt.Fprintln(w, "      --bridge SRC,DST  Forward messages matching --topics from profile SRC to DST")
+		fmt.Fprintln(w, "      --bridge-rewrite FROM=TO")
+		fmt.Fprintln(w, "                        Replace topic prefix FROM with TO (e.g., prod/=staging/)")
+		fmt.Fprintln(w, "      --bridge-qos N    QoS of forwarded messages (default -1 keeps the received QoS)")
+		fmt.Fprintln(w, "      --bridge-retain M Retain flag: keep (default), always or never")
+		fmt.Fprintln(w, "      --bridge-both     Also forward back from DST to SRC without looping")
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Subcommands:")
+		fmt.Fprintln(w, "  pub                   Publish a message with a profile (see pub -h)")
+		fmt.Fprintln(w, "  sub                   Print messages received with a profile (see sub -h)")
+		fmt.Fprintln(w, "  export                Write stored history or a trace to a file (see export -h)")
+		fmt.Fprintln(w, "  load                  Import a message capture into history or a trace (see load -h)")
+		fmt.Fprintln(w, "  proxy                 Run the database proxy as a standalone daemon (see proxy -h)")
 	}
 	_ = fs.Parse(os.Args[1:])
 	return cfg
 }
+
+// parseSubcommand parses the flags of "emqutiti pub" and "emqutiti sub".
+func parseSubcommand(name string, args []string) AppConfig {
+	cfg := AppConfig{Command: name}
+	o := &cfg.PubSub
+	var verbose bool
+	fs := flag.NewFlagSet(os.Args[0]+" "+name, flag.ExitOnError)
+	fs.StringVar(&cfg.ProfileName, "profile", "", "Connection profile name to use")
+	fs.StringVar(&cfg.ProfileName, "p", "", "(shorthand)")
+	fs.Var((*stringList)(&o.Topics), "topic", "Topic to publish to or filter to subscribe (repeatable for sub)")
+	fs.Var((*stringList)(&o.Topics), "t", "(shorthand)")
+	fs.IntVar(&o.QoS, "qos", 0, "QoS level 0, 1 or 2")
+	fs.IntVar(&o.QoS, "q", 0, "(shorthand)")
+	fs.DurationVar(&cfg.Timeout, "timeout", 0, "Optional overall runtime limit (e.g., 30s)")
+	if name == "pub" {
+		fs.StringVar(&o.Message, "message", "", "Payload to publish")
+		fs.StringVar(&o.Message, "m", "", "(shorthand)")
+		fs.StringVar(&o.File, "file", "", "Publish the contents of FILE (- for stdin)")
+		fs.StringVar(&o.File, "f", "", "(shorthand)")
+		fs.BoolVar(&o.Stdin, "stdin", false, "Publish stdin as one message")
+		fs.BoolVar(&o.Stdin, "s", false, "(shorthand)")
+		fs.BoolVar(&o.Lines, "lines", false, "Publish each line of stdin as a message")
+		fs.BoolVar(&o.Lines, "l", false, "(shorthand)")
+		fs.BoolVar(&o.Null, "null", false, "Publish an empty message")
+		fs.BoolVar(&o.Null, "n", false, "(shorthand)")
+		fs.BoolVar(&o.Retain, "retain", false, "Set the retain flag")
+		fs.BoolVar(&o.Retain, "r", false, "(shorthand)")
+	} else {
+		fs.StringVar(&o.Format, "format", "plain", "Output format: plain, topic or ndjson")
+		fs.StringVar(&o.Format, "F", "plain", "(shorthand)")
+		fs.BoolVar(&verbose, "v", false, "Print the topic before each payload (same as --format topic)")
+		fs.IntVar(&o.Count, "count", 0, "Exit after receiving N messages")
+		fs.IntVar(&o.Count, "C", 0, "(shorthand)")
+	}
+	fs.Usage = func() {
+		w := fs.Output()
+		if name == "pub" {
+			fmt.Fprintf(w, "Usage: %s pub [-p PROFILE] -t TOPIC (-m MSG | -f FILE | -s | -l | -n) [-q QOS] [-r]\n\n", os.Args[0])
+			fmt.Fprintln(w, "  -m, --message MSG     Payload to publish")
+			fmt.Fprintln(w, "  -f, --file FILE       Publish the contents of FILE (- for stdin)")
+			fmt.Fprintln(w, "  -s, --stdin           Publish stdin as one message")
+			fmt.Fprintln(w, "  -l, --lines           Publish each line of stdin as a message")
+			fmt.Fprintln(w, "  -n, --null            Publish an empty message")
+			fmt.Fprintln(w, "  -r, --retain          Set the retain flag")
+		} else {
+			fmt.Fprintf(w, "Usage: %s sub [-p PROFILE] -t FILTER [-t FILTER...] [-q QOS] [--format FMT] [-C N]\n\n", os.Args[0])
+			fmt.Fprintln(w, "  -F, --format FMT      plain (payload), topic (topic and payload) or ndjson")
+			fmt.Fprintln(w, "  -v                    Same as --format topic")
+			fmt.Fprintln(w, "  -C, --count N         Exit after receiving N messages")
+		}
+		fmt.Fprintln(w, "  -p, --profile NAME    Connection profile (default profile when omitted)")
+		fmt.Fprintln(w, "  -t, --topic TOPIC     Topic to publish to or filter to subscribe")
+		fmt.Fprintln(w, "  -q, --qos N           QoS level 0, 1 or 2 (default 0)")
+		fmt.Fprintln(w, "      --timeout D       Optional overall runtime limit (e.g., 30s)")
+	}
+	_ = fs.Parse(args)
+	if verbose {
+		o.Format = "topic"
+	}
+	return cfg
+}
+
+// parseExport parses the flags of "emqutiti export".
+func parseExport(args []string) AppConfig {
+	cfg := AppConfig{Command: "export"}
+	o := &cfg.Export
+	fs := flag.NewFlagSet(os.Args[0]+" export", flag.ExitOnError)
+	fs.StringVar(&cfg.ProfileName, "profile", "", "Connection profile whose history to export")
+	fs.StringVar(&cfg.ProfileName, "p", "", "(shorthand)")
+	fs.StringVar(&o.Query, "query", "", "Filter such as \"topic=a,b start=... payload=foo\"")
+	fs.StringVar(&o.Query, "q", "", "(shorthand)")
+	fs.StringVar(&o.Trace, "trace", "", "Export the trace KEY instead of the history")
+	fs.BoolVar(&o.Archived, "archived", false, "Export archived history messages")
+	fs.StringVar(&o.Format, "format", "ndjson", "Output format: ndjson, csv, json or html")
+	fs.StringVar(&o.Format, "F", "ndjson", "(shorthand)")
+	fs.StringVar(&o.Columns, "columns", "", "Comma-separated CSV columns")
+	fs.StringVar(&o.Output, "output", "", "Write to FILE instead of stdout")
+	fs.StringVar(&o.Output, "o", "", "(shorthand)")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: %s export [-p PROFILE | --trace KEY] [-q QUERY] [-F FORMAT] [-o FILE]\n\n", os.Args[0])
+		fmt.Fprintln(w, "  -p, --profile NAME    Profile whose history to export (default profile when omitted)")
+		fmt.Fprintln(w, "      --trace KEY       Export the messages of a trace instead")
+		fmt.Fprintln(w, "  -q, --query QUERY     Filter, e.g. \"topic=sensors/1 start=2025-08-05T11:00:00Z payload=error\"")
+		fmt.Fprintln(w, "      --archived        Export archived history messages")
+		fmt.Fprintln(w, "  -F, --format FMT      ndjson (default), csv, json or html")
+		fmt.Fprintln(w, "      --columns LIST    CSV columns (default time,topic,qos,retained,payload,encoding; also kind)")
+		fmt.Fprintln(w, "  -o, --output FILE     Write to FILE instead of stdout")
+	}
+	_ = fs.Parse(args)
+	return cfg
+}
+
+// parseLoad parses the flags of "emqutiti load".
+func parseLoad(args []string) AppConfig {
+	cfg := AppConfig{Command: "load"}
+	o := &cfg.Capture
+	fs := flag.NewFlagSet(os.Args[0]+" load", flag.ExitOnError)
+	fs.StringVar(&cfg.ProfileName, "profile", "", "Connection profile to load the messages into")
+	fs.StringVar(&cfg.ProfileName, "p", "", "(shorthand)")
+	fs.StringVar(&o.Trace, "trace", "", "Load into the new trace KEY instead of the history")
+	fs.StringVar(&o.Format, "format", "", "Capture format: ndjson, mosquitto or mqtt-explorer (detected when omitted)")
+	fs.StringVar(&o.Format, "F", "", "(shorthand)")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: %s load [-p PROFILE] [--trace KEY] [-F FORMAT] FILE\n\n", os.Args[0])
+		fmt.Fprintln(w, "  FILE                  Capture to import, - for stdin")
+		fmt.Fprintln(w, "  -p, --profile NAME    Profile whose history receives the messages (default profile when omitted)")
+		fmt.Fprintln(w, "      --trace KEY       Create trace KEY of the profile instead")
+		fmt.Fprintln(w, "  -F, --format FMT      ndjson, mosquitto (mosquitto_sub -v output) or mqtt-explorer")
+	}
+	_ = fs.Parse(args)
+	o.File = fs.Arg(0)
+	return cfg
+}
+
+// parseProxy parses the flags of "emqutiti proxy".
+func parseProxy(args []string) AppConfig {
+	cfg := AppConfig{Command: "proxy"}
+	o := &cfg.Proxy
+	fs := flag.NewFlagSet(os.Args[0]+" proxy", flag.ExitOnError)
+	fs.StringVar(&o.Addr, "addr", "", "Listen address, host:port or unix:PATH (default: proxy_addr of config.toml or the proxy.sock socket)")
+	fs.DurationVar(&o.IdleTimeout, "idle-timeout", 0, "Exit after this long without clients (e.g., 10m; 0 runs until stopped)")
+	fs.BoolVar(&o.Stop, "stop", false, "Stop the running daemon and exit")
+	fs.DurationVar(&o.PruneInterval, "prune-interval", 10*time.Minute, "How often to prune to the retention limits of the profiles")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: %s proxy [--addr ADDR] [--idle-timeout D] [--prune-interval D] [--stop]\n\n", os.Args[0])
+		fmt.Fprintln(w, "  Serve history and traces to every emqutiti instance until interrupted.")
+		fmt.Fprintln(w, "  A proxy embedded in a running instance hands its databases over.")
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "      --addr ADDR       host:port or unix:PATH (default proxy_addr of config.toml or the proxy.sock socket)")
+		fmt.Fprintln(w, "      --idle-timeout D  Exit after D without clients (default 0 runs until stopped)")
+		fmt.Fprintln(w, "      --prune-interval D")
+		fmt.Fprintln(w, "                        Prune to the retention limits of the profiles every D (default 10m)")
+		fmt.Fprintln(w, "      --stop            Ask the running daemon to shut down")
+	}
+	_ = fs.Parse(args)
+	return cfg
+}
@@ -32,11 +32,12 @@ type API interface {
 	ConnectionMessage() string
 	SetConnectionMessage(string)
 	Active() string
-	BeginAdd()
+	BeginAdd(p Profile)
+	BeginBridge(index int)
 	BeginEdit(index int)
 	BeginDelete(index int)
 	Connect(p Profile) tea.Cmd
-	HandleConnectResult(msg ConnectResult)
+	HandleConnectResult(msg ConnectResult) tea.Cmd
 	DisconnectActive()
 	ResizeTraces(width, height int)
 	ResetElemPos()
@@ -19,6 +19,7 @@ type connectionItem struct {
 	title  string
 	status string
 	detail string
+	tunnel string
 }
 
 func (c connectionItem) FilterValue() string { return c.title }
@@ -53,6 +54,35 @@ func (c *State) SetConnected(name string) {
 	c.Manager.Errors[name] = ""
 }
 
+// SetConnectedVia marks the named connection as connected and shows the
+// broker endpoint in use. An empty endpoint leaves the detail blank.
+func (c *State) SetConnectedVia(name, endpoint string) {
+	c.SetConnected(name)
+	if endpoint != "" {
+		c.Manager.Errors[name] = "via " + endpoint
+	}
+}
+
+// SetTunnel records the SSH tunnel status of the named connection. An empty
+// status hides it.
+func (c *State) SetTunnel(name, status string) {
+	if status == "" {
+		delete(c.Manager.Tunnels, name)
+		return
+	}
+	if c.Manager.Tunnels == nil {
+		c.Manager.Tunnels = make(map[string]string)
+	}
+	c.Manager.Tunnels[name] = status
+}
+
+// SetReconnecting marks the named connection as reconnecting with the
+// attempt and countdown in detail.
+func (c *State) SetReconnecting(name, detail string) {
+	c.Manager.Statuses[name] = "reconnecting"
+	c.Manager.Errors[name] = detail
+}
+
 // SetDisconnected marks the named connection as disconnected with an optional detail.
 func (c *State) SetDisconnected(name, detail string) {
 	c.Manager.Statuses[name] = "disconnected"
@@ -134,9 +164,17 @@ func NewComponent(nav Navigator, api API) *Component {
 			return nil
 		},
 		constants.KeyA: func(tea.KeyMsg) tea.Cmd {
-			c.api.BeginAdd()
+			c.api.BeginAdd(Profile{})
 			return c.nav.SetMode(constants.ModeEditConnection)
 		},
+		constants.KeyS: func(tea.KeyMsg) tea.Cmd {
+			c.api.BeginAdd(SandboxProfile())
+			return c.nav.SetMode(constants.ModeEditConnection)
+		},
+		constants.KeyB: func(tea.KeyMsg) tea.Cmd {
+			c.api.BeginBridge(c.api.Manager().ConnectionsList.Index())
+			return c.nav.SetMode(constants.ModeBridge)
+		},
 		constants.KeyE: func(tea.KeyMsg) tea.Cmd {
 			mgr := c.api.Manager()
 			i := mgr.ConnectionsList.Index()
@@ -186,10 +224,10 @@ func (c *Component) Update(msg tea.Msg) tea.Cmd {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case ConnectResult:
-		c.api.HandleConnectResult(msg)
+		watch := c.api.HandleConnectResult(msg)
 		if msg.Err == nil {
 			cmd = c.nav.SetMode(constants.ModeClient)
-			return tea.Batch(cmd, c.api.ListenStatus())
+			return tea.Batch(cmd, c.api.ListenStatus(), watch)
 		}
 		return c.api.ListenStatus()
 	case tea.KeyMsg:
@@ -214,7 +252,7 @@ func (c *Component) View() string {
 	ch := c.nav.Height() - 6
 	c.api.Manager().ConnectionsList.SetSize(cw, ch)
 	listView := c.api.Manager().ConnectionsList.View()
-	help := ui.InfoStyle.Render("[enter] connect/open client  Ctrl+X disconnect  [a]dd [e]dit [del] delete  Ctrl+O default  Alt+R traces")
+	help := ui.InfoStyle.Render("[enter] connect/open client  Ctrl+X disconnect  [a]dd [s]andbox [e]dit [b]ridge [del] delete  Ctrl+O default  Alt+R traces")
 	content := lipgloss.JoinVertical(lipgloss.Left, listView, help)
 	view := ui.LegendBox(content, "Brokers", c.nav.Width()-2, 0, ui.ColBlue, true, -1)
 	return c.api.OverlayHelp(view)
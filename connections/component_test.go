@@ -15,31 +15,33 @@ func (t *testNav) Height() int                         { return 24 }
 
 type testAPI struct {
 	began bool
+	added Profile
 	mgr   *Connections
 }
 
-func (t *testAPI) Manager() *Connections             { return t.mgr }
-func (t *testAPI) ListenStatus() tea.Cmd             { return nil }
-func (t *testAPI) SendStatus(string)                 {}
-func (t *testAPI) FlushStatus()                      {}
-func (t *testAPI) RefreshConnectionItems()           {}
-func (t *testAPI) SubscribeActiveTopics()            {}
-func (t *testAPI) ConnectionMessage() string         { return "" }
-func (t *testAPI) SetConnectionMessage(string)       {}
-func (t *testAPI) Active() string                    { return "" }
-func (t *testAPI) BeginAdd()                         { t.began = true }
-func (t *testAPI) BeginEdit(int)                     {}
-func (t *testAPI) BeginDelete(int)                   {}
-func (t *testAPI) Connect(Profile) tea.Cmd           { return nil }
-func (t *testAPI) HandleConnectResult(ConnectResult) {}
-func (t *testAPI) DisconnectActive()                 {}
-func (t *testAPI) ResizeTraces(int, int)             {}
-func (t *testAPI) ResetElemPos()                     {}
-func (t *testAPI) SetElemPos(string, int)            {}
-func (t *testAPI) OverlayHelp(view string) string    { return view }
-func (t *testAPI) SetConnecting(string)              {}
-func (t *testAPI) SetConnected(string)               {}
-func (t *testAPI) SetDisconnected(string, string)    {}
+func (t *testAPI) Manager() *Connections                     { return t.mgr }
+func (t *testAPI) ListenStatus() tea.Cmd                     { return nil }
+func (t *testAPI) SendStatus(string)                         {}
+func (t *testAPI) FlushStatus()                              {}
+func (t *testAPI) RefreshConnectionItems()                   {}
+func (t *testAPI) SubscribeActiveTopics()                    {}
+func (t *testAPI) ConnectionMessage() string                 { return "" }
+func (t *testAPI) SetConnectionMessage(string)               {}
+func (t *testAPI) Active() string                            { return "" }
+func (t *testAPI) BeginAdd(p Profile)                        { t.began, t.added = true, p }
+func (t *testAPI) BeginBridge(int)                           {}
+func (t *testAPI) BeginEdit(int)                             {}
+func (t *testAPI) BeginDelete(int)                           {}
+func (t *testAPI) Connect(Profile) tea.Cmd                   { return nil }
+func (t *testAPI) HandleConnectResult(ConnectResult) tea.Cmd { return nil }
+func (t *testAPI) DisconnectActive()                         {}
+func (t *testAPI) ResizeTraces(int, int)                     {}
+func (t *testAPI) ResetElemPos()                             {}
+func (t *testAPI) SetElemPos(string, int)                    {}
+func (t *testAPI) OverlayHelp(view string) string            { return view }
+func (t *testAPI) SetConnecting(string)                      {}
+func (t *testAPI) SetConnected(string)                       {}
+func (t *testAPI) SetDisconnected(string, string)            {}
 
 func TestAddKeyTriggersBeginAdd(t *testing.T) {
 	mgr := NewConnectionsModel()
@@ -54,3 +56,17 @@ func TestAddKeyTriggersBeginAdd(t *testing.T) {
 		t.Fatalf("expected mode %v, got %v", constants.ModeEditConnection, nav.mode)
 	}
 }
+
+func TestSandboxKeyAddsSandboxProfile(t *testing.T) {
+	mgr := NewConnectionsModel()
+	api := &testAPI{mgr: &mgr}
+	nav := &testNav{}
+	c := NewComponent(nav, api)
+	c.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	if !api.began || api.added.Preset != PresetSandbox || api.added.Host != "127.0.0.1" || api.added.Port != 1883 {
+		t.Fatalf("expected sandbox profile form, got %+v", api.added)
+	}
+	if nav.mode != constants.ModeEditConnection {
+		t.Fatalf("expected mode %v, got %v", constants.ModeEditConnection, nav.mode)
+	}
+}
@@ -18,6 +18,7 @@ type Connections struct {
 	Profiles           []Profile         `toml:"profiles"`
 	Statuses           map[string]string // connection status by name
 	Errors             map[string]string // last connection error message
+	Tunnels            map[string]string // SSH tunnel status by name
 	Focused            bool              // Indicates if the broker manager is focused
 }
 
@@ -91,6 +92,10 @@ func (m *Connections) EditConnection(index int, p Profile) {
 				delete(m.Errors, oldName)
 				m.Errors[p.Name] = errMsg
 			}
+			if tunnel, ok := m.Tunnels[oldName]; ok {
+				delete(m.Tunnels, oldName)
+				m.Tunnels[p.Name] = tunnel
+			}
 			if m.DefaultProfileName == oldName {
 				m.DefaultProfileName = p.Name
 			}
@@ -117,6 +122,7 @@ func (m *Connections) DeleteConnection(index int) {
 		}
 		delete(m.Statuses, name)
 		delete(m.Errors, name)
+		delete(m.Tunnels, name)
 		// Persist removal so the connection no longer appears after a restart
 		if err := saveConfig(m.Profiles, m.DefaultProfileName); err != nil {
 			log.Printf("Failed to save config after deleting %s: %v", name, err)
@@ -124,6 +130,14 @@ func (m *Connections) DeleteConnection(index int) {
 		if err := deletePasswordFromKeyring(p.Name, p.Username); err != nil {
 			log.Printf("Failed to delete password for %s/%s: %v", p.Name, p.Username, err)
 		}
+		for _, s := range p.secrets() {
+			if *s.value == "" {
+				continue
+			}
+			if err := deletePasswordFromKeyring(p.Name, s.account); err != nil {
+				log.Printf("Failed to delete %s secret for %s: %v", s.account, p.Name, err)
+			}
+		}
 		if err := deleteProfileData(name); err != nil {
 			log.Printf("Failed to remove data for profile %s: %v", name, err)
 		}
@@ -161,7 +175,7 @@ func (m *Connections) refreshList() {
 		if p.Name == m.DefaultProfileName {
 			title += " *"
 		}
-		items = append(items, connectionItem{title: title, status: status, detail: detail})
+		items = append(items, connectionItem{title: title, status: status, detail: detail, tunnel: m.Tunnels[p.Name]})
 	}
 	m.ConnectionsList.SetItems(items)
 }
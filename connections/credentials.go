@@ -0,0 +1,234 @@
+package connections
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// credentialTimeout bounds a single run of a password command.
+const credentialTimeout = 30 * time.Second
+
+// credentialRefreshMargin renews credentials this long before they expire
+// so a connect does not race the expiry.
+const credentialRefreshMargin = 30 * time.Second
+
+// Credential is a username and password for one or more connects.
+type Credential struct {
+	// Username is empty to keep the profile username.
+	Username string
+	Password string
+	// Expires is zero when the credential does not expire.
+	Expires time.Time
+}
+
+// Credentials produces dynamic broker credentials, from a password command
+// or a cloud preset, and caches them until they expire.
+type Credentials struct {
+	fetch func(ctx context.Context, now time.Time) (Credential, error)
+	cache time.Duration
+
+	mu      sync.Mutex
+	cur     Credential
+	fetched bool
+	validTo time.Time
+	now     func() time.Time
+}
+
+// commandCredentials identifies a shared password command.
+type commandCredentials struct {
+	command string
+	cache   time.Duration
+	creds   *Credentials
+}
+
+var (
+	credentialMu       sync.Mutex
+	credentialCommands = map[string]commandCredentials{}
+)
+
+// CredentialsFor returns the dynamic credentials of p, or nil if it uses a
+// static password. Password commands are shared per profile so cached
+// output survives a disconnect.
+func CredentialsFor(p Profile) *Credentials {
+	switch {
+	case strings.TrimSpace(p.PasswordCommand) != "":
+	case p.Preset == PresetAzureIoTHub:
+		return newCredentials(func(_ context.Context, now time.Time) (Credential, error) {
+			return azureSASCredential(p, now)
+		}, 0)
+	case p.Preset == PresetJWT:
+		return newCredentials(func(_ context.Context, now time.Time) (Credential, error) {
+			return jwtCredential(p, now)
+		}, 0)
+	default:
+		return nil
+	}
+	cache := time.Duration(p.PasswordCommandCache) * time.Second
+	credentialMu.Lock()
+	defer credentialMu.Unlock()
+	if c, ok := credentialCommands[p.Name]; ok && c.command == p.PasswordCommand && c.cache == cache {
+		return c.creds
+	}
+	name, command := p.Name, p.PasswordCommand
+	creds := newCredentials(func(ctx context.Context, _ time.Time) (Credential, error) {
+		return runPasswordCommand(ctx, name, command)
+	}, cache)
+	credentialCommands[p.Name] = commandCredentials{command: command, cache: cache, creds: creds}
+	return creds
+}
+
+func newCredentials(fetch func(context.Context, time.Time) (Credential, error), cache time.Duration) *Credentials {
+	return &Credentials{fetch: fetch, cache: cache, now: time.Now}
+}
+
+// Resolve returns the cached credential while it is valid and fetches a new
+// one otherwise. Credentials with an expiry are cached until shortly before
+// they expire; others are cached for the profile's password_command_cache
+// seconds, and not at all by default.
+func (c *Credentials) Resolve(ctx context.Context) (Credential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if c.fetched && now.Before(c.validTo) {
+		return c.cur, nil
+	}
+	cred, err := c.fetch(ctx, now)
+	if err != nil {
+		return Credential{}, err
+	}
+	c.cur, c.fetched = cred, true
+	switch {
+	case !cred.Expires.IsZero():
+		c.validTo = cred.Expires.Add(-credentialRefreshMargin)
+	default:
+		c.validTo = now.Add(c.cache)
+	}
+	return cred, nil
+}
+
+// Current returns the last credential without fetching a new one.
+func (c *Credentials) Current() Credential {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cur
+}
+
+// Provider returns a function suitable for mqtt.ClientOptions'
+// CredentialsProvider. It refreshes the credential when it expired and
+// falls back to username and the last credential when that fails.
+func (c *Credentials) Provider(username string) func() (string, string) {
+	return func() (string, string) {
+		cred, err := c.Resolve(context.Background())
+		if err != nil {
+			cred = c.Current()
+		}
+		if cred.Username != "" {
+			username = cred.Username
+		}
+		return username, cred.Password
+	}
+}
+
+// runPasswordCommand executes command through the shell and parses its
+// stdout.
+func runPasswordCommand(ctx context.Context, profile, command string) (Credential, error) {
+	ctx, cancel := context.WithTimeout(ctx, credentialTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), "EMQUTITI_PROFILE="+profile)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return Credential{}, fmt.Errorf("password command: %w: %s", err, msg)
+		}
+		return Credential{}, fmt.Errorf("password command: %w", err)
+	}
+	cred, err := parseCredential(stdout.String())
+	if err != nil {
+		return Credential{}, fmt.Errorf("password command: %w", err)
+	}
+	return cred, nil
+}
+
+// parseCredential reads password command output. It is either the password
+// itself or a JSON object with "password" (or "token"), optional
+// "username" and an expiry as "expires_at" (RFC 3339 or Unix seconds) or
+// "expires_in" (seconds). A plain JWT password expires with its "exp" claim.
+func parseCredential(out string) (Credential, error) {
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return Credential{}, errors.New("no output")
+	}
+	if !strings.HasPrefix(out, "{") {
+		return Credential{Password: out, Expires: jwtExpiry(out)}, nil
+	}
+	var v struct {
+		Username  string          `json:"username"`
+		Password  string          `json:"password"`
+		Token     string          `json:"token"`
+		ExpiresAt json.RawMessage `json:"expires_at"`
+		ExpiresIn float64         `json:"expires_in"`
+	}
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		return Credential{}, fmt.Errorf("parse output: %w", err)
+	}
+	cred := Credential{Username: v.Username, Password: v.Password}
+	if cred.Password == "" {
+		cred.Password = v.Token
+	}
+	if cred.Password == "" {
+		return Credential{}, errors.New(`output has no "password" or "token"`)
+	}
+	switch {
+	case len(v.ExpiresAt) > 0 && string(v.ExpiresAt) != "null":
+		var unix float64
+		var ts time.Time
+		if err := json.Unmarshal(v.ExpiresAt, &unix); err == nil {
+			cred.Expires = time.Unix(int64(unix), 0)
+		} else if err := json.Unmarshal(v.ExpiresAt, &ts); err == nil {
+			cred.Expires = ts
+		} else {
+			return Credential{}, fmt.Errorf("invalid expires_at %s", v.ExpiresAt)
+		}
+	case v.ExpiresIn > 0:
+		cred.Expires = time.Now().Add(time.Duration(v.ExpiresIn * float64(time.Second)))
+	default:
+		cred.Expires = jwtExpiry(cred.Password)
+	}
+	return cred, nil
+}
+
+// jwtExpiry returns the "exp" claim of a JWT, or zero if s is not one.
+func jwtExpiry(s string) time.Time {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if json.Unmarshal(data, &claims) != nil || claims.Exp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(claims.Exp), 0)
+}
@@ -0,0 +1,114 @@
+package connections
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCredential(t *testing.T) {
+	exp := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"dev","exp":1893553445}`))
+	jwt := "eyJhbGciOiJub25lIn0." + claims + ".sig"
+	cases := map[string]Credential{
+		"hunter2\n": {Password: "hunter2"},
+		jwt + "\n":  {Password: jwt, Expires: exp},
+		`{"username":"svc","password":"pw","expires_at":"2030-01-02T03:04:05Z"}`: {Username: "svc", Password: "pw", Expires: exp},
+		`{"token":"tok","expires_at":1893553445}`:                                {Password: "tok", Expires: exp},
+		`{"password":"` + jwt + `"}`:                                             {Password: jwt, Expires: exp},
+	}
+	for out, want := range cases {
+		got, err := parseCredential(out)
+		if err != nil {
+			t.Fatalf("parseCredential(%q): %v", out, err)
+		}
+		if got.Username != want.Username || got.Password != want.Password || !got.Expires.Equal(want.Expires) {
+			t.Fatalf("parseCredential(%q) = %+v, want %+v", out, got, want)
+		}
+	}
+	got, err := parseCredential(`{"password":"pw","expires_in":60}`)
+	if err != nil || time.Until(got.Expires) < 50*time.Second {
+		t.Fatalf("expires_in not applied: %+v, %v", got, err)
+	}
+	for _, out := range []string{"", "  \n", `{"username":"svc"}`, `{"password":"pw","expires_at":"soon"}`, `{oops`} {
+		if _, err := parseCredential(out); err == nil {
+			t.Fatalf("expected error for %q", out)
+		}
+	}
+}
+
+func TestCredentialCommandCaching(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "runs")
+	// Each run appends a line and prints the run count.
+	cmd := "echo x >> " + counter + "; wc -l < " + counter
+
+	now := time.Now()
+	c := CredentialsFor(Profile{Name: "cache", PasswordCommand: cmd, PasswordCommandCache: 60})
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	first, err := c.Resolve(ctx)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if second, _ := c.Resolve(ctx); strings.TrimSpace(second.Password) != strings.TrimSpace(first.Password) {
+		t.Fatalf("cached credential not reused: %q then %q", first.Password, second.Password)
+	}
+	now = now.Add(61 * time.Second)
+	if third, _ := c.Resolve(ctx); third.Password == first.Password {
+		t.Fatalf("expected a fresh credential after the cache expired")
+	}
+	if again := CredentialsFor(Profile{Name: "cache", PasswordCommand: cmd, PasswordCommandCache: 60}); again != c {
+		t.Fatalf("expected the command to be shared per profile")
+	}
+	if CredentialsFor(Profile{Name: "none"}) != nil {
+		t.Fatalf("expected no command without password_command")
+	}
+
+	// Without caching the command runs on every connect.
+	c = CredentialsFor(Profile{Name: "cache", PasswordCommand: cmd})
+	a, _ := c.Resolve(ctx)
+	b, _ := c.Resolve(ctx)
+	if a.Password == b.Password {
+		t.Fatalf("expected the command to run again, got %q twice", a.Password)
+	}
+	data, _ := os.ReadFile(counter)
+	if n := strings.Count(string(data), "\n"); n != 4 {
+		t.Fatalf("command ran %d times, want 4", n)
+	}
+}
+
+func TestCredentialCommandExpiry(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "runs")
+	exp := time.Now().Add(5 * time.Minute).Unix()
+	cmd := fmt.Sprintf(`echo x >> %s; printf '{"username":"svc","password":"%%s-%%s","expires_at":%d}' "$EMQUTITI_PROFILE" $(wc -l < %s)`, counter, exp, counter)
+	now := time.Now()
+	c := CredentialsFor(Profile{Name: "expiry", PasswordCommand: cmd})
+	c.now = func() time.Time { return now }
+	provider := c.Provider("profile-user")
+	if user, pw := provider(); user != "svc" || pw != "expiry-1" {
+		t.Fatalf("provider() = %q, %q", user, pw)
+	}
+	if _, pw := provider(); pw != "expiry-1" {
+		t.Fatalf("expected the credential to be cached until it expires, got %q", pw)
+	}
+	now = time.Unix(exp, 0).Add(-10 * time.Second)
+	if _, pw := provider(); pw != "expiry-2" {
+		t.Fatalf("expected a refresh shortly before expiry, got %q", pw)
+	}
+}
+
+func TestCredentialCommandFailure(t *testing.T) {
+	c := CredentialsFor(Profile{Name: "fail", PasswordCommand: "echo vault sealed >&2; exit 3"})
+	_, err := c.Resolve(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "vault sealed") {
+		t.Fatalf("expected stderr in error, got %v", err)
+	}
+	if user, pw := c.Provider("fallback")(); user != "fallback" || pw != "" {
+		t.Fatalf("provider() = %q, %q after failure", user, pw)
+	}
+}
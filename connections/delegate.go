@@ -39,10 +39,13 @@ func (d connectionDelegate) Render(w io.Writer, m list.Model, index int, item li
 		color = ui.ColGreen
 	case "disconnected":
 		color = ui.ColWarn
-	case "connecting":
+	case "connecting", "reconnecting":
 		color = ui.ColCyan
 	}
 	status := lipgloss.NewStyle().Foreground(color).Render(ci.status)
+	if ci.tunnel != "" {
+		status += lipgloss.NewStyle().Foreground(ui.ColGray).Render("  ssh " + ci.tunnel)
+	}
 	status = ansi.Truncate(status, width-2, "")
 	status = lipgloss.PlaceHorizontal(width-2, lipgloss.Left, status)
 	detail := lipgloss.NewStyle().Foreground(ui.ColGray).Render(ci.detail)
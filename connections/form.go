@@ -21,6 +21,9 @@ type Form struct {
 	Index    int   // -1 for new
 	fromEnv  bool  // current state of env loading
 	rowIndex []int // maps rendered rows to field indices; -1 for non-field rows
+
+	// preset is the selected preset, to notice when it changes.
+	preset string
 }
 
 type fieldType int
@@ -41,31 +44,70 @@ type fieldDef struct {
 var formFields = []fieldDef{
 	{key: "FromEnv", label: "Load from env", placeholder: "Values from env", fieldType: ftBool},
 	{key: "Name", label: "Name", placeholder: "Name", fieldType: ftText},
+	{key: "Preset", label: "Preset", placeholder: "Preset", fieldType: ftSelect, options: []string{PresetNone, PresetAWSIoT, PresetAzureIoTHub, PresetJWT, PresetSandbox}},
 	{key: "Schema", label: "Schema", placeholder: "Schema", fieldType: ftSelect, options: []string{"tcp", "ssl", "ws", "wss", "mqtt", "mqtts"}},
 	{key: "Host", label: "Host", placeholder: "Host", fieldType: ftText},
 	{key: "Port", label: "Port", placeholder: "Port", fieldType: ftText},
+	{key: "Brokers", label: "More Brokers", placeholder: "host2:1883, tcp://host3:1883", fieldType: ftText},
+	{key: "BrokerStrategy", label: "Broker Strategy", placeholder: "Broker Strategy", fieldType: ftSelect, options: []string{StrategyFailover, StrategyRoundRobin}},
+	{key: "WSPath", label: "WebSocket Path", placeholder: "/mqtt", fieldType: ftText},
+	{key: "WSHeaders", label: "WebSocket Headers", placeholder: "Authorization: Bearer …, Origin: https://…", fieldType: ftText},
+	{key: "WSProxy", label: "WebSocket Proxy", placeholder: "http://proxy:3128 or socks5://proxy:1080", fieldType: ftText},
+	{key: "SSHHost", label: "SSH Host", placeholder: "bastion.example.com:22", fieldType: ftText},
+	{key: "SSHUser", label: "SSH User", placeholder: "SSH User", fieldType: ftText},
+	{key: "SSHKeyPath", label: "SSH Key Path", placeholder: "~/.ssh/id_ed25519", fieldType: ftText},
+	{key: "SSHPassword", label: "SSH Passphrase/Password", placeholder: "Key passphrase or password", fieldType: ftPassword},
+	{key: "SSHKnownHosts", label: "SSH Known Hosts", placeholder: "~/.ssh/known_hosts", fieldType: ftText},
+	{key: "SSHSkipHostKeyCheck", label: "Skip SSH Host Key Check", placeholder: "Skip SSH Host Key Check", fieldType: ftBool},
 	{key: "ClientID", label: "Client ID", placeholder: "Client ID", fieldType: ftText},
 	{key: "RandomIDSuffix", label: "Random ID suffix", placeholder: "Random ID suffix", fieldType: ftBool},
 	{key: "Username", label: "Username", placeholder: "Username", fieldType: ftText},
 	{key: "Password", label: "Password", fieldType: ftPassword},
+	{key: "PasswordCommand", label: "Password Command", placeholder: "vault read -field=password secret/mqtt", fieldType: ftText},
+	{key: "PasswordCommandCache", label: "Password Command Cache (s)", placeholder: "0", fieldType: ftText},
+	{key: "AzureDeviceKey", label: "Azure Device Key", placeholder: "Base64 device key", fieldType: ftPassword},
+	{key: "JWTKeyPath", label: "JWT Key Path", placeholder: "jwt_private.pem", fieldType: ftText},
+	{key: "JWTAlgorithm", label: "JWT Algorithm", placeholder: "JWT Algorithm", fieldType: ftSelect, options: []string{"RS256", "ES256", "EdDSA"}},
+	{key: "JWTClaims", label: "JWT Claims", placeholder: "aud=my-project, sub={client_id}", fieldType: ftText},
+	{key: "TokenTTL", label: "Token TTL (s)", placeholder: "3600", fieldType: ftText},
 	{key: "SSL", label: "SSL/TLS", placeholder: "SSL/TLS", fieldType: ftBool},
 	{key: "SkipTLSVerify", label: "Skip TLS verify", placeholder: "Skip TLS verify", fieldType: ftBool},
 	{key: "CACertPath", label: "CA Cert Path", placeholder: "CA Cert Path", fieldType: ftText},
 	{key: "ClientCertPath", label: "Client Cert Path", placeholder: "Client Cert Path", fieldType: ftText},
 	{key: "ClientKeyPath", label: "Client Key Path", placeholder: "Client Key Path", fieldType: ftText},
+	{key: "ClientKeyPassphrase", label: "Client Key Passphrase", placeholder: "Client Key Passphrase", fieldType: ftPassword},
+	{key: "PKCS12Path", label: "PKCS#12 Bundle", placeholder: "client.p12", fieldType: ftText},
+	{key: "TLSServerName", label: "TLS Server Name", placeholder: "SNI override", fieldType: ftText},
+	{key: "TLSALPN", label: "TLS ALPN", placeholder: "x-amzn-mqtt-ca", fieldType: ftText},
+	{key: "TLSMinVersion", label: "TLS Min Version", placeholder: "1.2", fieldType: ftText},
+	{key: "TLSCipherSuites", label: "TLS Cipher Suites", placeholder: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, …", fieldType: ftText},
+	{key: "TLSSystemRoots", label: "Also Trust System CAs", placeholder: "Also Trust System CAs", fieldType: ftBool},
 	{key: "MQTTVersion", label: "MQTT Version", placeholder: "MQTT Version", fieldType: ftSelect, options: []string{"3", "4", "5"}},
 	{key: "ConnectTimeout", label: "Connect Timeout (s)", placeholder: "Connect Timeout (s)", fieldType: ftText},
 	{key: "KeepAlive", label: "Keep Alive (s)", placeholder: "Keep Alive (s)", fieldType: ftText},
 	{key: "QoS", label: "QoS", placeholder: "QoS", fieldType: ftSelect, options: []string{"0", "1", "2"}},
 	{key: "AutoReconnect", label: "Auto Reconnect", placeholder: "Auto Reconnect", fieldType: ftBool},
 	{key: "ReconnectPeriod", label: "Reconnect Period (s)", placeholder: "Reconnect Period (s)", fieldType: ftText},
+	{key: "ReconnectMaxInterval", label: "Reconnect Max Interval (s)", placeholder: "60", fieldType: ftText},
+	{key: "MessageBuffer", label: "Message Buffer", placeholder: "20", fieldType: ftText},
+	{key: "SpillToDisk", label: "Spill Overflow To Disk", placeholder: "Spill Overflow To Disk", fieldType: ftBool},
+	{key: "HistoryMaxAge", label: "History Max Age", placeholder: "30d", fieldType: ftText},
+	{key: "HistoryMaxMessages", label: "History Max Messages", placeholder: "100000", fieldType: ftText},
+	{key: "HistoryMaxSizeMB", label: "History Max Size (MB)", placeholder: "500", fieldType: ftText},
+	{key: "TraceMaxAge", label: "Trace Max Age", placeholder: "7d", fieldType: ftText},
+	{key: "TraceMaxMessages", label: "Trace Max Messages", placeholder: "100000", fieldType: ftText},
+	{key: "TraceMaxSizeMB", label: "Trace Max Size (MB)", placeholder: "100", fieldType: ftText},
 	{key: "CleanStart", label: "Clean Start", placeholder: "Clean Start", fieldType: ftBool},
+	{key: "PersistInflight", label: "Persist In-flight Messages", placeholder: "Persist In-flight Messages", fieldType: ftBool},
 	{key: "SessionExpiry", label: "Session Expiry (s)", placeholder: "Session Expiry (s)", fieldType: ftText},
 	{key: "ReceiveMaximum", label: "Receive Maximum", placeholder: "Receive Maximum", fieldType: ftText},
 	{key: "MaximumPacketSize", label: "Maximum Packet Size", placeholder: "Maximum Packet Size", fieldType: ftText},
 	{key: "TopicAliasMaximum", label: "Topic Alias Maximum", placeholder: "Topic Alias Maximum", fieldType: ftText},
 	{key: "RequestResponseInfo", label: "Request Response Info", placeholder: "Request Response Info", fieldType: ftBool},
 	{key: "RequestProblemInfo", label: "Request Problem Info", placeholder: "Request Problem Info", fieldType: ftBool},
+	{key: "ReplyTopic", label: "Reply Topic (3.1.1)", placeholder: "{topic}/reply", fieldType: ftText},
+	{key: "CorrelationField", label: "Correlation Field (3.1.1)", placeholder: "correlation_id", fieldType: ftText},
+	{key: "RequestTimeout", label: "Request Timeout (s)", placeholder: "30", fieldType: ftText},
 	{key: "LastWillEnabled", label: "Use Last Will", placeholder: "Use Last Will", fieldType: ftBool},
 	{key: "LastWillTopic", label: "Last Will Topic", placeholder: "Last Will Topic", fieldType: ftText},
 	{key: "LastWillQos", label: "Last Will QoS", placeholder: "Last Will QoS", fieldType: ftSelect, options: []string{"0", "1", "2"}},
@@ -105,6 +147,12 @@ func NewForm(p Profile, idx int) Form {
 	if p.Name != "" && p.Username != "" {
 		pwKey = fmt.Sprintf("keyring:emqutiti-%s/%s", p.Name, p.Username)
 	}
+	secretRefs := map[string]string{}
+	for _, s := range p.secrets() {
+		if p.Name != "" && *s.value != "" {
+			secretRefs[s.field] = p.keyringRef(s.account)
+		}
+	}
 	rv := reflect.ValueOf(p)
 	fields := make([]ui.Field, len(formFields))
 	for i, fd := range formFields {
@@ -112,6 +160,9 @@ func NewForm(p Profile, idx int) Form {
 		if fd.key == "Password" && pwKey != "" {
 			placeholder = pwKey
 		}
+		if ref, ok := secretRefs[fd.key]; ok {
+			placeholder = ref
+		}
 		fv := rv.FieldByName(fd.key)
 		var strVal string
 		var boolVal bool
@@ -123,6 +174,8 @@ func NewForm(p Profile, idx int) Form {
 		case reflect.Bool:
 			boolVal = fv.Bool()
 			strVal = fmt.Sprintf("%v", boolVal)
+		case reflect.Slice:
+			strVal = strings.Join(fv.Interface().([]string), ", ")
 		}
 		switch fd.fieldType {
 		case ftBool:
@@ -149,7 +202,7 @@ func NewForm(p Profile, idx int) Form {
 			fld.SetReadOnly(true)
 		}
 	}
-	cf := Form{Form: ui.Form{Fields: fields, Focus: 0}, Index: idx, fromEnv: p.FromEnv}
+	cf := Form{Form: ui.Form{Fields: fields, Focus: 0}, Index: idx, fromEnv: p.FromEnv, preset: fields[fieldIndex["Preset"]].Value()}
 	cf.ApplyFocus()
 	return cf
 }
@@ -194,6 +247,17 @@ func (f Form) Update(msg tea.Msg) (Form, tea.Cmd) {
 			f.fromEnv = chk.Bool()
 		}
 	}
+	idxPreset := fieldIndex["Preset"]
+	if preset := f.Fields[idxPreset].Value(); preset != f.preset {
+		// Fill the settings the preset needs; errors in other fields are
+		// left for saving to report.
+		p, _ := f.Profile()
+		ApplyPreset(&p, preset)
+		focus := f.Focus
+		f = NewForm(p, f.Index)
+		f.Focus = focus
+		f.ApplyFocus()
+	}
 	return f, tea.Batch(cmds...)
 }
 
@@ -296,8 +360,22 @@ func (f Form) Profile() (Profile, error) {
 				continue
 			}
 			field.SetBool(bv)
+		case reflect.Slice:
+			field.Set(reflect.ValueOf(SplitList(val)))
 		}
 	}
+	if p.Preset == PresetNone {
+		p.Preset = ""
+	}
+	if _, err := ParseAge(p.HistoryMaxAge); err != nil {
+		errs = append(errs, fmt.Sprintf("History Max Age: %v", err))
+	}
+	if _, err := ParseAge(p.TraceMaxAge); err != nil {
+		errs = append(errs, fmt.Sprintf("Trace Max Age: %v", err))
+	}
+	if err := p.CheckInflight(); err != nil {
+		errs = append(errs, fmt.Sprintf("Session Expiry (s): %v", err))
+	}
 	if len(errs) > 0 {
 		return p, errors.New(strings.Join(errs, "; "))
 	}
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"testing"
 
+	tea "github.com/charmbracelet/bubbletea"
+
 	"github.com/marang/emqutiti/ui"
 )
 
@@ -85,6 +87,21 @@ func TestConnectionFormProfile(t *testing.T) {
 	}
 }
 
+func TestConnectionFormBrokers(t *testing.T) {
+	cf := NewForm(Profile{Brokers: []string{"b:1883", "c:1883"}, BrokerStrategy: StrategyRoundRobin}, 0)
+	if v := cf.Fields[fieldIndex["Brokers"]].Value(); v != "b:1883, c:1883" {
+		t.Fatalf("unexpected brokers field %q", v)
+	}
+	cf.Fields[fieldIndex["Brokers"]].(*ui.TextField).SetValue("x:1, ,y:2")
+	p, err := cf.Profile()
+	if err != nil {
+		t.Fatalf("Profile error: %v", err)
+	}
+	if len(p.Brokers) != 2 || p.Brokers[0] != "x:1" || p.Brokers[1] != "y:2" || p.BrokerStrategy != StrategyRoundRobin {
+		t.Fatalf("unexpected profile: %#v", p)
+	}
+}
+
 func TestConnectionFormProfileInvalidInt(t *testing.T) {
 	cf := NewForm(Profile{}, -1)
 	cf.Fields[fieldIndex["Port"]].(*ui.TextField).SetValue("abc")
@@ -97,6 +114,21 @@ func TestConnectionFormProfileInvalidInt(t *testing.T) {
 	}
 }
 
+func TestConnectionFormRejectsInflightWithoutSessionExpiry(t *testing.T) {
+	cf := NewForm(Profile{MQTTVersion: "5", PersistInflight: true}, -1)
+	if _, err := cf.Profile(); err == nil || !strings.Contains(err.Error(), "Session Expiry") {
+		t.Fatalf("expected session expiry error, got %v", err)
+	}
+	cf.Fields[fieldIndex["SessionExpiry"]].(*ui.TextField).SetValue("3600")
+	if _, err := cf.Profile(); err != nil {
+		t.Fatalf("Profile error: %v", err)
+	}
+	cf = NewForm(Profile{MQTTVersion: "4", PersistInflight: true}, -1)
+	if _, err := cf.Profile(); err != nil {
+		t.Fatalf("MQTT 3.1.1 keeps sessions without an expiry: %v", err)
+	}
+}
+
 func TestConnectionFormSchemaOptions(t *testing.T) {
 	cf := NewForm(Profile{Schema: "mqtt"}, -1)
 	sf := cf.Fields[fieldIndex["Schema"]].(*ui.SelectField)
@@ -109,3 +141,35 @@ func TestConnectionFormSchemaOptions(t *testing.T) {
 		t.Fatalf("expected schema mqtts, got %s", sf.Value())
 	}
 }
+
+func TestConnectionFormPresetFillsConnection(t *testing.T) {
+	cf := NewForm(Profile{Name: "hub", Host: "myhub.azure-devices.net", Port: 1883}, -1)
+	cf.Focus = fieldIndex["Preset"]
+	cf.ApplyFocus()
+	// none -> aws_iot -> azure_iot_hub
+	cf, _ = cf.Update(tea.KeyMsg{Type: tea.KeyDown})
+	cf, _ = cf.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if cf.Focus != fieldIndex["Preset"] {
+		t.Fatalf("focus moved off the preset selector: %d", cf.Focus)
+	}
+	p, err := cf.Profile()
+	if err != nil {
+		t.Fatalf("profile: %v", err)
+	}
+	if p.Preset != PresetAzureIoTHub || p.Schema != "ssl" || p.Port != 8883 || !p.SSL || p.MQTTVersion != "4" || p.TokenTTL != 3600 {
+		t.Fatalf("azure preset not applied: %+v", p)
+	}
+	if p.Host != "myhub.azure-devices.net" {
+		t.Fatalf("preset changed the host: %q", p.Host)
+	}
+
+	cf, _ = cf.Update(tea.KeyMsg{Type: tea.KeyUp})
+	p, _ = cf.Profile()
+	if p.Preset != PresetAWSIoT || p.Port != 443 || strings.Join(p.TLSALPN, ",") != "x-amzn-mqtt-ca" {
+		t.Fatalf("aws preset not applied: %+v", p)
+	}
+	cf, _ = cf.Update(tea.KeyMsg{Type: tea.KeyUp})
+	if p, _ = cf.Profile(); p.Preset != "" {
+		t.Fatalf("expected no preset to be stored, got %q", p.Preset)
+	}
+}
@@ -0,0 +1,62 @@
+package connections
+
+// Connection presets.
+const (
+	PresetNone        = "none"
+	PresetAWSIoT      = "aws_iot"
+	PresetAzureIoTHub = "azure_iot_hub"
+	PresetJWT         = "jwt"
+	// PresetSandbox connects to the embedded broker, which is started on
+	// host:port when needed.
+	PresetSandbox = "sandbox"
+)
+
+// defaultTokenTTL is the lifetime of generated tokens without token_ttl.
+const defaultTokenTTL = 3600
+
+// ApplyPreset sets p.Preset and fills the connection settings the preset
+// requires. Fields the preset does not cover are left alone.
+func ApplyPreset(p *Profile, preset string) {
+	if preset == PresetNone {
+		preset = ""
+	}
+	p.Preset = preset
+	switch preset {
+	case PresetAWSIoT:
+		// Port 443 needs ALPN; AWS also accepts 8883 without it.
+		p.Schema, p.Port, p.SSL = "ssl", 443, true
+		p.TLSALPN = []string{"x-amzn-mqtt-ca"}
+		p.TLSMinVersion = "1.2"
+		p.TLSSystemRoots = true
+	case PresetAzureIoTHub:
+		// IoT Hub speaks MQTT 3.1.1 and expects the device ID as client ID.
+		p.Schema, p.Port, p.SSL = "ssl", 8883, true
+		p.MQTTVersion = "4"
+		p.TLSMinVersion = "1.2"
+		p.TLSSystemRoots = true
+		p.RandomIDSuffix = false
+		if p.TokenTTL == 0 {
+			p.TokenTTL = defaultTokenTTL
+		}
+	case PresetSandbox:
+		p.Schema, p.Host, p.Port, p.SSL = "tcp", "127.0.0.1", 1883, false
+		p.Brokers, p.SSHHost = nil, ""
+	case PresetJWT:
+		p.Schema, p.Port, p.SSL = "ssl", 8883, true
+		p.TLSMinVersion = "1.2"
+		p.TLSSystemRoots = true
+		if p.JWTAlgorithm == "" {
+			p.JWTAlgorithm = "RS256"
+		}
+		if p.TokenTTL == 0 {
+			p.TokenTTL = defaultTokenTTL
+		}
+	}
+}
+
+// SandboxProfile returns a new profile for the embedded local broker.
+func SandboxProfile() Profile {
+	p := Profile{Name: "sandbox", ClientID: "emqutiti-sandbox", RandomIDSuffix: true, MQTTVersion: "5", CleanStart: true}
+	ApplyPreset(&p, PresetSandbox)
+	return p
+}
@@ -1,7 +1,12 @@
 package connections
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/zalando/go-keyring"
@@ -9,10 +14,23 @@ import (
 
 // Profile defines a broker connection.
 type Profile struct {
-	Name            string `toml:"name" env:"name"`
-	Schema          string `toml:"schema" env:"schema"`
-	Host            string `toml:"host" env:"host"`
-	Port            int    `toml:"port" env:"port"`
+	Name   string `toml:"name" env:"name"`
+	Schema string `toml:"schema" env:"schema"`
+	Host   string `toml:"host" env:"host"`
+	Port   int    `toml:"port" env:"port"`
+	// Brokers lists further endpoints tried after Host and Port, either as
+	// URLs or host:port using the profile schema and port.
+	Brokers []string `toml:"brokers" env:"brokers"`
+	// BrokerStrategy is "failover" to always prefer the first reachable
+	// endpoint or "round_robin" to move on to the next one on reconnect.
+	BrokerStrategy string `toml:"broker_strategy" env:"broker_strategy"`
+	// WSPath is the HTTP path of ws and wss brokers, e.g. "/mqtt".
+	WSPath string `toml:"ws_path" env:"ws_path"`
+	// WSHeaders are "Name: value" headers sent with the websocket upgrade.
+	WSHeaders []string `toml:"ws_headers" env:"ws_headers"`
+	// WSProxy is an http:// or https:// (CONNECT) or socks5:// proxy for ws
+	// and wss brokers.
+	WSProxy         string `toml:"ws_proxy" env:"ws_proxy"`
 	ClientID        string `toml:"client_id" env:"client_id"`
 	Username        string `toml:"username" env:"username"`
 	Password        string `toml:"password" env:"password"`
@@ -28,13 +46,33 @@ type Profile struct {
 	QoS             int    `toml:"qos" env:"qos"`
 	AutoReconnect   bool   `toml:"auto_reconnect" env:"auto_reconnect"`
 	ReconnectPeriod int    `toml:"reconnect_period" env:"reconnect_period"`
+	// ReconnectMaxInterval caps the reconnect backoff in seconds.
+	ReconnectMaxInterval int `toml:"reconnect_max_interval" env:"reconnect_max_interval"`
+	// MessageBuffer is the number of inbound messages buffered for the UI.
+	MessageBuffer int `toml:"message_buffer" env:"message_buffer"`
+	// SpillToDisk queues messages that overflow the buffer in the DB proxy
+	// instead of dropping them.
+	SpillToDisk bool `toml:"spill_to_disk" env:"spill_to_disk"`
+	// HistoryMaxAge, HistoryMaxMessages and HistoryMaxSizeMB limit the
+	// history the DB proxy keeps; the TraceMax settings limit each trace of
+	// the profile. The oldest messages are pruned first and zero keeps
+	// everything. Ages are durations such as "12h" or "30d".
+	HistoryMaxAge      string `toml:"history_max_age" env:"history_max_age"`
+	HistoryMaxMessages int    `toml:"history_max_messages" env:"history_max_messages"`
+	HistoryMaxSizeMB   int    `toml:"history_max_size_mb" env:"history_max_size_mb"`
+	TraceMaxAge        string `toml:"trace_max_age" env:"trace_max_age"`
+	TraceMaxMessages   int    `toml:"trace_max_messages" env:"trace_max_messages"`
+	TraceMaxSizeMB     int    `toml:"trace_max_size_mb" env:"trace_max_size_mb"`
 	// PublishTimeout is the time in seconds to wait for a publish token.
 	PublishTimeout int `toml:"publish_timeout" env:"publish_timeout"`
 	// SubscribeTimeout is the time in seconds to wait for a subscribe token.
 	SubscribeTimeout int `toml:"subscribe_timeout" env:"subscribe_timeout"`
 	// UnsubscribeTimeout is the time in seconds to wait for an unsubscribe token.
-	UnsubscribeTimeout  int    `toml:"unsubscribe_timeout" env:"unsubscribe_timeout"`
-	CleanStart          bool   `toml:"clean_start" env:"clean_start"`
+	UnsubscribeTimeout int  `toml:"unsubscribe_timeout" env:"unsubscribe_timeout"`
+	CleanStart         bool `toml:"clean_start" env:"clean_start"`
+	// PersistInflight keeps unacknowledged QoS 1/2 packets on disk so
+	// sessions without clean start survive a restart.
+	PersistInflight     bool   `toml:"persist_inflight" env:"persist_inflight"`
 	SessionExpiry       int    `toml:"session_expiry_interval" env:"session_expiry_interval"`
 	ReceiveMaximum      int    `toml:"receive_maximum" env:"receive_maximum"`
 	MaximumPacketSize   int    `toml:"maximum_packet_size" env:"maximum_packet_size"`
@@ -47,11 +85,173 @@ type Profile struct {
 	LastWillRetain      bool   `toml:"last_will_retain" env:"last_will_retain"`
 	LastWillPayload     string `toml:"last_will_payload" env:"last_will_payload"`
 	RandomIDSuffix      bool   `toml:"random_id_suffix" env:"random_id_suffix"`
+	// ReplyTopic is the reply topic template for requests on MQTT 3.1.1.
+	// {topic} and {id} expand to the request topic and correlation ID.
+	ReplyTopic string `toml:"reply_topic" env:"reply_topic"`
+	// CorrelationField names the JSON payload field carrying the
+	// correlation ID on MQTT 3.1.1.
+	CorrelationField string `toml:"correlation_field" env:"correlation_field"`
+	// RequestTimeout is the time in seconds to wait for a reply.
+	RequestTimeout int `toml:"request_timeout" env:"request_timeout"`
+	// SSHHost is host[:port] of an SSH server the broker is reached
+	// through. Broker hosts are then resolved on that server.
+	SSHHost string `toml:"ssh_host" env:"ssh_host"`
+	SSHUser string `toml:"ssh_user" env:"ssh_user"`
+	// SSHKeyPath is a private key file. SSHPassword is its passphrase or,
+	// without a key, the login password; it is kept in the keyring like
+	// Password.
+	SSHKeyPath  string `toml:"ssh_key_path" env:"ssh_key_path"`
+	SSHPassword string `toml:"ssh_password" env:"ssh_password"`
+	// SSHKnownHosts overrides ~/.ssh/known_hosts for host key checks.
+	SSHKnownHosts       string `toml:"ssh_known_hosts" env:"ssh_known_hosts"`
+	SSHSkipHostKeyCheck bool   `toml:"ssh_skip_host_key_check" env:"ssh_skip_host_key_check"`
+	// ClientKeyPassphrase decrypts ClientKeyPath or PKCS12Path and is kept
+	// in the keyring like Password.
+	ClientKeyPassphrase string `toml:"client_key_passphrase" env:"client_key_passphrase"`
+	// PKCS12Path is a .p12/.pfx client certificate bundle used instead of
+	// ClientCertPath and ClientKeyPath.
+	PKCS12Path string `toml:"pkcs12_path" env:"pkcs12_path"`
+	// TLSServerName overrides the name sent via SNI and checked against the
+	// broker certificate.
+	TLSServerName string `toml:"tls_server_name" env:"tls_server_name"`
+	// TLSALPN lists ALPN protocols, e.g. "x-amzn-mqtt-ca" for AWS IoT on 443.
+	TLSALPN []string `toml:"tls_alpn" env:"tls_alpn"`
+	// TLSMinVersion is "1.0", "1.1", "1.2" or "1.3".
+	TLSMinVersion string `toml:"tls_min_version" env:"tls_min_version"`
+	// TLSCipherSuites restricts the TLS 1.2 cipher suites by IANA name.
+	TLSCipherSuites []string `toml:"tls_cipher_suites" env:"tls_cipher_suites"`
+	// TLSSystemRoots trusts the system CAs in addition to CACertPath.
+	TLSSystemRoots bool `toml:"tls_system_roots" env:"tls_system_roots"`
+	// PasswordCommand is run through the shell on connect and its output
+	// used as the password, e.g. to fetch short-lived tokens. See
+	// parseCredential for the output formats.
+	PasswordCommand string `toml:"password_command" env:"password_command"`
+	// PasswordCommandCache reuses the command output for this many seconds
+	// when it carries no expiry.
+	PasswordCommandCache int `toml:"password_command_cache" env:"password_command_cache"`
+	// Preset is "sandbox" for the embedded broker or a cloud broker type:
+	// "aws_iot", "azure_iot_hub" or "jwt". Azure and JWT profiles generate
+	// their password on connect.
+	Preset string `toml:"preset" env:"preset"`
+	// AzureDeviceKey is the base64 device key SAS tokens are signed with.
+	// It is kept in the keyring like Password.
+	AzureDeviceKey string `toml:"azure_device_key" env:"azure_device_key"`
+	// JWTKeyPath is the PEM private key JWTs are signed with.
+	JWTKeyPath string `toml:"jwt_key_path" env:"jwt_key_path"`
+	// JWTAlgorithm is "RS256", "ES256" or "EdDSA".
+	JWTAlgorithm string `toml:"jwt_algorithm" env:"jwt_algorithm"`
+	// JWTClaims are "name=value" claims added to iat and exp. Values may
+	// use {client_id} and {username}.
+	JWTClaims []string `toml:"jwt_claims" env:"jwt_claims"`
+	// TokenTTL is the lifetime of generated tokens in seconds.
+	TokenTTL int `toml:"token_ttl" env:"token_ttl"`
+}
+
+// CheckInflight reports an error when persisted in-flight messages could
+// not survive a disconnect. An MQTT 5 broker discards the session, and
+// paho the stored packets with it, when the session expiry interval is
+// zero.
+func (p Profile) CheckInflight() error {
+	if p.PersistInflight && p.MQTTVersion == "5" && p.SessionExpiry <= 0 {
+		return errors.New("persisting in-flight messages on MQTT 5 needs a session expiry above 0")
+	}
+	return nil
+}
+
+// secret is a keyring-backed profile field besides Password.
+type secret struct {
+	field string
+	value *string
+	// account is the keyring user under the "emqutiti-<name>" service.
+	account string
+}
+
+// secrets lists the keyring-backed fields of p besides Password.
+func (p *Profile) secrets() []secret {
+	return []secret{
+		{"SSHPassword", &p.SSHPassword, "ssh:" + p.SSHUser},
+		{"ClientKeyPassphrase", &p.ClientKeyPassphrase, "tls:client-key"},
+		{"AzureDeviceKey", &p.AzureDeviceKey, "azure:device-key"},
+	}
+}
+
+// keyringRef returns the keyring reference of the secret stored for account.
+func (p Profile) keyringRef(account string) string {
+	return "keyring:emqutiti-" + p.Name + "/" + account
 }
 
 // BrokerURL returns the formatted broker URL.
 func (p Profile) BrokerURL() string {
-	return fmt.Sprintf("%s://%s:%d", p.Schema, p.Host, p.Port)
+	u := fmt.Sprintf("%s://%s:%d", p.Schema, p.Host, p.Port)
+	if p.websocket() && p.WSPath != "" {
+		u += "/" + strings.TrimPrefix(p.WSPath, "/")
+	}
+	return u
+}
+
+func (p Profile) websocket() bool { return p.Schema == "ws" || p.Schema == "wss" }
+
+// WSHeader parses WSHeaders into an http.Header.
+func (p Profile) WSHeader() (http.Header, error) {
+	h := http.Header{}
+	for _, kv := range p.WSHeaders {
+		name, value, ok := strings.Cut(kv, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid websocket header %q (want Name: value)", kv)
+		}
+		h.Add(name, strings.TrimSpace(value))
+	}
+	return h, nil
+}
+
+// Broker strategies for profiles with several endpoints.
+const (
+	StrategyFailover   = "failover"
+	StrategyRoundRobin = "round_robin"
+)
+
+// BrokerURLs returns the primary broker URL followed by the additional
+// endpoints in order. Endpoints without a scheme use the profile schema and
+// those without a port use the profile port.
+func (p Profile) BrokerURLs() []string {
+	urls := []string{p.BrokerURL()}
+	seen := map[string]bool{urls[0]: true}
+	for _, b := range p.Brokers {
+		b = strings.TrimSpace(b)
+		if b == "" {
+			continue
+		}
+		if !strings.Contains(b, "://") {
+			b = p.Schema + "://" + b
+		}
+		if u, err := url.Parse(b); err == nil {
+			if u.Port() == "" && p.Port != 0 {
+				u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(p.Port))
+			}
+			if (u.Scheme == "ws" || u.Scheme == "wss") && u.Path == "" && p.WSPath != "" {
+				u.Path = "/" + strings.TrimPrefix(p.WSPath, "/")
+			}
+			b = u.String()
+		}
+		if !seen[b] {
+			seen[b] = true
+			urls = append(urls, b)
+		}
+	}
+	return urls
+}
+
+// SplitList parses a comma separated list as used for list fields in the
+// form and environment variables.
+func SplitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
 }
 
 // RetrievePasswordFromKeyring resolves a keyring:<service>/<user> reference.
@@ -53,6 +53,16 @@ func LoadConfig(filePath string) (*Config, error) {
 			}
 			p.Password = pw
 		}
+		for _, s := range p.secrets() {
+			if !strings.HasPrefix(*s.value, "keyring:") {
+				continue
+			}
+			pw, err := RetrievePasswordFromKeyring(*s.value)
+			if err != nil {
+				return nil, err
+			}
+			*s.value = pw
+		}
 	}
 	return &cfg, nil
 }
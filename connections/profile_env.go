@@ -78,6 +78,8 @@ func ApplyEnvVars(p *Profile) {
 			if bv, err := strconv.ParseBool(val); err == nil {
 				field.SetBool(bv)
 			}
+		case reflect.Slice:
+			field.Set(reflect.ValueOf(SplitList(val)))
 		}
 	}
 }
@@ -20,6 +20,7 @@ func saveConfig(profiles []Profile, defaultName string) error {
 		Profiles:           profiles,
 		Saved:              saved,
 		ProxyAddr:          LoadProxyAddr(),
+		Proxy:              loadProxySettings(),
 	}
 	return writeConfig(cfg)
 }
@@ -71,6 +72,18 @@ func persistProfileChange(profiles *[]Profile, defaultName string, p Profile, id
 			p.Password = plain
 		}
 	}
+	// Further secrets go to the keyring under their own accounts.
+	stored := map[string]string{}
+	for _, s := range p.secrets() {
+		plain := *s.value
+		switch {
+		case p.FromEnv:
+			*s.value = ""
+		case strings.TrimSpace(plain) != "" && strings.TrimSpace(p.Name) != "":
+			stored[s.account] = plain
+			*s.value = p.keyringRef(s.account)
+		}
+	}
 	if idx >= 0 && idx < len(*profiles) {
 		(*profiles)[idx] = p
 	} else {
@@ -84,5 +97,10 @@ func persistProfileChange(profiles *[]Profile, defaultName string, p Profile, id
 			return err
 		}
 	}
+	for account, plain := range stored {
+		if err := savePasswordToKeyring(p.Name, account, plain); err != nil {
+			return err
+		}
+	}
 	return nil
 }
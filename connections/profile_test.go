@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/BurntSushi/toml"
@@ -22,6 +23,40 @@ func TestProfileBrokerURL(t *testing.T) {
 	}
 }
 
+func TestProfileBrokerURLs(t *testing.T) {
+	p := Profile{Schema: "tcp", Host: "a", Port: 1883, Brokers: []string{"b", " ws://c:8080/mqtt ", "a:1883", "", "d:1884"}}
+	want := []string{"tcp://a:1883", "tcp://b:1883", "ws://c:8080/mqtt", "tcp://d:1884"}
+	got := p.BrokerURLs()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("BrokerURLs() = %v, want %v", got, want)
+	}
+}
+
+func TestProfileWebsocketOptions(t *testing.T) {
+	p := Profile{Schema: "wss", Host: "a", Port: 443, WSPath: "mqtt", Brokers: []string{"wss://b:443", "wss://c:443/ws"}}
+	want := []string{"wss://a:443/mqtt", "wss://b:443/mqtt", "wss://c:443/ws"}
+	if got := p.BrokerURLs(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("BrokerURLs() = %v, want %v", got, want)
+	}
+	p.Schema = "tcp"
+	if got := p.BrokerURL(); got != "tcp://a:443" {
+		t.Fatalf("path must only apply to websockets, got %q", got)
+	}
+
+	p.WSHeaders = []string{"Authorization: Bearer x:y", " X-Tenant :acme"}
+	h, err := p.WSHeader()
+	if err != nil {
+		t.Fatalf("WSHeader: %v", err)
+	}
+	if h.Get("Authorization") != "Bearer x:y" || h.Get("X-Tenant") != "acme" {
+		t.Fatalf("unexpected headers %v", h)
+	}
+	p.WSHeaders = []string{"broken"}
+	if _, err := p.WSHeader(); err == nil {
+		t.Fatalf("expected error for header without colon")
+	}
+}
+
 // TestApplyEnvVars ensures environment variables override profile fields.
 func TestApplyEnvVars(t *testing.T) {
 	p := Profile{Name: "test", FromEnv: true}
@@ -29,7 +64,9 @@ func TestApplyEnvVars(t *testing.T) {
 	os.Setenv(prefix+"HOST", "example.com")
 	os.Setenv(prefix+"PORT", "1884")
 	os.Setenv(prefix+"SSL_TLS", "true")
+	os.Setenv(prefix+"BROKERS", "b:1883, c:1883")
 	t.Cleanup(func() {
+		os.Unsetenv(prefix + "BROKERS")
 		os.Unsetenv(prefix + "HOST")
 		os.Unsetenv(prefix + "PORT")
 		os.Unsetenv(prefix + "SSL_TLS")
@@ -46,6 +83,9 @@ func TestApplyEnvVars(t *testing.T) {
 	if !p.SSL {
 		t.Errorf("SSL not set: %v", p.SSL)
 	}
+	if len(p.Brokers) != 2 || p.Brokers[1] != "c:1883" {
+		t.Errorf("Brokers not set: %v", p.Brokers)
+	}
 }
 
 func TestLoadConfig(t *testing.T) {
@@ -209,6 +249,31 @@ func TestPersistProfileChange(t *testing.T) {
 	}
 }
 
+func TestPersistProfileChangeSecrets(t *testing.T) {
+	keyring.MockInit()
+	dir := t.TempDir()
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", oldHome)
+
+	profiles := []Profile{}
+	p := Profile{Name: "test", SSHHost: "bastion", SSHUser: "ops", SSHPassword: "phrase", ClientKeyPassphrase: "keypass"}
+	if err := persistProfileChange(&profiles, "test", p, -1); err != nil {
+		t.Fatalf("persistProfileChange: %v", err)
+	}
+	if profiles[0].SSHPassword != "keyring:emqutiti-test/ssh:ops" || profiles[0].ClientKeyPassphrase != "keyring:emqutiti-test/tls:client-key" {
+		t.Fatalf("secrets not rewritten: %q %q", profiles[0].SSHPassword, profiles[0].ClientKeyPassphrase)
+	}
+	cfgPath, _ := DefaultUserConfigFile()
+	cfg, err := LoadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Profiles[0].SSHPassword != "phrase" || cfg.Profiles[0].ClientKeyPassphrase != "keypass" {
+		t.Fatalf("secrets not resolved from keyring: %q %q", cfg.Profiles[0].SSHPassword, cfg.Profiles[0].ClientKeyPassphrase)
+	}
+}
+
 func TestPersistProfileChangeWithoutPassword(t *testing.T) {
 	keyring.MockInit()
 	dir := t.TempDir()
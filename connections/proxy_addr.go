@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -40,8 +41,72 @@ func SaveProxyAddr(addr string) error {
 	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
 		return err
 	}
+	return WriteConfigFile(fp, buf.Bytes())
+}
+
+// WriteConfigFile replaces the config file fp with data. A file holding
+// proxy settings is made readable by its owner only, as the token may be
+// stored in plain text.
+func WriteConfigFile(fp string, data []byte) error {
 	if err := os.MkdirAll(filepath.Dir(fp), os.ModePerm); err != nil {
 		return err
 	}
-	return os.WriteFile(fp, buf.Bytes(), 0644)
+	var cfg struct {
+		Proxy ProxySettings `toml:"proxy"`
+	}
+	perm := os.FileMode(0644)
+	if _, err := toml.Decode(string(data), &cfg); err != nil || cfg.Proxy != (ProxySettings{}) {
+		perm = 0600
+	}
+	if err := os.WriteFile(fp, data, perm); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file.
+	return os.Chmod(fp, perm)
+}
+
+// ProxySettings secure the connections to the DB proxy. They are kept in
+// the [proxy] table of config.toml and apply to the daemon and its clients
+// alike.
+type ProxySettings struct {
+	// Token is the shared secret, or a keyring:<service>/<user> reference.
+	Token string `toml:"token,omitempty"`
+	// TLSCert and TLSKey are the certificate presented to the peer and
+	// TLSCA the authority verifying the peer's.
+	TLSCert       string `toml:"tls_cert,omitempty"`
+	TLSKey        string `toml:"tls_key,omitempty"`
+	TLSCA         string `toml:"tls_ca,omitempty"`
+	TLSServerName string `toml:"tls_server_name,omitempty"`
+}
+
+// LoadProxySettings returns the proxy settings of config.toml with the
+// token resolved. EMQUTITI_PROXY_TOKEN overrides the stored token.
+func LoadProxySettings() (ProxySettings, error) {
+	s := loadProxySettings()
+	if tok := os.Getenv("EMQUTITI_PROXY_TOKEN"); tok != "" {
+		s.Token = tok
+	}
+	if strings.HasPrefix(s.Token, "keyring:") {
+		tok, err := RetrievePasswordFromKeyring(s.Token)
+		if err != nil {
+			return s, err
+		}
+		s.Token = tok
+	}
+	return s, nil
+}
+
+// loadProxySettings returns the proxy settings as stored.
+func loadProxySettings() ProxySettings {
+	fp, err := DefaultUserConfigFile()
+	if err != nil {
+		return ProxySettings{}
+	}
+	var cfg struct {
+		Proxy ProxySettings `toml:"proxy"`
+	}
+	if _, err := toml.DecodeFile(fp, &cfg); err != nil {
+		return ProxySettings{}
+	}
+	return cfg.Proxy
 }
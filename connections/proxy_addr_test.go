@@ -2,6 +2,7 @@ package connections
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -25,3 +26,36 @@ func TestSaveLoadProxyAddr(t *testing.T) {
 		t.Fatalf("proxy addr lost after SaveState: %q", got)
 	}
 }
+
+func TestProxySettingsSurviveConfigWrites(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	t.Setenv("EMQUTITI_PROXY_TOKEN", "")
+	fp, _ := DefaultUserConfigFile()
+	if err := SaveState(map[string]ConnectionSnapshot{}); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	if b, _ := os.ReadFile(fp); strings.Contains(string(b), "[proxy]") {
+		t.Fatalf("empty proxy settings written:\n%s", b)
+	}
+	cfg := "[proxy]\ntoken = \"s3cret\"\ntls_ca = \"/etc/ca.pem\"\n"
+	if err := os.WriteFile(fp, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveConfig([]Profile{{Name: "p"}}, "p"); err != nil {
+		t.Fatalf("saveConfig: %v", err)
+	}
+	if err := SaveProxyAddr("unix:/tmp/proxy.sock"); err != nil {
+		t.Fatalf("SaveProxyAddr: %v", err)
+	}
+	if fi, err := os.Stat(fp); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("config with proxy settings not private: %v %v", fi.Mode(), err)
+	}
+	want := ProxySettings{Token: "s3cret", TLSCA: "/etc/ca.pem"}
+	if got, err := LoadProxySettings(); err != nil || got != want {
+		t.Fatalf("LoadProxySettings = %+v, %v; want %+v", got, err, want)
+	}
+	t.Setenv("EMQUTITI_PROXY_TOKEN", "env")
+	if got, _ := LoadProxySettings(); got.Token != "env" {
+		t.Fatalf("token = %q, want the environment's", got.Token)
+	}
+}
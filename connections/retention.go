@@ -0,0 +1,88 @@
+package connections
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Retention limits the messages the DB proxy keeps for a history or a
+// trace. Zero values impose no limit.
+type Retention struct {
+	MaxAge      time.Duration
+	MaxMessages int
+	MaxBytes    int64
+}
+
+// IsZero reports whether r keeps everything.
+func (r Retention) IsZero() bool { return r == Retention{} }
+
+// ProfileRetention holds the limits of a profile's history and of each of
+// its traces.
+type ProfileRetention struct {
+	History, Trace Retention
+}
+
+// ParseAge parses a retention age: a duration such as "12h" or a number of
+// days such as "30d". Empty means no limit.
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// Retention returns the limits configured in p. Invalid ages are reported
+// and leave the age unlimited.
+func (p Profile) Retention() (ProfileRetention, error) {
+	histAge, herr := ParseAge(p.HistoryMaxAge)
+	traceAge, terr := ParseAge(p.TraceMaxAge)
+	return ProfileRetention{
+		History: Retention{MaxAge: histAge, MaxMessages: max(p.HistoryMaxMessages, 0), MaxBytes: int64(max(p.HistoryMaxSizeMB, 0)) << 20},
+		Trace:   Retention{MaxAge: traceAge, MaxMessages: max(p.TraceMaxMessages, 0), MaxBytes: int64(max(p.TraceMaxSizeMB, 0)) << 20},
+	}, errors.Join(herr, terr)
+}
+
+// LoadRetention returns the retention limits of the profiles in
+// config.toml by name. Unlike LoadConfig it leaves secrets in the keyring.
+func LoadRetention() (map[string]ProfileRetention, error) {
+	fp, err := DefaultUserConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	var cfg struct {
+		Profiles []Profile `toml:"profiles"`
+	}
+	if _, err := toml.DecodeFile(fp, &cfg); err != nil {
+		return nil, err
+	}
+	out := make(map[string]ProfileRetention, len(cfg.Profiles))
+	var errs []error
+	for _, p := range cfg.Profiles {
+		if p.FromEnv {
+			ApplyEnvVars(&p)
+		}
+		r, err := p.Retention()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("profile %s: %w", p.Name, err))
+		}
+		out[p.Name] = r
+	}
+	return out, errors.Join(errs...)
+}
@@ -0,0 +1,52 @@
+package connections
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	for in, want := range map[string]time.Duration{"": 0, "12h": 12 * time.Hour, "30d": 30 * 24 * time.Hour, "1.5d": 36 * time.Hour} {
+		if got, err := ParseAge(in); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"soon", "-1d", "-2h"} {
+		if _, err := ParseAge(in); err == nil {
+			t.Errorf("ParseAge(%q) accepted", in)
+		}
+	}
+}
+
+func TestLoadRetention(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	fp, _ := DefaultUserConfigFile()
+	cfg := `[[profiles]]
+name = "a"
+password = "keyring:emqutiti-a/user"
+history_max_age = "7d"
+history_max_size_mb = 2
+trace_max_messages = 10
+
+[[profiles]]
+name = "b"
+`
+	if err := os.WriteFile(fp, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadRetention()
+	if err != nil {
+		t.Fatalf("LoadRetention: %v", err)
+	}
+	want := ProfileRetention{
+		History: Retention{MaxAge: 7 * 24 * time.Hour, MaxBytes: 2 << 20},
+		Trace:   Retention{MaxMessages: 10},
+	}
+	if got["a"] != want {
+		t.Fatalf("retention of a = %+v, want %+v", got["a"], want)
+	}
+	if r, ok := got["b"]; !ok || !r.History.IsZero() || !r.Trace.IsZero() {
+		t.Fatalf("retention of b = %+v, %v; want unlimited", r, ok)
+	}
+}
@@ -5,6 +5,8 @@ type TopicSnapshot struct {
 	Title      string `toml:"title"`
 	Subscribed bool   `toml:"subscribed"`
 	Publish    bool   `toml:"publish"`
+	// QoS overrides the profile default QoS when set.
+	QoS *int `toml:"qos,omitempty"`
 }
 
 // PayloadSnapshot represents a stored payload for persistence.
@@ -2,8 +2,6 @@ package connections
 
 import (
 	"bytes"
-	"os"
-	"path/filepath"
 
 	"github.com/BurntSushi/toml"
 )
@@ -24,6 +22,7 @@ type userConfig struct {
 	Profiles           []Profile                     `toml:"profiles"`
 	Saved              map[string]ConnectionSnapshot `toml:"saved"`
 	ProxyAddr          string                        `toml:"proxy_addr"`
+	Proxy              ProxySettings                 `toml:"proxy,omitempty"`
 }
 
 // LoadState retrieves saved topics and payloads from config.toml.
@@ -48,17 +47,11 @@ func writeConfig(cfg userConfig) error {
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(filepath.Dir(fp), os.ModePerm); err != nil {
-		return err
-	}
 	var buf bytes.Buffer
 	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
 		return err
 	}
-	if err := os.WriteFile(fp, buf.Bytes(), 0644); err != nil {
-		return err
-	}
-	return nil
+	return WriteConfigFile(fp, buf.Bytes())
 }
 
 // SaveState updates only the Saved section in config.toml.
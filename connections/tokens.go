@@ -0,0 +1,166 @@
+package connections
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// azureAPIVersion is sent in the IoT Hub MQTT username.
+const azureAPIVersion = "2021-04-12"
+
+// tokenTTL returns the lifetime of generated tokens.
+func (p Profile) tokenTTL() time.Duration {
+	if p.TokenTTL > 0 {
+		return time.Duration(p.TokenTTL) * time.Second
+	}
+	return defaultTokenTTL * time.Second
+}
+
+// azureSASCredential signs an IoT Hub SAS token for the device p.ClientID
+// that expires after the profile's token TTL.
+func azureSASCredential(p Profile, now time.Time) (Credential, error) {
+	if p.Host == "" || p.ClientID == "" {
+		return Credential{}, errors.New("azure: host and client ID (the device ID) are required")
+	}
+	key, err := base64.StdEncoding.DecodeString(p.AzureDeviceKey)
+	if err != nil || len(key) == 0 {
+		return Credential{}, errors.New("azure: device key must be set and base64 encoded")
+	}
+	expires := now.Add(p.tokenTTL()).Truncate(time.Second)
+	resource := url.QueryEscape(p.Host + "/devices/" + p.ClientID)
+	se := strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(resource + "\n" + se))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return Credential{
+		Username: p.Host + "/" + p.ClientID + "/?api-version=" + azureAPIVersion,
+		Password: "SharedAccessSignature sr=" + resource + "&sig=" + url.QueryEscape(sig) + "&se=" + se,
+		Expires:  expires,
+	}, nil
+}
+
+// jwtCredential signs a JWT with the profile's key. The token is the
+// password; the username is left to the profile.
+func jwtCredential(p Profile, now time.Time) (Credential, error) {
+	if p.JWTKeyPath == "" {
+		return Credential{}, errors.New("jwt: no key path configured")
+	}
+	data, err := os.ReadFile(p.JWTKeyPath)
+	if err != nil {
+		return Credential{}, fmt.Errorf("jwt: read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Credential{}, errors.New("jwt: key is not PEM encoded")
+	}
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return Credential{}, fmt.Errorf("jwt: parse key: %w", err)
+	}
+	alg := p.JWTAlgorithm
+	if alg == "" {
+		alg = "RS256"
+	}
+	expires := now.Add(p.tokenTTL()).Truncate(time.Second)
+	claims := map[string]any{"iat": now.Unix(), "exp": expires.Unix()}
+	expand := strings.NewReplacer("{client_id}", p.ClientID, "{username}", p.Username)
+	for _, kv := range p.JWTClaims {
+		name, value, ok := strings.Cut(kv, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return Credential{}, fmt.Errorf("jwt: invalid claim %q (want name=value)", kv)
+		}
+		claims[name] = claimValue(expand.Replace(strings.TrimSpace(value)))
+	}
+	token, err := signJWT(alg, key, claims)
+	if err != nil {
+		return Credential{}, err
+	}
+	return Credential{Password: token, Expires: expires}, nil
+}
+
+// claimValue keeps numbers and booleans typed in the claims set.
+func claimValue(s string) any {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return s
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if k, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if s, ok := k.(crypto.Signer); ok {
+			return s, nil
+		}
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return k, nil
+	}
+	return nil, errors.New("unsupported private key")
+}
+
+// signJWT encodes claims as a compact JWS signed with alg.
+func signJWT(alg string, key crypto.Signer, claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	input := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if alg != "RS256" {
+			return "", fmt.Errorf("jwt: %s does not match an RSA key", alg)
+		}
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		if alg != "ES256" || k.Curve.Params().BitSize != 256 {
+			return "", fmt.Errorf("jwt: %s does not match a %d-bit EC key", alg, k.Curve.Params().BitSize)
+		}
+		r, s, serr := ecdsa.Sign(rand.Reader, k, digest[:])
+		if serr != nil {
+			return "", fmt.Errorf("jwt: sign: %w", serr)
+		}
+		// JWS uses fixed-size r || s rather than ASN.1.
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		if alg != "EdDSA" {
+			return "", fmt.Errorf("jwt: %s does not match an Ed25519 key", alg)
+		}
+		sig = ed25519.Sign(k, []byte(input))
+	default:
+		return "", errors.New("jwt: unsupported key type")
+	}
+	if err != nil {
+		return "", fmt.Errorf("jwt: sign: %w", err)
+	}
+	return input + "." + enc.EncodeToString(sig), nil
+}
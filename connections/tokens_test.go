@@ -0,0 +1,125 @@
+package connections
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAzureSASCredential(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	p := Profile{Preset: PresetAzureIoTHub, Host: "hub.azure-devices.net", ClientID: "dev-1", AzureDeviceKey: key, TokenTTL: 600}
+	now := time.Unix(1700000000, 0)
+	cred, err := azureSASCredential(p, now)
+	if err != nil {
+		t.Fatalf("azureSASCredential: %v", err)
+	}
+	if cred.Username != "hub.azure-devices.net/dev-1/?api-version=2021-04-12" {
+		t.Fatalf("username = %q", cred.Username)
+	}
+	want := "SharedAccessSignature sr=hub.azure-devices.net%2Fdevices%2Fdev-1&sig=A1%2BmhKiZ6XV%2F6FQ0NLERWtJ8iW8nQo62Fp4ZeQyqcMA%3D&se=1700000600"
+	if cred.Password != want {
+		t.Fatalf("password = %q, want %q", cred.Password, want)
+	}
+	if !cred.Expires.Equal(now.Add(10 * time.Minute)) {
+		t.Fatalf("expires = %v", cred.Expires)
+	}
+	p.AzureDeviceKey = "not base64!"
+	if _, err := azureSASCredential(p, now); err == nil {
+		t.Fatalf("expected error for invalid device key")
+	}
+}
+
+func TestJWTCredential(t *testing.T) {
+	dir := t.TempDir()
+	writeKey := func(name string, key any) string {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("marshal key: %v", err)
+		}
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+		return path
+	}
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	verify := map[string]func(input, sig []byte) bool{
+		"RS256": func(input, sig []byte) bool {
+			d := sha256.Sum256(input)
+			return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, d[:], sig) == nil
+		},
+		"ES256": func(input, sig []byte) bool {
+			d := sha256.Sum256(input)
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			return len(sig) == 64 && ecdsa.Verify(&ecKey.PublicKey, d[:], r, s)
+		},
+		"EdDSA": func(input, sig []byte) bool { return ed25519.Verify(edKey.Public().(ed25519.PublicKey), input, sig) },
+	}
+	paths := map[string]string{
+		"RS256": writeKey("rsa.pem", rsaKey),
+		"ES256": writeKey("ec.pem", ecKey),
+		"EdDSA": writeKey("ed.pem", edKey),
+	}
+	now := time.Unix(1700000000, 0)
+	for alg, path := range paths {
+		p := Profile{Preset: PresetJWT, ClientID: "dev-1", JWTKeyPath: path, JWTAlgorithm: alg, JWTClaims: []string{"aud=my-project", "sub={client_id}", "ver=2"}}
+		cred, err := jwtCredential(p, now)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		parts := strings.Split(cred.Password, ".")
+		if len(parts) != 3 {
+			t.Fatalf("%s: not a JWT: %q", alg, cred.Password)
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if !verify[alg]([]byte(parts[0]+"."+parts[1]), sig) {
+			t.Fatalf("%s: signature does not verify", alg)
+		}
+		data, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]any
+		json.Unmarshal(data, &claims)
+		if claims["aud"] != "my-project" || claims["sub"] != "dev-1" || claims["ver"] != float64(2) || claims["iat"] != float64(1700000000) || claims["exp"] != float64(1700003600) {
+			t.Fatalf("%s: claims = %v", alg, claims)
+		}
+		if !cred.Expires.Equal(jwtExpiry(cred.Password)) {
+			t.Fatalf("%s: expires %v does not match the token", alg, cred.Expires)
+		}
+	}
+	if _, err := jwtCredential(Profile{JWTKeyPath: paths["RS256"], JWTAlgorithm: "ES256"}, now); err == nil {
+		t.Fatalf("expected algorithm mismatch error")
+	}
+}
+
+func TestPresetCredentialsRefreshBeforeExpiry(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("secret"))
+	c := CredentialsFor(Profile{Preset: PresetAzureIoTHub, Host: "h", ClientID: "d", AzureDeviceKey: key, TokenTTL: 120})
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+	first, err := c.Resolve(t.Context())
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if again, _ := c.Resolve(t.Context()); again.Password != first.Password {
+		t.Fatalf("token regenerated while still valid")
+	}
+	now = now.Add(40 * time.Second)
+	if next, _ := c.Resolve(t.Context()); next.Password == first.Password || !next.Expires.After(first.Expires) {
+		t.Fatalf("token not refreshed before expiry")
+	}
+}
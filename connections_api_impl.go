@@ -2,9 +2,7 @@ package emqutiti
 
 import (
 	"fmt"
-	"time"
 
-	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/marang/emqutiti/connections"
@@ -15,7 +13,7 @@ import (
 func (m *model) Manager() *connections.Connections { return &m.connections.Manager }
 
 func (m *model) SetConnecting(name string) { m.connections.SetConnecting(name) }
-func (m *model) SetConnected(name string)  { m.connections.SetConnected(name) }
+func (m *model) SetConnected(name string)  { m.markConnected(name) }
 func (m *model) SetDisconnected(name, detail string) {
 	m.connections.SetDisconnected(name, detail)
 }
@@ -30,7 +28,7 @@ func (m *model) SubscribeActiveTopics() {
 	}
 	for _, t := range m.topics.Items {
 		if t.Subscribed {
-			if err := m.mqttClient.Subscribe(t.Name, 0, nil); err != nil {
+			if _, err := m.subscribeTopic(t.Name); err != nil {
 				m.connections.SendStatus(fmt.Sprintf("Subscribe error for %s: %v", t.Name, err))
 			}
 		}
@@ -43,8 +41,8 @@ func (m *model) Active() string                  { return m.connections.Active }
 func (m *model) validProfileIndex(idx int) bool {
 	return idx >= 0 && idx < len(m.connections.Manager.Profiles)
 }
-func (m *model) BeginAdd() {
-	f := connections.NewForm(connections.Profile{}, -1)
+func (m *model) BeginAdd(p connections.Profile) {
+	f := connections.NewForm(p, -1)
 	m.connections.Form = &f
 }
 func (m *model) BeginEdit(index int) {
@@ -85,36 +83,31 @@ func (m *model) Connect(p connections.Profile) tea.Cmd {
 	m.RefreshConnectionItems()
 	return tea.Batch(connectBroker(p, m.connections.SendStatus), m.startAnimationTick())
 }
-func (m *model) HandleConnectResult(msg connections.ConnectResult) {
+func (m *model) HandleConnectResult(msg connections.ConnectResult) tea.Cmd {
 	m.ui.listeners.mqtt = false
 	profile := msg.Profile
-	brokerURL := fmt.Sprintf("%s://%s:%d", profile.Schema, profile.Host, profile.Port)
+	brokerURL := brokerLabel(profile)
 	if err := msg.Err; err != nil {
 		m.connections.SetDisconnected(profile.Name, fmt.Sprintf("Failed to connect to %s: %v", brokerURL, err))
 		m.connections.Connection = fmt.Sprintf("Failed to connect to %s: %v", brokerURL, err)
 		m.RefreshConnectionItems()
-		return
+		return nil
 	}
+	m.stopReconnect()
 	m.mqttClient = msg.Client.(*MQTTClient)
 	m.connections.Active = profile.Name
+	m.history.StopWatch()
 	if st := m.history.Store(); st != nil {
 		st.Close()
 	}
+	var watch tea.Cmd
 	idx, err := history.OpenStore(profile.Name)
 	if err != nil {
 		m.connections.SendStatus(fmt.Sprintf("History open error for %s: %v", profile.Name, err))
 	} else if idx != nil {
 		m.history.SetStore(idx)
-		msgs := idx.Search(false, nil, time.Time{}, time.Time{}, "")
-		hitems := make([]history.Item, len(msgs))
-		items := make([]list.Item, len(msgs))
-		for i, mmsg := range msgs {
-			hi := history.Item{Timestamp: mmsg.Timestamp, Topic: mmsg.Topic, Payload: mmsg.Payload, Kind: mmsg.Kind, Archived: mmsg.Archived, Retained: mmsg.Retained}
-			hitems[i] = hi
-			items[i] = hi
-		}
-		m.history.SetItems(hitems)
-		m.history.List().SetItems(items)
+		m.history.Load(m.history.FilterQuery())
+		watch = m.history.Watch()
 	}
 	ts, ps := m.connections.RestoreState(profile.Name)
 	m.topics.SetSnapshot(ts)
@@ -124,13 +117,16 @@ func (m *model) HandleConnectResult(msg connections.ConnectResult) {
 	m.topics.RebuildActiveTopicList()
 	m.SubscribeActiveTopics()
 	m.connections.Connection = "Connected to " + brokerURL
-	m.connections.SetConnected(profile.Name)
+	m.markConnected(profile.Name)
 	m.RefreshConnectionItems()
+	return watch
 }
 func (m *model) DisconnectActive() {
+	m.stopReconnect()
 	if m.mqttClient != nil {
 		m.mqttClient.Disconnect()
 		m.connections.SetDisconnected(m.connections.Active, "")
+		m.syncTunnel(m.connections.Active)
 		m.RefreshConnectionItems()
 		m.connections.Connection = ""
 		m.connections.Active = ""
@@ -19,6 +19,10 @@ const (
 	ModeHistoryDetail
 	ModeHelp
 	ModeLogs
+	ModeMessageProps
+	ModePending
+	ModeBridge
+	ModeExport
 )
 
 // ID constants for shared elements.
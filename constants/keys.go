@@ -21,11 +21,14 @@ const (
 	KeyD             = "d"
 	KeyE             = "e"
 	KeyQ             = "q"
+	KeyR             = "r"
 	KeyA             = "a"
 	KeyV             = "v"
 	KeyY             = "y"
 	KeyN             = "n"
 	KeyX             = "x"
+	KeyS             = "s"
+	KeyB             = "b"
 	KeySlash         = "/"
 	KeySpace         = "space"
 	KeySpaceBar      = " "
@@ -59,4 +62,7 @@ const (
 	KeyCtrlAltR      = "ctrl+alt+r"
 	KeyCtrlAltS      = "ctrl+alt+s"
 	KeyAltR          = "alt+r"
+	KeyAltP          = "alt+p"
+	KeyAltE          = "alt+e"
+	KeyAltO          = "alt+o"
 )
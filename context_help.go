@@ -86,7 +86,7 @@ func topicActionHint(t topics.Item) string {
 	if t.Publish {
 		pubAction = "clears publish target"
 	}
-	return fmt.Sprintf("Topic %q: %s. Enter %s; p %s.", name, topicStateHint(t), subAction, pubAction)
+	return fmt.Sprintf("Topic %q: %s. Enter %s; p %s; q cycles QoS.", name, topicStateHint(t), subAction, pubAction)
 }
 
 func (m *model) topicHoverHint(idx int) string {
@@ -130,7 +130,7 @@ func (m *model) messageHint() string {
 	if targets == "no target" {
 		return "Message: no publish target. Add or select a topic first."
 	}
-	return fmt.Sprintf("Message: Ctrl+S publishes to %s; Ctrl+E publishes retained.", targets)
+	return fmt.Sprintf("Message: Ctrl+S publishes to %s; Ctrl+E publishes retained; Ctrl+Q cycles QoS; Alt+E switches text/hex/base64; Alt+P edits properties; Ctrl+R sends a request.", targets)
 }
 
 func (m *model) focusHint(id string) string {
@@ -182,9 +182,9 @@ func (m *model) contextHelpDetailText() string {
 	}
 	switch id {
 	case idTopic, idTopics:
-		return "Enter toggles subscribe  p toggles publish  Delete removes"
+		return "Enter toggles subscribe  p toggles publish  q cycles QoS  Delete removes"
 	case idMessage:
-		return "Publish targets are marked on topic chips | Ctrl+S publish  Ctrl+E retain"
+		return "Publish targets are marked on topic chips | Ctrl+S publish  Ctrl+E retain  Ctrl+Q QoS  Alt+E encoding  Alt+P props  Ctrl+R request"
 	case idHistory:
 		return "History stores received and published messages | Enter details  / filter"
 	case idHelp:
@@ -0,0 +1,41 @@
+package emqutiti
+
+import (
+	"strings"
+
+	"github.com/marang/emqutiti/connections"
+)
+
+// ActiveBroker returns the URL of the broker the client is connected to, or
+// an empty string before the first connection.
+func (m *MQTTClient) ActiveBroker() string { return m.endpoints.Current() }
+
+// brokerLabel names the brokers of p for status messages.
+func brokerLabel(p connections.Profile) string {
+	return strings.Join(p.BrokerURLs(), ", ")
+}
+
+// markConnected shows name as connected. For profiles with several brokers
+// the broker manager also shows which endpoint is in use.
+func (m *model) markConnected(name string) {
+	ep := ""
+	if m.mqttClient != nil {
+		ep = m.mqttClient.ActiveBroker()
+	}
+	if ep != "" {
+		m.connections.Connection = "Connected to " + ep
+	}
+	if p, ok := m.activeProfile(); !ok || p.Name != name || len(p.BrokerURLs()) < 2 {
+		ep = ""
+	}
+	m.connections.SetConnectedVia(name, ep)
+	m.syncTunnel(name)
+}
+
+// syncTunnel shows the SSH tunnel status of the client next to name.
+func (m *model) syncTunnel(name string) {
+	if name == "" {
+		return
+	}
+	m.connections.SetTunnel(name, m.mqttClient.TunnelStatus())
+}
@@ -0,0 +1,49 @@
+package emqutiti
+
+import (
+	"net/url"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/clientopts"
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/mqttclient"
+)
+
+func TestMarkConnectedShowsEndpoint(t *testing.T) {
+	p := connections.Profile{Schema: "tcp", Host: "a", Port: 1, Brokers: []string{"b"}}
+	m := clientModel(t, p, &fakeClient{})
+	o, err := clientopts.Build(p, nil)
+	if err != nil {
+		t.Fatalf("options: %v", err)
+	}
+	b, _ := url.Parse("tcp://b:1")
+	o.Options.OnConnectionNotification(nil, mqtt.ConnectionNotificationBroker{Broker: b})
+	o.Endpoints.Connected()
+	m.mqttClient.endpoints = o.Endpoints
+	m.markConnected("p")
+	if d := m.connections.Manager.Errors["p"]; d != "via tcp://b:1" {
+		t.Fatalf("expected active endpoint in detail, got %q", d)
+	}
+	if m.connections.Connection != "Connected to tcp://b:1" {
+		t.Fatalf("unexpected connection line %q", m.connections.Connection)
+	}
+}
+
+func TestSSHTunnelStatusShownInBrokerManager(t *testing.T) {
+	m := clientModel(t, connections.Profile{Schema: "tcp", Host: "a", Port: 1, SSHHost: "bastion"}, &fakeClient{})
+	tunnel, err := mqttclient.NewSSHTunnel(mqttclient.SSHConfig{Addr: "bastion", User: "u", Password: "pw", InsecureHostKey: true})
+	if err != nil {
+		t.Fatalf("tunnel: %v", err)
+	}
+	m.mqttClient.tunnel = tunnel
+	m.handleStatusMessage(connections.StatusMessage("SSH tunnel bastion:22 idle"))
+	if got := m.connections.Manager.Tunnels["p"]; got != "bastion:22 idle" {
+		t.Fatalf("tunnel status = %q", got)
+	}
+	m.DisconnectActive()
+	if got := m.connections.Manager.Tunnels["p"]; got != "bastion:22 closed" {
+		t.Fatalf("tunnel status after disconnect = %q", got)
+	}
+}
@@ -0,0 +1,66 @@
+package emqutiti
+
+import (
+	"fmt"
+
+	"github.com/marang/emqutiti/history"
+)
+
+// runExport writes stored history or trace messages matching the export
+// query to stdout or a file. History is streamed page by page.
+func runExport(d *appDeps) error {
+	o := d.export
+	var src history.RecordSource
+	var title, name string
+	if o.Trace != "" {
+		profile := d.profileName
+		if profile == "" {
+			tc, ok := d.traceStore.LoadTraces()[o.Trace]
+			if !ok {
+				return fmt.Errorf("export: unknown trace %q", o.Trace)
+			}
+			profile = tc.Profile
+		}
+		tmsgs, err := d.traceStore.Messages(profile, o.Trace)
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		msgs := make([]history.Message, len(tmsgs))
+		for i, m := range tmsgs {
+			msgs[i] = history.Message{Timestamp: m.Timestamp, Topic: m.Topic, Payload: m.Payload, Kind: m.Kind, Retained: m.Retained, Properties: m.Properties}
+		}
+		msgs = history.FilterMessages(msgs, o.Query)
+		records := make([]history.Record, len(msgs))
+		for i, m := range msgs {
+			records[i] = history.MessageRecord(m)
+		}
+		src = history.Records(records)
+		title, name = "Trace "+o.Trace, o.Trace
+	} else {
+		p, err := d.loadProfile(d.profileName, d.configFile)
+		if err != nil {
+			return fmt.Errorf("error loading profile: %w", err)
+		}
+		st, err := d.openHistory(p.Name)
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		defer st.Close()
+		src = history.QueryRecords(st, history.NewQuery(o.Query, o.Archived), history.ParseProperties(o.Query), nil)
+		title, name = "History of "+p.Name, p.Name
+	}
+	if o.Query != "" {
+		title += " (" + o.Query + ")"
+	}
+	columns := splitList(o.Columns)
+	if o.Output == "" {
+		if err := history.Export(d.stdout, o.Format, columns, title, src); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		return nil
+	}
+	if err := history.ExportFile(o.Output, o.Format, columns, title, src); err != nil {
+		return fmt.Errorf("export %s: %w", name, err)
+	}
+	return nil
+}
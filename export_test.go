@@ -0,0 +1,165 @@
+package emqutiti
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	cfg "github.com/marang/emqutiti/cmd"
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/traces"
+	"github.com/marang/emqutiti/ui"
+)
+
+// exportTraceStore serves the messages of one trace.
+type exportTraceStore struct {
+	stubTraceStore
+	msgs []traces.TracerMessage
+}
+
+func (s *exportTraceStore) LoadTraces() map[string]traces.TracerConfig {
+	return map[string]traces.TracerConfig{"run1": {Key: "run1", Profile: "prod"}}
+}
+
+func (s *exportTraceStore) Messages(profile, key string) ([]traces.TracerMessage, error) {
+	s.checkedProfile, s.checkedKey = profile, key
+	return s.msgs, nil
+}
+
+// exportHistoryStore returns its messages for any query.
+type exportHistoryStore struct {
+	stubHistoryStore
+	msgs     []history.Message
+	archived bool
+}
+
+func (s *exportHistoryStore) Query(q history.Query) (history.Page, error) {
+	s.archived = q.Archived
+	return history.Page{Messages: s.msgs}, nil
+}
+
+func TestRunExportHistoryToStdout(t *testing.T) {
+	ts := time.Unix(1700000000, 0).UTC()
+	st := &exportHistoryStore{msgs: []history.Message{
+		{Timestamp: ts, Topic: "a", Payload: []byte("1"), Kind: "sub"},
+		{Timestamp: ts, Topic: "b", Payload: []byte("2"), Kind: "pub"},
+	}}
+	var out bytes.Buffer
+	var opened string
+	d := &appDeps{
+		loadProfile: func(string, string) (*connections.Profile, error) { return &connections.Profile{Name: "local"}, nil },
+		openHistory: func(p string) (history.Store, error) { opened = p; return st, nil },
+		stdout:      &out,
+		export:      cfg.ExportConfig{Format: "ndjson", Query: "prop.user.site=x", Archived: true},
+	}
+	if err := runExport(d); err != nil {
+		t.Fatalf("runExport: %v", err)
+	}
+	if opened != "local" || !st.archived || !st.closed {
+		t.Fatalf("expected archived search on local history, got %q %v %v", opened, st.archived, st.closed)
+	}
+	// The property filter drops messages without the property.
+	if out.Len() != 0 {
+		t.Fatalf("expected no output, got %q", out.String())
+	}
+
+	d.export = cfg.ExportConfig{Format: "csv", Columns: "topic,kind,payload"}
+	out.Reset()
+	if err := runExport(d); err != nil {
+		t.Fatalf("runExport: %v", err)
+	}
+	if want := "topic,kind,payload\na,sub,1\nb,pub,2\n"; out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestRunExportTraceToFile(t *testing.T) {
+	ts := time.Unix(1700000000, 0).UTC()
+	st := &exportTraceStore{msgs: []traces.TracerMessage{
+		{Timestamp: ts, Topic: "sensors/1", Payload: []byte("21"), Kind: "sub"},
+		{Timestamp: ts.Add(time.Minute), Topic: "sensors/2", Payload: []byte("22"), Kind: "sub"},
+		{Timestamp: ts.Add(2 * time.Minute), Topic: "other", Payload: []byte("x"), Kind: "sub"},
+	}}
+	path := filepath.Join(t.TempDir(), "run1.json")
+	d := &appDeps{
+		traceStore: st,
+		export:     cfg.ExportConfig{Trace: "run1", Format: "json", Query: "topic=sensors/1,sensors/2 end=2023-11-14T22:14:00Z", Output: path},
+	}
+	if err := runExport(d); err != nil {
+		t.Fatalf("runExport: %v", err)
+	}
+	if st.checkedProfile != "prod" || st.checkedKey != "run1" {
+		t.Fatalf("expected trace profile from config, got %q %q", st.checkedProfile, st.checkedKey)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var got []history.Record
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("expected JSON array, got %q: %v", data, err)
+	}
+	if len(got) != 1 || got[0].Topic != "sensors/1" {
+		t.Fatalf("expected only sensors/1 before the end time, got %+v", got)
+	}
+
+	d.export = cfg.ExportConfig{Trace: "missing", Format: "json"}
+	if err := runExport(d); err == nil {
+		t.Fatalf("expected error for unknown trace")
+	}
+}
+
+func TestHistoryExportKeyWritesSelection(t *testing.T) {
+	m := clientModel(t, connections.Profile{}, &fakeClient{})
+	m.history.Append("a", "1", "sub", false, "")
+	m.history.Append("b", "2", "sub", false, "")
+	m.history.Append("c", "3", "sub", false, "")
+	m.SetFocus(idHistory)
+	m.history.SetSelectionAnchor(0)
+	m.history.UpdateSelectionRange(1)
+
+	m.handleExportKey()
+	if m.CurrentMode() != constants.ModeExport {
+		t.Fatalf("expected export mode, got %v", m.CurrentMode())
+	}
+	if v := m.viewExport(); !strings.Contains(v, "History of p: 2 message(s)") {
+		t.Fatalf("expected selection summary, got %q", v)
+	}
+	m.export.form.Fields[idxExportFormat].(*ui.SelectField).Index = 3 // html
+	path := filepath.Join(t.TempDir(), "out.html")
+	m.export.form.Fields[idxExportFile].(*ui.TextField).SetValue(path)
+	m.updateExport(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.CurrentMode() != constants.ModeClient {
+		t.Fatalf("expected to return to the client, got %v", m.CurrentMode())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(string(data), "<td>a</td>") || strings.Contains(string(data), "<td>c</td>") {
+		t.Fatalf("expected report of the selection, got %s", data)
+	}
+	if want := "Exported 2 message(s) to " + path; lastLog(m) != want {
+		t.Fatalf("expected %q, got %q", want, lastLog(m))
+	}
+}
+
+func TestExportPathFollowsFormat(t *testing.T) {
+	m := clientModel(t, connections.Profile{}, &fakeClient{})
+	m.StartExport("History", "p", []history.Record{{Topic: "a"}})
+	if got := m.exportPath(history.FormatCSV); !strings.HasSuffix(got, ".csv") || !strings.HasPrefix(got, "emqutiti-p-") {
+		t.Fatalf("expected suggested name with csv extension, got %q", got)
+	}
+	m.export.form.Fields[idxExportFile].(*ui.TextField).SetValue("mine.txt")
+	if got := m.exportPath(history.FormatCSV); got != "mine.txt" {
+		t.Fatalf("expected edited name kept, got %q", got)
+	}
+}
@@ -0,0 +1,157 @@
+package emqutiti
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/ui"
+)
+
+const (
+	idxExportFormat = iota
+	idxExportColumns
+	idxExportFile
+)
+
+var exportLabels = []string{"Format", "CSV columns", "File"}
+
+// exportState backs the export dialog opened from the history and trace
+// views. The loaded records are captured when the dialog opens; rest reads
+// the matches not loaded yet from the store while exporting.
+type exportState struct {
+	form    ui.Form
+	title   string
+	records []history.Record
+	rest    func() history.RecordSource
+	// file is the suggested file name; it follows the chosen format until
+	// the user edits it.
+	file string
+	err  string
+}
+
+// StartExport opens the export dialog for records. name suggests the file
+// name and title heads the HTML report.
+func (m *model) StartExport(title, name string, records []history.Record) tea.Cmd {
+	return m.startExport(title, name, records, nil)
+}
+
+// startExport opens the export dialog for records followed by the records
+// of rest, which may be nil.
+func (m *model) startExport(title, name string, records []history.Record, rest func() history.RecordSource) tea.Cmd {
+	if len(records) == 0 && rest == nil {
+		m.history.Append("", "", "log", false, "Nothing to export")
+		return nil
+	}
+	file := history.ExportFileName(name, history.FormatNDJSON, time.Now())
+	formatField, _ := ui.NewSelectField(history.FormatNDJSON, history.ExportFormats)
+	fileField := ui.NewTextField(file, "file")
+	f := ui.Form{Fields: []ui.Field{
+		formatField,
+		ui.NewTextField("", strings.Join(history.CSVColumns, ",")),
+		fileField,
+	}}
+	f.ApplyFocus()
+	m.export = exportState{form: f, title: title, records: records, rest: rest, file: file}
+	return m.SetMode(constants.ModeExport)
+}
+
+// exportPath returns the target file, switching the extension of the
+// suggested name to the chosen format.
+func (m *model) exportPath(format string) string {
+	path := strings.TrimSpace(m.export.form.Fields[idxExportFile].Value())
+	if path == m.export.file {
+		path = strings.TrimSuffix(path, filepath.Ext(path)) + "." + format
+	}
+	return path
+}
+
+// runExport writes the records and returns to the previous view.
+func (m *model) runExport() tea.Cmd {
+	es := &m.export
+	format := es.form.Fields[idxExportFormat].Value()
+	path := m.exportPath(format)
+	if path == "" {
+		es.err = "export: enter a file name"
+		return nil
+	}
+	columns := splitList(es.form.Fields[idxExportColumns].Value())
+	src := history.Records(es.records)
+	if es.rest != nil {
+		src = history.Concat(src, es.rest())
+	}
+	n := 0
+	counted := func() ([]history.Record, error) {
+		records, err := src()
+		n += len(records)
+		return records, err
+	}
+	if err := history.ExportFile(path, format, columns, es.title, counted); err != nil {
+		es.err = fmt.Sprintf("export: %v", err)
+		return nil
+	}
+	msg := fmt.Sprintf("Exported %d message(s) to %s", n, path)
+	m.export = exportState{}
+	m.history.Append("", "", "log", false, msg)
+	return m.SetMode(m.PreviousMode())
+}
+
+// updateExport handles input of the export dialog.
+func (m *model) updateExport(msg tea.Msg) tea.Cmd {
+	es := &m.export
+	if km, ok := msg.(tea.KeyMsg); ok {
+		switch km.String() {
+		case constants.KeyEsc:
+			m.export = exportState{}
+			return m.SetMode(m.PreviousMode())
+		case constants.KeyCtrlD:
+			return tea.Quit
+		case constants.KeyEnter:
+			return m.runExport()
+		}
+		es.form.CycleFocus(km)
+	}
+	if len(es.form.Fields) == 0 {
+		return nil
+	}
+	es.form.ApplyFocus()
+	return es.form.Fields[es.form.Focus].Update(msg)
+}
+
+// viewExport renders the export dialog.
+func (m *model) viewExport() string {
+	es := m.export
+	var b strings.Builder
+	if es.rest != nil {
+		fmt.Fprintf(&b, "%s: %d loaded message(s) and more\n\n", es.title, len(es.records))
+	} else {
+		fmt.Fprintf(&b, "%s: %d message(s)\n\n", es.title, len(es.records))
+	}
+	for i, fld := range es.form.Fields {
+		label := exportLabels[i]
+		if i == es.form.Focus {
+			label = ui.FocusedStyle.Render(label)
+		}
+		b.WriteString(label + ": " + fld.View() + "\n")
+		if sf, ok := fld.(*ui.SelectField); ok && es.form.IsFocused(i) {
+			if opts := sf.OptionsView(); opts != "" {
+				b.WriteString(opts + "\n")
+			}
+		}
+	}
+	if len(es.form.Fields) > 0 {
+		b.WriteString("\n" + ui.InfoSubtleStyle.Render("Writes "+m.exportPath(es.form.Fields[idxExportFormat].Value())))
+	}
+	if es.err != "" {
+		b.WriteString("\n" + ui.ErrorStyle.Render(es.err))
+	}
+	b.WriteString("\n" + ui.InfoSubtleStyle.Render("[enter] export  [esc] cancel"))
+	content := lipgloss.NewStyle().Padding(1, 2).Render(b.String())
+	return ui.LegendBox(content, "Export", m.ui.width-2, m.ui.height-2, ui.ColBlue, true, -1)
+}
@@ -1,8 +1,6 @@
 package history
 
 import (
-	"time"
-
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -29,7 +27,8 @@ type Model interface {
 // Store defines operations for storing and querying history messages.
 type Store interface {
 	Append(Message) error
-	Search(archived bool, topics []string, start, end time.Time, payload string) []Message
+	// Query returns one page of the messages matching q.
+	Query(q Query) (Page, error)
 	Delete(key string) error
 	Archive(key string) error
 	Count(archived bool) int
@@ -58,14 +57,12 @@ func NewComponent(m Model, st Store) *Component {
 		selectionAnchor: -1,
 		detail:          viewport.New(0, 0),
 	}
+	sc := ui.NewListMouseScroller(&hs.list, 3)
+	h := &Component{historyState: &hs, m: m, sc: sc}
 	if st != nil {
-		msgs := st.Search(false, nil, time.Time{}, time.Time{}, "")
-		var items []list.Item
-		hs.items, items = MessagesToItems(msgs)
-		hs.list.SetItems(items)
+		h.Load("")
 	}
-	sc := ui.NewListMouseScroller(&hs.list, 3)
-	return &Component{historyState: &hs, m: m, sc: sc}
+	return h
 }
 
 // OpenStore opens or creates a persistent history store for the given profile.
@@ -0,0 +1,253 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Capture formats understood by ReadCapture.
+const (
+	// CaptureNDJSON is one Record per line, as written by "sub --format
+	// ndjson", headless traces and exports.
+	CaptureNDJSON = "ndjson"
+	// CaptureMosquitto is "topic payload" per line as printed by
+	// "mosquitto_sub -v", optionally preceded by a timestamp from
+	// -F "%I %t %p" or -F "%U %t %p".
+	CaptureMosquitto = "mosquitto"
+	// CaptureMQTTExplorer is a JSON array of messages saved by MQTT
+	// Explorer.
+	CaptureMQTTExplorer = "mqtt-explorer"
+)
+
+// CaptureFormats lists the formats accepted by ReadCapture.
+var CaptureFormats = []string{CaptureNDJSON, CaptureMosquitto, CaptureMQTTExplorer}
+
+// ReadCapture parses a capture of received messages in format, or detects
+// the format from the content when it is empty. Original timestamps are
+// kept; messages without one are stamped base plus one microsecond per
+// message so they keep their order. As messages are stored by topic and
+// timestamp, a message sharing both with an earlier one is moved a
+// nanosecond later until it is unique.
+func ReadCapture(r io.Reader, format string, base time.Time) ([]Message, error) {
+	msgs, err := readCapture(r, format, base)
+	if err != nil {
+		return nil, err
+	}
+	type slot struct {
+		topic string
+		ts    int64
+	}
+	seen := make(map[slot]bool, len(msgs))
+	for i := range msgs {
+		k := slot{msgs[i].Topic, msgs[i].Timestamp.UnixNano()}
+		orig := k.ts
+		for seen[k] {
+			k.ts++
+		}
+		seen[k] = true
+		msgs[i].Timestamp = msgs[i].Timestamp.Add(time.Duration(k.ts - orig))
+	}
+	return msgs, nil
+}
+
+func readCapture(r io.Reader, format string, base time.Time) ([]Message, error) {
+	br := bufio.NewReader(r)
+	if format == "" {
+		format = detectCapture(br)
+	}
+	stamp := func(i int, ts time.Time) time.Time {
+		if ts.IsZero() {
+			return base.Add(time.Duration(i) * time.Microsecond)
+		}
+		return ts
+	}
+	switch format {
+	case CaptureNDJSON:
+		return readNDJSONCapture(br, stamp)
+	case CaptureMosquitto:
+		return readMosquittoCapture(br, stamp)
+	case CaptureMQTTExplorer:
+		return readExplorerCapture(br, stamp)
+	}
+	return nil, fmt.Errorf("unknown capture format %q (want %s)", format, strings.Join(CaptureFormats, ", "))
+}
+
+// detectCapture guesses the format from the first non-blank byte.
+func detectCapture(br *bufio.Reader) string {
+	for n := 1; ; n++ {
+		b, _ := br.Peek(n)
+		if len(b) < n {
+			return CaptureMosquitto
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+		case '[':
+			return CaptureMQTTExplorer
+		case '{':
+			return CaptureNDJSON
+		default:
+			return CaptureMosquitto
+		}
+	}
+}
+
+// scanLines returns a scanner for captures with long payload lines.
+func scanLines(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	return sc
+}
+
+func readNDJSONCapture(r io.Reader, stamp func(int, time.Time) time.Time) ([]Message, error) {
+	var out []Message
+	sc := scanLines(r)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Topic == "" {
+			return nil, fmt.Errorf("line %d: missing topic", line)
+		}
+		payload, err := rec.PayloadBytes()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		kind := rec.Kind
+		if kind == "" {
+			kind = "sub"
+		}
+		out = append(out, Message{Timestamp: stamp(len(out), rec.Time), Topic: rec.Topic, Payload: payload, Kind: kind, Retained: rec.Retained, Properties: rec.Properties})
+	}
+	return out, sc.Err()
+}
+
+func readMosquittoCapture(r io.Reader, stamp func(int, time.Time) time.Time) ([]Message, error) {
+	var out []Message
+	sc := scanLines(r)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var ts time.Time
+		if first, rest, ok := strings.Cut(line, " "); ok {
+			if t, ok := parseCaptureTime(first); ok {
+				ts, line = t, rest
+			}
+		}
+		topic, payload, _ := strings.Cut(line, " ")
+		out = append(out, Message{Timestamp: stamp(len(out), ts), Topic: topic, Payload: []byte(payload), Kind: "sub"})
+	}
+	return out, sc.Err()
+}
+
+// parseCaptureTime reads the ISO 8601 (%I) and Unix (%U) timestamps of
+// mosquitto_sub. Unix times need a fraction so numeric topics are not
+// mistaken for them.
+func parseCaptureTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05-0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	sec, frac, ok := strings.Cut(s, ".")
+	if !ok || len(sec) < 9 {
+		return time.Time{}, false
+	}
+	secs, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	frac = (frac + "000000000")[:9]
+	nanos, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, nanos), true
+}
+
+// explorerMessage is a message saved by MQTT Explorer. The payload is a
+// string or an object holding base64, and the time is in milliseconds or
+// an ISO string.
+type explorerMessage struct {
+	Topic     string          `json:"topic"`
+	Payload   json.RawMessage `json:"payload"`
+	Received  json.RawMessage `json:"received"`
+	Timestamp json.RawMessage `json:"timestamp"`
+	Retain    bool            `json:"retain"`
+}
+
+func readExplorerCapture(r io.Reader, stamp func(int, time.Time) time.Time) ([]Message, error) {
+	var in []explorerMessage
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+	out := make([]Message, 0, len(in))
+	for i, em := range in {
+		if em.Topic == "" {
+			return nil, fmt.Errorf("message %d: missing topic", i+1)
+		}
+		payload, err := explorerPayload(em.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i+1, err)
+		}
+		raw := em.Received
+		if len(raw) == 0 {
+			raw = em.Timestamp
+		}
+		ts, err := explorerTime(raw)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i+1, err)
+		}
+		out = append(out, Message{Timestamp: stamp(len(out), ts), Topic: em.Topic, Payload: payload, Kind: "sub", Retained: em.Retain})
+	}
+	return out, nil
+}
+
+func explorerPayload(raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []byte(s), nil
+	}
+	var b struct {
+		Base64Message string `json:"base64Message"`
+	}
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, fmt.Errorf("payload: %w", err)
+	}
+	return base64.StdEncoding.DecodeString(b.Base64Message)
+}
+
+func explorerTime(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}, nil
+	}
+	var ms int64
+	if err := json.Unmarshal(raw, &ms); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return time.Time{}, errors.New("time: want milliseconds or an RFC 3339 string")
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,101 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCaptureNDJSONKeepsTimestamps(t *testing.T) {
+	in := `{"time":"2025-08-05T11:47:00Z","topic":"a","payload":"1","retained":true}
+
+{"time":"2025-08-05T11:47:01Z","topic":"b","payload":"/w==","encoding":"base64","kind":"pub"}
+`
+	msgs, err := ReadCapture(strings.NewReader(in), "", time.Time{})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %+v", msgs)
+	}
+	if want := time.Date(2025, 8, 5, 11, 47, 0, 0, time.UTC); !msgs[0].Timestamp.Equal(want) || !msgs[0].Retained || msgs[0].Kind != "sub" {
+		t.Fatalf("unexpected first message %+v", msgs[0])
+	}
+	if string(msgs[1].Payload) != "\xff" || msgs[1].Kind != "pub" {
+		t.Fatalf("unexpected second message %+v", msgs[1])
+	}
+	if _, err := ReadCapture(strings.NewReader(`{"payload":"x"}`), CaptureNDJSON, time.Time{}); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected missing topic error, got %v", err)
+	}
+}
+
+func TestReadCaptureMosquitto(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	in := "sensors/1 21.5\nsensors/2 {\"t\": 1}\nempty\n"
+	msgs, err := ReadCapture(strings.NewReader(in), "", base)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(msgs) != 3 || msgs[1].Topic != "sensors/2" || string(msgs[1].Payload) != `{"t": 1}` || len(msgs[2].Payload) != 0 {
+		t.Fatalf("unexpected messages %+v", msgs)
+	}
+	if !msgs[0].Timestamp.Equal(base) || !msgs[2].Timestamp.Equal(base.Add(2*time.Microsecond)) {
+		t.Fatalf("expected ordered stamps from base, got %v %v", msgs[0].Timestamp, msgs[2].Timestamp)
+	}
+
+	in = "2025-08-05T11:47:00+0200 a on\n1754387220.500000000 b off\n"
+	msgs, err = ReadCapture(strings.NewReader(in), CaptureMosquitto, base)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := time.Date(2025, 8, 5, 9, 47, 0, 0, time.UTC); !msgs[0].Timestamp.Equal(want) || msgs[0].Topic != "a" || string(msgs[0].Payload) != "on" {
+		t.Fatalf("unexpected %%I message %+v", msgs[0])
+	}
+	if want := time.Unix(1754387220, 500000000); !msgs[1].Timestamp.Equal(want) || msgs[1].Topic != "b" {
+		t.Fatalf("unexpected %%U message %+v", msgs[1])
+	}
+}
+
+func TestReadCaptureSeparatesSameSecondMessages(t *testing.T) {
+	in := "2025-08-05T11:47:00+0200 a 1\n2025-08-05T11:47:00+0200 a 2\n2025-08-05T11:47:00+0200 b 3\n2025-08-05T11:47:00+0200 a 4\n"
+	msgs, err := ReadCapture(strings.NewReader(in), CaptureMosquitto, time.Time{})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := time.Date(2025, 8, 5, 9, 47, 0, 0, time.UTC)
+	for i, off := range []time.Duration{0, 1, 0, 2} {
+		if !msgs[i].Timestamp.Equal(want.Add(off)) {
+			t.Fatalf("message %d stamped %v, want %v", i, msgs[i].Timestamp, want.Add(off))
+		}
+	}
+	keys := map[string]bool{}
+	for _, m := range msgs {
+		keys[messageKey(m.Topic, m.Timestamp)] = true
+	}
+	if len(keys) != len(msgs) {
+		t.Fatalf("%d messages share %d keys", len(msgs), len(keys))
+	}
+}
+
+func TestReadCaptureMQTTExplorer(t *testing.T) {
+	in := ` [
+  {"topic": "a", "payload": "on", "received": 1754387220000, "retain": true},
+  {"topic": "b", "payload": {"base64Message": "AAE="}, "timestamp": "2025-08-05T09:47:01Z"}
+]`
+	msgs, err := ReadCapture(strings.NewReader(in), "", time.Time{})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(msgs) != 2 || !msgs[0].Retained || !msgs[0].Timestamp.Equal(time.UnixMilli(1754387220000)) {
+		t.Fatalf("unexpected messages %+v", msgs)
+	}
+	if string(msgs[1].Payload) != "\x00\x01" || !msgs[1].Timestamp.Equal(time.Date(2025, 8, 5, 9, 47, 1, 0, time.UTC)) {
+		t.Fatalf("unexpected second message %+v", msgs[1])
+	}
+}
+
+func TestReadCaptureUnknownFormat(t *testing.T) {
+	if _, err := ReadCapture(strings.NewReader(""), "pcap", time.Time{}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
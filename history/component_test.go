@@ -2,6 +2,7 @@ package history
 
 import (
 	"testing"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
@@ -41,3 +42,72 @@ func TestHandleSelection(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadMoreKeepsAppendedMessagesLast(t *testing.T) {
+	hs := &store{}
+	base := time.Unix(1700000000, 0)
+	for i := 0; i < pageSize+20; i++ {
+		if err := hs.Append(Message{Timestamp: base.Add(time.Duration(i) * time.Second), Topic: "t", Payload: []byte("p"), Kind: "sub"}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	c := NewComponent(stubModel{}, hs)
+	if got := len(c.Items()); got != pageSize {
+		t.Fatalf("first page holds %d items, want %d", got, pageSize)
+	}
+
+	c.Append("live", "new", "sub", false, "new")
+	c.LoadMore()
+
+	items := c.Items()
+	if len(items) != pageSize+21 {
+		t.Fatalf("got %d items, want %d without duplicates", len(items), pageSize+21)
+	}
+	if last := items[len(items)-1]; last.Topic != "live" || c.List().Index() != len(items)-1 {
+		t.Fatalf("appended message not kept last and selected: %+v at %d", last, c.List().Index())
+	}
+	c.LoadMore()
+	if len(c.Items()) != pageSize+21 {
+		t.Fatalf("loaded past the last page: %d items", len(c.Items()))
+	}
+}
+
+func TestRemainingRecordsReadsUnloadedPages(t *testing.T) {
+	hs := &store{}
+	base := time.Unix(1700000000, 0)
+	for i := 0; i < pageSize+20; i++ {
+		if err := hs.Append(Message{Timestamp: base.Add(time.Duration(i) * time.Second), Topic: "t", Payload: []byte("p"), Kind: "sub"}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	c := NewComponent(stubModel{}, hs)
+	c.Append("live", "new", "sub", false, "new")
+	rest := c.RemainingRecords()
+	if rest == nil {
+		t.Fatalf("expected a source for the unloaded messages")
+	}
+	for run := 0; run < 2; run++ {
+		n := 0
+		src := rest()
+		for {
+			records, err := src()
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if len(records) == 0 {
+				break
+			}
+			n += len(records)
+		}
+		if n != 20 {
+			t.Fatalf("run %d read %d records, want 20", run, n)
+		}
+	}
+	if len(c.Items()) != pageSize+1 {
+		t.Fatalf("exporting loaded %d items into the list", len(c.Items()))
+	}
+	c.LoadMore()
+	if c.RemainingRecords() != nil {
+		t.Fatalf("expected no source once every match is loaded")
+	}
+}
@@ -0,0 +1,364 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Export formats in addition to the record formats.
+const (
+	FormatJSON = "json"
+	FormatHTML = "html"
+)
+
+// ExportFormats lists the formats accepted by Export.
+var ExportFormats = []string{FormatNDJSON, FormatCSV, FormatJSON, FormatHTML}
+
+// MessageRecord converts a stored message for export.
+func MessageRecord(m Message) Record {
+	r := NewRecord(m.Timestamp, m.Topic, m.Payload, 0, m.Retained, m.Properties)
+	r.Kind = m.Kind
+	return r
+}
+
+// ItemRecord converts a history list entry for export. Log entries keep
+// their text as payload.
+func ItemRecord(it Item) Record {
+	r := NewRecord(it.Timestamp, it.Topic, []byte(it.Payload), 0, it.Retained, it.Properties)
+	r.Kind = it.Kind
+	return r
+}
+
+// SearchQuery returns all messages of st matching a filter query in the
+// ParseQuery syntax, including property filters.
+func SearchQuery(st Store, q string, archived bool) ([]Message, error) {
+	msgs, err := Collect(st, NewQuery(q, archived))
+	return FilterProperties(msgs, ParseProperties(q)), err
+}
+
+// FilterMessages applies a filter query to messages that are not kept in a
+// store, such as the messages of a trace.
+func FilterMessages(msgs []Message, q string) []Message {
+	out, _ := SearchQuery(&store{msgs: msgs}, q, false)
+	return out
+}
+
+// RecordSource yields the records of an export in batches. It returns no
+// records once it is exhausted.
+type RecordSource func() ([]Record, error)
+
+// Records returns a source yielding records in a single batch.
+func Records(records []Record) RecordSource {
+	return func() ([]Record, error) {
+		out := records
+		records = nil
+		return out, nil
+	}
+}
+
+// Concat returns a source yielding the records of srcs in turn. nil sources
+// are skipped.
+func Concat(srcs ...RecordSource) RecordSource {
+	return func() ([]Record, error) {
+		for len(srcs) > 0 {
+			if srcs[0] != nil {
+				records, err := srcs[0]()
+				if err != nil || len(records) > 0 {
+					return records, err
+				}
+			}
+			srcs = srcs[1:]
+		}
+		return nil, nil
+	}
+}
+
+// QueryRecords returns a source reading the messages of st that match q
+// and the property filters props one page at a time. Messages for which
+// skip reports true are left out; skip may be nil.
+func QueryRecords(st Store, q Query, props map[string]string, skip func(Message) bool) RecordSource {
+	q.Limit = pageSize
+	done := false
+	return func() ([]Record, error) {
+		for !done {
+			pg, err := st.Query(q)
+			if err != nil {
+				return nil, err
+			}
+			q.Cursor = pg.Next
+			done = pg.Next == ""
+			msgs := FilterProperties(pg.Messages, props)
+			if skip != nil {
+				msgs = slices.DeleteFunc(msgs, skip)
+			}
+			if len(msgs) == 0 {
+				continue
+			}
+			records := make([]Record, len(msgs))
+			for i, m := range msgs {
+				records[i] = MessageRecord(m)
+			}
+			return records, nil
+		}
+		return nil, nil
+	}
+}
+
+// Export writes the records of src to w in format, flushing after each
+// batch. columns select the CSV columns and title heads the HTML report.
+func Export(w io.Writer, format string, columns []string, title string, src RecordSource) error {
+	bw := bufio.NewWriter(w)
+	var write func([]Record) error
+	finish := func() error { return nil }
+	switch format {
+	case FormatJSON:
+		n := 0
+		write = func(records []Record) error {
+			for _, r := range records {
+				data, err := json.MarshalIndent(r, "  ", "  ")
+				if err != nil {
+					return err
+				}
+				sep := ",\n  "
+				if n == 0 {
+					sep = "[\n  "
+				}
+				n++
+				bw.WriteString(sep)
+				bw.Write(data)
+			}
+			return nil
+		}
+		finish = func() error {
+			if n == 0 {
+				_, err := bw.WriteString("[]\n")
+				return err
+			}
+			_, err := bw.WriteString("\n]\n")
+			return err
+		}
+	case FormatHTML:
+		rep := newReport(title, time.Now())
+		write = func(records []Record) error {
+			rep.add(records)
+			return nil
+		}
+		finish = func() error { return rep.render(bw) }
+	default:
+		rw, err := NewRecordWriter(bw, format, columns)
+		if err != nil {
+			return fmt.Errorf("unknown format %q (want %s)", format, strings.Join(ExportFormats, ", "))
+		}
+		write = func(records []Record) error {
+			for _, r := range records {
+				if err := rw.Write(r); err != nil {
+					return err
+				}
+			}
+			return rw.Flush()
+		}
+	}
+	for {
+		records, err := src()
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			break
+		}
+		if err := write(records); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ExportFile writes the records of src to a new file at path.
+func ExportFile(path, format string, columns []string, title string, src RecordSource) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Export(f, format, columns, title, src); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// ExportFileName suggests a file name for exporting name, such as a
+// profile or trace key, at now.
+func ExportFileName(name, format string, now time.Time) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+	if safe == "" {
+		safe = "history"
+	}
+	return fmt.Sprintf("emqutiti-%s-%s.%s", safe, now.Format("20060102-150405"), format)
+}
+
+// TopicCount summarises the records of one topic.
+type TopicCount struct {
+	Topic       string
+	Count       int
+	First, Last time.Time
+}
+
+// CountTopics returns per-topic counts of records, busiest topic first.
+func CountTopics(records []Record) []TopicCount {
+	var tc topicCounter
+	tc.add(records)
+	return tc.sorted()
+}
+
+// topicCounter accumulates TopicCounts batch by batch.
+type topicCounter struct {
+	idx    map[string]int
+	counts []TopicCount
+}
+
+func (c *topicCounter) add(records []Record) {
+	if c.idx == nil {
+		c.idx = map[string]int{}
+	}
+	for _, r := range records {
+		i, ok := c.idx[r.Topic]
+		if !ok {
+			i = len(c.counts)
+			c.idx[r.Topic] = i
+			c.counts = append(c.counts, TopicCount{Topic: r.Topic, First: r.Time, Last: r.Time})
+		}
+		tc := &c.counts[i]
+		tc.Count++
+		if r.Time.Before(tc.First) {
+			tc.First = r.Time
+		}
+		if r.Time.After(tc.Last) {
+			tc.Last = r.Time
+		}
+	}
+}
+
+func (c *topicCounter) sorted() []TopicCount {
+	out := slices.Clone(c.counts)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Topic < out[j].Topic
+	})
+	return out
+}
+
+// reportLimit caps the messages listed in an HTML report. The summary and
+// topic table still count every message.
+const reportLimit = 10000
+
+// report is the data of the HTML report template.
+type report struct {
+	Title       string
+	Generated   time.Time
+	Total       int
+	First, Last time.Time
+	Topics      []TopicCount
+	Records     []Record
+	topics      topicCounter
+}
+
+func newReport(title string, now time.Time) *report {
+	return &report{Title: title, Generated: now}
+}
+
+// add counts records and keeps them for the message table up to
+// reportLimit.
+func (rep *report) add(records []Record) {
+	rep.Total += len(records)
+	rep.topics.add(records)
+	for _, r := range records {
+		if rep.First.IsZero() || r.Time.Before(rep.First) {
+			rep.First = r.Time
+		}
+		if r.Time.After(rep.Last) {
+			rep.Last = r.Time
+		}
+	}
+	if n := reportLimit - len(rep.Records); n > 0 {
+		rep.Records = append(rep.Records, records[:min(n, len(records))]...)
+	}
+}
+
+const timeLayout = "2006-01-02 15:04:05.000 MST"
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ts": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(timeLayout)
+	},
+	"props": func(r Record) string { return strings.Join(r.Properties.Lines(), "\n") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+td.num { text-align: right; }
+pre { margin: 0; white-space: pre-wrap; word-break: break-all; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Total}} message(s){{if .Total}} from {{ts .First}} to {{ts .Last}}{{end}}. Generated {{ts .Generated}} by emqutiti.</p>
+<h2>Topics</h2>
+<table>
+<tr><th>Topic</th><th>Messages</th><th>First</th><th>Last</th></tr>
+{{- range .Topics}}
+<tr><td>{{.Topic}}</td><td class="num">{{.Count}}</td><td>{{ts .First}}</td><td>{{ts .Last}}</td></tr>
+{{- end}}
+</table>
+<h2>Messages</h2>
+{{- if lt (len .Records) .Total}}
+<p class="meta">Showing the first {{len .Records}} of {{.Total}} messages.</p>
+{{- end}}
+<table>
+<tr><th>Time</th><th>Kind</th><th>Topic</th><th>Retained</th><th>Payload</th><th>Properties</th></tr>
+{{- range .Records}}
+<tr><td>{{ts .Time}}</td><td>{{.Kind}}</td><td>{{.Topic}}</td><td>{{if .Retained}}yes{{end}}</td><td><pre>{{.Payload}}</pre>{{if .Encoding}} <span class="meta">({{.Encoding}})</span>{{end}}</td><td><pre>{{props .}}</pre></td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// render writes the report as a self-contained HTML page.
+func (rep *report) render(w io.Writer) error {
+	rep.Topics = rep.topics.sorted()
+	return reportTemplate.Execute(w, rep)
+}
@@ -0,0 +1,144 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportRecords() []Record {
+	ts := time.Unix(1700000000, 0).UTC()
+	return []Record{
+		MessageRecord(Message{Timestamp: ts, Topic: "a", Payload: []byte("<b>1</b>"), Kind: "sub"}),
+		MessageRecord(Message{Timestamp: ts.Add(time.Second), Topic: "b", Payload: []byte("2"), Kind: "pub", Retained: true}),
+		MessageRecord(Message{Timestamp: ts.Add(2 * time.Second), Topic: "a", Payload: []byte{0xff}, Kind: "sub"}),
+	}
+}
+
+func TestExportJSONIsIndentedArray(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, FormatJSON, nil, "", Records(exportRecords())); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "[\n  {") {
+		t.Fatalf("expected pretty JSON, got %q", buf.String())
+	}
+	var got []Record
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || len(got) != 3 || got[1].Kind != "pub" {
+		t.Fatalf("unexpected records %+v: %v", got, err)
+	}
+	buf.Reset()
+	Export(&buf, FormatJSON, nil, "", Records(nil))
+	if buf.String() != "[]\n" {
+		t.Fatalf("expected empty array, got %q", buf.String())
+	}
+}
+
+func TestExportHTMLReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, FormatHTML, nil, "Trace run1", Records(exportRecords())); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	html := buf.String()
+	for _, want := range []string{
+		"<title>Trace run1</title>",
+		`<tr><td>a</td><td class="num">2</td>`,
+		`<tr><td>b</td><td class="num">1</td>`,
+		"&lt;b&gt;1&lt;/b&gt;",
+		"(base64)",
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("expected %q in report:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "<b>1</b>") || strings.Contains(html, "<script") || strings.Contains(html, "<link") {
+		t.Fatalf("report must escape payloads and be self-contained")
+	}
+}
+
+func TestExportStreamsQueryPages(t *testing.T) {
+	hs := &store{}
+	base := time.Unix(1700000000, 0)
+	n := 2*pageSize + 1
+	for i := 0; i < n; i++ {
+		hs.Append(Message{Timestamp: base.Add(time.Duration(i) * time.Millisecond), Topic: "a", Payload: []byte("x"), Kind: "sub"})
+	}
+	hs.Append(Message{Timestamp: base, Topic: "b", Payload: []byte("y"), Kind: "sub"})
+
+	var buf bytes.Buffer
+	src := QueryRecords(hs, NewQuery("topic=a", false), nil, nil)
+	batches := 0
+	counted := func() ([]Record, error) {
+		if batches > 0 && buf.Len() == 0 {
+			t.Fatalf("batch %d read before the previous one was written", batches)
+		}
+		records, err := src()
+		if len(records) > pageSize {
+			t.Fatalf("batch of %d records exceeds the page size", len(records))
+		}
+		if len(records) > 0 {
+			batches++
+		}
+		return records, err
+	}
+	if err := Export(&buf, FormatNDJSON, nil, "", counted); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != n {
+		t.Fatalf("exported %d records, want %d", lines, n)
+	}
+	if batches != 3 {
+		t.Fatalf("read %d batches, want 3", batches)
+	}
+}
+
+func TestExportHTMLReportCapsListedMessages(t *testing.T) {
+	records := make([]Record, reportLimit+5)
+	for i := range records {
+		records[i] = Record{Topic: "a", Kind: "sub"}
+	}
+	var buf bytes.Buffer
+	if err := Export(&buf, FormatHTML, nil, "", Records(records)); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	html := buf.String()
+	if !strings.Contains(html, fmt.Sprintf("Showing the first %d of %d messages", reportLimit, len(records))) {
+		t.Fatalf("expected truncation note in report")
+	}
+	if !strings.Contains(html, fmt.Sprintf(`<td class="num">%d</td>`, len(records))) {
+		t.Fatalf("topic table must count every message")
+	}
+}
+
+func TestExportRejectsUnknownFormatAndColumn(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, "xml", nil, "", Records(nil)); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+	if _, err := NewRecordWriter(&buf, FormatCSV, []string{"nope"}); err == nil || !strings.Contains(err.Error(), "kind") {
+		t.Fatalf("expected column error listing kind, got %v", err)
+	}
+}
+
+func TestFilterMessagesUsesQuerySyntax(t *testing.T) {
+	ts := time.Unix(1700000000, 0).UTC()
+	msgs := []Message{
+		{Timestamp: ts, Topic: "sensors/1", Payload: []byte("ok")},
+		{Timestamp: ts, Topic: "sensors/2", Payload: []byte("error: low battery")},
+		{Timestamp: ts.Add(time.Hour), Topic: "sensors/2", Payload: []byte("error")},
+	}
+	got := FilterMessages(msgs, "topic=sensors/2 end=2023-11-14T22:30:00Z error")
+	if len(got) != 1 || string(got[0].Payload) != "error: low battery" {
+		t.Fatalf("unexpected result %+v", got)
+	}
+}
+
+func TestExportFileName(t *testing.T) {
+	got := ExportFileName("my trace/1", FormatCSV, time.Date(2025, 8, 5, 11, 47, 0, 0, time.UTC))
+	if got != "emqutiti-my_trace_1-20250805-114700.csv" {
+		t.Fatalf("got %q", got)
+	}
+}
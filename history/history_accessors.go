@@ -26,8 +26,12 @@ func (h *Component) Detail() *viewport.Model { return &h.detail }
 // DetailItem returns the item shown in the detail viewport.
 func (h *Component) DetailItem() Item { return h.detailItem }
 
-// SetDetailItem sets the item shown in the detail viewport.
-func (h *Component) SetDetailItem(it Item) { h.detailItem = it }
+// SetDetailItem sets the item shown in the detail viewport and resets the
+// payload view to text.
+func (h *Component) SetDetailItem(it Item) {
+	h.detailItem = it
+	h.detailView = ViewText
+}
 
 // ShowArchived reports whether archived messages are displayed.
 func (h *Component) ShowArchived() bool { return h.showArchived }
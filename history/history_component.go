@@ -2,6 +2,7 @@ package history
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/marang/emqutiti/constants"
 	"github.com/marang/emqutiti/internal/clipboardutil"
+	"github.com/marang/emqutiti/properties"
 	"github.com/marang/emqutiti/ui"
 )
 
@@ -27,6 +29,18 @@ type historyState struct {
 	filterQuery     string
 	detail          viewport.Model
 	detailItem      Item
+	detailView      PayloadView
+	// query reads the pages of the list; its cursor points at the next
+	// page. props are the property filters of the filter query.
+	query Query
+	props map[string]string
+	// live holds the keys of messages appended while pages remain, so
+	// later pages skip them.
+	live map[string]struct{}
+	// changes delivers changes of the store made elsewhere; stopWatch
+	// ends the watch.
+	changes   <-chan Change
+	stopWatch context.CancelFunc
 }
 
 // Component provides history browsing and filtering functionality. It holds its
@@ -50,9 +64,11 @@ func (h *Component) Update(msg tea.Msg) tea.Cmd {
 		if m.Action == tea.MouseActionPress && m.Button == tea.MouseButtonLeft {
 			h.HandleSelection(h.list.Index(), m.Shift)
 		}
+		h.LoadMore()
 		return cmd
 	}
 	h.list, cmd = h.list.Update(msg)
+	h.LoadMore()
 	return cmd
 }
 
@@ -77,6 +93,11 @@ func (h *Component) UpdateDetail(msg tea.Msg) tea.Cmd {
 			return tea.Quit
 		case constants.KeyCtrlC:
 			return h.copyDetailPayload()
+		case constants.KeyV:
+			h.detailView = h.detailView.Next()
+			h.detail.SetContent(FormatDetailAs(h.detailItem, h.detailView))
+			h.detail.SetYOffset(0)
+			return nil
 		}
 	}
 	h.detail, cmd = h.detail.Update(msg)
@@ -103,15 +124,9 @@ func (h *Component) UpdateFilter(msg tea.Msg) tea.Cmd {
 			}
 			h.filterForm = &form
 			h.showArchived = h.filterForm.archived.Bool()
-			q := h.filterForm.query()
-			topics, start, end, payload := ParseQuery(q)
-			msgs := h.store.Search(h.showArchived, topics, start, end, payload)
-			var items []list.Item
-			h.items, items = MessagesToItems(msgs)
-			h.list.SetItems(items)
+			h.Load(h.filterForm.query())
 			h.list.FilterInput.SetValue("")
 			h.list.SetFilterState(list.Unfiltered)
-			h.filterQuery = q
 			h.filterForm = nil
 			cmd := tea.Batch(h.m.SetMode(h.m.PreviousMode()), h.m.SetFocus(ID))
 			return cmd
@@ -125,7 +140,7 @@ func (h *Component) UpdateFilter(msg tea.Msg) tea.Cmd {
 // ViewDetail renders the full payload of a history message.
 func (h *Component) ViewDetail() string {
 	lines := strings.Split(h.detail.View(), "\n")
-	help := ui.InfoStyle.Render("[esc] back • [ctrl+c] copy")
+	help := ui.InfoStyle.Render(fmt.Sprintf("[esc] back • [v] view: %s • [ctrl+c] copy", h.detailView))
 	lines = append(lines, help)
 	content := strings.Join(lines, "\n")
 	sp := -1.0
@@ -148,6 +163,25 @@ func FormatDetailPayload(payload string) string {
 	return buf.String()
 }
 
+// FormatDetail renders the detail view text for it. MQTT 5 properties are
+// listed above the formatted payload.
+func FormatDetail(it Item) string { return FormatDetailAs(it, ViewText) }
+
+// FormatDetailAs renders the detail view with the payload shown as text,
+// hex dump or base64.
+func FormatDetailAs(it Item, v PayloadView) string {
+	payload := FormatPayloadAs([]byte(it.Payload), v)
+	lines := it.Properties.Lines()
+	if it.CorrelationID != "" {
+		lines = append([]string{"request: " + requestLabel(it)}, lines...)
+	}
+	if len(lines) == 0 {
+		return payload
+	}
+	head := ui.InfoStyle.Render("Properties")
+	return head + "\n" + strings.Join(lines, "\n") + "\n\n" + payload
+}
+
 // ViewFilter displays the history filter form.
 func (h *Component) ViewFilter() string {
 	if h.filterForm == nil {
@@ -162,7 +196,8 @@ func (h *Component) ViewFilter() string {
 // managed externally, so this returns an empty map.
 func (h *Component) Focusables() map[string]Focusable { return map[string]Focusable{} }
 
-// appendItems updates the history list with new items.
+// appendItems updates the history list with new items. With a filter the
+// loaded pages are read again so the list shows what the store matches.
 func (h *Component) appendItems(items ...Item) {
 	if h.showArchived {
 		return
@@ -171,36 +206,48 @@ func (h *Component) appendItems(items ...Item) {
 		if h.store == nil {
 			return
 		}
-		var listItems []list.Item
-		h.items, listItems = ApplyFilter(h.filterQuery, h.store, h.showArchived)
-		h.list.SetItems(listItems)
-		if len(listItems) > 0 {
-			h.list.Select(len(listItems) - 1)
-		} else {
-			h.list.Select(-1)
-		}
+		h.load(h.filterQuery, max(pageSize, len(h.items)+1))
+		h.list.Select(len(h.items) - 1)
 		return
 	}
-	h.items = append(h.items, items...)
-	listItems := make([]list.Item, len(h.items))
-	for i, it := range h.items {
-		listItems[i] = it
+	if h.query.Cursor != "" {
+		if h.live == nil {
+			h.live = map[string]struct{}{}
+		}
+		for _, it := range items {
+			h.live[messageKey(it.Topic, it.Timestamp)] = struct{}{}
+		}
 	}
-	h.list.SetItems(listItems)
-	h.list.Select(len(listItems) - 1)
+	h.items = append(h.items, items...)
+	h.setListItems()
+	h.list.Select(len(h.items) - 1)
 }
 
 // Append stores a message in the history list and optional store.
 func (h *Component) Append(topic, payload, kind string, retained bool, logText string) {
-	ts := time.Now()
-	text := payload
-	if kind == "log" {
+	h.AppendWithProperties(topic, payload, kind, retained, logText, nil)
+}
+
+// AppendWithProperties stores a message carrying MQTT 5 properties.
+func (h *Component) AppendWithProperties(topic, payload, kind string, retained bool, logText string, props *properties.Properties) {
+	h.AppendMessage(Message{Topic: topic, Payload: []byte(payload), Kind: kind, Retained: retained, Properties: props}, logText)
+}
+
+// AppendMessage stores msg in the history list and optional store. A zero
+// timestamp is set to the current time.
+func (h *Component) AppendMessage(msg Message, logText string) {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	ts := msg.Timestamp
+	text := string(msg.Payload)
+	if msg.Kind == "log" {
 		text = logText
 	}
-	hi := Item{Timestamp: ts, Topic: topic, Payload: text, Kind: kind, Archived: false, Retained: retained}
+	hi := Item{Timestamp: ts, Topic: msg.Topic, Payload: text, Kind: msg.Kind, Retained: msg.Retained, Properties: msg.Properties, CorrelationID: msg.CorrelationID, RTT: msg.RTT}
 	items := []Item{hi}
 	if h.store != nil {
-		if err := h.store.Append(Message{Timestamp: ts, Topic: topic, Payload: payload, Kind: kind, Archived: false, Retained: retained}); err != nil {
+		if err := h.store.Append(msg); err != nil {
 			fmt.Printf("history append error: %v\n", err)
 			msg := fmt.Sprintf("history append error: %v", err)
 			items = append(items, Item{Timestamp: ts, Topic: "", Payload: msg, Kind: "log"})
@@ -210,7 +257,7 @@ func (h *Component) Append(topic, payload, kind string, retained bool, logText s
 }
 
 func (h *Component) copyDetailPayload() tea.Cmd {
-	formatted := FormatDetailPayload(h.detailItem.Payload)
+	formatted := FormatPayloadAs([]byte(h.detailItem.Payload), h.detailView)
 	if err := clipboardutil.Copy(formatted); err != nil {
 		msg := fmt.Sprintf("history copy error: %v", err)
 		h.Append("", msg, "log", false, msg)
@@ -3,11 +3,14 @@ package history
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/marang/emqutiti/internal/clipboardutil"
+	"github.com/marang/emqutiti/properties"
 )
 
 func TestFormatDetailPayloadJSON(t *testing.T) {
@@ -30,6 +33,30 @@ func TestFormatDetailPayloadNonJSON(t *testing.T) {
 	}
 }
 
+func TestFormatDetailListsProperties(t *testing.T) {
+	it := Item{Payload: "plain", Properties: &properties.Properties{ResponseTopic: "reply", User: []properties.UserProperty{{Key: "tenant", Value: "abc"}}}}
+	got := FormatDetail(it)
+	for _, want := range []string{"Properties", "response-topic: reply", "user tenant=abc", "plain"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in detail %q", want, got)
+		}
+	}
+	if got := FormatDetail(Item{Payload: "plain"}); got != "plain" {
+		t.Fatalf("expected bare payload without properties, got %q", got)
+	}
+}
+
+func TestFormatDetailShowsRequestPairing(t *testing.T) {
+	reply := Item{Kind: "sub", Payload: "pong", CorrelationID: "1a2b", RTT: 42 * time.Millisecond}
+	if got := FormatDetail(reply); !strings.Contains(got, "request: reply 1a2b in 42ms") {
+		t.Fatalf("expected reply pairing in %q", got)
+	}
+	req := Item{Kind: "pub", Payload: "ping", CorrelationID: "1a2b"}
+	if got := FormatDetail(req); !strings.Contains(got, "request: request 1a2b") {
+		t.Fatalf("expected request label in %q", got)
+	}
+}
+
 func TestUpdateDetailCopyPayload(t *testing.T) {
 	originalCopy := clipboardutil.Copy
 	t.Cleanup(func() { clipboardutil.Copy = originalCopy })
@@ -58,3 +85,61 @@ func TestUpdateDetailCopyPayload(t *testing.T) {
 		t.Fatalf("expected copy log payload, got %q", items[0].Payload)
 	}
 }
+
+func TestUpdateDetailCyclesPayloadViews(t *testing.T) {
+	h := NewComponent(stubModel{}, nil)
+	h.Detail().Width, h.Detail().Height = 80, 5
+	it := Item{Payload: string([]byte{0xde, 0xad, 0xbe, 0xef}), Kind: "sub"}
+	h.SetDetailItem(it)
+	if got := FormatDetail(it); got != "0xdeadbeef" {
+		t.Fatalf("expected hex text for binary payload, got %q", got)
+	}
+	h.UpdateDetail(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+	if !strings.Contains(h.Detail().View(), "de ad be ef") || !strings.Contains(h.ViewDetail(), "view: hex") {
+		t.Fatalf("expected hex dump view")
+	}
+	h.UpdateDetail(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+	if !strings.Contains(h.Detail().View(), "3q2+7w==") {
+		t.Fatalf("expected base64 view, got %q", h.Detail().View())
+	}
+	h.SetDetailItem(it)
+	if h.detailView != ViewText {
+		t.Fatalf("new detail item should reset the view")
+	}
+}
+
+func TestMessageJSONPayloadSchema(t *testing.T) {
+	var legacy Message
+	if err := json.Unmarshal([]byte(`{"Topic":"a","Payload":"hi"}`), &legacy); err != nil {
+		t.Fatalf("unmarshal legacy: %v", err)
+	}
+	if string(legacy.Payload) != "hi" || legacy.Schema >= PayloadSchema {
+		t.Fatalf("unexpected legacy decode: %+v", legacy)
+	}
+	in := Message{Topic: "a", Payload: []byte{0, 1, 0xff}}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out Message
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !bytes.Equal(out.Payload, in.Payload) || out.Schema != PayloadSchema {
+		t.Fatalf("binary payload not round-tripped: %s", b)
+	}
+}
+
+func TestPayloadTextShowsBinaryAsHex(t *testing.T) {
+	cases := map[string]string{
+		"hello\n\tworld": "hello\n\tworld",
+		"\x01\x02":       "0x0102",
+		"a\x1b[2Jb":      "0x611b5b324a62",
+		"\xff":           "0xff",
+	}
+	for in, want := range cases {
+		if got := PayloadText([]byte(in)); got != want {
+			t.Errorf("PayloadText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
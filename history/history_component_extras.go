@@ -2,8 +2,13 @@ package history
 
 import tea "github.com/charmbracelet/bubbletea"
 
-// Scroll delegates mouse wheel handling to the configured scroller.
-func (h *Component) Scroll(msg tea.MouseMsg) tea.Cmd { return h.sc.Scroll(msg) }
+// Scroll delegates mouse wheel handling to the configured scroller and
+// loads the next page when the selection nears the end.
+func (h *Component) Scroll(msg tea.MouseMsg) tea.Cmd {
+	cmd := h.sc.Scroll(msg)
+	h.LoadMore()
+	return cmd
+}
 
 // CanScroll reports whether the configured scroller can scroll.
 func (h *Component) CanScroll() bool { return h.sc.CanScroll() }
@@ -54,6 +54,12 @@ func (d historyDelegate) Render(w io.Writer, m list.Model, index int, item list.
 	if hi.Retained && hi.Kind != "log" {
 		label += " (retained)"
 	}
+	if !hi.Properties.IsEmpty() && hi.Kind != "log" {
+		label += " (props)"
+	}
+	if hi.CorrelationID != "" && hi.Kind != "log" {
+		label += " (" + requestLabel(hi) + ")"
+	}
 	align := lipgloss.Left
 	if hi.Kind == "pub" {
 		align = lipgloss.Right
@@ -71,15 +77,16 @@ func (d historyDelegate) Render(w io.Writer, m list.Model, index int, item list.
 			lipgloss.NewStyle().Foreground(ui.ColGray).Render(" "+ts+":"))
 		lines = append(lines, lipgloss.PlaceHorizontal(innerWidth, align, header))
 	}
-	payload := strings.ReplaceAll(hi.Payload, "\r\n", "\n")
+	text := hi.Text()
+	payload := strings.ReplaceAll(text, "\r\n", "\n")
 	payload = strings.ReplaceAll(payload, "\n", "\u23ce")
 	more := utf8.RuneCountInString(payload) > historyPreviewLimit
 	if more {
 		payload = ansi.Truncate(payload, historyPreviewLimit, "")
 	}
-	trunc := ansi.Truncate(hi.Payload, innerWidth, "")
+	trunc := ansi.Truncate(text, innerWidth, "")
 	trunc = strings.NewReplacer("\r\n", "\u23ce", "\n", "\u23ce").Replace(trunc)
-	if more || lipgloss.Width(hi.Payload) > innerWidth {
+	if more || lipgloss.Width(text) > innerWidth {
 		if lipgloss.Width(trunc) >= innerWidth {
 			trunc = ansi.Truncate(trunc, innerWidth-1, "")
 		}
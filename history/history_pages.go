@@ -0,0 +1,130 @@
+package history
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+const (
+	// pageSize is the number of messages read from the store at a time.
+	pageSize = 500
+	// pageMargin loads the next page once the selection is this close to
+	// the end of the list.
+	pageMargin = 20
+)
+
+// Load replaces the list with the first page of stored messages matching
+// the filter query q.
+func (h *Component) Load(q string) { h.load(q, pageSize) }
+
+// load reads at least limit messages matching q, unless fewer match.
+func (h *Component) load(q string, limit int) {
+	h.filterQuery = q
+	h.query = NewQuery(q, h.showArchived)
+	h.props = ParseProperties(q)
+	h.live = nil
+	h.items = nil
+	if h.store != nil {
+		for len(h.items) < limit {
+			msgs, err := h.nextPage(limit - len(h.items))
+			items, _ := MessagesToItems(msgs)
+			h.items = append(h.items, items...)
+			if err != nil {
+				h.items = append(h.items, loadErrorItem(err))
+				break
+			}
+			if h.query.Cursor == "" {
+				break
+			}
+		}
+	}
+	h.setListItems()
+}
+
+// LoadMore reads the next page of the current query once the selection
+// nears the end of the list. Messages appended meanwhile stay at the end.
+func (h *Component) LoadMore() {
+	if h.store == nil || h.query.Cursor == "" || h.list.Index() < len(h.items)-pageMargin {
+		return
+	}
+	msgs, err := h.nextPage(pageSize)
+	items, _ := MessagesToItems(msgs)
+	if err != nil {
+		items = append(items, loadErrorItem(err))
+	}
+	at := len(h.items)
+	for i, it := range h.items {
+		if _, ok := h.live[messageKey(it.Topic, it.Timestamp)]; ok {
+			at = i
+			break
+		}
+	}
+	idx := h.list.Index()
+	h.items = slices.Insert(h.items, at, items...)
+	if h.query.Cursor == "" {
+		h.live = nil
+	}
+	h.setListItems()
+	if idx >= at {
+		idx += len(items)
+	}
+	h.list.Select(idx)
+}
+
+// RemainingRecords returns a function that starts reading the messages of
+// the current query that are not loaded yet, one page at a time, for
+// export. It returns nil when every match is loaded.
+func (h *Component) RemainingRecords() func() RecordSource {
+	if h.store == nil || h.query.Cursor == "" {
+		return nil
+	}
+	st, q, props, live := h.store, h.query, h.props, h.live
+	skip := func(m Message) bool {
+		_, ok := live[messageKey(m.Topic, m.Timestamp)]
+		return ok
+	}
+	return func() RecordSource { return QueryRecords(st, q, props, skip) }
+}
+
+// nextPage reads pages of the current query until one leaves messages
+// after the property filters, or the query is exhausted.
+func (h *Component) nextPage(limit int) ([]Message, error) {
+	q := h.query
+	q.Limit = limit
+	for {
+		pg, err := h.store.Query(q)
+		if err != nil {
+			return nil, err
+		}
+		h.query.Cursor, q.Cursor = pg.Next, pg.Next
+		msgs := FilterProperties(pg.Messages, h.props)
+		msgs = slices.DeleteFunc(msgs, h.isLive)
+		if len(msgs) > 0 || pg.Next == "" {
+			return msgs, nil
+		}
+	}
+}
+
+// isLive reports whether m was appended after the current query was
+// loaded and is therefore already listed.
+func (h *Component) isLive(m Message) bool {
+	_, ok := h.live[messageKey(m.Topic, m.Timestamp)]
+	return ok
+}
+
+// setListItems shows h.items in the list.
+func (h *Component) setListItems() {
+	items := make([]list.Item, len(h.items))
+	for i, it := range h.items {
+		items[i] = it
+	}
+	h.list.SetItems(items)
+}
+
+func loadErrorItem(err error) Item {
+	msg := fmt.Sprintf("history load error: %v", err)
+	return Item{Timestamp: time.Now(), Topic: "", Payload: msg, Kind: "log"}
+}
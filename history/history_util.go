@@ -9,31 +9,18 @@ func MessagesToItems(msgs []Message) ([]Item, []list.Item) {
 	litems := make([]list.Item, len(msgs))
 	for i, m := range msgs {
 		hi := Item{
-			Timestamp: m.Timestamp,
-			Topic:     m.Topic,
-			Payload:   m.Payload,
-			Kind:      m.Kind,
-			Archived:  m.Archived,
-			Retained:  m.Retained,
+			Timestamp:     m.Timestamp,
+			Topic:         m.Topic,
+			Payload:       string(m.Payload),
+			Kind:          m.Kind,
+			Archived:      m.Archived,
+			Retained:      m.Retained,
+			Properties:    m.Properties,
+			CorrelationID: m.CorrelationID,
+			RTT:           m.RTT,
 		}
 		hitems[i] = hi
 		litems[i] = hi
 	}
 	return hitems, litems
 }
-
-// ApplyFilter parses the query and retrieves matching messages from the
-// store.
-func ApplyFilter(q string, store Store, archived bool) ([]Item, []list.Item) {
-	if store == nil {
-		return nil, nil
-	}
-	topics, start, end, payload := ParseQuery(q)
-	var msgs []Message
-	if archived {
-		msgs = store.Search(true, topics, start, end, payload)
-	} else {
-		msgs = store.Search(false, topics, start, end, payload)
-	}
-	return MessagesToItems(msgs)
-}
@@ -14,6 +14,7 @@ import (
 const (
 	idxFilterTopic = iota
 	idxFilterPayload
+	idxFilterProps
 	idxFilterStart
 	idxFilterEnd
 	idxFilterArchived
@@ -26,6 +27,7 @@ type historyFilterForm struct {
 	ui.Form
 	topic    *ui.SuggestField
 	payload  *ui.TextField
+	props    *ui.TextField
 	start    *ui.TextField
 	end      *ui.TextField
 	archived *ui.CheckField
@@ -38,6 +40,9 @@ func (f *historyFilterForm) Topic() *ui.SuggestField { return f.topic }
 // Payload returns the payload field.
 func (f *historyFilterForm) Payload() *ui.TextField { return f.payload }
 
+// Props returns the property filter field.
+func (f *historyFilterForm) Props() *ui.TextField { return f.props }
+
 // Start returns the start time field.
 func (f *historyFilterForm) Start() *ui.TextField { return f.start }
 
@@ -49,7 +54,8 @@ func (f *historyFilterForm) Archived() *ui.CheckField { return f.archived }
 
 // newHistoryFilterForm builds a form with optional prefilled values.
 // Start and end remain blank when zero, allowing searches across all time.
-func newHistoryFilterForm(topics []string, topic, payload string, start, end time.Time, archived bool) historyFilterForm {
+// props prefills the property filters as returned by ParseProperties.
+func newHistoryFilterForm(topics []string, topic, payload string, props map[string]string, start, end time.Time, archived bool) historyFilterForm {
 	sort.Strings(topics)
 	tf := ui.NewSuggestField(topics, "topic")
 	tf.SetValue(topic)
@@ -57,6 +63,9 @@ func newHistoryFilterForm(topics []string, topic, payload string, start, end tim
 	pf := ui.NewTextField("", "text contains")
 	pf.SetValue(payload)
 
+	prf := ui.NewTextField("", "key=value ...")
+	prf.SetValue(formatPropertyFilters(props))
+
 	sf := ui.NewTextField("", fmt.Sprintf("Start (%s)", dateFormatPlaceholder), ui.WithRFC3339())
 	if !start.IsZero() {
 		sf.SetValue(start.Format(time.RFC3339))
@@ -70,9 +79,10 @@ func newHistoryFilterForm(topics []string, topic, payload string, start, end tim
 	af := ui.NewCheckField(archived)
 
 	f := historyFilterForm{
-		Form:     ui.Form{Fields: []ui.Field{tf, pf, sf, ef, af}},
+		Form:     ui.Form{Fields: []ui.Field{tf, pf, prf, sf, ef, af}},
 		topic:    tf,
 		payload:  pf,
+		props:    prf,
 		start:    sf,
 		end:      ef,
 		archived: af,
@@ -82,8 +92,19 @@ func newHistoryFilterForm(topics []string, topic, payload string, start, end tim
 }
 
 // NewFilterForm builds a history filter form with optional prefilled values.
-func NewFilterForm(topics []string, topic, payload string, start, end time.Time, archived bool) historyFilterForm {
-	return newHistoryFilterForm(topics, topic, payload, start, end, archived)
+func NewFilterForm(topics []string, topic, payload string, props map[string]string, start, end time.Time, archived bool) historyFilterForm {
+	return newHistoryFilterForm(topics, topic, payload, props, start, end, archived)
+}
+
+// formatPropertyFilters renders property filters as sorted "key=value"
+// tokens for the filter form.
+func formatPropertyFilters(props map[string]string) string {
+	parts := make([]string, 0, len(props))
+	for k, v := range props {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
 }
 
 // Update handles focus cycling and topic completion.
@@ -125,6 +146,8 @@ func (f historyFilterForm) View() string {
 		"",
 		fmt.Sprintf("Text:  %s", f.payload.View()),
 		"",
+		fmt.Sprintf("Props: %s", f.props.View()),
+		"",
 		fmt.Sprintf("Start: %s", f.start.View()),
 		"",
 		fmt.Sprintf("End:   %s", f.end.View()),
@@ -171,6 +194,9 @@ func (f historyFilterForm) query() string {
 	if v := f.payload.Value(); v != "" {
 		parts = append(parts, "payload="+v)
 	}
+	for _, p := range strings.Fields(f.props.Value()) {
+		parts = append(parts, propPrefix+strings.TrimPrefix(p, propPrefix))
+	}
 	if v := f.start.Value(); v != "" {
 		parts = append(parts, "start="+v)
 	}
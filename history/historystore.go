@@ -4,13 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 	"time"
 
 	connections "github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/properties"
 	"github.com/marang/emqutiti/proxy"
-	"github.com/sahilm/fuzzy"
 	"google.golang.org/grpc"
 )
 
@@ -18,6 +19,9 @@ var proxyAddr string
 
 const proxyRPCTimeout = 5 * time.Second
 
+// queryRPCTimeout bounds queries, which may scan a whole profile.
+const queryRPCTimeout = 30 * time.Second
+
 // SetProxyAddr configures the DB proxy address.
 func SetProxyAddr(addr string) { proxyAddr = addr }
 
@@ -32,27 +36,43 @@ func proxyContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), proxyRPCTimeout)
 }
 
-// Message holds a timestamped MQTT message with optional payload text.
+// Message holds a timestamped MQTT message with its raw payload.
 type Message struct {
 	Timestamp time.Time
 	Topic     string
-	Payload   string
+	Payload   []byte
 	Kind      string
 	Archived  bool
 	Retained  bool
+	// Properties holds MQTT 5 publish properties, if any.
+	Properties *properties.Properties `json:",omitempty"`
+	// CorrelationID pairs a request with its reply.
+	CorrelationID string `json:",omitempty"`
+	// RTT is the round-trip time of a reply to a request.
+	RTT time.Duration `json:",omitempty"`
+	// Schema is the payload encoding the message was stored with.
+	Schema int `json:",omitempty"`
 }
 
-// store stores messages in memory and optionally persists them to disk.
+// store keeps messages in the DB proxy, or in memory when it is not
+// backed by one.
 type store struct {
-	mu      sync.RWMutex
+	mu sync.RWMutex
+	// msgs holds the messages of stores without a proxy.
 	msgs    []Message
 	cl      proxy.DBProxyClient
 	conn    *grpc.ClientConn
 	profile string
+	// counts caches the number of archived and unarchived messages kept
+	// by the proxy.
+	counts map[bool]int
+	// origin tags the writes of this store so Watch can skip them.
+	origin string
 }
 
 // openStore opens (or creates) a persistent message index for the given profile.
-// If profile is empty, "default" is used.
+// If profile is empty, "default" is used. Messages stay in the proxy and
+// are read page by page with Query.
 func openStore(profile string) (Store, error) {
 	if profile == "" {
 		profile = "default"
@@ -65,22 +85,13 @@ func openStore(profile string) (Store, error) {
 	if err != nil {
 		return nil, err
 	}
-	idx := &store{cl: cl, conn: conn, profile: profile}
-	ctx, cancel := proxyContext()
-	defer cancel()
-	resp, err := cl.Read(ctx, &proxy.ReadRequest{Profile: profile, Bucket: "history", Key: ""})
+	idx := &store{cl: cl, conn: conn, profile: profile, counts: map[bool]int{}, origin: newOrigin()}
+	n, err := idx.count(false)
 	if err != nil {
 		conn.Close()
 		return nil, err
 	}
-	for _, v := range resp.Values {
-		var m Message
-		if err := json.Unmarshal(v, &m); err != nil {
-			conn.Close()
-			return nil, err
-		}
-		idx.msgs = append(idx.msgs, m)
-	}
+	idx.counts[false] = n
 	return idx, nil
 }
 
@@ -96,18 +107,21 @@ func (i *store) Close() error {
 func (i *store) Append(msg Message) error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	i.msgs = append(i.msgs, msg)
-	if i.cl != nil {
-		key := fmt.Sprintf("%s/%020d", msg.Topic, msg.Timestamp.UnixNano())
-		val, err := json.Marshal(msg)
-		if err != nil {
-			return err
-		}
-		ctx, cancel := proxyContext()
-		defer cancel()
-		if _, err := i.cl.Write(ctx, &proxy.WriteRequest{Profile: i.profile, Bucket: "history", Key: key, Value: val}); err != nil {
-			return err
-		}
+	if i.cl == nil {
+		i.msgs = append(i.msgs, msg)
+		return nil
+	}
+	val, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := proxyContext()
+	defer cancel()
+	if _, err := i.cl.Write(ctx, &proxy.WriteRequest{Profile: i.profile, Bucket: "history", Key: messageKey(msg.Topic, msg.Timestamp), Value: val, Origin: i.origin}); err != nil {
+		return err
+	}
+	if n, ok := i.counts[msg.Archived]; ok {
+		i.counts[msg.Archived] = n + 1
 	}
 	return nil
 }
@@ -121,14 +135,15 @@ func (i *store) Delete(key string) error {
 	if i.cl != nil {
 		ctx, cancel := proxyContext()
 		defer cancel()
-		if _, err := i.cl.Delete(ctx, &proxy.DeleteRequest{Profile: i.profile, Bucket: "history", Key: key}); err != nil {
+		if _, err := i.cl.Delete(ctx, &proxy.DeleteRequest{Profile: i.profile, Bucket: "history", Key: key, Origin: i.origin}); err != nil {
 			return err
 		}
+		clear(i.counts)
+		return nil
 	}
 
 	for idx, m := range i.msgs {
-		k := fmt.Sprintf("%s/%020d", m.Topic, m.Timestamp.UnixNano())
-		if k == key {
+		if messageKey(m.Topic, m.Timestamp) == key {
 			i.msgs = append(i.msgs[:idx], i.msgs[idx+1:]...)
 			break
 		}
@@ -142,100 +157,141 @@ func (i *store) Archive(key string) error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
+	if i.cl != nil {
+		return i.archiveStored(key)
+	}
 	for idx, m := range i.msgs {
-		k := fmt.Sprintf("%s/%020d", m.Topic, m.Timestamp.UnixNano())
-		if k == key {
-			m.Archived = true
-			if i.cl != nil {
-				val, err := json.Marshal(m)
-				if err != nil {
-					return err
-				}
-				ctx, cancel := proxyContext()
-				defer cancel()
-				if _, err := i.cl.Write(ctx, &proxy.WriteRequest{Profile: i.profile, Bucket: "history", Key: key, Value: val}); err != nil {
-					return err
-				}
-			}
-			i.msgs[idx] = m
+		if messageKey(m.Topic, m.Timestamp) == key {
+			i.msgs[idx].Archived = true
 			return nil
 		}
 	}
 	return fmt.Errorf("message %s not found", key)
 }
 
+// archiveStored rewrites the message at key in the proxy as archived.
+func (i *store) archiveStored(key string) error {
+	ctx, cancel := proxyContext()
+	defer cancel()
+	resp, err := i.cl.Read(ctx, &proxy.ReadRequest{Profile: i.profile, Bucket: "history", Key: key})
+	if err != nil {
+		return err
+	}
+	if len(resp.GetValues()) == 0 {
+		return fmt.Errorf("message %s not found", key)
+	}
+	var m Message
+	if err := json.Unmarshal(resp.GetValues()[0], &m); err != nil {
+		return err
+	}
+	m.Archived = true
+	val, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if _, err := i.cl.Write(ctx, &proxy.WriteRequest{Profile: i.profile, Bucket: "history", Key: key, Value: val, Origin: i.origin}); err != nil {
+		return err
+	}
+	clear(i.counts)
+	return nil
+}
+
 // fuzzyMatchTopic reports whether the message topic fuzzy-matches any of the
 // provided patterns. An empty pattern list matches all topics.
 func fuzzyMatchTopic(topic string, patterns []string) bool {
-	if len(patterns) == 0 {
-		return true
+	return proxy.MatchTopic(topic, patterns)
+}
+
+// Query returns one page of messages matching q. The proxy filters the
+// messages.
+func (i *store) Query(q Query) (Page, error) {
+	if i.cl == nil {
+		i.mu.RLock()
+		defer i.mu.RUnlock()
+		return q.Select(i.msgs), nil
 	}
-	for _, p := range patterns {
-		if p == "" {
-			continue
+	ctx, cancel := context.WithTimeout(context.Background(), queryRPCTimeout)
+	defer cancel()
+	stream, err := i.cl.Query(ctx, q.request(i.profile))
+	if err != nil {
+		return Page{}, err
+	}
+	var pg Page
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return pg, nil
 		}
-		// Exact match takes priority.
-		if topic == p {
-			return true
+		if err != nil {
+			return Page{}, err
+		}
+		for _, rec := range resp.GetRecords() {
+			var m Message
+			if err := json.Unmarshal(rec.GetValue(), &m); err != nil {
+				return Page{}, fmt.Errorf("decode %s: %w", rec.GetKey(), err)
+			}
+			pg.Messages = append(pg.Messages, m)
 		}
-		// Fuzzy match: pattern should match within topic.
-		matches := fuzzy.Find(p, []string{topic})
-		if len(matches) > 0 {
-			return true
+		if next := resp.GetNextCursor(); next != "" {
+			pg.Next = next
 		}
 	}
-	return false
 }
 
-// Search returns messages matching the provided filters. Zero timestamps
-// disable the corresponding time constraints. When archived is true, only
-// archived messages are returned. Topic filtering uses fuzzy matching.
+// Search returns all messages matching the provided filters. Zero
+// timestamps disable the corresponding time constraints. When archived is
+// true, only archived messages are returned. Topic filtering uses fuzzy
+// matching.
 func (i *store) Search(archived bool, topics []string, start, end time.Time, payload string) []Message {
-	i.mu.RLock()
-	defer i.mu.RUnlock()
+	msgs, _ := Collect(i, Query{Archived: archived, Topics: topics, Start: start, End: end, Payload: payload})
+	return msgs
+}
 
-	var out []Message
-	// Filter out empty topic patterns.
-	var topicPatterns []string
-	for _, t := range topics {
-		if t != "" {
-			topicPatterns = append(topicPatterns, t)
-		}
+// count asks the proxy for the number of archived or unarchived messages.
+func (i *store) count(archived bool) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryRPCTimeout)
+	defer cancel()
+	stream, err := i.cl.Query(ctx, &proxy.QueryRequest{Profile: i.profile, Bucket: "history", Archived: archived, CountOnly: true})
+	if err != nil {
+		return 0, err
 	}
-
-	for _, m := range i.msgs {
-		if m.Archived != archived {
-			continue
-		}
-		if !fuzzyMatchTopic(m.Topic, topicPatterns) {
-			continue
-		}
-		if !start.IsZero() && m.Timestamp.Before(start) {
-			continue
+	n := 0
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return n, nil
 		}
-		if !end.IsZero() && m.Timestamp.After(end) {
-			continue
-		}
-		if payload != "" && !strings.Contains(m.Payload, payload) {
-			continue
+		if err != nil {
+			return 0, err
 		}
-		out = append(out, m)
+		n += int(resp.GetCount())
 	}
-	return out
 }
 
 // Count reports the number of stored messages. When archived is true,
 // only archived messages are counted; otherwise only unarchived messages
-// are included.
+// are included. Counts from the proxy are cached until messages are
+// deleted or archived.
 func (i *store) Count(archived bool) int {
-	i.mu.RLock()
-	defer i.mu.RUnlock()
-	c := 0
-	for _, m := range i.msgs {
-		if m.Archived == archived {
-			c++
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cl == nil {
+		c := 0
+		for _, m := range i.msgs {
+			if m.Archived == archived {
+				c++
+			}
 		}
+		return c
+	}
+	if c, ok := i.counts[archived]; ok {
+		return c
+	}
+	c, err := i.count(archived)
+	if err != nil {
+		return 0
 	}
+	i.counts[archived] = c
 	return c
 }
 
@@ -244,11 +300,14 @@ func (i *store) Count(archived bool) int {
 //	"topic=a,b start=2023-01-02T15:04:05Z end=2023-01-02T16:00 payload=foo".
 //
 // Fields may appear in any order and are optional. Unrecognised tokens are
-// treated as payload search text.
+// treated as payload search text. Property filters ("prop.key=value") are
+// returned by ParseProperties instead.
 func ParseQuery(q string) (topics []string, start, end time.Time, payload string) {
 	var payloadParts []string
 	for _, f := range strings.Fields(q) {
 		switch {
+		case strings.HasPrefix(f, propPrefix):
+			// handled by ParseProperties
 		case strings.HasPrefix(f, "topic="):
 			ts := strings.TrimPrefix(f, "topic=")
 			if ts != "" {
@@ -273,3 +332,50 @@ func ParseQuery(q string) (topics []string, start, end time.Time, payload string
 	payload = strings.Join(payloadParts, " ")
 	return
 }
+
+const propPrefix = "prop."
+
+// ParseProperties extracts "prop.key=value" filters from q. An empty value
+// matches any message carrying the property.
+func ParseProperties(q string) map[string]string {
+	var props map[string]string
+	for _, f := range strings.Fields(q) {
+		if !strings.HasPrefix(f, propPrefix) {
+			continue
+		}
+		k, v, _ := strings.Cut(strings.TrimPrefix(f, propPrefix), "=")
+		if k == "" {
+			continue
+		}
+		if props == nil {
+			props = map[string]string{}
+		}
+		props[k] = v
+	}
+	return props
+}
+
+// matchProperties reports whether p satisfies all property filters.
+func matchProperties(p *properties.Properties, filters map[string]string) bool {
+	for k, want := range filters {
+		v, ok := p.Lookup(k)
+		if !ok || (want != "" && v != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterProperties returns the messages whose properties satisfy filters.
+func FilterProperties(msgs []Message, filters map[string]string) []Message {
+	if len(filters) == 0 {
+		return msgs
+	}
+	var out []Message
+	for _, m := range msgs {
+		if matchProperties(m.Properties, filters) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
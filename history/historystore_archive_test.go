@@ -9,7 +9,7 @@ import (
 func TestArchiveAndSearch(t *testing.T) {
 	hs := &store{}
 	ts := time.Now()
-	msg := Message{Timestamp: ts, Topic: "t1", Payload: "p1", Kind: "pub", Retained: false}
+	msg := Message{Timestamp: ts, Topic: "t1", Payload: []byte("p1"), Kind: "pub", Retained: false}
 	if err := hs.Append(msg); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
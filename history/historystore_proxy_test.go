@@ -1,6 +1,7 @@
 package history
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 	"time"
@@ -22,7 +23,7 @@ func TestOpenStoreProxy(t *testing.T) {
 	if err != nil {
 		t.Fatalf("open: %v", err)
 	}
-	msg := Message{Timestamp: time.Now(), Topic: "t1", Payload: "p1", Kind: "pub", Retained: false}
+	msg := Message{Timestamp: time.Now(), Topic: "t1", Payload: []byte("p1"), Kind: "pub", Retained: false}
 	if err := st.Append(msg); err != nil {
 		t.Fatalf("append: %v", err)
 	}
@@ -35,8 +36,163 @@ func TestOpenStoreProxy(t *testing.T) {
 		t.Fatalf("reopen: %v", err)
 	}
 	defer st2.Close()
-	msgs := st2.Search(false, []string{"t1"}, time.Time{}, time.Time{}, "")
-	if len(msgs) != 1 || msgs[0].Topic != "t1" || msgs[0].Payload != "p1" {
+	msgs, err := Collect(st2, Query{Topics: []string{"t1"}})
+	if err != nil || len(msgs) != 1 || msgs[0].Topic != "t1" || string(msgs[0].Payload) != "p1" {
 		t.Fatalf("expected persisted message for key %s, got %v", key, msgs)
 	}
 }
+
+func TestOpenStoreReadsLegacyPayloads(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	SetProxyAddr(p.Addr())
+	t.Cleanup(p.Stop)
+
+	cl, conn, err := proxy.NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	ts := time.Now()
+	key := fmt.Sprintf("legacy/%020d", ts.UnixNano())
+	legacy := fmt.Sprintf(`{"Timestamp":%q,"Topic":"legacy","Payload":"héllo","Kind":"sub"}`, ts.Format(time.RFC3339Nano))
+	ctx, cancel := proxyContext()
+	defer cancel()
+	if _, err := cl.Write(ctx, &proxy.WriteRequest{Profile: "test", Bucket: "history", Key: key, Value: []byte(legacy)}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	st, err := openStore("test")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	msgs, err := Collect(st, Query{Topics: []string{"legacy"}})
+	if err != nil || len(msgs) != 1 || string(msgs[0].Payload) != "héllo" {
+		t.Fatalf("legacy payload not decoded: %v %v", msgs, err)
+	}
+	bin := Message{Timestamp: ts.Add(time.Second), Topic: "bin", Payload: []byte{0x00, 0xff, 0x10}, Kind: "sub"}
+	if err := st.Append(bin); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	st.Close()
+
+	st2, err := openStore("test")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer st2.Close()
+	msgs, err = Collect(st2, Query{Topics: []string{"bin"}})
+	if err != nil || len(msgs) != 1 || !bytes.Equal(msgs[0].Payload, bin.Payload) {
+		t.Fatalf("binary payload not preserved: %v", msgs)
+	}
+}
+
+func TestStoreQueryPagesThroughProxy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	SetProxyAddr(p.Addr())
+	t.Cleanup(p.Stop)
+
+	st, err := openStore("test")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+	base := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		msg := Message{Timestamp: base.Add(time.Duration(i) * time.Second), Topic: "sensor/temp", Payload: []byte(fmt.Sprintf("v%d", i)), Kind: "sub"}
+		if err := st.Append(msg); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := st.Append(Message{Timestamp: base, Topic: "door", Payload: []byte("open"), Kind: "pub"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if n := st.Count(false); n != 6 {
+		t.Fatalf("count = %d, want 6", n)
+	}
+
+	q := Query{Topics: []string{"temp"}, Limit: 2}
+	var got []string
+	for pages := 1; ; pages++ {
+		pg, err := st.Query(q)
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		for _, m := range pg.Messages {
+			got = append(got, string(m.Payload))
+		}
+		if pg.Next == "" {
+			if pages != 3 {
+				t.Fatalf("read %d pages, want 3", pages)
+			}
+			break
+		}
+		q.Cursor = pg.Next
+	}
+	if want := "v0 v1 v2 v3 v4"; fmt.Sprint(got) != "["+want+"]" {
+		t.Fatalf("pages = %v, want %s", got, want)
+	}
+
+	if err := st.Archive(fmt.Sprintf("door/%020d", base.UnixNano())); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if n := st.Count(false); n != 5 {
+		t.Fatalf("count after archive = %d, want 5", n)
+	}
+	pg, err := st.Query(Query{Archived: true, Kinds: []string{"pub"}})
+	if err != nil || len(pg.Messages) != 1 || !pg.Messages[0].Archived {
+		t.Fatalf("archived query: %v %v", pg.Messages, err)
+	}
+}
+
+func TestStoreImportMovesCollidingMessages(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	SetProxyAddr(p.Addr())
+	t.Cleanup(p.Stop)
+
+	st, err := openStore("test")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+	base := time.Unix(1700000000, 0)
+	if err := st.Append(Message{Timestamp: base, Topic: "t", Payload: []byte("live"), Kind: "sub"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	// More than one batch, with the first message on the stored key and
+	// the second on the nanosecond it would move to.
+	msgs := make([]Message, 2*importBatchSize+10)
+	for i := range msgs {
+		msgs[i] = Message{Timestamp: base.Add(time.Duration(i) * time.Millisecond), Topic: "t", Payload: []byte(fmt.Sprintf("v%d", i)), Kind: "sub"}
+	}
+	msgs[1].Timestamp = base.Add(time.Nanosecond)
+	moved, err := Import(st, msgs)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if moved != 1 {
+		t.Fatalf("moved = %d, want 1", moved)
+	}
+	got, err := Collect(st, Query{Topics: []string{"t"}})
+	if err != nil || len(got) != len(msgs)+1 {
+		t.Fatalf("stored %d messages, want %d: %v", len(got), len(msgs)+1, err)
+	}
+	if string(got[0].Payload) != "live" || string(got[2].Payload) != "v0" || !got[2].Timestamp.Equal(base.Add(2*time.Nanosecond)) {
+		t.Fatalf("colliding message not moved past the stored ones: %+v", got[:3])
+	}
+	if n := st.Count(false); n != len(msgs)+1 {
+		t.Fatalf("count = %d, want %d", n, len(msgs)+1)
+	}
+}
@@ -1,9 +1,13 @@
 package history
 
 import (
+	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/marang/emqutiti/properties"
 )
 
 func TestParseQuery(t *testing.T) {
@@ -79,22 +83,22 @@ func TestParseQuery(t *testing.T) {
 	}
 }
 
-func TestApplyFilterArchived(t *testing.T) {
+func TestSearchQueryArchived(t *testing.T) {
 	hs := &store{}
 	ts := time.Now()
-	if err := hs.Append(Message{Timestamp: ts, Topic: "t1", Payload: "active", Kind: "pub", Retained: false}); err != nil {
+	if err := hs.Append(Message{Timestamp: ts, Topic: "t1", Payload: []byte("active"), Kind: "pub", Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
-	if err := hs.Append(Message{Timestamp: ts.Add(time.Second), Topic: "t2", Payload: "arch", Kind: "pub", Archived: true, Retained: false}); err != nil {
+	if err := hs.Append(Message{Timestamp: ts.Add(time.Second), Topic: "t2", Payload: []byte("arch"), Kind: "pub", Archived: true, Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
 
-	items, _ := ApplyFilter("", hs, false)
+	items, _ := SearchQuery(hs, "", false)
 	if len(items) != 1 || items[0].Archived {
 		t.Fatalf("expected 1 unarchived item, got %v", items)
 	}
 
-	items, _ = ApplyFilter("", hs, true)
+	items, _ = SearchQuery(hs, "", true)
 	if len(items) != 1 || !items[0].Archived {
 		t.Fatalf("expected 1 archived item, got %v", items)
 	}
@@ -105,7 +109,7 @@ func TestAppendWhileFilteredUsesFilteredStoreResults(t *testing.T) {
 	h := NewComponent(stubModel{}, hs)
 	h.filterQuery = "payload=match"
 	ts := time.Now()
-	if err := hs.Append(Message{Timestamp: ts, Topic: "t1", Payload: "match", Kind: "pub"}); err != nil {
+	if err := hs.Append(Message{Timestamp: ts, Topic: "t1", Payload: []byte("match"), Kind: "pub"}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
 
@@ -130,3 +134,63 @@ func TestAppendWhileFilteredExcludesNonMatchingMessage(t *testing.T) {
 		t.Fatalf("expected non-matching append hidden by active filter, got %d: %#v", got, h.Items())
 	}
 }
+
+func TestParseProperties(t *testing.T) {
+	q := "topic=a prop.tenant=abc prop.content-type= hello prop.=x"
+	got := ParseProperties(q)
+	want := map[string]string{"tenant": "abc", "content-type": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if _, _, _, payload := ParseQuery(q); payload != "hello" {
+		t.Fatalf("property filters leaked into payload: %q", payload)
+	}
+	if ParseProperties("topic=a") != nil {
+		t.Fatalf("expected no property filters")
+	}
+}
+
+func TestSearchQueryProperties(t *testing.T) {
+	hs := &store{}
+	ts := time.Now()
+	abc := &properties.Properties{ContentType: "text/plain", User: []properties.UserProperty{{Key: "tenant", Value: "abc"}}}
+	def := &properties.Properties{User: []properties.UserProperty{{Key: "tenant", Value: "def"}}}
+	for i, p := range []*properties.Properties{abc, def, nil} {
+		if err := hs.Append(Message{Timestamp: ts.Add(time.Duration(i) * time.Second), Topic: "t", Payload: []byte("p"), Kind: "sub", Properties: p}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	items, _ := SearchQuery(hs, "prop.tenant=abc", false)
+	if len(items) != 1 || items[0].Properties != abc {
+		t.Fatalf("expected the tenant=abc message, got %v", items)
+	}
+	items, _ = SearchQuery(hs, "prop.tenant=", false)
+	if len(items) != 2 {
+		t.Fatalf("expected 2 messages carrying tenant, got %d", len(items))
+	}
+	items, _ = SearchQuery(hs, "prop.content-type=text/plain prop.tenant=def", false)
+	if len(items) != 0 {
+		t.Fatalf("expected no match, got %v", items)
+	}
+}
+
+func TestMessagePropertiesJSONRoundTrip(t *testing.T) {
+	exp := uint32(30)
+	in := Message{Topic: "t", Payload: []byte("p"), Kind: "sub", Properties: &properties.Properties{MessageExpiry: &exp, CorrelationData: []byte{0, 1}}}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out Message
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(out.Properties, in.Properties) {
+		t.Fatalf("properties mismatch: %+v != %+v", out.Properties, in.Properties)
+	}
+	plain, _ := json.Marshal(Message{Topic: "t"})
+	if strings.Contains(string(plain), "Properties") {
+		t.Fatalf("empty properties should be omitted: %s", plain)
+	}
+}
@@ -12,10 +12,10 @@ func TestHistoryStoreSearch(t *testing.T) {
 
 	t.Run("active", func(t *testing.T) {
 		hs := &store{}
-		if err := hs.Append(Message{Timestamp: now.Add(-30 * time.Minute), Topic: "a", Payload: "foo", Kind: "pub", Retained: false}); err != nil {
+		if err := hs.Append(Message{Timestamp: now.Add(-30 * time.Minute), Topic: "a", Payload: []byte("foo"), Kind: "pub", Retained: false}); err != nil {
 			t.Fatalf("Append failed: %v", err)
 		}
-		if err := hs.Append(Message{Timestamp: now.Add(-2 * time.Hour), Topic: "b", Payload: "bar", Kind: "pub", Retained: false}); err != nil {
+		if err := hs.Append(Message{Timestamp: now.Add(-2 * time.Hour), Topic: "b", Payload: []byte("bar"), Kind: "pub", Retained: false}); err != nil {
 			t.Fatalf("Append failed: %v", err)
 		}
 
@@ -25,7 +25,7 @@ func TestHistoryStoreSearch(t *testing.T) {
 		}
 
 		res = hs.Search(false, nil, now.Add(-1*time.Hour), now, "foo")
-		if len(res) != 1 || res[0].Payload != "foo" {
+		if len(res) != 1 || string(res[0].Payload) != "foo" {
 			t.Fatalf("payload filter failed: %#v", res)
 		}
 
@@ -37,10 +37,10 @@ func TestHistoryStoreSearch(t *testing.T) {
 
 	t.Run("archived", func(t *testing.T) {
 		hs := &store{}
-		if err := hs.Append(Message{Timestamp: now.Add(-30 * time.Minute), Topic: "a", Payload: "foo", Kind: "pub", Archived: true, Retained: false}); err != nil {
+		if err := hs.Append(Message{Timestamp: now.Add(-30 * time.Minute), Topic: "a", Payload: []byte("foo"), Kind: "pub", Archived: true, Retained: false}); err != nil {
 			t.Fatalf("Append failed: %v", err)
 		}
-		if err := hs.Append(Message{Timestamp: now.Add(-2 * time.Hour), Topic: "b", Payload: "bar", Kind: "pub", Archived: true, Retained: false}); err != nil {
+		if err := hs.Append(Message{Timestamp: now.Add(-2 * time.Hour), Topic: "b", Payload: []byte("bar"), Kind: "pub", Archived: true, Retained: false}); err != nil {
 			t.Fatalf("Append failed: %v", err)
 		}
 
@@ -50,7 +50,7 @@ func TestHistoryStoreSearch(t *testing.T) {
 		}
 
 		res = hs.Search(true, nil, now.Add(-1*time.Hour), now, "foo")
-		if len(res) != 1 || res[0].Payload != "foo" {
+		if len(res) != 1 || string(res[0].Payload) != "foo" {
 			t.Fatalf("payload filter failed: %#v", res)
 		}
 
@@ -144,7 +144,7 @@ func TestHistoryStoreSearchFuzzy(t *testing.T) {
 		if err := hs.Append(Message{
 			Timestamp: now.Add(-time.Duration(i) * time.Minute),
 			Topic:     topic,
-			Payload:   "test",
+			Payload:   []byte("test"),
 			Kind:      "pub",
 		}); err != nil {
 			t.Fatalf("Append failed: %v", err)
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/marang/emqutiti/proxy"
+)
+
+// Limits of one WriteBatch request, well below the gRPC message limit.
+const (
+	importBatchSize  = 500
+	importBatchBytes = 1 << 20
+)
+
+// Importer is implemented by stores that add many messages at once.
+type Importer interface {
+	// Import stores msgs without replacing stored messages. A message
+	// whose topic and timestamp are taken is moved to the next free
+	// nanosecond, as ReadCapture does within a file. It returns the number
+	// of messages moved.
+	Import(msgs []Message) (int, error)
+}
+
+// Import adds msgs to st, in batches when st is an Importer, and returns
+// the number of messages moved to a free timestamp. Other stores get one
+// Append per message and cannot report collisions.
+func Import(st Store, msgs []Message) (int, error) {
+	if im, ok := st.(Importer); ok {
+		return im.Import(msgs)
+	}
+	for _, m := range msgs {
+		if err := st.Append(m); err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// Import stores msgs with WriteBatch requests that keep existing keys and
+// retries the rejected messages one nanosecond later.
+func (i *store) Import(msgs []Message) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	msgs = slices.Clone(msgs)
+	moved := map[int]bool{}
+	if i.cl == nil {
+		taken := make(map[string]bool, len(i.msgs)+len(msgs))
+		for _, m := range i.msgs {
+			taken[messageKey(m.Topic, m.Timestamp)] = true
+		}
+		for n := range msgs {
+			m := &msgs[n]
+			for taken[messageKey(m.Topic, m.Timestamp)] {
+				m.Timestamp = m.Timestamp.Add(time.Nanosecond)
+				moved[n] = true
+			}
+			taken[messageKey(m.Topic, m.Timestamp)] = true
+			i.msgs = append(i.msgs, *m)
+		}
+		return len(moved), nil
+	}
+	defer clear(i.counts)
+	for start := 0; start < len(msgs); {
+		var pending []int
+		size := 0
+		for start < len(msgs) && len(pending) < importBatchSize && (size < importBatchBytes || len(pending) == 0) {
+			size += len(msgs[start].Payload)
+			pending = append(pending, start)
+			start++
+		}
+		for len(pending) > 0 {
+			existing, err := i.writeBatch(msgs, pending)
+			if err != nil {
+				return len(moved), err
+			}
+			pending = pending[:0]
+			for _, n := range existing {
+				msgs[n].Timestamp = msgs[n].Timestamp.Add(time.Nanosecond)
+				moved[n] = true
+				pending = append(pending, n)
+			}
+		}
+	}
+	return len(moved), nil
+}
+
+// writeBatch writes the messages of msgs at the indexes of batch unless
+// their keys exist and returns the indexes of those that were left out.
+func (i *store) writeBatch(msgs []Message, batch []int) ([]int, error) {
+	req := &proxy.WriteBatchRequest{Profile: i.profile, Bucket: "history", Origin: i.origin, IfAbsent: true}
+	index := make(map[string]int, len(batch))
+	for _, n := range batch {
+		val, err := json.Marshal(msgs[n])
+		if err != nil {
+			return nil, err
+		}
+		key := messageKey(msgs[n].Topic, msgs[n].Timestamp)
+		index[key] = n
+		req.Records = append(req.Records, &proxy.QueryRecord{Key: key, Value: val})
+	}
+	ctx, cancel := proxyContext()
+	defer cancel()
+	resp, err := i.cl.WriteBatch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	existing := make([]int, 0, len(resp.GetExisting()))
+	for _, key := range resp.GetExisting() {
+		existing = append(existing, index[key])
+	}
+	return existing, nil
+}
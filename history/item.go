@@ -3,9 +3,11 @@ package history
 import (
 	"fmt"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/marang/emqutiti/properties"
 	"github.com/marang/emqutiti/ui"
 )
 
@@ -13,16 +15,19 @@ import (
 type Item struct {
 	Timestamp           time.Time
 	Topic               string
-	Payload             string
+	Payload             string // raw payload bytes or log text
 	Kind                string // pub, sub, log
 	Archived            bool
 	Retained            bool
+	Properties          *properties.Properties
+	CorrelationID       string
+	RTT                 time.Duration
 	IsSelected          *bool
 	IsMarkedForDeletion *bool
 }
 
 // FilterValue implements list.Item and returns the payload text.
-func (h Item) FilterValue() string { return h.Payload }
+func (h Item) FilterValue() string { return h.Text() }
 
 // Title renders a colored label used by the list delegate.
 func (h Item) Title() string {
@@ -42,10 +47,30 @@ func (h Item) Title() string {
 	if h.Retained {
 		label += " (retained)"
 	}
+	if !h.Properties.IsEmpty() {
+		label += " (props)"
+	}
 	return lipgloss.NewStyle().Foreground(color).Render(
-		fmt.Sprintf("%s %s: %s", label, h.Topic, h.Payload),
+		fmt.Sprintf("%s %s: %s", label, h.Topic, h.Text()),
 	)
 }
 
 // Description implements list.Item and returns an empty string.
 func (h Item) Description() string { return "" }
+
+// Text returns the payload for display. Binary payloads are shown as hex.
+func (h Item) Text() string {
+	if utf8.ValidString(h.Payload) {
+		return h.Payload
+	}
+	return PayloadText([]byte(h.Payload))
+}
+
+// requestLabel describes the request/response pairing of h, for example
+// "request 1a2b3c4d" or "reply 1a2b3c4d in 42ms".
+func requestLabel(h Item) string {
+	if h.Kind == "pub" {
+		return "request " + h.CorrelationID
+	}
+	return fmt.Sprintf("reply %s in %s", h.CorrelationID, h.RTT.Round(time.Millisecond))
+}
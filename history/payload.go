@@ -0,0 +1,112 @@
+package history
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PayloadSchema is the current encoding of stored payloads. Schema 1 (the
+// field was absent) stored payloads as JSON strings, which corrupted
+// binary data; schema 2 stores the raw bytes base64 encoded.
+const PayloadSchema = 2
+
+// DecodePayload decodes a stored payload field written with schema.
+func DecodePayload(raw json.RawMessage, schema int) ([]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if schema < PayloadSchema {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	}
+	var b []byte
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// MarshalJSON stores the payload as base64 and stamps the current schema.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type alias Message
+	a := alias(m)
+	a.Schema = PayloadSchema
+	return json.Marshal(a)
+}
+
+// UnmarshalJSON decodes messages of any schema. The proxy upgrades stored
+// legacy records when it opens their database.
+func (m *Message) UnmarshalJSON(b []byte) error {
+	type alias Message
+	aux := struct {
+		*alias
+		Payload json.RawMessage
+	}{alias: (*alias)(m)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	p, err := DecodePayload(aux.Payload, m.Schema)
+	if err != nil {
+		return err
+	}
+	m.Payload = p
+	return nil
+}
+
+// PayloadText returns payload as text, or as "0x"-prefixed hex when it is
+// not valid UTF-8 or holds control characters other than line breaks and
+// tabs.
+func PayloadText(payload []byte) string {
+	if !utf8.Valid(payload) {
+		return "0x" + hex.EncodeToString(payload)
+	}
+	for _, r := range string(payload) {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return "0x" + hex.EncodeToString(payload)
+		}
+	}
+	return string(payload)
+}
+
+// PayloadView selects how the detail view renders a payload.
+type PayloadView int
+
+const (
+	ViewText PayloadView = iota
+	ViewHex
+	ViewBase64
+)
+
+// String returns the view name shown in the detail view.
+func (v PayloadView) String() string {
+	switch v {
+	case ViewHex:
+		return "hex"
+	case ViewBase64:
+		return "base64"
+	default:
+		return "text"
+	}
+}
+
+// Next returns the view following v.
+func (v PayloadView) Next() PayloadView { return (v + 1) % 3 }
+
+// FormatPayloadAs renders payload in the given view. Text views pretty
+// print JSON and fall back to hex for binary data.
+func FormatPayloadAs(payload []byte, v PayloadView) string {
+	switch v {
+	case ViewHex:
+		return hex.Dump(payload)
+	case ViewBase64:
+		return base64.StdEncoding.EncodeToString(payload)
+	default:
+		return FormatDetailPayload(PayloadText(payload))
+	}
+}
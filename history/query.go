@@ -0,0 +1,124 @@
+package history
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/marang/emqutiti/proxy"
+)
+
+// Query selects stored messages. Zero values leave out a constraint.
+type Query struct {
+	Archived bool
+	// Topics match exactly or fuzzily; any of them may match.
+	Topics     []string
+	Start, End time.Time
+	// Payload is a substring of the payload.
+	Payload string
+	Kinds   []string
+	// Cursor continues after the page that returned it.
+	Cursor string
+	// Limit caps the messages of a page. Zero returns all matches.
+	Limit int
+}
+
+// Page is one batch of query results.
+type Page struct {
+	Messages []Message
+	// Next is the cursor of the following page, empty after the last one.
+	Next string
+}
+
+// NewQuery builds a query from a filter string in the ParseQuery syntax.
+// Property filters are not part of it; see ParseProperties.
+func NewQuery(q string, archived bool) Query {
+	topics, start, end, payload := ParseQuery(q)
+	return Query{Archived: archived, Topics: topics, Start: start, End: end, Payload: payload}
+}
+
+// Match reports whether m satisfies the filters of q.
+func (q Query) Match(m Message) bool {
+	if m.Archived != q.Archived {
+		return false
+	}
+	if len(q.Kinds) > 0 && !slices.Contains(q.Kinds, m.Kind) {
+		return false
+	}
+	if !q.Start.IsZero() && m.Timestamp.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && m.Timestamp.After(q.End) {
+		return false
+	}
+	if !fuzzyMatchTopic(m.Topic, q.Topics) {
+		return false
+	}
+	return q.Payload == "" || bytes.Contains(m.Payload, []byte(q.Payload))
+}
+
+// Select returns the page of msgs matching q, for stores that keep their
+// messages in memory. Cursors are offsets into msgs.
+func (q Query) Select(msgs []Message) Page {
+	from, _ := strconv.Atoi(q.Cursor)
+	var pg Page
+	for i := from; i < len(msgs); i++ {
+		if !q.Match(msgs[i]) {
+			continue
+		}
+		if q.Limit > 0 && len(pg.Messages) == q.Limit {
+			pg.Next = strconv.Itoa(i)
+			break
+		}
+		pg.Messages = append(pg.Messages, msgs[i])
+	}
+	return pg
+}
+
+// request converts q for the Query RPC of the DB proxy.
+func (q Query) request(profile string) *proxy.QueryRequest {
+	req := &proxy.QueryRequest{
+		Profile:  profile,
+		Bucket:   "history",
+		Topics:   q.Topics,
+		Payload:  q.Payload,
+		Kinds:    q.Kinds,
+		Archived: q.Archived,
+		Cursor:   q.Cursor,
+		Limit:    uint32(q.Limit),
+	}
+	if !q.Start.IsZero() {
+		req.Start = q.Start.UnixNano()
+	}
+	if !q.End.IsZero() {
+		req.End = q.End.UnixNano()
+	}
+	return req
+}
+
+// Collect returns all messages of st matching q, reading them page by
+// page.
+func Collect(st Store, q Query) ([]Message, error) {
+	if q.Limit == 0 {
+		q.Limit = pageSize
+	}
+	var out []Message
+	for {
+		pg, err := st.Query(q)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, pg.Messages...)
+		if pg.Next == "" {
+			return out, nil
+		}
+		q.Cursor = pg.Next
+	}
+}
+
+// messageKey is the store key of a message, "<topic>/<timestamp>".
+func messageKey(topic string, ts time.Time) string {
+	return fmt.Sprintf("%s/%020d", topic, ts.UnixNano())
+}
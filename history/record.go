@@ -0,0 +1,141 @@
+package history
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/marang/emqutiti/properties"
+)
+
+// Record is the portable JSON form of a message written by the command
+// line tools. Text payloads are kept as is; binary ones are base64
+// encoded and flagged in Encoding.
+type Record struct {
+	Time       time.Time              `json:"time"`
+	Topic      string                 `json:"topic"`
+	QoS        byte                   `json:"qos,omitempty"`
+	Retained   bool                   `json:"retained,omitempty"`
+	Payload    string                 `json:"payload"`
+	Encoding   string                 `json:"encoding,omitempty"`
+	Properties *properties.Properties `json:"properties,omitempty"`
+	// Kind is "pub", "sub" or "log" for exported history entries.
+	Kind string `json:"kind,omitempty"`
+}
+
+// NewRecord builds a record for a message received at ts.
+func NewRecord(ts time.Time, topic string, payload []byte, qos byte, retained bool, props *properties.Properties) Record {
+	r := Record{Time: ts, Topic: topic, QoS: qos, Retained: retained, Properties: props}
+	if utf8.Valid(payload) {
+		r.Payload = string(payload)
+	} else {
+		r.Payload, r.Encoding = base64.StdEncoding.EncodeToString(payload), "base64"
+	}
+	return r
+}
+
+// PayloadBytes returns the decoded payload.
+func (r Record) PayloadBytes() ([]byte, error) {
+	switch r.Encoding {
+	case "":
+		return []byte(r.Payload), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(r.Payload)
+	}
+	return nil, fmt.Errorf("unknown payload encoding %q", r.Encoding)
+}
+
+// Record output formats.
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// CSVColumns are the columns of CSV output in their default order.
+var CSVColumns = []string{"time", "topic", "qos", "retained", "payload", "encoding"}
+
+// csvExtraColumns may be chosen in addition to CSVColumns.
+var csvExtraColumns = []string{"kind"}
+
+// RecordWriter writes records in one of the output formats.
+type RecordWriter interface {
+	Write(Record) error
+	// Flush writes buffered output to the underlying writer.
+	Flush() error
+}
+
+// NewRecordWriter returns a writer for format. CSV output starts with a
+// header of columns, or of CSVColumns when none are given.
+func NewRecordWriter(w io.Writer, format string, columns []string) (RecordWriter, error) {
+	switch format {
+	case FormatNDJSON:
+		return ndjsonWriter{json.NewEncoder(w)}, nil
+	case FormatCSV:
+		if len(columns) == 0 {
+			columns = CSVColumns
+		}
+		for _, c := range columns {
+			if !slices.Contains(CSVColumns, c) && !slices.Contains(csvExtraColumns, c) {
+				all := append(slices.Clone(CSVColumns), csvExtraColumns...)
+				return nil, fmt.Errorf("unknown CSV column %q (want %s)", c, strings.Join(all, ", "))
+			}
+		}
+		return &csvWriter{w: csv.NewWriter(w), columns: columns}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (want ndjson or csv)", format)
+}
+
+type ndjsonWriter struct{ enc *json.Encoder }
+
+func (n ndjsonWriter) Write(r Record) error { return n.enc.Encode(r) }
+func (n ndjsonWriter) Flush() error         { return nil }
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	started bool
+}
+
+func (c *csvWriter) Write(r Record) error {
+	if !c.started {
+		c.started = true
+		if err := c.w.Write(c.columns); err != nil {
+			return err
+		}
+	}
+	row := make([]string, len(c.columns))
+	for i, col := range c.columns {
+		switch col {
+		case "time":
+			row[i] = r.Time.Format(time.RFC3339Nano)
+		case "topic":
+			row[i] = r.Topic
+		case "qos":
+			row[i] = strconv.Itoa(int(r.QoS))
+		case "retained":
+			row[i] = strconv.FormatBool(r.Retained)
+		case "payload":
+			row[i] = r.Payload
+		case "encoding":
+			row[i] = r.Encoding
+		case "kind":
+			row[i] = r.Kind
+		}
+	}
+	return c.w.Write(row)
+}
+
+// SkipHeader omits the header, e.g. when appending to an existing file.
+func (c *csvWriter) SkipHeader() { c.started = true }
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
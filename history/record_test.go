@@ -0,0 +1,31 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRecordRoundTripsBinaryPayload(t *testing.T) {
+	payload := []byte{0x00, 0xff, 'a'}
+	r := NewRecord(time.Unix(1700000000, 0), "bin", payload, 2, false, nil)
+	if r.Encoding != "base64" {
+		t.Fatalf("expected base64 encoding, got %q", r.Encoding)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var back Record
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	got, err := back.PayloadBytes()
+	if err != nil || !bytes.Equal(got, payload) {
+		t.Fatalf("payload %q, %v", got, err)
+	}
+	if text := NewRecord(time.Time{}, "t", []byte("hi"), 0, false, nil); text.Payload != "hi" || text.Encoding != "" {
+		t.Fatalf("text payload should stay readable, got %+v", text)
+	}
+}
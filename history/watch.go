@@ -0,0 +1,186 @@
+package history
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/marang/emqutiti/proxy"
+)
+
+// Change is a modification of a store made elsewhere, such as by another
+// emqutiti instance or a headless trace sharing the DB proxy.
+type Change struct {
+	Key string
+	// Message is the stored message, or nil when it was deleted.
+	Message *Message
+}
+
+// Watcher is implemented by stores that report changes made elsewhere.
+type Watcher interface {
+	// Watch reports changes until ctx ends. The channel is closed when
+	// watching stops.
+	Watch(ctx context.Context) (<-chan Change, error)
+}
+
+// watchRetry is the delay before watching again after a watch ended.
+const watchRetry = 2 * time.Second
+
+// ChangeMsg carries a change of the watched store to its Component.
+type ChangeMsg struct {
+	ch     <-chan Change
+	change Change
+	// closed reports that the watch ended; retry asks to watch again.
+	closed, retry bool
+}
+
+// newOrigin returns a random tag for the writes of one store.
+func newOrigin() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Watch reports changes of the history made through other stores. Writes
+// of this store are skipped as the UI already shows them.
+func (i *store) Watch(ctx context.Context) (<-chan Change, error) {
+	if i.cl == nil {
+		return nil, nil
+	}
+	stream, err := i.cl.Watch(ctx, &proxy.WatchRequest{Profile: i.profile, Bucket: "history"})
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Change, 64)
+	go func() {
+		defer close(ch)
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			if ev.GetOrigin() == i.origin {
+				continue
+			}
+			i.mu.Lock()
+			clear(i.counts)
+			i.mu.Unlock()
+			c := Change{Key: ev.GetKey()}
+			if ev.GetOp() == proxy.WatchEvent_PUT {
+				var m Message
+				if err := json.Unmarshal(ev.GetValue(), &m); err != nil {
+					continue
+				}
+				c.Message = &m
+			}
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Watch follows changes of the store made elsewhere and shows them in the
+// list. A previous watch is stopped. Stores that cannot be watched return
+// nil.
+func (h *Component) Watch() tea.Cmd {
+	h.StopWatch()
+	w, ok := h.store.(Watcher)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := w.Watch(ctx)
+	if err != nil || ch == nil {
+		cancel()
+		return nil
+	}
+	h.changes, h.stopWatch = ch, cancel
+	return listenChanges(ch)
+}
+
+// StopWatch stops following the store.
+func (h *Component) StopWatch() {
+	if h.stopWatch != nil {
+		h.stopWatch()
+	}
+	h.changes, h.stopWatch = nil, nil
+}
+
+func listenChanges(ch <-chan Change) tea.Cmd {
+	return func() tea.Msg {
+		c, ok := <-ch
+		return ChangeMsg{ch: ch, change: c, closed: !ok}
+	}
+}
+
+// HandleChange applies a change of the watched store and waits for the
+// next one. It reports false when msg belongs to another Component or to
+// a watch that was stopped. When the watch ends, for example because the
+// proxy restarted, the list is read again and watched anew.
+func (h *Component) HandleChange(msg ChangeMsg) (tea.Cmd, bool) {
+	if msg.ch == nil || msg.ch != h.changes {
+		return nil, false
+	}
+	switch {
+	case msg.retry:
+		cmd := h.Watch()
+		if cmd != nil {
+			h.load(h.filterQuery, max(pageSize, len(h.items)))
+		}
+		return cmd, true
+	case msg.closed:
+		ch := msg.ch
+		return tea.Tick(watchRetry, func(time.Time) tea.Msg { return ChangeMsg{ch: ch, retry: true} }), true
+	}
+	h.applyChange(msg.change)
+	return listenChanges(msg.ch), true
+}
+
+// applyChange updates, removes or appends the message of c. Log entries
+// of other instances are not shown. The selection follows appended
+// messages when it was on the last item.
+func (h *Component) applyChange(c Change) {
+	idx := slices.IndexFunc(h.items, func(it Item) bool {
+		return messageKey(it.Topic, it.Timestamp) == c.Key
+	})
+	follow := h.list.Index() >= len(h.items)-1
+	switch m := c.Message; {
+	case m == nil || !h.query.Match(*m) || !matchProperties(m.Properties, h.props):
+		if idx < 0 {
+			return
+		}
+		h.items = slices.Delete(h.items, idx, idx+1)
+		follow = false
+	case idx >= 0:
+		items, _ := MessagesToItems([]Message{*m})
+		items[0].IsSelected = h.items[idx].IsSelected
+		h.items[idx] = items[0]
+		follow = false
+	case m.Kind == "log":
+		return
+	default:
+		if h.query.Cursor != "" {
+			if h.live == nil {
+				h.live = map[string]struct{}{}
+			}
+			h.live[c.Key] = struct{}{}
+		}
+		items, _ := MessagesToItems([]Message{*m})
+		h.items = append(h.items, items...)
+	}
+	idx = h.list.Index()
+	h.setListItems()
+	if follow {
+		idx = len(h.items) - 1
+	}
+	h.list.Select(min(idx, len(h.items)-1))
+}
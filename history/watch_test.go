@@ -0,0 +1,104 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/marang/emqutiti/proxy"
+)
+
+func TestComponentShowsChangesOfOtherStores(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	SetProxyAddr(p.Addr())
+	t.Cleanup(func() { SetProxyAddr("") })
+	t.Cleanup(p.Stop)
+
+	mine, err := openStore("test")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer mine.Close()
+	other, err := openStore("test")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer other.Close()
+
+	h := NewComponent(stubModel{}, mine)
+	cmd := h.Watch()
+	if cmd == nil {
+		t.Fatal("proxy store not watched")
+	}
+	msgs := make(chan tea.Msg, 1)
+	next := func(cmd tea.Cmd) ChangeMsg {
+		t.Helper()
+		go func() { msgs <- cmd() }()
+		select {
+		case msg := <-msgs:
+			return msg.(ChangeMsg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change received")
+		}
+		return ChangeMsg{}
+	}
+
+	// The watch starts asynchronously; write until a change arrives. Writes
+	// of the component's own store are skipped.
+	h.Append("own", "mine", "pub", false, "mine")
+	base := time.Unix(1700000000, 0)
+	go func() { msgs <- cmd() }()
+	var change ChangeMsg
+	for i := 0; change.ch == nil; i++ {
+		if i == 250 {
+			t.Fatal("no change received")
+		}
+		if err := other.Append(Message{Timestamp: base.Add(time.Duration(i)), Topic: "remote", Payload: []byte("theirs"), Kind: "sub"}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		select {
+		case msg := <-msgs:
+			change = msg.(ChangeMsg)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	if change.change.Message == nil || change.change.Message.Topic != "remote" {
+		t.Fatalf("first change = %+v, want the other store's message", change.change)
+	}
+	cmd, ok := h.HandleChange(change)
+	if !ok || cmd == nil {
+		t.Fatal("change not handled")
+	}
+	items := h.Items()
+	if last := items[len(items)-1]; last.Topic != "remote" || len(items) != 2 {
+		t.Fatalf("remote message not appended: %+v", items)
+	}
+
+	// Drain the remaining probe writes, then archive the shown message.
+	key := fmt.Sprintf("remote/%020d", change.change.Message.Timestamp.UnixNano())
+	if err := other.Archive(key); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	for {
+		change = next(cmd)
+		if cmd, _ = h.HandleChange(change); change.change.Key == key && change.change.Message.Archived {
+			break
+		}
+	}
+	for _, it := range h.Items() {
+		if messageKey(it.Topic, it.Timestamp) == key {
+			t.Fatalf("archived message still listed: %+v", h.Items())
+		}
+	}
+
+	h.StopWatch()
+	if _, ok := h.HandleChange(change); ok {
+		t.Fatal("change of a stopped watch handled")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/marang/emqutiti/constants"
-	"github.com/marang/emqutiti/history"
 )
 
 // updateClientInputs updates form inputs, viewport and history list.
@@ -70,15 +69,9 @@ func (m *model) updateViewport(msg tea.Msg) tea.Cmd {
 // filterHistoryList refreshes history items based on the current filter state.
 func (m *model) filterHistoryList() {
 	if st := m.history.List().FilterState(); st == list.Filtering || st == list.FilterApplied {
-		q := m.history.List().FilterInput.Value()
-		hitems, litems := history.ApplyFilter(q, m.history.Store(), m.history.ShowArchived())
-		m.history.SetItems(hitems)
-		m.history.SetFilterQuery(q)
-		m.history.List().SetItems(litems)
+		m.history.Load(m.history.List().FilterInput.Value())
 	} else if m.history.FilterQuery() != "" {
-		hitems, litems := history.ApplyFilter(m.history.FilterQuery(), m.history.Store(), m.history.ShowArchived())
-		m.history.SetItems(hitems)
-		m.history.List().SetItems(litems)
+		m.history.Load(m.history.FilterQuery())
 	} else {
 		items := make([]list.Item, len(m.history.Items()))
 		for i, it := range m.history.Items() {
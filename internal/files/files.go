@@ -5,6 +5,19 @@ import (
 	"path/filepath"
 )
 
+// BaseDir returns the directory holding the configuration and data of
+// emqutiti: ~/.config/emqutiti, or EMQUTITI_HOME when it is set.
+func BaseDir() string {
+	if override := os.Getenv("EMQUTITI_HOME"); override != "" {
+		return override
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(home, ".config", "emqutiti")
+}
+
 // DataDir returns the base data directory for the given profile.
 // If the profile is empty, "default" is used.
 // The directory is placed under ~/.config/emqutiti/data by default.
@@ -13,14 +26,7 @@ func DataDir(profile string) string {
 	if profile == "" {
 		profile = "default"
 	}
-	if override := os.Getenv("EMQUTITI_HOME"); override != "" {
-		return filepath.Join(override, "data", profile)
-	}
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return filepath.Join("data", profile)
-	}
-	return filepath.Join(home, ".config", "emqutiti", "data", profile)
+	return filepath.Join(BaseDir(), "data", profile)
 }
 
 // EnsureDir creates the directory with 0755 permissions if it does not exist.
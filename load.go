@@ -0,0 +1,96 @@
+package emqutiti
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/traces"
+)
+
+// runLoad imports a capture file into the history or a new trace of a
+// profile, keeping the original timestamps. Messages are never replaced:
+// one whose topic and timestamp are taken in the history is moved to the
+// next free nanosecond and reported.
+func runLoad(d *appDeps) error {
+	o := d.capture
+	if o.File == "" {
+		return errors.New("load: a capture file is required")
+	}
+	var r io.Reader = d.stdin
+	// Lines without timestamps are dated from the file.
+	base := time.Now()
+	if o.File != "-" {
+		f, err := os.Open(o.File)
+		if err != nil {
+			return fmt.Errorf("load: %w", err)
+		}
+		defer f.Close()
+		if st, err := f.Stat(); err == nil {
+			base = st.ModTime()
+		}
+		r = f
+	}
+	msgs, err := history.ReadCapture(r, o.Format, base)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", o.File, err)
+	}
+	if len(msgs) == 0 {
+		return fmt.Errorf("load %s: no messages", o.File)
+	}
+	p, err := d.loadProfile(d.profileName, d.configFile)
+	if err != nil {
+		return fmt.Errorf("error loading profile: %w", err)
+	}
+	if o.Trace != "" {
+		if err := loadTrace(d, p.Name, o.Trace, msgs); err != nil {
+			return fmt.Errorf("load: %w", err)
+		}
+		fmt.Fprintf(d.stdout, "Loaded %d message(s) into trace %s of %s\n", len(msgs), o.Trace, p.Name)
+		return nil
+	}
+	st, err := d.openHistory(p.Name)
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+	defer st.Close()
+	moved, err := history.Import(st, msgs)
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+	fmt.Fprintf(d.stdout, "Loaded %d message(s) into the history of %s\n", len(msgs), p.Name)
+	if moved > 0 {
+		fmt.Fprintf(d.stdout, "%d message(s) shared topic and time with stored ones and were moved by a few nanoseconds\n", moved)
+	}
+	return nil
+}
+
+// loadTrace stores msgs as a new finished trace covering all topics.
+func loadTrace(d *appDeps, profile, key string, msgs []history.Message) error {
+	if _, ok := d.traceStore.LoadTraces()[key]; ok {
+		return fmt.Errorf("trace %q already exists", key)
+	}
+	if has, err := d.traceStore.HasData(profile, key); err != nil {
+		return err
+	} else if has {
+		return fmt.Errorf("trace %q already has data", key)
+	}
+	cfg := traces.TracerConfig{Profile: profile, Key: key, Topics: []string{"#"}}
+	tmsgs := make([]traces.TracerMessage, len(msgs))
+	for i, m := range msgs {
+		tmsgs[i] = traces.TracerMessage{Timestamp: m.Timestamp, Topic: m.Topic, Payload: m.Payload, Kind: m.Kind, Retained: m.Retained, Properties: m.Properties}
+		if cfg.Start.IsZero() || m.Timestamp.Before(cfg.Start) {
+			cfg.Start = m.Timestamp
+		}
+		if m.Timestamp.After(cfg.End) {
+			cfg.End = m.Timestamp
+		}
+	}
+	if err := d.traceStore.AddMessages(profile, key, tmsgs); err != nil {
+		return err
+	}
+	return d.traceStore.AddTrace(cfg)
+}
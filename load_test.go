@@ -0,0 +1,143 @@
+package emqutiti
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cfg "github.com/marang/emqutiti/cmd"
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/proxy"
+	"github.com/marang/emqutiti/traces"
+)
+
+// appendHistoryStore records appended messages.
+type appendHistoryStore struct {
+	stubHistoryStore
+	appended []history.Message
+}
+
+func (s *appendHistoryStore) Append(m history.Message) error {
+	s.appended = append(s.appended, m)
+	return nil
+}
+
+func writeCapture(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("write capture: %v", err)
+	}
+	return path
+}
+
+func TestRunLoadIntoHistory(t *testing.T) {
+	path := writeCapture(t, "capture.txt", "sensors/1 21.5\nsensors/2 22\n")
+	st := &appendHistoryStore{}
+	var out bytes.Buffer
+	d := &appDeps{
+		loadProfile: func(string, string) (*connections.Profile, error) { return &connections.Profile{Name: "local"}, nil },
+		openHistory: func(string) (history.Store, error) { return st, nil },
+		stdout:      &out,
+		capture:     cfg.CaptureConfig{File: path},
+	}
+	if err := runLoad(d); err != nil {
+		t.Fatalf("runLoad: %v", err)
+	}
+	if len(st.appended) != 2 || st.appended[1].Topic != "sensors/2" || !st.closed {
+		t.Fatalf("unexpected appends %+v", st.appended)
+	}
+	info, _ := os.Stat(path)
+	if !st.appended[0].Timestamp.Equal(info.ModTime()) {
+		t.Fatalf("expected messages dated from the file, got %v", st.appended[0].Timestamp)
+	}
+	if out.String() != "Loaded 2 message(s) into the history of local\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	d.capture = cfg.CaptureConfig{}
+	if err := runLoad(d); err == nil {
+		t.Fatalf("expected error without a file")
+	}
+}
+
+func TestRunLoadIntoTraceThroughProxy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	t.Cleanup(p.Stop)
+	traces.SetProxyAddr(p.Addr())
+	t.Cleanup(func() { traces.SetProxyAddr("") })
+
+	path := writeCapture(t, "capture.ndjson", `{"time":"2025-08-05T11:47:00Z","topic":"a","payload":"1"}
+{"time":"2025-08-05T11:48:00Z","topic":"b/c","payload":"2"}
+`)
+	var out bytes.Buffer
+	d := &appDeps{
+		traceStore:  traces.FileStore{},
+		loadProfile: func(string, string) (*connections.Profile, error) { return &connections.Profile{Name: "customer"}, nil },
+		stdout:      &out,
+		capture:     cfg.CaptureConfig{File: path, Trace: "ticket-42"},
+	}
+	if err := runLoad(d); err != nil {
+		t.Fatalf("runLoad: %v", err)
+	}
+	msgs, err := traces.FileStore{}.Messages("customer", "ticket-42")
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("expected stored trace messages, got %+v %v", msgs, err)
+	}
+	if want := time.Date(2025, 8, 5, 11, 48, 0, 0, time.UTC); !msgs[1].Timestamp.Equal(want) {
+		t.Fatalf("expected original timestamp, got %v", msgs[1].Timestamp)
+	}
+	tc, ok := traces.FileStore{}.LoadTraces()["ticket-42"]
+	if !ok || tc.Profile != "customer" || !tc.End.Equal(msgs[1].Timestamp) {
+		t.Fatalf("expected finished trace config, got %+v", tc)
+	}
+	if err := runLoad(d); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected existing trace to be refused, got %v", err)
+	}
+}
+
+func TestRunLoadTwiceKeepsStoredHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	t.Cleanup(p.Stop)
+	history.SetProxyAddr(p.Addr())
+	t.Cleanup(func() { history.SetProxyAddr("") })
+
+	path := writeCapture(t, "capture.ndjson", `{"time":"2025-08-05T11:47:00Z","topic":"a","payload":"1"}
+{"time":"2025-08-05T11:48:00Z","topic":"b/c","payload":"2"}
+`)
+	var out bytes.Buffer
+	d := &appDeps{
+		loadProfile: func(string, string) (*connections.Profile, error) { return &connections.Profile{Name: "local"}, nil },
+		openHistory: history.OpenStore,
+		stdout:      &out,
+		capture:     cfg.CaptureConfig{File: path},
+	}
+	for range 2 {
+		if err := runLoad(d); err != nil {
+			t.Fatalf("runLoad: %v", err)
+		}
+	}
+	if !strings.HasSuffix(out.String(), "2 message(s) shared topic and time with stored ones and were moved by a few nanoseconds\n") {
+		t.Fatalf("collisions not reported: %q", out.String())
+	}
+	st, err := history.OpenStore("local")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+	if n := st.Count(false); n != 4 {
+		t.Fatalf("history holds %d messages, want 4", n)
+	}
+}
@@ -1,17 +1,27 @@
 package message
 
 import (
+	"fmt"
+
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/x/ansi"
 
 	"github.com/marang/emqutiti/focus"
+	"github.com/marang/emqutiti/properties"
 	"github.com/marang/emqutiti/ui"
 )
 
 // State holds the textarea model for composing messages.
 type State struct {
 	TA textarea.Model
+	// qos overrides the topic and profile QoS for the next publishes.
+	qos *int
+	// props are the MQTT 5 properties attached to the next publishes.
+	props     *properties.Properties
+	propsForm *propsForm
+	// encoding decodes the editor text into payload bytes.
+	encoding Encoding
 }
 
 // Component implements the message editor.
@@ -56,6 +66,15 @@ func (c *Component) View() string {
 	focused := c.m.FocusedID() == ID
 	hovered := c.m.HoveredID() == ID
 	label := c.m.MessageTargetPreview()
+	if c.qos != nil {
+		label = fmt.Sprintf("%s [QoS %d]", label, *c.qos)
+	}
+	if c.encoding != EncodingText {
+		label = fmt.Sprintf("%s [%s]", label, c.encoding)
+	}
+	if !c.props.IsEmpty() {
+		label += " [props]"
+	}
 	if maxLabel := c.m.Width() - 6; maxLabel > 0 {
 		label = ansi.Truncate(label, maxLabel, "…")
 	}
@@ -72,6 +91,24 @@ func (c *Component) Input() *textarea.Model { return &c.TA }
 // SetPayload updates the textarea with the provided payload.
 func (c *Component) SetPayload(payload string) { c.TA.SetValue(payload) }
 
+// QoS returns the per-publish QoS override or nil when unset.
+func (c *Component) QoS() *int { return c.qos }
+
+// CycleQoS advances the per-publish QoS override through default, 0, 1
+// and 2.
+func (c *Component) CycleQoS() {
+	switch {
+	case c.qos == nil:
+		q := 0
+		c.qos = &q
+	case *c.qos >= 2:
+		c.qos = nil
+	default:
+		q := *c.qos + 1
+		c.qos = &q
+	}
+}
+
 // Focusables exposes focusable elements for the message component.
 func (c *Component) Focusables() map[string]focus.Focusable {
 	return map[string]focus.Focusable{ID: focus.Adapt(&c.TA)}
@@ -0,0 +1,64 @@
+package message
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Encoding selects how the editor text is turned into payload bytes.
+type Encoding int
+
+const (
+	// EncodingText publishes the editor text as is.
+	EncodingText Encoding = iota
+	// EncodingHex decodes the editor text as hexadecimal bytes.
+	EncodingHex
+	// EncodingBase64 decodes the editor text as standard base64.
+	EncodingBase64
+)
+
+// String returns the encoding name shown in the editor label.
+func (e Encoding) String() string {
+	switch e {
+	case EncodingHex:
+		return "hex"
+	case EncodingBase64:
+		return "base64"
+	default:
+		return "text"
+	}
+}
+
+// Decode converts editor text to payload bytes. Whitespace is ignored for
+// hex and base64 input, and hex may carry a "0x" prefix.
+func (e Encoding) Decode(s string) ([]byte, error) {
+	switch e {
+	case EncodingHex:
+		s = strings.Join(strings.Fields(s), "")
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex payload: %w", err)
+		}
+		return b, nil
+	case EncodingBase64:
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 payload: %w", err)
+		}
+		return b, nil
+	default:
+		return []byte(s), nil
+	}
+}
+
+// Encoding returns the payload encoding of the editor.
+func (c *Component) Encoding() Encoding { return c.encoding }
+
+// CycleEncoding advances the payload encoding through text, hex and base64.
+func (c *Component) CycleEncoding() { c.encoding = (c.encoding + 1) % 3 }
+
+// Payload decodes the editor text using the current encoding.
+func (c *Component) Payload() ([]byte, error) { return c.encoding.Decode(c.TA.Value()) }
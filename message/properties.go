@@ -0,0 +1,172 @@
+package message
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/properties"
+	"github.com/marang/emqutiti/ui"
+)
+
+// propsForm edits the MQTT 5 properties attached to published messages.
+type propsForm struct {
+	ui.Form
+	contentType   *ui.TextField
+	utf8          *ui.CheckField
+	expiry        *ui.TextField
+	responseTopic *ui.TextField
+	correlation   *ui.TextField
+	user          *ui.TextField
+	errMsg        string
+}
+
+// newPropsForm builds the properties form prefilled from p.
+func newPropsForm(p *properties.Properties) *propsForm {
+	if p == nil {
+		p = &properties.Properties{}
+	}
+	ct := ui.NewTextField(p.ContentType, "application/json")
+	pf := ui.NewCheckField(p.PayloadFormat != nil && *p.PayloadFormat == 1)
+	exp := ""
+	if p.MessageExpiry != nil {
+		exp = strconv.FormatUint(uint64(*p.MessageExpiry), 10)
+	}
+	ef := ui.NewTextField(exp, "seconds")
+	rt := ui.NewTextField(p.ResponseTopic, "reply/topic")
+	cd := ui.NewTextField(string(p.CorrelationData), "request-id")
+	uf := ui.NewTextField(properties.FormatUserProperties(p.User), "key=value, key=value")
+	f := &propsForm{
+		Form:          ui.Form{Fields: []ui.Field{ct, pf, ef, rt, cd, uf}},
+		contentType:   ct,
+		utf8:          pf,
+		expiry:        ef,
+		responseTopic: rt,
+		correlation:   cd,
+		user:          uf,
+	}
+	f.ApplyFocus()
+	return f
+}
+
+// properties validates the form and returns the entered properties. Empty
+// forms yield nil.
+func (f *propsForm) properties() (*properties.Properties, error) {
+	p := &properties.Properties{
+		ContentType:   strings.TrimSpace(f.contentType.Value()),
+		ResponseTopic: strings.TrimSpace(f.responseTopic.Value()),
+	}
+	if f.utf8.Bool() {
+		v := byte(1)
+		p.PayloadFormat = &v
+	}
+	if s := strings.TrimSpace(f.expiry.Value()); s != "" {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("message expiry must be a number of seconds")
+		}
+		v := uint32(n)
+		p.MessageExpiry = &v
+	}
+	if s := f.correlation.Value(); s != "" {
+		p.CorrelationData = []byte(s)
+	}
+	user, err := properties.ParseUserProperties(f.user.Value())
+	if err != nil {
+		return nil, err
+	}
+	p.User = user
+	if p.IsEmpty() {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// update handles focus cycling and field input.
+func (f *propsForm) update(msg tea.Msg) tea.Cmd {
+	f.errMsg = ""
+	var cmd tea.Cmd
+	switch m := msg.(type) {
+	case tea.KeyMsg:
+		// Only tab and arrows move focus so j/k stay typeable.
+		switch m.String() {
+		case constants.KeyTab, constants.KeyDown:
+			f.Focus = (f.Focus + 1) % len(f.Fields)
+		case constants.KeyShiftTab, constants.KeyUp:
+			f.Focus = (f.Focus - 1 + len(f.Fields)) % len(f.Fields)
+		default:
+			cmd = f.Fields[f.Focus].Update(msg)
+		}
+	case tea.MouseMsg:
+		cmd = f.Fields[f.Focus].Update(msg)
+	}
+	f.ApplyFocus()
+	return cmd
+}
+
+// view renders the fields with labels.
+func (f *propsForm) view() string {
+	lines := []string{
+		fmt.Sprintf("Content type:     %s", f.contentType.View()),
+		fmt.Sprintf("UTF-8 payload:    %s", f.utf8.View()),
+		fmt.Sprintf("Expiry (s):       %s", f.expiry.View()),
+		fmt.Sprintf("Response topic:   %s", f.responseTopic.View()),
+		fmt.Sprintf("Correlation data: %s", f.correlation.View()),
+		fmt.Sprintf("User properties:  %s", f.user.View()),
+		"",
+		ui.InfoStyle.Render("[enter] save • [esc] cancel • properties require MQTT 5"),
+	}
+	if f.errMsg != "" {
+		lines = append(lines, "", ui.ErrorStyle.Render(f.errMsg))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Properties returns the MQTT 5 properties attached to publishes or nil.
+func (c *Component) Properties() *properties.Properties { return c.props }
+
+// SetProperties replaces the properties attached to publishes.
+func (c *Component) SetProperties(p *properties.Properties) { c.props = p }
+
+// StartPropertiesEditor opens the properties form for the current
+// properties.
+func (c *Component) StartPropertiesEditor() { c.propsForm = newPropsForm(c.props) }
+
+// UpdateProperties forwards input to the open properties form.
+func (c *Component) UpdateProperties(msg tea.Msg) tea.Cmd {
+	if c.propsForm == nil {
+		return nil
+	}
+	return c.propsForm.update(msg)
+}
+
+// SaveProperties validates and applies the form. The form stays open when
+// validation fails.
+func (c *Component) SaveProperties() error {
+	if c.propsForm == nil {
+		return nil
+	}
+	p, err := c.propsForm.properties()
+	if err != nil {
+		c.propsForm.errMsg = err.Error()
+		return err
+	}
+	c.props = p
+	c.propsForm = nil
+	return nil
+}
+
+// CancelProperties closes the form without applying changes.
+func (c *Component) CancelProperties() { c.propsForm = nil }
+
+// ViewProperties renders the properties form or an empty string when
+// closed.
+func (c *Component) ViewProperties() string {
+	if c.propsForm == nil {
+		return ""
+	}
+	return c.propsForm.view()
+}
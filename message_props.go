@@ -0,0 +1,33 @@
+package emqutiti
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/ui"
+)
+
+// updateMessageProps handles input for the message properties editor.
+func (m *model) updateMessageProps(msg tea.Msg) tea.Cmd {
+	if k, ok := msg.(tea.KeyMsg); ok {
+		switch k.String() {
+		case constants.KeyEsc:
+			m.message.CancelProperties()
+			return m.SetMode(m.PreviousMode())
+		case constants.KeyEnter:
+			if err := m.message.SaveProperties(); err != nil {
+				return nil
+			}
+			return m.SetMode(m.PreviousMode())
+		}
+	}
+	return m.message.UpdateProperties(msg)
+}
+
+// viewMessageProps renders the message properties editor.
+func (m *model) viewMessageProps() string {
+	content := lipgloss.NewStyle().Padding(1, 2).Render(m.message.ViewProperties())
+	box := ui.LegendBox(content, "MQTT 5 Properties", m.ui.width*2/3, 0, ui.ColBlue, true, -1)
+	return lipgloss.Place(m.ui.width, m.ui.height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -0,0 +1,116 @@
+package emqutiti
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/properties"
+	"github.com/marang/emqutiti/topics"
+)
+
+// propsClient records the properties passed to PublishWithProperties.
+type propsClient struct {
+	mockClient
+	props *properties.Properties
+}
+
+func (c *propsClient) PublishWithProperties(topic string, qos byte, retained bool, payload interface{}, props *properties.Properties) mqtt.Token {
+	c.props = props
+	return stubToken{}
+}
+
+func typeText(m *model, s string) {
+	for _, r := range s {
+		m.updateMessageProps(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+func TestPublishAttachesMessageProperties(t *testing.T) {
+	m, _ := initialModel(nil)
+	fc := &propsClient{}
+	m.mqttClient = &MQTTClient{Client: fc}
+	m.topics.Items = []topics.Item{{Name: "a"}}
+	m.topics.SetSelected(0)
+	m.message.SetPayload("hi")
+	props := &properties.Properties{ContentType: "text/plain"}
+	m.message.SetProperties(props)
+	m.SetFocus(idMessage)
+	m.handlePublishKey()
+	if fc.props != props {
+		t.Fatalf("expected properties passed to client, got %+v", fc.props)
+	}
+	items := m.history.Items()
+	if len(items) == 0 || items[len(items)-1].Properties != props {
+		t.Fatalf("expected published history item to carry properties")
+	}
+}
+
+func TestPublishPropertiesIgnoredWithoutV5(t *testing.T) {
+	m, _ := initialModel(nil)
+	m.mqttClient = &MQTTClient{Client: &mockClient{}}
+	m.topics.Items = []topics.Item{{Name: "a"}}
+	m.topics.SetSelected(0)
+	m.message.SetPayload("hi")
+	m.message.SetProperties(&properties.Properties{ContentType: "text/plain"})
+	m.SetFocus(idMessage)
+	m.handlePublishKey()
+	items := m.history.Items()
+	if len(items) < 2 || !strings.Contains(items[len(items)-2].Payload, "properties ignored") {
+		t.Fatalf("expected log about ignored properties, got %+v", items)
+	}
+	if items[len(items)-1].Properties != nil {
+		t.Fatalf("history should not record properties that were not sent")
+	}
+}
+
+func TestMessagePropsEditorSave(t *testing.T) {
+	m, _ := initialModel(nil)
+	m.SetFocus(idMessage)
+	m.handleMessagePropsKey()
+	if m.CurrentMode() != constants.ModeMessageProps {
+		t.Fatalf("expected properties mode, got %v", m.CurrentMode())
+	}
+	typeText(m, "text/plain")
+	for i := 0; i < 5; i++ {
+		m.updateMessageProps(tea.KeyMsg{Type: tea.KeyTab})
+	}
+	typeText(m, "tenant=abc")
+	m.updateMessageProps(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.CurrentMode() == constants.ModeMessageProps {
+		t.Fatalf("expected editor to close on save")
+	}
+	p := m.message.Properties()
+	if p == nil || p.ContentType != "text/plain" {
+		t.Fatalf("content type not saved: %+v", p)
+	}
+	if v, ok := p.Lookup("tenant"); !ok || v != "abc" {
+		t.Fatalf("user property not saved: %+v", p.User)
+	}
+	if !strings.Contains(m.message.View(), "[props]") {
+		t.Fatalf("expected message label to flag properties")
+	}
+}
+
+func TestMessagePropsEditorRejectsBadExpiry(t *testing.T) {
+	m, _ := initialModel(nil)
+	m.SetFocus(idMessage)
+	m.handleMessagePropsKey()
+	m.updateMessageProps(tea.KeyMsg{Type: tea.KeyTab})
+	m.updateMessageProps(tea.KeyMsg{Type: tea.KeyTab})
+	typeText(m, "soon")
+	m.updateMessageProps(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.CurrentMode() != constants.ModeMessageProps {
+		t.Fatalf("expected editor to stay open on invalid expiry")
+	}
+	if !strings.Contains(m.viewMessageProps(), "message expiry") {
+		t.Fatalf("expected validation error in view")
+	}
+	m.updateMessageProps(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.CurrentMode() == constants.ModeMessageProps || m.message.Properties() != nil {
+		t.Fatalf("expected cancel to close editor without properties")
+	}
+}
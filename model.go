@@ -129,6 +129,21 @@ type model struct {
 
 	layout layoutConfig
 
+	// requests tracks request/response exchanges awaiting replies.
+	requests requestState
+
+	// reconnect restores a lost broker connection with backoff.
+	reconnect reconnectState
+
+	// pending lists unacknowledged outbound packets of the active profile.
+	pending pendingState
+
+	// bridge forwards messages between two profiles in the background.
+	bridge bridgeState
+
+	// export holds the open export dialog.
+	export exportState
+
 	// components maps each application mode to its corresponding component
 	// implementation. These components handle mode-specific update and view
 	// logic which the model delegates to at runtime.
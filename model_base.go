@@ -35,4 +35,8 @@ var focusByMode = map[constants.AppMode][]string{
 	constants.ModeHistoryDetail:  {idHelp},
 	constants.ModeHelp:           {idHelp},
 	constants.ModeLogs:           {idHelp},
+	constants.ModeMessageProps:   {idHelp},
+	constants.ModePending:        {idHelp},
+	constants.ModeBridge:         {idHelp},
+	constants.ModeExport:         {idHelp},
 }
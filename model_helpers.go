@@ -52,6 +52,7 @@ func (m *model) startHistoryFilter() tea.Cmd {
 		topics = append(topics, t.Name)
 	}
 	var topic, payload string
+	var props map[string]string
 	var start, end time.Time
 	if m.history.FilterQuery() != "" {
 		ts, s, e, p := history.ParseQuery(m.history.FilterQuery())
@@ -59,11 +60,12 @@ func (m *model) startHistoryFilter() tea.Cmd {
 			topic = ts[0]
 		}
 		start, end, payload = s, e, p
+		props = history.ParseProperties(m.history.FilterQuery())
 	} else {
 		end = time.Now()
 		start = end.Add(-time.Hour)
 	}
-	hf := history.NewFilterForm(topics, topic, payload, start, end, m.history.ShowArchived())
+	hf := history.NewFilterForm(topics, topic, payload, props, start, end, m.history.ShowArchived())
 	m.history.SetFilterForm(&hf)
 	return m.SetMode(constants.ModeHistoryFilter)
 }
@@ -3,7 +3,6 @@ package emqutiti
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
@@ -104,36 +103,8 @@ func (s *historyStore) Append(m history.Message) error {
 	return nil
 }
 
-func (s *historyStore) Search(archived bool, topics []string, start, end time.Time, payload string) []history.Message {
-	var out []history.Message
-	for _, m := range s.msgs {
-		if m.Archived != archived {
-			continue
-		}
-		if len(topics) > 0 {
-			match := false
-			for _, t := range topics {
-				if m.Topic == t {
-					match = true
-					break
-				}
-			}
-			if !match {
-				continue
-			}
-		}
-		if !start.IsZero() && m.Timestamp.Before(start) {
-			continue
-		}
-		if !end.IsZero() && m.Timestamp.After(end) {
-			continue
-		}
-		if payload != "" && !strings.Contains(m.Payload, payload) {
-			continue
-		}
-		out = append(out, m)
-	}
-	return out
+func (s *historyStore) Query(q history.Query) (history.Page, error) {
+	return q.Select(s.msgs), nil
 }
 
 func (s *historyStore) Delete(string) error  { return nil }
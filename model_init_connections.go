@@ -67,5 +67,9 @@ func initComponents(m *model, order []string, connComp Component) {
 		constants.ModeHistoryDetail:  component{update: m.history.UpdateDetail, view: m.history.ViewDetail},
 		constants.ModeHelp:           m.help,
 		constants.ModeLogs:           m.logs,
+		constants.ModeMessageProps:   component{update: m.updateMessageProps, view: m.viewMessageProps},
+		constants.ModePending:        component{update: m.updatePending, view: m.viewPending},
+		constants.ModeBridge:         component{update: m.updateBridge, view: m.viewBridge},
+		constants.ModeExport:         component{update: m.updateExport, view: m.viewExport},
 	}
 }
@@ -1,10 +1,16 @@
 package emqutiti
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	broker "github.com/marang/emqutiti/broker"
+	"github.com/marang/emqutiti/clientopts"
 	connections "github.com/marang/emqutiti/connections"
 	mqttclient "github.com/marang/emqutiti/mqttclient"
+	"github.com/marang/emqutiti/properties"
+	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,8 +21,10 @@ const defaultTokenTimeout = 5 * time.Second
 
 type MQTTMessage struct {
 	Topic    string
-	Payload  string
+	Payload  []byte
 	Retained bool
+	// Properties holds the MQTT 5 publish properties, if any.
+	Properties *properties.Properties
 }
 
 type MQTTClient struct {
@@ -30,6 +38,33 @@ type MQTTClient struct {
 	done               chan struct{}
 	closeOnce          sync.Once
 	mu                 sync.RWMutex
+	// granted records the SUBACK result per topic filter.
+	granted map[string]byte
+	// stats counts inbound messages; spill holds overflow on disk.
+	stats bufferStats
+	spill *spillQueue
+	// spillReady wakes the spill drainer.
+	spillReady chan struct{}
+	// inflight persists unacknowledged packets when enabled on the profile.
+	inflight *mqttclient.InflightStore
+	// endpoints orders the profile's brokers for reconnect attempts.
+	endpoints *clientopts.BrokerEndpoints
+	// tunnel carries broker connections when the profile uses SSH.
+	tunnel *mqttclient.SSHTunnel
+	// credentials runs the profile's password command, if any.
+	credentials *connections.Credentials
+}
+
+// subscribeResult is implemented by subscribe tokens that expose SUBACK
+// reason codes.
+type subscribeResult interface {
+	Result() map[string]byte
+}
+
+// propertiesPublisher is implemented by clients that can attach MQTT 5
+// properties to a PUBLISH.
+type propertiesPublisher interface {
+	PublishWithProperties(topic string, qos byte, retained bool, payload interface{}, props *properties.Properties) mqtt.Token
 }
 
 // waitToken blocks until the MQTT token completes or the timeout expires.
@@ -50,58 +85,51 @@ func waitToken(token mqtt.Token, timeout time.Duration, action string) error {
 // NewMQTTClient creates and configures a new MQTT client based on the profile
 // details. Status updates are delivered via the provided callback.
 func NewMQTTClient(p connections.Profile, fn statusFunc) (*MQTTClient, error) {
-	opts := mqtt.NewClientOptions()
-
-	// Build option list from profile.
-	optionFns := []mqttclient.ClientOption{
-		mqttclient.WithBroker(p.BrokerURL()),
-		mqttclient.WithClientID(p.ClientID, p.RandomIDSuffix),
-		mqttclient.WithAuth(p.Username, p.Password),
-		mqttclient.WithTimeouts(p.ConnectTimeout, p.KeepAlive),
-		mqttclient.WithSession(p.AutoReconnect, p.CleanStart),
-		mqttclient.WithWill(p.LastWillEnabled, p.LastWillTopic, p.LastWillPayload, p.LastWillQos, p.LastWillRetain),
-	}
-
-	if opt, err := mqttclient.WithVersion(p.MQTTVersion); err != nil {
-		return nil, err
-	} else {
-		optionFns = append(optionFns, opt)
+	if p.Preset == connections.PresetSandbox {
+		if err := broker.EnsureLocal(net.JoinHostPort(p.Host, strconv.Itoa(p.Port)), p.Username, p.Password); err != nil {
+			return nil, err
+		}
 	}
-
-	if opt, err := mqttclient.WithTLS(p.SSL, p.SkipTLSVerify, p.CACertPath, p.ClientCertPath, p.ClientKeyPath); err != nil {
+	o, err := clientopts.Build(p, fn)
+	if err != nil {
 		return nil, err
-	} else {
-		optionFns = append(optionFns, opt)
-	}
-
-	for _, opt := range optionFns {
-		opt(opts)
 	}
+	opts := o.Options
+	// Reconnects are driven by the reconnect manager so they can be shown
+	// and followed by resubscribing.
+	opts.SetAutoReconnect(false)
 
-	opts.OnConnect = func(client mqtt.Client) {
-		if fn != nil {
-			fn("Connected to MQTT broker")
-		}
-	}
+	msgChan := make(chan MQTTMessage, messageBufferSize(p))
+	done := make(chan struct{})
+	mc := &MQTTClient{MessageChan: msgChan, done: done, inflight: o.Inflight, endpoints: o.Endpoints, tunnel: o.Tunnel, credentials: o.Credentials}
 	opts.OnConnectionLost = func(client mqtt.Client, err error) {
 		if fn != nil {
 			fn(fmt.Sprintf("Connection lost: %v", err))
 		}
 	}
 
-	msgChan := make(chan MQTTMessage, 20)
-	done := make(chan struct{})
-	mc := &MQTTClient{MessageChan: msgChan, done: done}
+	if p.SpillToDisk {
+		if q, err := openSpillQueue(spillAddr(), p.Name); err != nil {
+			if fn != nil {
+				fn(fmt.Sprintf("Spill to disk unavailable, dropping on overflow: %v", err))
+			}
+		} else {
+			mc.spill = q
+			mc.spillReady = make(chan struct{}, 1)
+			go mc.drainSpill()
+		}
+	}
 	opts.SetDefaultPublishHandler(func(client mqtt.Client, m mqtt.Message) {
 		_ = mc.enqueueMessage(m, fn)
 	})
 
-	client := mqtt.NewClient(opts)
+	client := o.NewClient()
 	mc.Client = client
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
 		mc.Disconnect()
 		return nil, fmt.Errorf("failed to connect: %w", token.Error())
 	}
+	mc.endpoints.Connected()
 
 	pubTimeout := time.Duration(p.PublishTimeout) * time.Second
 	subTimeout := time.Duration(p.SubscribeTimeout) * time.Second
@@ -121,17 +149,110 @@ func (m *MQTTClient) Publish(topic string, qos byte, retained bool, payload inte
 	return waitToken(token, m.publishTimeout, "publish")
 }
 
+// SupportsProperties reports whether the client can send MQTT 5 publish
+// properties.
+func (m *MQTTClient) SupportsProperties() bool {
+	_, ok := m.Client.(propertiesPublisher)
+	return ok
+}
+
+// ResponseInformation returns the response topic prefix assigned by an MQTT 5
+// broker, or an empty string.
+func (m *MQTTClient) ResponseInformation() string {
+	if v5, ok := m.Client.(*mqttclient.V5Client); ok {
+		return v5.ResponseInformation()
+	}
+	return ""
+}
+
+// PublishWithProperties behaves like Publish but attaches MQTT 5 properties.
+// Clients without MQTT 5 support publish without them.
+func (m *MQTTClient) PublishWithProperties(topic string, qos byte, retained bool, payload interface{}, props *properties.Properties) error {
+	pp, ok := m.Client.(propertiesPublisher)
+	if !ok || props.IsEmpty() {
+		return m.Publish(topic, qos, retained, payload)
+	}
+	token := pp.PublishWithProperties(topic, qos, retained, payload, props)
+	return waitToken(token, m.publishTimeout, "publish")
+}
+
+// Inflight returns the on-disk store of unacknowledged packets, or nil when
+// the profile keeps them in memory.
+func (m *MQTTClient) Inflight() *mqttclient.InflightStore { return m.inflight }
+
+// TunnelStatus describes the SSH tunnel, or returns an empty string when the
+// profile connects directly.
+func (m *MQTTClient) TunnelStatus() string {
+	if m == nil || m.tunnel == nil {
+		return ""
+	}
+	return m.tunnel.Status()
+}
+
+// Reconnect re-establishes a lost connection on the same client so message
+// handlers stay in place. Subscriptions must be restored by the caller.
+func (m *MQTTClient) Reconnect() error {
+	if m.credentials != nil {
+		// Refresh expired credentials here so command failures are
+		// reported instead of retrying with a stale password.
+		if _, err := m.credentials.Resolve(context.Background()); err != nil {
+			return err
+		}
+	}
+	m.endpoints.Rotate()
+	token := m.Client.Connect()
+	token.Wait()
+	if err := token.Error(); err != nil {
+		return err
+	}
+	m.endpoints.Connected()
+	return nil
+}
+
 // Subscribe registers callback for messages on topic at the specified QoS.
 // The method blocks until the broker acknowledges the subscription and
-// returns an error if the request fails.
+// returns an error if the request fails or the broker rejects the filter.
+// The granted QoS is available from GrantedQoS afterwards.
 func (m *MQTTClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) error {
 	token := m.Client.Subscribe(topic, qos, callback)
-	return waitToken(token, m.subscribeTimeout, "subscribe")
+	if err := waitToken(token, m.subscribeTimeout, "subscribe"); err != nil {
+		return err
+	}
+	res, ok := token.(subscribeResult)
+	if !ok {
+		return nil
+	}
+	code, ok := res.Result()[topic]
+	if !ok {
+		return nil
+	}
+	m.mu.Lock()
+	if m.granted == nil {
+		m.granted = make(map[string]byte)
+	}
+	m.granted[topic] = code
+	m.mu.Unlock()
+	if code >= 0x80 {
+		return fmt.Errorf("subscribe failed: rejected by broker (0x%02X)", code)
+	}
+	return nil
+}
+
+// GrantedQoS returns the QoS granted for topic by the last SUBACK. Codes of
+// 0x80 and above indicate a rejected subscription.
+func (m *MQTTClient) GrantedQoS(topic string) (byte, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	code, ok := m.granted[topic]
+	return code, ok
 }
 
 // Unsubscribe removes the subscription for the topic. It waits for
 // completion and returns an error if the unsubscribe request fails.
 func (m *MQTTClient) Unsubscribe(topic string) error {
+	m.mu.Lock()
+	delete(m.granted, topic)
+	m.mu.Unlock()
 	token := m.Client.Unsubscribe(topic)
 	return waitToken(token, m.unsubscribeTimeout, "unsubscribe")
 }
@@ -154,6 +275,12 @@ func (m *MQTTClient) Disconnect() {
 			close(m.MessageChan)
 			m.MessageChan = nil
 		}
+		if m.spill != nil {
+			m.spill.close()
+		}
+		if m.tunnel != nil {
+			m.tunnel.Close()
+		}
 	})
 }
 
@@ -167,21 +294,42 @@ func (m *MQTTClient) safeMessageChan() chan MQTTMessage {
 }
 
 func (m *MQTTClient) enqueueMessage(msg mqtt.Message, fn statusFunc) error {
+	out := MQTTMessage{Topic: msg.Topic(), Payload: msg.Payload(), Retained: msg.Retained(), Properties: mqttclient.MessageProperties(msg)}
+	spill, err := m.deliver(out)
+	if err != nil || !spill {
+		return err
+	}
+	if m.spill != nil {
+		// Written without the lock so that a slow proxy does not hold up
+		// Disconnect.
+		return m.spillMessage(out, fn)
+	}
+	m.stats.dropped.Add(1)
+	if fn != nil {
+		fn(fmt.Sprintf("Dropped MQTT message on %s: message buffer full", msg.Topic()))
+	}
+	return nil
+}
+
+// deliver counts msg as received and offers it to the buffer. It reports
+// whether msg did not fit or must queue behind spilled messages.
+func (m *MQTTClient) deliver(msg MQTTMessage) (bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.MessageChan == nil {
-		return errors.New("message channel is closed")
+		return false, errors.New("message channel is closed")
+	}
+	m.stats.received.Add(1)
+	if m.spill.len() > 0 {
+		// Keep arrival order while older messages wait on disk.
+		return true, nil
 	}
-	out := MQTTMessage{Topic: msg.Topic(), Payload: string(msg.Payload()), Retained: msg.Retained()}
 	select {
 	case <-m.done:
-		return errors.New("message channel is closed")
-	case m.MessageChan <- out:
-		return nil
+		return false, errors.New("message channel is closed")
+	case m.MessageChan <- msg:
+		return false, nil
 	default:
-		if fn != nil {
-			fn(fmt.Sprintf("Dropped MQTT message on %s: message buffer full", msg.Topic()))
-		}
-		return nil
+		return true, nil
 	}
 }
@@ -0,0 +1,184 @@
+package mqttclient
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho/session/state"
+	storefile "github.com/eclipse/paho.golang/paho/store/file"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	v3packets "github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// File name parts of the MQTT 5 session store. MQTT 3 packets use the
+// "o.<id>.msg" and "i.<id>.msg" names of paho's FileStore, so both protocol
+// versions can share a directory.
+const (
+	v5ClientPrefix = "c."
+	v5ServerPrefix = "s."
+	v5Extension    = ".pkt"
+	v3Outbound     = "o."
+)
+
+// PendingPacket describes an outbound packet that the broker has not
+// acknowledged yet.
+type PendingPacket struct {
+	ID uint16
+	// Kind is "publish" for a PUBLISH awaiting PUBACK/PUBREC and "pubrel"
+	// for a QoS 2 release awaiting PUBCOMP.
+	Kind     string
+	Topic    string
+	QoS      byte
+	Retained bool
+	Payload  []byte
+}
+
+// InflightStore persists unacknowledged QoS 1/2 packets on disk so a session
+// started with CleanStart=false can be resumed after a restart. It
+// implements mqtt.Store for MQTT 3 clients; MQTT 5 clients use its session
+// state.
+type InflightStore struct {
+	dir string
+	v3  *mqtt.FileStore
+
+	v5client, v5server *storefile.Store
+	session            *state.State
+}
+
+// OpenInflightStore opens the store in dir for the given protocol version.
+func OpenInflightStore(dir, version string) (*InflightStore, error) {
+	s := &InflightStore{dir: dir}
+	if version != "5" {
+		s.v3 = mqtt.NewFileStore(dir)
+		s.v3.Open()
+		return s, nil
+	}
+	var err error
+	if s.v5client, err = storefile.New(dir, v5ClientPrefix, v5Extension); err != nil {
+		return nil, fmt.Errorf("open inflight store: %w", err)
+	}
+	if s.v5server, err = storefile.New(dir, v5ServerPrefix, v5Extension); err != nil {
+		return nil, fmt.Errorf("open inflight store: %w", err)
+	}
+	s.session = state.New(s.v5client, s.v5server)
+	return s, nil
+}
+
+// WithInflightStore persists in-flight packets in s. A nil store keeps the
+// default in-memory store.
+func WithInflightStore(s *InflightStore) ClientOption {
+	return func(o *mqtt.ClientOptions) {
+		if s != nil {
+			o.SetStore(s)
+		}
+	}
+}
+
+// Dir returns the directory holding the packets.
+func (s *InflightStore) Dir() string { return s.dir }
+
+// Pending lists the outbound packets awaiting acknowledgement ordered by
+// packet ID.
+func (s *InflightStore) Pending() ([]PendingPacket, error) {
+	var out []PendingPacket
+	if s.v3 != nil {
+		for _, key := range s.v3.All() {
+			if !strings.HasPrefix(key, v3Outbound) {
+				continue
+			}
+			id, err := strconv.ParseUint(key[len(v3Outbound):], 10, 16)
+			if err != nil {
+				continue
+			}
+			p := PendingPacket{ID: uint16(id)}
+			switch cp := s.v3.Get(key).(type) {
+			case *v3packets.PublishPacket:
+				p.Kind, p.Topic, p.QoS, p.Retained, p.Payload = "publish", cp.TopicName, cp.Qos, cp.Retain, cp.Payload
+			case *v3packets.PubrelPacket:
+				p.Kind = "pubrel"
+			default:
+				continue
+			}
+			out = append(out, p)
+		}
+	} else {
+		ids, err := s.v5client.List()
+		if err != nil {
+			return nil, fmt.Errorf("list inflight packets: %w", err)
+		}
+		for _, id := range ids {
+			p, ok, err := s.readV5(id)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out = append(out, p)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *InflightStore) readV5(id uint16) (PendingPacket, bool, error) {
+	r, err := s.v5client.Get(id)
+	if err != nil {
+		return PendingPacket{}, false, fmt.Errorf("read inflight packet %d: %w", id, err)
+	}
+	defer r.Close()
+	cp, err := packets.ReadPacket(r)
+	if err != nil {
+		return PendingPacket{}, false, fmt.Errorf("read inflight packet %d: %w", id, err)
+	}
+	switch c := cp.Content.(type) {
+	case *packets.Publish:
+		return PendingPacket{ID: id, Kind: "publish", Topic: c.Topic, QoS: c.QoS, Retained: c.Retain, Payload: c.Payload}, true, nil
+	case *packets.Pubrel:
+		return PendingPacket{ID: id, Kind: "pubrel"}, true, nil
+	}
+	return PendingPacket{}, false, nil
+}
+
+// Discard removes the outbound packet with the given ID so it is not resent
+// when the session resumes.
+func (s *InflightStore) Discard(id uint16) error {
+	if s.v3 != nil {
+		s.v3.Del(fmt.Sprintf("%s%d", v3Outbound, id))
+		return nil
+	}
+	if err := s.v5client.Delete(id); err != nil {
+		return fmt.Errorf("discard inflight packet %d: %w", id, err)
+	}
+	return nil
+}
+
+// Session returns the MQTT 5 session state backed by the store, or nil for
+// MQTT 3 stores.
+func (s *InflightStore) Session() *state.State { return s.session }
+
+// Open implements mqtt.Store.
+func (s *InflightStore) Open() { s.v3.Open() }
+
+// Put implements mqtt.Store.
+func (s *InflightStore) Put(key string, m v3packets.ControlPacket) { s.v3.Put(key, m) }
+
+// Get implements mqtt.Store.
+func (s *InflightStore) Get(key string) v3packets.ControlPacket { return s.v3.Get(key) }
+
+// All implements mqtt.Store.
+func (s *InflightStore) All() []string { return s.v3.All() }
+
+// Del implements mqtt.Store.
+func (s *InflightStore) Del(key string) { s.v3.Del(key) }
+
+// Reset implements mqtt.Store.
+func (s *InflightStore) Reset() { s.v3.Reset() }
+
+// Close implements mqtt.Store. The files stay readable so pending packets
+// can be listed and discarded while disconnected.
+func (s *InflightStore) Close() {}
+
+var _ mqtt.Store = (*InflightStore)(nil)
@@ -0,0 +1,78 @@
+package mqttclient
+
+import (
+	"testing"
+
+	"github.com/eclipse/paho.golang/packets"
+	v3packets "github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+func TestInflightStoreV3PendingAndDiscard(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenInflightStore(dir, "4")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	pub := v3packets.NewControlPacket(v3packets.Publish).(*v3packets.PublishPacket)
+	pub.TopicName, pub.Qos, pub.MessageID, pub.Payload = "a/b", 1, 7, []byte("hi")
+	s.Put("o.7", pub)
+	rel := v3packets.NewControlPacket(v3packets.Pubrel).(*v3packets.PubrelPacket)
+	rel.MessageID = 3
+	s.Put("o.3", rel)
+	s.Put("i.9", pub)
+	s.Close()
+
+	// A fresh store sees the packets written before the restart.
+	s, err = OpenInflightStore(dir, "4")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := s.Pending()
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 3 || got[0].Kind != "pubrel" || got[1].Topic != "a/b" || string(got[1].Payload) != "hi" || got[1].QoS != 1 {
+		t.Fatalf("unexpected pending packets: %+v", got)
+	}
+	if err := s.Discard(7); err != nil {
+		t.Fatalf("discard: %v", err)
+	}
+	if got, _ := s.Pending(); len(got) != 1 || got[0].ID != 3 {
+		t.Fatalf("expected packet 7 discarded, got %+v", got)
+	}
+}
+
+func TestInflightStoreV5PendingAndDiscard(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenInflightStore(dir, "5")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if s.Session() == nil {
+		t.Fatalf("expected session state for MQTT 5")
+	}
+	cp := packets.NewControlPacket(packets.PUBLISH)
+	pub := cp.Content.(*packets.Publish)
+	pub.Topic, pub.QoS, pub.PacketID, pub.Retain, pub.Payload = "x", 2, 5, true, []byte{0xff}
+	if err := s.v5client.Put(5, packets.PUBLISH, cp); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	s, err = OpenInflightStore(dir, "5")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := s.Pending()
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != 5 || got[0].Topic != "x" || got[0].QoS != 2 || !got[0].Retained {
+		t.Fatalf("unexpected pending packets: %+v", got)
+	}
+	if err := s.Discard(5); err != nil {
+		t.Fatalf("discard: %v", err)
+	}
+	if got, _ := s.Pending(); len(got) != 0 {
+		t.Fatalf("expected store empty, got %+v", got)
+	}
+}
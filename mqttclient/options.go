@@ -1,10 +1,10 @@
 package mqttclient
 
 import (
-	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"os"
+	"net"
+	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -21,6 +21,15 @@ func WithBroker(url string) ClientOption {
 	}
 }
 
+// WithBrokers adds the broker URLs in the order they are tried.
+func WithBrokers(urls []string) ClientOption {
+	return func(o *mqtt.ClientOptions) {
+		for _, u := range urls {
+			o.AddBroker(u)
+		}
+	}
+}
+
 // WithClientID sets the client ID, optionally adding a random suffix.
 func WithClientID(id string, random bool) ClientOption {
 	return func(o *mqtt.ClientOptions) {
@@ -41,6 +50,16 @@ func WithAuth(user, pass string) ClientOption {
 	}
 }
 
+// WithCredentials asks provider for the username and password on every
+// connect, overriding WithAuth. A nil provider leaves the options unchanged.
+func WithCredentials(provider func() (username, password string)) ClientOption {
+	return func(o *mqtt.ClientOptions) {
+		if provider != nil {
+			o.SetCredentialsProvider(provider)
+		}
+	}
+}
+
 // WithVersion sets the MQTT protocol version if specified.
 func WithVersion(ver string) (ClientOption, error) {
 	if ver == "" {
@@ -86,31 +105,50 @@ func WithWill(enabled bool, topic, payload string, qos int, retain bool) ClientO
 	}
 }
 
-// WithTLS applies TLS configuration when SSL is enabled.
+// WithTLS applies TLS configuration when SSL is enabled. See WithTLSOptions
+// for the full set of settings.
 func WithTLS(ssl bool, skipVerify bool, caCertPath, clientCertPath, clientKeyPath string) (ClientOption, error) {
-	if !ssl {
-		return func(o *mqtt.ClientOptions) {}, nil
-	}
-	tlsCfg := &tls.Config{InsecureSkipVerify: skipVerify}
-	if caCertPath != "" {
-		caData, err := os.ReadFile(caCertPath)
+	return WithTLSOptions(ssl, TLSOptions{
+		SkipVerify:     skipVerify,
+		CACertPath:     caCertPath,
+		ClientCertPath: clientCertPath,
+		ClientKeyPath:  clientKeyPath,
+	})
+}
+
+// WithWebsocket sets the HTTP headers sent with the websocket upgrade and
+// the proxy used to reach ws:// and wss:// brokers. proxyURL uses the http
+// or https scheme for an HTTP CONNECT proxy or socks5 for a SOCKS5 proxy;
+// when empty the proxy environment variables apply. An https proxy is
+// verified like the broker, with the CA and skip-verify TLS settings.
+func WithWebsocket(headers http.Header, proxyURL string) (ClientOption, error) {
+	var proxy mqtt.ProxyFunction
+	tlsProxy := false
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
 		if err != nil {
-			return nil, fmt.Errorf("read CA cert: %w", err)
-		}
-		pool := x509.NewCertPool()
-		if !pool.AppendCertsFromPEM(caData) {
-			return nil, fmt.Errorf("invalid CA cert")
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
 		}
-		tlsCfg.RootCAs = pool
-	}
-	if clientCertPath != "" && clientKeyPath != "" {
-		cert, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
-		if err != nil {
-			return nil, fmt.Errorf("load client cert: %w", err)
+		switch u.Scheme {
+		case "http", "socks5":
+		case "https":
+			tlsProxy = true
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", u.Scheme)
 		}
-		tlsCfg.Certificates = []tls.Certificate{cert}
+		proxy = http.ProxyURL(u)
 	}
 	return func(o *mqtt.ClientOptions) {
-		o.SetTLSConfig(tlsCfg)
+		if len(headers) > 0 {
+			o.SetHTTPHeaders(headers)
+		}
+		if proxy != nil {
+			o.SetWebsocketOptions(&mqtt.WebsocketOptions{Proxy: proxy})
+		}
+		if tlsProxy && o.CustomOpenConnectionFn == nil {
+			// paho's own websocket dialer cannot use TLS to the proxy.
+			var d net.Dialer
+			o.SetCustomOpenConnectionFn(openConnection(d.DialContext))
+		}
 	}, nil
 }
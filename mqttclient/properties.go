@@ -0,0 +1,57 @@
+package mqttclient
+
+import (
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/properties"
+)
+
+// pahoProperties converts p into PUBLISH properties for paho.golang.
+func pahoProperties(p *properties.Properties) *paho.PublishProperties {
+	if p.IsEmpty() {
+		return nil
+	}
+	pp := &paho.PublishProperties{
+		ContentType:     p.ContentType,
+		PayloadFormat:   p.PayloadFormat,
+		MessageExpiry:   p.MessageExpiry,
+		ResponseTopic:   p.ResponseTopic,
+		CorrelationData: p.CorrelationData,
+	}
+	for _, u := range p.User {
+		pp.User = append(pp.User, paho.UserProperty{Key: u.Key, Value: u.Value})
+	}
+	return pp
+}
+
+// propertiesFromPaho converts received PUBLISH properties. It returns nil
+// when no properties are set.
+func propertiesFromPaho(pp *paho.PublishProperties) *properties.Properties {
+	if pp == nil {
+		return nil
+	}
+	p := &properties.Properties{
+		ContentType:     pp.ContentType,
+		PayloadFormat:   pp.PayloadFormat,
+		MessageExpiry:   pp.MessageExpiry,
+		ResponseTopic:   pp.ResponseTopic,
+		CorrelationData: pp.CorrelationData,
+	}
+	for _, u := range pp.User {
+		p.User = append(p.User, properties.UserProperty{Key: u.Key, Value: u.Value})
+	}
+	if p.IsEmpty() {
+		return nil
+	}
+	return p
+}
+
+// MessageProperties returns the MQTT 5 properties carried by msg or nil for
+// MQTT 3.1.1 messages and messages without properties.
+func MessageProperties(msg mqtt.Message) *properties.Properties {
+	if m, ok := msg.(interface{ Properties() *properties.Properties }); ok {
+		return m.Properties()
+	}
+	return nil
+}
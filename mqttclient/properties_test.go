@@ -0,0 +1,83 @@
+package mqttclient
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/properties"
+)
+
+func TestPropertiesPahoRoundTrip(t *testing.T) {
+	if pahoProperties(&properties.Properties{}) != nil {
+		t.Fatalf("empty properties should not produce packet properties")
+	}
+	p := &properties.Properties{ContentType: "text/plain", CorrelationData: []byte("c1"), User: []properties.UserProperty{{Key: "k", Value: "v"}}}
+	got := propertiesFromPaho(pahoProperties(p))
+	if !reflect.DeepEqual(got, p) {
+		t.Fatalf("round trip mismatch: %+v != %+v", got, p)
+	}
+	if propertiesFromPaho(&paho.PublishProperties{}) != nil {
+		t.Fatalf("empty packet properties should convert to nil")
+	}
+}
+
+func TestV5PublishPropertiesWithBroker(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	url := startV5Broker(t)
+
+	opts := mqtt.NewClientOptions()
+	WithBroker(url)(opts)
+	WithClientID("v5-props", false)(opts)
+	WithAuth("user", "secret")(opts)
+	WithTimeouts(2, 30)(opts)
+	got := make(chan mqtt.Message, 1)
+	opts.SetDefaultPublishHandler(func(_ mqtt.Client, m mqtt.Message) { got <- m })
+
+	// Brokers may strip user properties when problem information is not
+	// requested.
+	c := NewV5Client(opts, V5Properties{RequestProblemInfo: true})
+	if tok := c.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("connect: %v", tok.Error())
+	}
+	defer c.Disconnect(0)
+	if tok := c.Subscribe("props/#", 1, nil); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("subscribe: %v", tok.Error())
+	}
+	pf := byte(1)
+	exp := uint32(120)
+	sent := &properties.Properties{
+		ContentType:     "application/json",
+		PayloadFormat:   &pf,
+		MessageExpiry:   &exp,
+		ResponseTopic:   "props/reply",
+		CorrelationData: []byte("req-1"),
+		User:            []properties.UserProperty{{Key: "tenant", Value: "abc"}},
+	}
+	if tok := c.PublishWithProperties("props/a", 1, false, `{"a":1}`, sent); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("publish: %v", tok.Error())
+	}
+	select {
+	case m := <-got:
+		p := MessageProperties(m)
+		if p == nil {
+			t.Fatalf("expected properties on received message")
+		}
+		if p.ContentType != sent.ContentType || p.ResponseTopic != sent.ResponseTopic || string(p.CorrelationData) != "req-1" {
+			t.Fatalf("unexpected properties %+v", p)
+		}
+		if v, ok := p.Lookup("tenant"); !ok || v != "abc" {
+			t.Fatalf("user property lost: %+v", p.User)
+		}
+		if p.PayloadFormat == nil || *p.PayloadFormat != 1 || p.MessageExpiry == nil {
+			t.Fatalf("format/expiry lost: %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message not received")
+	}
+}
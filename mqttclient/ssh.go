@@ -0,0 +1,304 @@
+package mqttclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHConfig describes the SSH server used to reach brokers.
+type SSHConfig struct {
+	// Addr is host[:port] of the SSH server; the port defaults to 22.
+	Addr string
+	// User defaults to the current OS user.
+	User string
+	// KeyPath is a private key file. Password is its passphrase or, without
+	// a key, the login password. With neither, a running ssh-agent is used.
+	KeyPath  string
+	Password string
+	// KnownHosts defaults to ~/.ssh/known_hosts.
+	KnownHosts string
+	// InsecureHostKey accepts any host key.
+	InsecureHostKey bool
+}
+
+// SSHTunnel dials brokers through an SSH connection that is opened on first
+// use and reopened when it drops.
+type SSHTunnel struct {
+	addr   string
+	config *ssh.ClientConfig
+	// agent is the ssh-agent socket used for authentication, connected for
+	// the handshake only.
+	agent string
+	// OnStatus, if set, is called whenever Status changes.
+	OnStatus func(status string)
+
+	mu     sync.Mutex
+	client *ssh.Client
+	status string
+}
+
+// NewSSHTunnel validates cfg and loads the credentials. No connection is
+// made until the first dial.
+func NewSSHTunnel(cfg SSHConfig) (*SSHTunnel, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("ssh: no host configured")
+	}
+	addr := cfg.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	name := cfg.User
+	if name == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("ssh: no user configured: %w", err)
+		}
+		name = u.Username
+	}
+	auth, agentSock, err := sshAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := sshHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &SSHTunnel{
+		addr: addr,
+		config: &ssh.ClientConfig{
+			User:            name,
+			Auth:            auth,
+			HostKeyCallback: hostKey,
+		},
+		agent:  agentSock,
+		status: addr + " idle",
+	}, nil
+}
+
+// sshAuth returns the authentication methods of cfg, or the ssh-agent socket
+// to authenticate with when it sets neither a key nor a password.
+func sshAuth(cfg SSHConfig) ([]ssh.AuthMethod, string, error) {
+	if cfg.KeyPath != "" {
+		pem, err := os.ReadFile(expandHome(cfg.KeyPath))
+		if err != nil {
+			return nil, "", fmt.Errorf("ssh: read key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			if cfg.Password == "" {
+				return nil, "", fmt.Errorf("ssh: key %s is encrypted and no passphrase is set", cfg.KeyPath)
+			}
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(cfg.Password))
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("ssh: parse key: %w", err)
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, "", nil
+	}
+	if cfg.Password != "" {
+		pw := cfg.Password
+		answer := func(_, _ string, questions []string, _ []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = pw
+			}
+			return answers, nil
+		}
+		return []ssh.AuthMethod{ssh.Password(pw), ssh.KeyboardInteractive(answer)}, "", nil
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		return nil, sock, nil
+	}
+	return nil, "", errors.New("ssh: set a key path or password, or run ssh-agent")
+}
+
+func sshHostKeyCallback(cfg SSHConfig) (ssh.HostKeyCallback, error) {
+	if cfg.InsecureHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	path := expandHome(cfg.KnownHosts)
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("ssh: locate known_hosts: %w", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	cb, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: load known hosts: %w", err)
+	}
+	return cb, nil
+}
+
+// expandHome replaces a leading "~/" with the user's home directory.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// Addr returns the host:port of the SSH server.
+func (t *SSHTunnel) Addr() string { return t.addr }
+
+// Status describes the tunnel, e.g. "bastion:22 open".
+func (t *SSHTunnel) Status() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// setStatus must be called with t.mu held. It returns the callback to run
+// after unlocking.
+func (t *SSHTunnel) setStatus(s string) func() {
+	s = t.addr + " " + s
+	if s == t.status || t.OnStatus == nil {
+		t.status = s
+		return func() {}
+	}
+	t.status = s
+	cb := t.OnStatus
+	return func() { cb(s) }
+}
+
+// connect returns the SSH client, opening the connection if needed.
+func (t *SSHTunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	if c := t.client; c != nil {
+		t.mu.Unlock()
+		return c, nil
+	}
+	notify := t.setStatus("connecting")
+	t.mu.Unlock()
+	notify()
+
+	c, err := t.dial(ctx)
+	t.mu.Lock()
+	if err != nil {
+		notify = t.setStatus("failed: " + err.Error())
+		t.mu.Unlock()
+		notify()
+		return nil, err
+	}
+	var dup *ssh.Client
+	if cur := t.client; cur != nil {
+		// Another dial won the race; keep its connection.
+		dup, c = c, cur
+	} else {
+		t.client = c
+		go t.watch(c)
+	}
+	notify = t.setStatus("open")
+	t.mu.Unlock()
+	notify()
+	if dup != nil {
+		dup.Close()
+	}
+	return c, nil
+}
+
+func (t *SSHTunnel) dial(ctx context.Context) (*ssh.Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	config := t.config
+	if t.agent != "" {
+		ac, err := d.DialContext(ctx, "unix", t.agent)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ssh: connect to agent: %w", err)
+		}
+		defer ac.Close()
+		cp := *config
+		cp.Auth = []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(ac).Signers)}
+		config = &cp
+	}
+	cc, chans, reqs, err := ssh.NewClientConn(conn, t.addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(cc, chans, reqs), nil
+}
+
+// watch marks the tunnel down when the SSH connection ends so the next dial
+// reopens it.
+func (t *SSHTunnel) watch(c *ssh.Client) {
+	err := c.Wait()
+	t.mu.Lock()
+	if t.client != c {
+		t.mu.Unlock()
+		return
+	}
+	t.client = nil
+	status := "down"
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		status += ": " + err.Error()
+	}
+	notify := t.setStatus(status)
+	t.mu.Unlock()
+	notify()
+}
+
+// DialContext opens a connection to addr from the SSH server.
+func (t *SSHTunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	c, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel to %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// Close shuts the SSH connection down. OnStatus is not called.
+func (t *SSHTunnel) Close() error {
+	t.mu.Lock()
+	c := t.client
+	t.client = nil
+	t.status = t.addr + " closed"
+	t.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	return c.Close()
+}
+
+// WithSSHTunnel routes broker connections through t. A nil tunnel dials
+// brokers directly.
+func WithSSHTunnel(t *SSHTunnel) ClientOption {
+	return func(o *mqtt.ClientOptions) {
+		if t == nil {
+			return
+		}
+		o.SetCustomOpenConnectionFn(openConnection(t.DialContext))
+	}
+}
@@ -0,0 +1,343 @@
+package mqttclient
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer is a minimal SSH server that forwards direct-tcpip channels.
+type sshServer struct {
+	addr    string
+	hostKey ssh.PublicKey
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// startSSHServer accepts the password "pw" and, if set, the public key
+// clientKey.
+func startSSHServer(t *testing.T, clientKey ssh.PublicKey) *sshServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("host signer: %v", err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, pw []byte) (*ssh.Permissions, error) {
+			if string(pw) == "pw" {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if clientKey != nil && string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	cfg.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &sshServer{addr: ln.Addr().String(), hostKey: signer.PublicKey()}
+	t.Cleanup(func() {
+		ln.Close()
+		s.drop()
+	})
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, c)
+			s.mu.Unlock()
+			go s.serve(c, cfg)
+		}
+	}()
+	return s
+}
+
+func (s *sshServer) serve(c net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(c, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "direct-tcpip" {
+			nc.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(nc.ExtraData(), &target); err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		up, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		ch, creqs, err := nc.Accept()
+		if err != nil {
+			up.Close()
+			continue
+		}
+		go ssh.DiscardRequests(creqs)
+		go func() {
+			io.Copy(ch, up)
+			ch.Close()
+		}()
+		go func() {
+			io.Copy(up, ch)
+			up.Close()
+		}()
+	}
+}
+
+// drop closes all SSH connections as if the server went away.
+func (s *sshServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func TestSSHTunnelCarriesMQTT(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	broker := startV5Broker(t)
+	srv := startSSHServer(t, nil)
+	for _, version := range []string{"4", "5"} {
+		t.Run("v"+version, func(t *testing.T) {
+			tunnel, err := NewSSHTunnel(SSHConfig{Addr: srv.addr, User: "u", Password: "pw", InsecureHostKey: true})
+			if err != nil {
+				t.Fatalf("tunnel: %v", err)
+			}
+			defer tunnel.Close()
+			var mu sync.Mutex
+			var statuses []string
+			tunnel.OnStatus = func(s string) {
+				mu.Lock()
+				statuses = append(statuses, s)
+				mu.Unlock()
+			}
+			opts := mqtt.NewClientOptions()
+			WithBroker(broker)(opts)
+			WithAuth("user", "secret")(opts)
+			WithTimeouts(5, 30)(opts)
+			WithSession(false, true)(opts)
+			WithSSHTunnel(tunnel)(opts)
+			opt, _ := WithVersion(version)
+			opt(opts)
+			c := NewClient(opts, version, V5Properties{})
+			if tok := c.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+				t.Fatalf("connect: %v", tok.Error())
+			}
+			defer c.Disconnect(0)
+			if got := tunnel.Status(); got != srv.addr+" open" {
+				t.Fatalf("status = %q", got)
+			}
+
+			// The tunnel reopens after the SSH connection drops.
+			srv.drop()
+			deadline := time.Now().Add(5 * time.Second)
+			for !strings.HasPrefix(tunnel.Status(), srv.addr+" down") {
+				if time.Now().After(deadline) {
+					t.Fatalf("tunnel not marked down: %q", tunnel.Status())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if tok := c.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+				t.Fatalf("reconnect: %v", tok.Error())
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			want := []string{"connecting", "open", "down", "connecting", "open"}
+			if len(statuses) != len(want) {
+				t.Fatalf("statuses = %v", statuses)
+			}
+			for i, w := range want {
+				if !strings.HasPrefix(statuses[i], srv.addr+" "+w) {
+					t.Fatalf("statuses = %v, want %v", statuses, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSSHTunnelEncryptedKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("phrase"))
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	if _, err := NewSSHTunnel(SSHConfig{Addr: "h", User: "u", KeyPath: keyPath, InsecureHostKey: true}); err == nil || !strings.Contains(err.Error(), "no passphrase") {
+		t.Fatalf("expected missing passphrase error, got %v", err)
+	}
+	sshPub, _ := ssh.NewPublicKey(pub)
+	srv := startSSHServer(t, sshPub)
+	tunnel, err := NewSSHTunnel(SSHConfig{Addr: srv.addr, User: "u", KeyPath: keyPath, Password: "phrase", InsecureHostKey: true})
+	if err != nil {
+		t.Fatalf("tunnel: %v", err)
+	}
+	defer tunnel.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Write([]byte("hi"))
+			c.Close()
+		}
+	}()
+	conn, err := tunnel.DialContext(t.Context(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if b, _ := io.ReadAll(conn); string(b) != "hi" {
+		t.Fatalf("read %q through tunnel", b)
+	}
+}
+
+func TestSSHTunnelChecksHostKey(t *testing.T) {
+	srv := startSSHServer(t, nil)
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _ := ssh.NewPublicKey(other)
+	known := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(srv.addr)}, otherKey)
+	if err := os.WriteFile(known, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+	tunnel, err := NewSSHTunnel(SSHConfig{Addr: srv.addr, User: "u", Password: "pw", KnownHosts: known})
+	if err != nil {
+		t.Fatalf("tunnel: %v", err)
+	}
+	if _, err := tunnel.DialContext(t.Context(), "tcp", "127.0.0.1:1"); err == nil {
+		t.Fatalf("expected host key mismatch")
+	}
+	if got := tunnel.Status(); !strings.HasPrefix(got, srv.addr+" failed: ") {
+		t.Fatalf("status = %q", got)
+	}
+
+	line = knownhosts.Line([]string{knownhosts.Normalize(srv.addr)}, srv.hostKey)
+	if err := os.WriteFile(known, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+	tunnel, err = NewSSHTunnel(SSHConfig{Addr: srv.addr, User: "u", Password: "pw", KnownHosts: known})
+	if err != nil {
+		t.Fatalf("tunnel: %v", err)
+	}
+	defer tunnel.Close()
+	if _, err := tunnel.connect(t.Context()); err != nil {
+		t.Fatalf("connect with known host key: %v", err)
+	}
+}
+
+func TestSSHTunnelClosesAgentConnection(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatalf("add key: %v", err)
+	}
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	var open sync.WaitGroup
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			open.Add(1)
+			go func() {
+				defer open.Done()
+				agent.ServeAgent(keyring, c)
+				c.Close()
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	signer, _ := ssh.NewSignerFromKey(priv)
+	srv := startSSHServer(t, signer.PublicKey())
+	tunnel, err := NewSSHTunnel(SSHConfig{Addr: srv.addr, User: "u", InsecureHostKey: true})
+	if err != nil {
+		t.Fatalf("tunnel: %v", err)
+	}
+	defer tunnel.Close()
+	for range 2 {
+		if _, err := tunnel.connect(t.Context()); err != nil {
+			t.Fatalf("connect with agent: %v", err)
+		}
+		srv.drop()
+		for deadline := time.Now().Add(5 * time.Second); !strings.HasPrefix(tunnel.Status(), srv.addr+" down"); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("tunnel not marked down: %q", tunnel.Status())
+			}
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		open.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent connections left open")
+	}
+}
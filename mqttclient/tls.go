@@ -0,0 +1,289 @@
+package mqttclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// TLSOptions describes the TLS settings of a broker connection.
+type TLSOptions struct {
+	SkipVerify bool
+	// CACertPath is a PEM bundle of trusted CAs. With SystemRoots they are
+	// trusted in addition to the system pool instead of replacing it.
+	CACertPath  string
+	SystemRoots bool
+	// ClientCertPath and ClientKeyPath are a PEM certificate and key;
+	// PKCS12Path is a .p12/.pfx bundle used instead. KeyPassphrase decrypts
+	// either.
+	ClientCertPath string
+	ClientKeyPath  string
+	PKCS12Path     string
+	KeyPassphrase  string
+	// ServerName overrides the name used for SNI and verification.
+	ServerName string
+	// ALPN lists the application protocols to offer, e.g. "x-amzn-mqtt-ca".
+	ALPN []string
+	// MinVersion is "1.0", "1.1", "1.2" or "1.3".
+	MinVersion string
+	// CipherSuites names the TLS 1.0-1.2 suites to offer, e.g.
+	// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+	CipherSuites []string
+}
+
+// WithTLSOptions applies o when SSL is enabled.
+func WithTLSOptions(ssl bool, o TLSOptions) (ClientOption, error) {
+	if !ssl {
+		return func(*mqtt.ClientOptions) {}, nil
+	}
+	cfg, err := BuildTLSConfig(o)
+	if err != nil {
+		return nil, err
+	}
+	return func(opts *mqtt.ClientOptions) {
+		opts.SetTLSConfig(cfg)
+	}, nil
+}
+
+// BuildTLSConfig loads the certificates referenced by o.
+func BuildTLSConfig(o TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: o.SkipVerify,
+		ServerName:         o.ServerName,
+		NextProtos:         o.ALPN,
+	}
+	if o.CACertPath != "" {
+		caData, err := os.ReadFile(o.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("read CA cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if o.SystemRoots {
+			if pool, err = x509.SystemCertPool(); err != nil {
+				return nil, fmt.Errorf("load system certs: %w", err)
+			}
+		}
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("invalid CA cert")
+		}
+		cfg.RootCAs = pool
+	}
+	switch {
+	case o.PKCS12Path != "":
+		cert, err := loadPKCS12(o.PKCS12Path, o.KeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	case o.ClientCertPath != "" && o.ClientKeyPath != "":
+		cert, err := loadKeyPair(o.ClientCertPath, o.ClientKeyPath, o.KeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if o.MinVersion != "" {
+		v, err := parseTLSVersion(o.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MinVersion = v
+	}
+	for _, name := range o.CipherSuites {
+		id, err := cipherSuiteID(name)
+		if err != nil {
+			return nil, err
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, id)
+	}
+	return cfg, nil
+}
+
+// TLSRecorder keeps the broker connection a client opened last so its TLS
+// state can be reported once the client is connected.
+type TLSRecorder struct {
+	conn atomic.Value // net.Conn
+}
+
+// RecordTLS routes the broker connections of o through a hook that hands
+// them to the returned recorder. A custom open-connection function already
+// set on o, such as an SSH tunnel, is kept.
+func RecordTLS(o *mqtt.ClientOptions) *TLSRecorder {
+	open := o.CustomOpenConnectionFn
+	if open == nil {
+		var d net.Dialer
+		open = openConnection(d.DialContext)
+	}
+	r := &TLSRecorder{}
+	o.SetCustomOpenConnectionFn(func(u *url.URL, opts mqtt.ClientOptions) (net.Conn, error) {
+		conn, err := open(u, opts)
+		if err == nil {
+			r.conn.Store(conn)
+		}
+		return conn, err
+	})
+	return r
+}
+
+// State returns the TLS state of the connection opened last. Call it once
+// the client has connected: a handshake the client completed can still be
+// rejected by the broker, e.g. for its client certificate.
+func (r *TLSRecorder) State() (tls.ConnectionState, bool) {
+	conn, _ := r.conn.Load().(net.Conn)
+	if ws, ok := conn.(*wsConn); ok {
+		conn = ws.UnderlyingConn()
+	}
+	if tc, ok := conn.(*tls.Conn); ok {
+		return tc.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+// loadKeyPair reads a PEM certificate and a key that may be encrypted.
+func loadKeyPair(certPath, keyPath, passphrase string) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("load client cert: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("load client cert: %w", err)
+	}
+	if keyPEM, err = decryptPEMKey(keyPEM, passphrase); err != nil {
+		return tls.Certificate{}, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("load client cert: %w", err)
+	}
+	return cert, nil
+}
+
+// decryptPEMKey returns the first private key in data as unencrypted PEM.
+// Both PKCS#8 "ENCRYPTED PRIVATE KEY" blocks and legacy OpenSSL
+// "Proc-Type: 4,ENCRYPTED" blocks are supported.
+func decryptPEMKey(data []byte, passphrase string) ([]byte, error) {
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return data, nil
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+		encrypted := block.Type == "ENCRYPTED PRIVATE KEY"
+		if !encrypted && !x509.IsEncryptedPEMBlock(block) {
+			return data, nil
+		}
+		if passphrase == "" {
+			return nil, errors.New("client key is encrypted and no passphrase is set")
+		}
+		if encrypted {
+			key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+			if err != nil {
+				return nil, fmt.Errorf("decrypt client key: %w", err)
+			}
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("decrypt client key: %w", err)
+			}
+			return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+		}
+		// Legacy OpenSSL encryption is insecure but still common.
+		der, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("decrypt client key: %w", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+	}
+}
+
+// loadPKCS12 reads the client certificate, its chain and key from a PKCS#12
+// bundle.
+func loadPKCS12(path, passphrase string) (tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("read PKCS#12 bundle: %w", err)
+	}
+	key, leaf, chain, err := pkcs12.DecodeChain(data, passphrase)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("decode PKCS#12 bundle: %w", err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: key, Leaf: leaf}
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert, nil
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion accepts "1.2", "TLS1.2" or "TLS 1.2".
+func parseTLSVersion(s string) (uint16, error) {
+	v := strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "TLS"))
+	if id, ok := tlsVersions[v]; ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("invalid TLS version %q (use 1.0, 1.1, 1.2 or 1.3)", s)
+}
+
+// cipherSuiteID looks up a cipher suite by its IANA name.
+func cipherSuiteID(name string) (uint16, error) {
+	want := strings.ToUpper(strings.TrimSpace(name))
+	for _, list := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, cs := range list {
+			if cs.Name == want {
+				return cs.ID, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q", name)
+}
+
+// DescribeTLS summarises a negotiated TLS session: version, cipher suite,
+// ALPN protocol and the peer certificate chain.
+func DescribeTLS(cs tls.ConnectionState) string {
+	parts := []string{tls.VersionName(cs.Version), tls.CipherSuiteName(cs.CipherSuite)}
+	if cs.NegotiatedProtocol != "" {
+		parts = append(parts, "ALPN "+cs.NegotiatedProtocol)
+	}
+	if cs.ServerName != "" {
+		parts = append(parts, "server name "+cs.ServerName)
+	}
+	if len(cs.PeerCertificates) > 0 {
+		chain := make([]string, len(cs.PeerCertificates))
+		for i, c := range cs.PeerCertificates {
+			chain[i] = fmt.Sprintf("%s (issuer %s, expires %s)", certName(c.Subject.CommonName, c.Subject.String()), certName(c.Issuer.CommonName, c.Issuer.String()), c.NotAfter.Format("2006-01-02"))
+		}
+		parts = append(parts, "peer chain: "+strings.Join(chain, " > "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func certName(cn, dn string) string {
+	if cn != "" {
+		return cn
+	}
+	if dn != "" {
+		return dn
+	}
+	return "(unnamed)"
+}
@@ -0,0 +1,217 @@
+package mqttclient
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// connectTLS connects an MQTT 3 client to addr with o applied.
+func connectTLS(t *testing.T, addr string, o TLSOptions) {
+	t.Helper()
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker("ssl://" + addr)
+	opts.SetClientID("cid")
+	opt, err := WithTLSOptions(true, o)
+	if err != nil {
+		t.Fatalf("WithTLSOptions: %v", err)
+	}
+	opt(opts)
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatalf("connect: %v", token.Error())
+	}
+	client.Disconnect(0)
+}
+
+func TestWithTLSOptionsEncryptedKeys(t *testing.T) {
+	caPEM, srvPEM, srvKey, cliPEM, cliKey := generateMTLSCerts(t)
+	block, _ := pem.Decode(cliKey)
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("parse key: %v", err)
+	}
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	cliPath := filepath.Join(dir, "client.pem")
+	os.WriteFile(caPath, caPEM, 0644)
+	os.WriteFile(cliPath, cliPEM, 0644)
+
+	der, err := pkcs8.MarshalPrivateKey(key, []byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("encrypt pkcs8: %v", err)
+	}
+	pkcs8Path := filepath.Join(dir, "pkcs8.key")
+	os.WriteFile(pkcs8Path, pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}), 0600)
+
+	legacy, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", block.Bytes, []byte("secret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatalf("encrypt legacy: %v", err)
+	}
+	legacyPath := filepath.Join(dir, "legacy.key")
+	os.WriteFile(legacyPath, pem.EncodeToMemory(legacy), 0600)
+
+	p12, err := pkcs12.Modern.Encode(key, mustParseCert(t, cliPEM), nil, "secret")
+	if err != nil {
+		t.Fatalf("encode pkcs12: %v", err)
+	}
+	p12Path := filepath.Join(dir, "client.p12")
+	os.WriteFile(p12Path, p12, 0600)
+
+	for name, o := range map[string]TLSOptions{
+		"pkcs8":  {CACertPath: caPath, ClientCertPath: cliPath, ClientKeyPath: pkcs8Path, KeyPassphrase: "secret"},
+		"legacy": {CACertPath: caPath, ClientCertPath: cliPath, ClientKeyPath: legacyPath, KeyPassphrase: "secret"},
+		"pkcs12": {CACertPath: caPath, PKCS12Path: p12Path, KeyPassphrase: "secret"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			addr, closeFn := startMutualTLSServer(t, caPEM, srvPEM, srvKey)
+			defer closeFn()
+			connectTLS(t, addr, o)
+		})
+	}
+
+	if _, err := BuildTLSConfig(TLSOptions{ClientCertPath: cliPath, ClientKeyPath: pkcs8Path}); err == nil || !strings.Contains(err.Error(), "no passphrase") {
+		t.Fatalf("expected missing passphrase error, got %v", err)
+	}
+	if _, err := BuildTLSConfig(TLSOptions{PKCS12Path: p12Path, KeyPassphrase: "wrong"}); err == nil {
+		t.Fatalf("expected error for wrong PKCS#12 passphrase")
+	}
+}
+
+func mustParseCert(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parse cert: %v", err)
+	}
+	return cert
+}
+
+func TestBuildTLSConfigHandshakeSettings(t *testing.T) {
+	caPEM, srvPEM, srvKey, _, _ := generateMTLSCerts(t)
+	srvCert, err := tls.X509KeyPair(srvPEM, srvKey)
+	if err != nil {
+		t.Fatalf("x509 key pair: %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{srvCert},
+		NextProtos:   []string{"x-amzn-mqtt-ca"},
+		MaxVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.(*tls.Conn).Handshake()
+			c.Close()
+		}
+	}()
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caPath, caPEM, 0644)
+
+	cfg, err := BuildTLSConfig(TLSOptions{
+		CACertPath:   caPath,
+		SystemRoots:  true,
+		ServerName:   "127.0.0.1",
+		ALPN:         []string{"x-amzn-mqtt-ca"},
+		MinVersion:   "TLS1.2",
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	})
+	if err != nil {
+		t.Fatalf("BuildTLSConfig: %v", err)
+	}
+	// Dial by a name the certificate does not cover; ServerName fixes it.
+	conn, err := tls.Dial("tcp", strings.Replace(ln.Addr().String(), "127.0.0.1", "localhost", 1), cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	got := conn.ConnectionState()
+	conn.Close()
+	if got.Version != tls.VersionTLS12 || got.CipherSuite != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || got.NegotiatedProtocol != "x-amzn-mqtt-ca" {
+		t.Fatalf("unexpected handshake %x %x %q", got.Version, got.CipherSuite, got.NegotiatedProtocol)
+	}
+	desc := DescribeTLS(got)
+	for _, want := range []string{"TLS 1.2", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "ALPN x-amzn-mqtt-ca", "peer chain: ", "issuer"} {
+		if !strings.Contains(desc, want) {
+			t.Fatalf("DescribeTLS() = %q, missing %q", desc, want)
+		}
+	}
+
+	cfg, err = BuildTLSConfig(TLSOptions{CACertPath: caPath, MinVersion: "1.3"})
+	if err != nil {
+		t.Fatalf("BuildTLSConfig: %v", err)
+	}
+	if conn, err := tls.Dial("tcp", ln.Addr().String(), cfg); err == nil {
+		conn.Close()
+		t.Fatalf("expected TLS 1.3 minimum to reject a TLS 1.2 server")
+	}
+
+	if _, err := BuildTLSConfig(TLSOptions{MinVersion: "1.4"}); err == nil {
+		t.Fatalf("expected invalid version error")
+	}
+	if _, err := BuildTLSConfig(TLSOptions{CipherSuites: []string{"TLS_NOPE"}}); err == nil {
+		t.Fatalf("expected unknown cipher suite error")
+	}
+}
+
+func TestRecordTLSReportsStateOfConnectedClient(t *testing.T) {
+	caPEM, srvPEM, srvKey, cliPEM, cliKey := generateMTLSCerts(t)
+	addr, closeFn := startMutualTLSServer(t, caPEM, srvPEM, srvKey)
+	defer closeFn()
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	cliPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client.key")
+	os.WriteFile(caPath, caPEM, 0644)
+	os.WriteFile(cliPath, cliPEM, 0644)
+	os.WriteFile(keyPath, cliKey, 0644)
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker("ssl://" + addr)
+	opts.SetClientID("cid")
+	opt, err := WithTLSOptions(true, TLSOptions{CACertPath: caPath, ClientCertPath: cliPath, ClientKeyPath: keyPath})
+	if err != nil {
+		t.Fatalf("WithTLSOptions: %v", err)
+	}
+	opt(opts)
+	rec := RecordTLS(opts)
+	if _, ok := rec.State(); ok {
+		t.Fatalf("state reported before connecting")
+	}
+	states := make(chan tls.ConnectionState, 1)
+	opts.SetOnConnectHandler(func(mqtt.Client) {
+		if cs, ok := rec.State(); ok {
+			states <- cs
+		}
+	})
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatalf("connect: %v", token.Error())
+	}
+	defer client.Disconnect(0)
+	select {
+	case cs := <-states:
+		if !cs.HandshakeComplete || len(cs.PeerCertificates) == 0 {
+			t.Fatalf("incomplete TLS state: %+v", cs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no TLS state once connected")
+	}
+}
@@ -0,0 +1,739 @@
+package mqttclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/eclipse/paho.golang/paho/session"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/properties"
+)
+
+const (
+	defaultV5ConnectTimeout    = 30 * time.Second
+	defaultV5ReconnectInterval = 10 * time.Minute
+)
+
+// V5Properties holds the MQTT 5 CONNECT properties configured on a profile.
+// Zero values are omitted from the CONNECT packet so the broker defaults
+// apply.
+type V5Properties struct {
+	SessionExpiry       int
+	ReceiveMaximum      int
+	MaximumPacketSize   int
+	TopicAliasMaximum   int
+	RequestResponseInfo bool
+	RequestProblemInfo  bool
+}
+
+// ConnackError reports a CONNECT request rejected by the broker.
+type ConnackError struct {
+	ReasonCode   byte
+	ReasonString string
+}
+
+func (e *ConnackError) Error() string {
+	msg := fmt.Sprintf("connection refused: reason 0x%02X %s", e.ReasonCode, connackReason(e.ReasonCode))
+	if e.ReasonString != "" {
+		msg += ": " + e.ReasonString
+	}
+	return msg
+}
+
+// DisconnectError reports a DISCONNECT packet sent by the broker.
+type DisconnectError struct {
+	ReasonCode      byte
+	ReasonString    string
+	ServerReference string
+}
+
+func (e *DisconnectError) Error() string {
+	msg := fmt.Sprintf("server disconnect: reason 0x%02X %s", e.ReasonCode, disconnectReason(e.ReasonCode))
+	if e.ReasonString != "" {
+		msg += ": " + e.ReasonString
+	}
+	if e.ServerReference != "" {
+		msg += " (use " + e.ServerReference + ")"
+	}
+	return msg
+}
+
+// V5Client implements mqtt.Client on top of the paho.golang MQTT 5 client so
+// the rest of the application can treat both protocol versions alike.
+type V5Client struct {
+	opts  *mqtt.ClientOptions
+	props V5Properties
+
+	mu        sync.RWMutex
+	cli       *paho.Client
+	connack   *paho.Connack
+	connected bool
+	closing   bool
+	routes    map[string]mqtt.MessageHandler
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewV5Client creates an MQTT 5 client from the shared client options and the
+// v5 specific CONNECT properties. Call Connect to open the connection.
+func NewV5Client(opts *mqtt.ClientOptions, props V5Properties) *V5Client {
+	return &V5Client{
+		opts:   opts,
+		props:  props,
+		routes: make(map[string]mqtt.MessageHandler),
+		stop:   make(chan struct{}),
+	}
+}
+
+// NewClient returns a V5Client when version is "5" and a paho.mqtt.golang
+// client for MQTT 3.1 and 3.1.1 otherwise.
+func NewClient(opts *mqtt.ClientOptions, version string, props V5Properties) mqtt.Client {
+	if version == "5" {
+		return NewV5Client(opts, props)
+	}
+	return mqtt.NewClient(opts)
+}
+
+// Connack returns the CONNACK of the current connection, or nil when not
+// connected.
+func (c *V5Client) Connack() *paho.Connack {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.connack
+}
+
+// ResponseInformation returns the response topic prefix the broker
+// assigned in CONNACK, if any. Brokers only send it when
+// RequestResponseInfo is set.
+func (c *V5Client) ResponseInformation() string {
+	ca := c.Connack()
+	if ca == nil || ca.Properties == nil {
+		return ""
+	}
+	return ca.Properties.ResponseInfo
+}
+
+// IsConnected reports whether the client has an established connection.
+func (c *V5Client) IsConnected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.connected
+}
+
+// IsConnectionOpen reports whether the client has an established connection.
+func (c *V5Client) IsConnectionOpen() bool { return c.IsConnected() }
+
+// Connect dials the configured brokers in order until one accepts the MQTT 5
+// handshake. The returned token completes with a *ConnackError when the
+// broker refuses the connection.
+func (c *V5Client) Connect() mqtt.Token {
+	t := newToken()
+	go func() {
+		err := c.connect()
+		if err == nil && c.opts.OnConnect != nil {
+			c.opts.OnConnect(c)
+		}
+		t.complete(err)
+	}()
+	return t
+}
+
+func (c *V5Client) connect() error {
+	if len(c.opts.Servers) == 0 {
+		return errors.New("no broker configured")
+	}
+	timeout := c.opts.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultV5ConnectTimeout
+	}
+	var err error
+	for _, u := range c.opts.Servers {
+		if c.opts.OnConnectionNotification != nil {
+			c.opts.OnConnectionNotification(c, mqtt.ConnectionNotificationBroker{Broker: u})
+		}
+		if err = c.connectTo(u, timeout); err == nil {
+			return nil
+		}
+		if c.opts.OnConnectionNotification != nil {
+			c.opts.OnConnectionNotification(c, mqtt.ConnectionNotificationBrokerFailed{Broker: u, Reason: err})
+		}
+	}
+	return err
+}
+
+// connectTo dials a single broker and performs the MQTT 5 handshake.
+func (c *V5Client) connectTo(u *url.URL, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := dialV5(ctx, u, c.opts)
+	if err != nil {
+		return err
+	}
+	var cli *paho.Client
+	cli = paho.NewClient(paho.ClientConfig{
+		ClientID:          c.opts.ClientID,
+		Conn:              conn,
+		Session:           c.session(),
+		PacketTimeout:     timeout,
+		OnPublishReceived: []func(paho.PublishReceived) (bool, error){c.route},
+		OnServerDisconnect: func(d *paho.Disconnect) {
+			de := &DisconnectError{ReasonCode: d.ReasonCode}
+			if d.Properties != nil {
+				de.ReasonString = d.Properties.ReasonString
+				de.ServerReference = d.Properties.ServerReference
+			}
+			c.connectionLost(cli, de)
+		},
+		OnClientError: func(err error) { c.connectionLost(cli, err) },
+	})
+	ca, err := cli.Connect(ctx, c.connectPacket())
+	if err != nil {
+		if ca != nil && ca.ReasonCode >= 0x80 {
+			ce := &ConnackError{ReasonCode: ca.ReasonCode}
+			if ca.Properties != nil {
+				ce.ReasonString = ca.Properties.ReasonString
+			}
+			return ce
+		}
+		return err
+	}
+	c.mu.Lock()
+	c.cli = cli
+	c.connack = ca
+	c.connected = true
+	c.mu.Unlock()
+	return nil
+}
+
+// connectPacket builds the CONNECT packet from the client options and
+// properties.
+func (c *V5Client) connectPacket() *paho.Connect {
+	o := c.opts
+	cp := &paho.Connect{
+		ClientID:   o.ClientID,
+		KeepAlive:  uint16(clamp(int(o.KeepAlive), math.MaxUint16)),
+		CleanStart: o.CleanSession,
+	}
+	username, password := o.Username, o.Password
+	if o.CredentialsProvider != nil {
+		username, password = o.CredentialsProvider()
+	}
+	if username != "" {
+		cp.UsernameFlag = true
+		cp.Username = username
+	}
+	if password != "" {
+		cp.PasswordFlag = true
+		cp.Password = []byte(password)
+	}
+	if o.WillEnabled && o.WillTopic != "" {
+		cp.WillMessage = &paho.WillMessage{
+			Topic:   o.WillTopic,
+			Payload: o.WillPayload,
+			QoS:     o.WillQos,
+			Retain:  o.WillRetained,
+		}
+		cp.WillProperties = &paho.WillProperties{}
+	}
+
+	p := c.props
+	props := &paho.ConnectProperties{
+		RequestResponseInfo: p.RequestResponseInfo,
+		RequestProblemInfo:  p.RequestProblemInfo,
+	}
+	if p.SessionExpiry > 0 {
+		v := uint32(clamp(p.SessionExpiry, math.MaxUint32))
+		props.SessionExpiryInterval = &v
+	}
+	if p.ReceiveMaximum > 0 {
+		v := uint16(clamp(p.ReceiveMaximum, math.MaxUint16))
+		props.ReceiveMaximum = &v
+	}
+	if p.MaximumPacketSize > 0 {
+		v := uint32(clamp(p.MaximumPacketSize, math.MaxUint32))
+		props.MaximumPacketSize = &v
+	}
+	if p.TopicAliasMaximum > 0 {
+		v := uint16(clamp(p.TopicAliasMaximum, math.MaxUint16))
+		props.TopicAliasMaximum = &v
+	}
+	cp.Properties = props
+	return cp
+}
+
+// connectionLost marks cli as disconnected, notifies OnConnectionLost and
+// starts reconnecting when enabled. Errors from stale clients are ignored.
+func (c *V5Client) connectionLost(cli *paho.Client, err error) {
+	c.mu.Lock()
+	if c.cli != cli || !c.connected {
+		c.mu.Unlock()
+		return
+	}
+	c.connected = false
+	c.connack = nil
+	closing := c.closing
+	c.mu.Unlock()
+	if closing {
+		return
+	}
+	if c.opts.OnConnectionLost != nil {
+		c.opts.OnConnectionLost(c, err)
+	}
+	if c.opts.AutoReconnect {
+		go c.reconnect()
+	}
+}
+
+// reconnect retries the connection with exponential backoff until it
+// succeeds or Disconnect is called.
+func (c *V5Client) reconnect() {
+	maxDelay := c.opts.MaxReconnectInterval
+	if maxDelay <= 0 {
+		maxDelay = defaultV5ReconnectInterval
+	}
+	delay := time.Second
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(delay):
+		}
+		if c.opts.OnReconnecting != nil {
+			c.opts.OnReconnecting(c, c.opts)
+		}
+		if err := c.connect(); err == nil {
+			if c.opts.OnConnect != nil {
+				c.opts.OnConnect(c)
+			}
+			return
+		}
+		if delay *= 2; delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// Disconnect sends a DISCONNECT packet and closes the connection. quiesce is
+// accepted for interface compatibility and ignored.
+func (c *V5Client) Disconnect(quiesce uint) {
+	c.stopOnce.Do(func() { close(c.stop) })
+	c.mu.Lock()
+	cli := c.cli
+	wasConnected := c.connected
+	c.closing = true
+	c.connected = false
+	c.connack = nil
+	c.mu.Unlock()
+	if cli != nil && wasConnected {
+		_ = cli.Disconnect(&paho.Disconnect{ReasonCode: 0})
+	}
+	if s := c.session(); s != nil {
+		_ = s.Close()
+	}
+}
+
+// session returns the persistent session state of the configured
+// InflightStore. A nil result makes paho keep the session in memory for the
+// lifetime of a single connection.
+func (c *V5Client) session() session.SessionManager {
+	if s, ok := c.opts.Store.(*InflightStore); ok && s.Session() != nil {
+		return s.Session()
+	}
+	return nil
+}
+
+func (c *V5Client) client() *paho.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.connected {
+		return nil
+	}
+	return c.cli
+}
+
+// Publish sends payload to topic. payload may be a string, []byte or
+// fmt.Stringer.
+func (c *V5Client) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	return c.PublishWithProperties(topic, qos, retained, payload, nil)
+}
+
+// PublishWithProperties sends payload to topic with the given PUBLISH
+// properties. A nil props publishes without properties.
+func (c *V5Client) PublishWithProperties(topic string, qos byte, retained bool, payload interface{}, props *properties.Properties) mqtt.Token {
+	t := newToken()
+	body, err := payloadBytes(payload)
+	if err != nil {
+		t.complete(err)
+		return t
+	}
+	cli := c.client()
+	if cli == nil {
+		t.complete(mqtt.ErrNotConnected)
+		return t
+	}
+	go func() {
+		_, err := cli.Publish(context.Background(), &paho.Publish{
+			Topic:      topic,
+			QoS:        qos,
+			Retain:     retained,
+			Payload:    body,
+			Properties: pahoProperties(props),
+		})
+		t.complete(err)
+	}()
+	return t
+}
+
+// Subscribe subscribes to topic. A nil callback routes messages to the
+// default publish handler.
+func (c *V5Client) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	return c.SubscribeMultiple(map[string]byte{topic: qos}, callback)
+}
+
+// SubscribeMultiple subscribes to all filters in a single SUBSCRIBE packet.
+// The token's Result reports the reason code granted for each filter.
+func (c *V5Client) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	t := &SubscribeToken{token: newToken(), result: make(map[string]byte)}
+	cli := c.client()
+	if cli == nil {
+		t.complete(mqtt.ErrNotConnected)
+		return t
+	}
+	topics := make([]string, 0, len(filters))
+	for f := range filters {
+		topics = append(topics, f)
+	}
+	sort.Strings(topics)
+	subs := make([]paho.SubscribeOptions, len(topics))
+	for i, f := range topics {
+		subs[i] = paho.SubscribeOptions{Topic: f, QoS: filters[f]}
+	}
+	if callback != nil {
+		c.mu.Lock()
+		for _, f := range topics {
+			c.routes[f] = callback
+		}
+		c.mu.Unlock()
+	}
+	go func() {
+		sa, err := cli.Subscribe(context.Background(), &paho.Subscribe{Subscriptions: subs})
+		if sa != nil {
+			t.mu.Lock()
+			for i, code := range sa.Reasons {
+				if i < len(topics) {
+					t.result[topics[i]] = code
+				}
+			}
+			t.mu.Unlock()
+			for i, code := range sa.Reasons {
+				if code >= 0x80 && i < len(topics) {
+					err = fmt.Errorf("subscribe %s rejected: reason 0x%02X %s", topics[i], code, subackReason(code))
+					break
+				}
+			}
+		}
+		t.complete(err)
+	}()
+	return t
+}
+
+// Unsubscribe removes the subscriptions and routes for topics.
+func (c *V5Client) Unsubscribe(topics ...string) mqtt.Token {
+	t := newToken()
+	cli := c.client()
+	if cli == nil {
+		t.complete(mqtt.ErrNotConnected)
+		return t
+	}
+	c.mu.Lock()
+	for _, f := range topics {
+		delete(c.routes, f)
+	}
+	c.mu.Unlock()
+	go func() {
+		_, err := cli.Unsubscribe(context.Background(), &paho.Unsubscribe{Topics: topics})
+		t.complete(err)
+	}()
+	return t
+}
+
+// AddRoute registers callback for messages matching topic without
+// subscribing.
+func (c *V5Client) AddRoute(topic string, callback mqtt.MessageHandler) {
+	if callback == nil {
+		return
+	}
+	c.mu.Lock()
+	c.routes[topic] = callback
+	c.mu.Unlock()
+}
+
+// OptionsReader returns a reader for the options the client was built with.
+func (c *V5Client) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.NewOptionsReader(c.opts)
+}
+
+// route dispatches an incoming PUBLISH to all matching handlers, falling
+// back to the default publish handler.
+func (c *V5Client) route(pr paho.PublishReceived) (bool, error) {
+	msg := &v5Message{pub: pr.Packet}
+	c.mu.RLock()
+	var handlers []mqtt.MessageHandler
+	for f, h := range c.routes {
+		if topicMatches(f, msg.Topic()) {
+			handlers = append(handlers, h)
+		}
+	}
+	c.mu.RUnlock()
+	if len(handlers) == 0 && c.opts.DefaultPublishHandler != nil {
+		handlers = append(handlers, c.opts.DefaultPublishHandler)
+	}
+	for _, h := range handlers {
+		h(c, msg)
+	}
+	return true, nil
+}
+
+// v5Message adapts a paho.golang PUBLISH packet to mqtt.Message.
+type v5Message struct{ pub *paho.Publish }
+
+func (m *v5Message) Duplicate() bool   { return m.pub.Duplicate() }
+func (m *v5Message) Qos() byte         { return m.pub.QoS }
+func (m *v5Message) Retained() bool    { return m.pub.Retain }
+func (m *v5Message) Topic() string     { return m.pub.Topic }
+func (m *v5Message) MessageID() uint16 { return m.pub.PacketID }
+func (m *v5Message) Payload() []byte   { return m.pub.Payload }
+func (m *v5Message) Ack()              {}
+
+// Properties returns the PUBLISH properties or nil when none were sent.
+func (m *v5Message) Properties() *properties.Properties { return propertiesFromPaho(m.pub.Properties) }
+
+// token is a minimal mqtt.Token completed once by the v5 client.
+type token struct {
+	done chan struct{}
+	err  error
+	once sync.Once
+}
+
+func newToken() *token { return &token{done: make(chan struct{})} }
+
+func (t *token) complete(err error) {
+	t.once.Do(func() {
+		t.err = err
+		close(t.done)
+	})
+}
+
+func (t *token) Wait() bool {
+	<-t.done
+	return true
+}
+
+func (t *token) WaitTimeout(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-t.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (t *token) Done() <-chan struct{} { return t.done }
+
+func (t *token) Error() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// SubscribeToken is returned by V5Client subscriptions and exposes the
+// reason codes granted by the broker.
+type SubscribeToken struct {
+	*token
+	mu     sync.Mutex
+	result map[string]byte
+}
+
+// Result returns the granted QoS or failure reason code per topic filter.
+func (t *SubscribeToken) Result() map[string]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]byte, len(t.result))
+	for k, v := range t.result {
+		out[k] = v
+	}
+	return out
+}
+
+// DescribeConnack summarises the CONNACK reason code and the server
+// properties relevant to a client.
+func DescribeConnack(ca *paho.Connack) string {
+	if ca == nil {
+		return ""
+	}
+	parts := []string{
+		fmt.Sprintf("CONNACK reason 0x%02X %s", ca.ReasonCode, connackReason(ca.ReasonCode)),
+		fmt.Sprintf("session present=%t", ca.SessionPresent),
+	}
+	p := ca.Properties
+	if p == nil {
+		return strings.Join(parts, ", ")
+	}
+	if p.AssignedClientID != "" {
+		parts = append(parts, "assigned client id="+p.AssignedClientID)
+	}
+	if p.SessionExpiryInterval != nil {
+		parts = append(parts, fmt.Sprintf("session expiry=%ds", *p.SessionExpiryInterval))
+	}
+	if p.ServerKeepAlive != nil {
+		parts = append(parts, fmt.Sprintf("server keep alive=%ds", *p.ServerKeepAlive))
+	}
+	if p.ReceiveMaximum != nil {
+		parts = append(parts, fmt.Sprintf("receive maximum=%d", *p.ReceiveMaximum))
+	}
+	if p.MaximumPacketSize != nil {
+		parts = append(parts, fmt.Sprintf("maximum packet size=%d", *p.MaximumPacketSize))
+	}
+	if p.TopicAliasMaximum != nil {
+		parts = append(parts, fmt.Sprintf("topic alias maximum=%d", *p.TopicAliasMaximum))
+	}
+	if p.MaximumQoS != nil {
+		parts = append(parts, fmt.Sprintf("maximum QoS=%d", *p.MaximumQoS))
+	}
+	parts = append(parts,
+		fmt.Sprintf("retain available=%t", p.RetainAvailable),
+		fmt.Sprintf("wildcard subscriptions=%t", p.WildcardSubAvailable),
+		fmt.Sprintf("shared subscriptions=%t", p.SharedSubAvailable),
+		fmt.Sprintf("subscription identifiers=%t", p.SubIDAvailable),
+	)
+	if p.ResponseInfo != "" {
+		parts = append(parts, "response info="+p.ResponseInfo)
+	}
+	if p.ServerReference != "" {
+		parts = append(parts, "server reference="+p.ServerReference)
+	}
+	if p.ReasonString != "" {
+		parts = append(parts, "reason="+p.ReasonString)
+	}
+	for _, u := range p.User {
+		parts = append(parts, fmt.Sprintf("user %s=%s", u.Key, u.Value))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// topicMatches reports whether topic matches the subscription filter,
+// honouring the + and # wildcards and shared subscription prefixes.
+func topicMatches(filter, topic string) bool {
+	if strings.HasPrefix(filter, "$share/") {
+		parts := strings.SplitN(filter, "/", 3)
+		if len(parts) < 3 {
+			return false
+		}
+		filter = parts[2]
+	}
+	fs := strings.Split(filter, "/")
+	ts := strings.Split(topic, "/")
+	if len(ts) > 0 && strings.HasPrefix(ts[0], "$") && len(fs) > 0 && (fs[0] == "+" || fs[0] == "#") {
+		return false
+	}
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) {
+			return false
+		}
+		if f != "+" && f != ts[i] {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
+
+func payloadBytes(payload interface{}) ([]byte, error) {
+	switch p := payload.(type) {
+	case string:
+		return []byte(p), nil
+	case []byte:
+		return p, nil
+	case fmt.Stringer:
+		return []byte(p.String()), nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown payload type %T", payload)
+	}
+}
+
+func clamp(v, max int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// connackReason returns the short name of a CONNACK reason code.
+func connackReason(code byte) string {
+	return shortReason((&packets.Connack{ReasonCode: code}).Reason())
+}
+
+// disconnectReason returns the short name of a DISCONNECT reason code.
+func disconnectReason(code byte) string {
+	return shortReason((&packets.Disconnect{ReasonCode: code}).Reason())
+}
+
+// subackReason returns the short name of a SUBACK failure code.
+func subackReason(code byte) string {
+	switch code {
+	case 0x80:
+		return "Unspecified error"
+	case 0x83:
+		return "Implementation specific error"
+	case 0x87:
+		return "Not authorized"
+	case 0x8F:
+		return "Topic Filter invalid"
+	case 0x91:
+		return "Packet Identifier in use"
+	case 0x97:
+		return "Quota exceeded"
+	case 0x9E:
+		return "Shared Subscriptions not supported"
+	case 0xA1:
+		return "Subscription Identifiers not supported"
+	case 0xA2:
+		return "Wildcard Subscriptions not supported"
+	}
+	return "Unknown"
+}
+
+// shortReason trims the explanatory sentence from paho reason strings.
+func shortReason(s string) string {
+	if s == "" {
+		return "Unknown"
+	}
+	if i := strings.Index(s, " - "); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,201 @@
+package mqttclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gorilla/websocket"
+)
+
+// dialFunc opens a network connection, e.g. net.Dialer.DialContext.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialV5 opens the network connection used by the MQTT 5 client. Like the
+// MQTT 3 client it honours CustomOpenConnectionFn, HTTPHeaders and
+// WebsocketOptions. The returned connection is safe for concurrent writes as
+// required by paho.golang.
+func dialV5(ctx context.Context, u *url.URL, o *mqtt.ClientOptions) (net.Conn, error) {
+	if o.CustomOpenConnectionFn != nil {
+		conn, err := o.CustomOpenConnectionFn(u, *o)
+		if err != nil {
+			return nil, err
+		}
+		return packets.NewThreadSafeConn(conn), nil
+	}
+	var d net.Dialer
+	conn, err := dialBroker(ctx, u, o, d.DialContext)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := conn.(*wsConn); ok {
+		return conn, nil
+	}
+	return packets.NewThreadSafeConn(conn), nil
+}
+
+// openConnection returns a paho connection hook that reaches brokers
+// through dial.
+func openConnection(dial dialFunc) mqtt.OpenConnectionFunc {
+	return func(u *url.URL, opts mqtt.ClientOptions) (net.Conn, error) {
+		ctx := context.Background()
+		if opts.ConnectTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.ConnectTimeout)
+			defer cancel()
+		}
+		return dialBroker(ctx, u, &opts, dial)
+	}
+}
+
+// dialBroker connects to u using dial for the underlying TCP connection and
+// layers TLS or websockets on top as the scheme requires.
+func dialBroker(ctx context.Context, u *url.URL, o *mqtt.ClientOptions, dial dialFunc) (net.Conn, error) {
+	switch strings.ToLower(u.Scheme) {
+	case "tcp", "mqtt", "":
+		return dial(ctx, "tcp", u.Host)
+	case "ssl", "tls", "mqtts", "tcps":
+		conn, err := dial(ctx, "tcp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		cfg := o.TLSConfig.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tc, nil
+	case "ws", "wss":
+		return dialWebsocket(ctx, u, o.TLSConfig, o.HTTPHeaders, o.WebsocketOptions, dial)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+}
+
+// dialWebsocket connects to a ws:// or wss:// broker using the "mqtt"
+// subprotocol, sending headers with the upgrade request.
+func dialWebsocket(ctx context.Context, u *url.URL, tlsCfg *tls.Config, headers http.Header, wo *mqtt.WebsocketOptions, dial dialFunc) (net.Conn, error) {
+	d := *websocket.DefaultDialer
+	d.NetDialContext = dial
+	d.TLSClientConfig = tlsCfg
+	d.Subprotocols = []string{"mqtt"}
+	if wo != nil {
+		if wo.Proxy != nil {
+			d.Proxy = wo.Proxy
+		}
+		d.ReadBufferSize, d.WriteBufferSize = wo.ReadBufferSize, wo.WriteBufferSize
+	}
+	dialTLSProxy(&d, dial, tlsCfg)
+	dialURL := *u
+	dialURL.User = nil
+	ws, _, err := d.DialContext(ctx, dialURL.String(), headers)
+	if err != nil {
+		return nil, fmt.Errorf("websocket connection failed: %w", err)
+	}
+	return &wsConn{Conn: ws, Locker: &sync.Mutex{}}, nil
+}
+
+// dialTLSProxy lets d reach https:// proxies. The websocket dialer speaks
+// CONNECT over plain connections only, so the proxy is handed to it as
+// http:// and its connection wrapped in TLS here. The proxy is verified
+// against the roots of tlsCfg, or the system's when none are set.
+func dialTLSProxy(d *websocket.Dialer, dial dialFunc, tlsCfg *tls.Config) {
+	proxy := d.Proxy
+	if proxy == nil {
+		return
+	}
+	var proxyAddr, proxyHost string
+	d.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil || u.Scheme != "https" {
+			return u, err
+		}
+		plain := *u
+		plain.Scheme = "http"
+		if u.Port() == "" {
+			plain.Host = net.JoinHostPort(u.Hostname(), "443")
+		}
+		proxyAddr, proxyHost = plain.Host, u.Hostname()
+		return &plain, nil
+	}
+	d.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil || proxyAddr == "" || addr != proxyAddr {
+			return conn, err
+		}
+		cfg := &tls.Config{ServerName: proxyHost, MinVersion: tls.VersionTLS12}
+		if tlsCfg != nil {
+			cfg.RootCAs, cfg.InsecureSkipVerify = tlsCfg.RootCAs, tlsCfg.InsecureSkipVerify
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tc, nil
+	}
+}
+
+// wsConn adapts a websocket connection to net.Conn. The embedded Locker is
+// used by paho.golang to serialise packet writes.
+type wsConn struct {
+	*websocket.Conn
+	r   io.Reader
+	rio sync.Mutex
+	sync.Locker
+}
+
+// SetDeadline sets both the read and write deadlines.
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// Write sends p as a single binary frame.
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read reads from the current frame, advancing to the next one when needed.
+func (c *wsConn) Read(p []byte) (int, error) {
+	c.rio.Lock()
+	defer c.rio.Unlock()
+	for {
+		if c.r == nil {
+			var err error
+			if _, c.r, err = c.NextReader(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := c.r.Read(p)
+		if err == io.EOF {
+			c.r = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
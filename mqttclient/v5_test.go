@@ -0,0 +1,351 @@
+package mqttclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	server "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+func TestV5ConnectPacket(t *testing.T) {
+	opts := mqtt.NewClientOptions()
+	WithClientID("cid", false)(opts)
+	WithAuth("user", "pass")(opts)
+	WithTimeouts(5, 30)(opts)
+	WithSession(false, true)(opts)
+	WithWill(true, "will/topic", "bye", 1, true)(opts)
+
+	c := NewV5Client(opts, V5Properties{
+		SessionExpiry:       3600,
+		ReceiveMaximum:      100,
+		MaximumPacketSize:   4096,
+		TopicAliasMaximum:   70000,
+		RequestResponseInfo: true,
+		RequestProblemInfo:  true,
+	})
+	cp := c.connectPacket()
+	if cp.ClientID != "cid" || !cp.CleanStart || cp.KeepAlive != 30 {
+		t.Fatalf("unexpected connect header: %+v", cp)
+	}
+	if !cp.UsernameFlag || cp.Username != "user" || !cp.PasswordFlag || string(cp.Password) != "pass" {
+		t.Fatalf("credentials not applied: %+v", cp)
+	}
+	if cp.WillMessage == nil || cp.WillMessage.Topic != "will/topic" || cp.WillMessage.QoS != 1 || !cp.WillMessage.Retain {
+		t.Fatalf("will not applied: %+v", cp.WillMessage)
+	}
+	p := cp.Properties
+	if p == nil {
+		t.Fatalf("expected properties")
+	}
+	if *p.SessionExpiryInterval != 3600 || *p.ReceiveMaximum != 100 || *p.MaximumPacketSize != 4096 {
+		t.Fatalf("unexpected properties: %+v", p)
+	}
+	if *p.TopicAliasMaximum != 65535 {
+		t.Fatalf("topic alias maximum should be clamped, got %d", *p.TopicAliasMaximum)
+	}
+	if !p.RequestResponseInfo || !p.RequestProblemInfo {
+		t.Fatalf("request flags not applied: %+v", p)
+	}
+}
+
+func TestV5ConnectPacketAsksCredentialsProvider(t *testing.T) {
+	opts := mqtt.NewClientOptions()
+	WithAuth("user", "stale")(opts)
+	calls := 0
+	WithCredentials(func() (string, string) {
+		calls++
+		return "user", fmt.Sprintf("token-%d", calls)
+	})(opts)
+	c := NewV5Client(opts, V5Properties{})
+	c.connectPacket()
+	if cp := c.connectPacket(); cp.Username != "user" || string(cp.Password) != "token-2" {
+		t.Fatalf("provider not used on each connect: %+v", cp)
+	}
+}
+
+func TestV5ConnectPacketOmitsZeroProperties(t *testing.T) {
+	c := NewV5Client(mqtt.NewClientOptions(), V5Properties{})
+	p := c.connectPacket().Properties
+	if p.SessionExpiryInterval != nil || p.ReceiveMaximum != nil || p.MaximumPacketSize != nil || p.TopicAliasMaximum != nil {
+		t.Fatalf("zero values should be omitted: %+v", p)
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "$SYS/x", false},
+		{"$share/g/a/+", "a/b", true},
+		{"a/b", "a/c", false},
+	}
+	for _, c := range cases {
+		if got := topicMatches(c.filter, c.topic); got != c.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", c.filter, c.topic, got, c.want)
+		}
+	}
+}
+
+func TestDescribeConnack(t *testing.T) {
+	rm := uint16(10)
+	qos := byte(1)
+	s := DescribeConnack(&paho.Connack{
+		ReasonCode: 0,
+		Properties: &paho.ConnackProperties{
+			ReceiveMaximum:   &rm,
+			MaximumQoS:       &qos,
+			AssignedClientID: "auto-1",
+			RetainAvailable:  true,
+			User:             paho.UserProperties{{Key: "region", Value: "eu"}},
+		},
+	})
+	for _, want := range []string{"reason 0x00 Success", "receive maximum=10", "maximum QoS=1", "assigned client id=auto-1", "retain available=true", "user region=eu"} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("expected %q in %q", want, s)
+		}
+	}
+}
+
+func TestConnackErrorMessage(t *testing.T) {
+	err := &ConnackError{ReasonCode: 0x86, ReasonString: "nope"}
+	if got := err.Error(); got != "connection refused: reason 0x86 Bad User Name or Password: nope" {
+		t.Fatalf("unexpected error %q", got)
+	}
+}
+
+// startV5Broker runs an in-process MQTT 5 broker accepting user/secret and
+// returns its tcp:// URL.
+func startV5Broker(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	srv := server.New(&server.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	ledger := &auth.Ledger{Auth: auth.AuthRules{{Username: "user", Password: "secret", Allow: true}}}
+	if err := srv.AddHook(new(auth.Hook), &auth.Options{Ledger: ledger}); err != nil {
+		t.Fatalf("add auth hook: %v", err)
+	}
+	if err := srv.AddListener(listeners.NewTCP(listeners.Config{ID: "t", Address: addr})); err != nil {
+		t.Fatalf("add listener: %v", err)
+	}
+	if err := srv.Serve(); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return "tcp://" + addr
+}
+
+func TestV5ClientWithBroker(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	url := startV5Broker(t)
+
+	opts := mqtt.NewClientOptions()
+	WithBroker(url)(opts)
+	WithClientID("v5-test", false)(opts)
+	WithAuth("user", "secret")(opts)
+	WithTimeouts(2, 30)(opts)
+	WithSession(false, true)(opts)
+	got := make(chan mqtt.Message, 1)
+	opts.SetDefaultPublishHandler(func(_ mqtt.Client, m mqtt.Message) { got <- m })
+
+	c := NewV5Client(opts, V5Properties{ReceiveMaximum: 10, RequestProblemInfo: true})
+	if tok := c.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("connect: %v", tok.Error())
+	}
+	defer c.Disconnect(0)
+	if !c.IsConnected() {
+		t.Fatalf("expected connected client")
+	}
+	if ca := c.Connack(); ca == nil || ca.ReasonCode != 0 {
+		t.Fatalf("unexpected connack: %+v", ca)
+	}
+
+	tok := c.Subscribe("v5/+", 1, nil)
+	if !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("subscribe: %v", tok.Error())
+	}
+	if res := tok.(*SubscribeToken).Result(); res["v5/+"] != 1 {
+		t.Fatalf("expected granted QoS 1, got %v", res)
+	}
+	if tok := c.Publish("v5/a", 1, false, "hello"); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("publish: %v", tok.Error())
+	}
+	select {
+	case m := <-got:
+		if m.Topic() != "v5/a" || string(m.Payload()) != "hello" {
+			t.Fatalf("unexpected message %s %q", m.Topic(), m.Payload())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message not received")
+	}
+}
+
+func TestV5ClientRefused(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	url := startV5Broker(t)
+
+	opts := mqtt.NewClientOptions()
+	WithBroker(url)(opts)
+	WithClientID("v5-refused", false)(opts)
+	WithAuth("user", "wrong")(opts)
+	WithTimeouts(2, 30)(opts)
+
+	c := NewV5Client(opts, V5Properties{})
+	tok := c.Connect()
+	if !tok.WaitTimeout(5 * time.Second) {
+		t.Fatalf("connect timed out")
+	}
+	var ce *ConnackError
+	if !errors.As(tok.Error(), &ce) {
+		t.Fatalf("expected ConnackError, got %v", tok.Error())
+	}
+	if ce.ReasonCode != 0x86 {
+		t.Fatalf("expected bad credentials reason, got 0x%02X", ce.ReasonCode)
+	}
+	if c.IsConnected() {
+		t.Fatalf("refused client should not be connected")
+	}
+}
+
+func TestV5ClientResendsPersistedInflight(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	url := startV5Broker(t)
+	dir := t.TempDir()
+	connect := func(id string, cleanStart bool, store *InflightStore, handler mqtt.MessageHandler) *V5Client {
+		t.Helper()
+		opts := mqtt.NewClientOptions()
+		WithBroker(url)(opts)
+		WithClientID(id, false)(opts)
+		WithAuth("user", "secret")(opts)
+		WithTimeouts(2, 30)(opts)
+		WithSession(false, cleanStart)(opts)
+		WithInflightStore(store)(opts)
+		opts.SetDefaultPublishHandler(handler)
+		c := NewV5Client(opts, V5Properties{SessionExpiry: 60})
+		if tok := c.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+			t.Fatalf("connect %s: %v", id, tok.Error())
+		}
+		return c
+	}
+
+	got := make(chan mqtt.Message, 1)
+	sub := connect("inflight-sub", true, nil, func(_ mqtt.Client, m mqtt.Message) { got <- m })
+	defer sub.Disconnect(0)
+	if tok := sub.Subscribe("inflight/#", 1, nil); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("subscribe: %v", tok.Error())
+	}
+
+	store, err := OpenInflightStore(dir, "5")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	connect("inflight-pub", false, store, nil).Disconnect(0)
+
+	// Leave an unacknowledged publish behind as if the previous run exited
+	// before the PUBACK arrived.
+	cp := packets.NewControlPacket(packets.PUBLISH)
+	pub := cp.Content.(*packets.Publish)
+	pub.Topic, pub.QoS, pub.PacketID, pub.Payload = "inflight/a", 1, 9, []byte("kept")
+	if err := store.v5client.Put(9, packets.PUBLISH, cp); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	store, err = OpenInflightStore(dir, "5")
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	pubc := connect("inflight-pub", false, store, nil)
+	defer pubc.Disconnect(0)
+	select {
+	case m := <-got:
+		if m.Topic() != "inflight/a" || string(m.Payload()) != "kept" {
+			t.Fatalf("unexpected message %s %q", m.Topic(), m.Payload())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("persisted publish not resent")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p, err := store.Pending()
+		if err != nil {
+			t.Fatalf("pending: %v", err)
+		}
+		if len(p) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected store emptied after PUBACK, got %+v", p)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestV5ClientFailsOverToNextBroker(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	url := startV5Broker(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	dead := "tcp://" + ln.Addr().String()
+	ln.Close()
+
+	opts := mqtt.NewClientOptions()
+	WithBrokers([]string{dead, url})(opts)
+	WithClientID("v5-failover", false)(opts)
+	WithAuth("user", "secret")(opts)
+	WithTimeouts(2, 30)(opts)
+	var failed, tried []string
+	opts.SetConnectionNotificationHandler(func(_ mqtt.Client, n mqtt.ConnectionNotification) {
+		switch n := n.(type) {
+		case mqtt.ConnectionNotificationBroker:
+			tried = append(tried, n.Broker.String())
+		case mqtt.ConnectionNotificationBrokerFailed:
+			failed = append(failed, n.Broker.String())
+		}
+	})
+
+	c := NewV5Client(opts, V5Properties{})
+	if tok := c.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("connect: %v", tok.Error())
+	}
+	defer c.Disconnect(0)
+	if len(failed) != 1 || failed[0] != dead {
+		t.Fatalf("expected %s to fail, got %v", dead, failed)
+	}
+	if len(tried) != 2 || tried[1] != url {
+		t.Fatalf("expected fallback to %s, got %v", url, tried)
+	}
+}
@@ -0,0 +1,178 @@
+package mqttclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gorilla/websocket"
+)
+
+// startConnectProxy runs a minimal HTTP CONNECT proxy and reports the
+// requested targets on the returned channel.
+func startConnectProxy(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	targets := make(chan string, 4)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				req, err := http.ReadRequest(bufio.NewReader(c))
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				targets <- req.Host
+				up, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					return
+				}
+				defer up.Close()
+				io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(up, c)
+				io.Copy(c, up)
+			}(c)
+		}
+	}()
+	return "http://" + ln.Addr().String(), targets
+}
+
+func TestWithWebsocket(t *testing.T) {
+	h := http.Header{"Authorization": {"Bearer t"}}
+	opt, err := WithWebsocket(h, "socks5://proxy:1080")
+	if err != nil {
+		t.Fatalf("WithWebsocket: %v", err)
+	}
+	opts := mqtt.NewClientOptions()
+	opt(opts)
+	if opts.HTTPHeaders.Get("Authorization") != "Bearer t" {
+		t.Fatalf("headers not applied: %v", opts.HTTPHeaders)
+	}
+	if opts.WebsocketOptions == nil || opts.WebsocketOptions.Proxy == nil {
+		t.Fatalf("proxy not applied")
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://broker/mqtt", nil)
+	if u, _ := opts.WebsocketOptions.Proxy(req); u == nil || u.Host != "proxy:1080" {
+		t.Fatalf("unexpected proxy %v", u)
+	}
+
+	if _, err := WithWebsocket(nil, "ftp://proxy"); err == nil {
+		t.Fatalf("expected unsupported proxy scheme error")
+	}
+}
+
+func TestWebsocketThroughHTTPSProxy(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"mqtt"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := upgrader.Upgrade(w, r, nil); err == nil {
+			c.Close()
+		}
+	}))
+	defer srv.Close()
+	targets := make(chan string, 1)
+	proxy := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		targets <- r.Host
+		up, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer up.Close()
+		c, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go io.Copy(up, c)
+		io.Copy(c, up)
+	}))
+	defer proxy.Close()
+
+	opts := mqtt.NewClientOptions()
+	opts.SetTLSConfig(&tls.Config{RootCAs: proxy.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs})
+	opt, err := WithWebsocket(nil, proxy.URL)
+	if err != nil {
+		t.Fatalf("WithWebsocket: %v", err)
+	}
+	opt(opts)
+	if opts.CustomOpenConnectionFn == nil {
+		t.Fatalf("https proxy needs the custom dialer")
+	}
+	u, _ := url.Parse(strings.Replace(srv.URL, "http://", "ws://", 1) + "/mqtt")
+	conn, err := opts.CustomOpenConnectionFn(u, *opts)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	conn.Close()
+	select {
+	case target := <-targets:
+		if target != u.Host {
+			t.Fatalf("proxy CONNECT to %s, want %s", target, u.Host)
+		}
+	default:
+		t.Fatalf("connection did not use the proxy")
+	}
+}
+
+func TestDialV5WebsocketHeadersThroughProxy(t *testing.T) {
+	var gotPath string
+	var gotHeader http.Header
+	upgrader := websocket.Upgrader{Subprotocols: []string{"mqtt"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotHeader = r.URL.Path, r.Header.Clone()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err == nil {
+			c.Close()
+		}
+	}))
+	defer srv.Close()
+	proxyURL, targets := startConnectProxy(t)
+
+	opts := mqtt.NewClientOptions()
+	opt, err := WithWebsocket(http.Header{"X-Tenant": {"acme"}}, proxyURL)
+	if err != nil {
+		t.Fatalf("WithWebsocket: %v", err)
+	}
+	opt(opts)
+	u, _ := url.Parse(strings.Replace(srv.URL, "http://", "ws://", 1) + "/mqtt")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dialV5(ctx, u, opts)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	conn.Close()
+	select {
+	case target := <-targets:
+		if target != u.Host {
+			t.Fatalf("proxy CONNECT to %s, want %s", target, u.Host)
+		}
+	default:
+		t.Fatalf("connection did not use the proxy")
+	}
+	if gotPath != "/mqtt" || gotHeader.Get("X-Tenant") != "acme" {
+		t.Fatalf("unexpected upgrade request path=%q headers=%v", gotPath, gotHeader)
+	}
+}
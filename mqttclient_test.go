@@ -1,11 +1,13 @@
 package emqutiti
 
 import (
+	"net"
 	"strings"
 	"testing"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	connections "github.com/marang/emqutiti/connections"
 	mqttoptions "github.com/marang/emqutiti/mqttclient"
 )
 
@@ -106,6 +108,50 @@ func TestWithAuthDefaults(t *testing.T) {
 	}
 }
 
+func TestPasswordCommandErrorsSurface(t *testing.T) {
+	p := connections.Profile{Name: "cmd-err", Schema: "tcp", Host: "127.0.0.1", Port: 1, PasswordCommand: "echo token expired >&2; exit 1"}
+	if _, err := NewMQTTClient(p, nil); err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Fatalf("expected password command error, got %v", err)
+	}
+	m := &MQTTClient{credentials: connections.CredentialsFor(p)}
+	if err := m.Reconnect(); err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Fatalf("expected Reconnect to report the password command error, got %v", err)
+	}
+}
+
+func TestNewMQTTClientWithSandbox(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	p := connections.SandboxProfile()
+	p.Port = ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	c, err := NewMQTTClient(p, nil)
+	if err != nil {
+		t.Fatalf("NewMQTTClient: %v", err)
+	}
+	defer c.Disconnect()
+	if err := c.Subscribe("sandbox/#", 1, nil); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := c.Publish("sandbox/hello", 1, false, "hi"); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	select {
+	case m := <-c.MessageChan:
+		if m.Topic != "sandbox/hello" || string(m.Payload) != "hi" {
+			t.Fatalf("unexpected message %+v", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message not received from the sandbox broker")
+	}
+}
+
 func TestWithTimeouts(t *testing.T) {
 	opts := mqtt.NewClientOptions()
 	mqttoptions.WithTimeouts(10, 20)(opts)
@@ -266,3 +266,31 @@ func TestNewMQTTClientTLSWithCerts(t *testing.T) {
 		t.Fatalf("expected error for bad client cert")
 	}
 }
+
+func TestNewMQTTClientReportsTLSSession(t *testing.T) {
+	addr, closeFn := startTLSServer(t)
+	defer closeFn()
+	host, portStr, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portStr)
+	p := connections.Profile{Schema: "ssl", Host: host, Port: port, SSL: true, SkipTLSVerify: true, ClientID: "cid"}
+	statuses := make(chan string, 10)
+	c, err := NewMQTTClient(p, func(s string) { statuses <- s })
+	if err != nil {
+		t.Fatalf("NewMQTTClient: %v", err)
+	}
+	defer c.Disconnect()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case s := <-statuses:
+			if strings.HasPrefix(s, "TLS 1.") {
+				if !strings.Contains(s, "peer chain: ") {
+					t.Fatalf("TLS summary without peer chain: %q", s)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no TLS summary reported")
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package emqutiti
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/marang/emqutiti/clientopts"
+	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/mqttclient"
+	"github.com/marang/emqutiti/ui"
+)
+
+// pendingState backs the view of unacknowledged outbound packets.
+type pendingState struct {
+	store   *mqttclient.InflightStore
+	packets []mqttclient.PendingPacket
+	cursor  int
+	err     error
+}
+
+// handlePendingKey opens the pending outbound view for the active profile.
+func (m *model) handlePendingKey() tea.Cmd {
+	store := m.inflightStore()
+	if store == nil {
+		m.history.Append("", "", "log", false, "In-flight messages are kept in memory; enable persist_inflight on the profile to inspect them")
+		return nil
+	}
+	m.pending = pendingState{store: store}
+	m.refreshPending()
+	return m.SetMode(constants.ModePending)
+}
+
+// inflightStore returns the store of the connected client or opens the
+// active profile's store while disconnected.
+func (m *model) inflightStore() *mqttclient.InflightStore {
+	if m.mqttClient != nil && m.mqttClient.Inflight() != nil {
+		return m.mqttClient.Inflight()
+	}
+	p, ok := m.activeProfile()
+	if !ok || !p.PersistInflight {
+		return nil
+	}
+	store, err := mqttclient.OpenInflightStore(clientopts.InflightDir(p.Name), p.MQTTVersion)
+	if err != nil {
+		m.history.Append("", "", "log", false, err.Error())
+		return nil
+	}
+	return store
+}
+
+// refreshPending reloads the packets and keeps the cursor in range.
+func (m *model) refreshPending() {
+	ps := &m.pending
+	ps.packets, ps.err = ps.store.Pending()
+	if ps.cursor >= len(ps.packets) {
+		ps.cursor = len(ps.packets) - 1
+	}
+	if ps.cursor < 0 {
+		ps.cursor = 0
+	}
+}
+
+// updatePending handles navigation and discarding in the pending view.
+func (m *model) updatePending(msg tea.Msg) tea.Cmd {
+	k, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+	ps := &m.pending
+	switch k.String() {
+	case constants.KeyEsc:
+		return m.SetMode(m.PreviousMode())
+	case constants.KeyCtrlD:
+		return tea.Quit
+	case constants.KeyUp, constants.KeyK:
+		if ps.cursor > 0 {
+			ps.cursor--
+		}
+	case constants.KeyDown, constants.KeyJ:
+		if ps.cursor < len(ps.packets)-1 {
+			ps.cursor++
+		}
+	case constants.KeyR:
+		m.refreshPending()
+	case constants.KeyD, constants.KeyDelete:
+		if ps.cursor >= len(ps.packets) {
+			return nil
+		}
+		p := ps.packets[ps.cursor]
+		if err := ps.store.Discard(p.ID); err != nil {
+			ps.err = err
+			return nil
+		}
+		m.history.Append(p.Topic, "", "log", false, fmt.Sprintf("Discarded pending %s #%d %s", p.Kind, p.ID, p.Topic))
+		m.refreshPending()
+	}
+	return nil
+}
+
+// pendingLine renders a single pending packet.
+func pendingLine(p mqttclient.PendingPacket) string {
+	if p.Kind != "publish" {
+		return fmt.Sprintf("#%-5d %-7s awaiting PUBCOMP", p.ID, p.Kind)
+	}
+	line := fmt.Sprintf("#%-5d %-7s QoS %d %s", p.ID, p.Kind, p.QoS, p.Topic)
+	if p.Retained {
+		line += " (retained)"
+	}
+	payload := strings.Join(strings.Fields(history.PayloadText(p.Payload)), " ")
+	if r := []rune(payload); len(r) > 40 {
+		payload = string(r[:40]) + "…"
+	}
+	return line + "  " + payload
+}
+
+// viewPending lists unacknowledged outbound packets.
+func (m *model) viewPending() string {
+	ps := m.pending
+	var b strings.Builder
+	switch {
+	case ps.err != nil:
+		b.WriteString(ui.ErrorStyle.Render(ps.err.Error()))
+	case len(ps.packets) == 0:
+		b.WriteString("No unacknowledged outbound packets.")
+	default:
+		for i, p := range ps.packets {
+			line := "  " + pendingLine(p)
+			if i == ps.cursor {
+				line = ui.FocusedStyle.Render("> " + pendingLine(p))
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	b.WriteString("\n\n" + ui.InfoSubtleStyle.Render("[d] discard  [r] refresh  [esc] back  stored in "+ps.store.Dir()))
+	content := lipgloss.NewStyle().Padding(1, 2).Render(b.String())
+	return ui.LegendBox(content, "Pending Outbound", m.ui.width-2, m.ui.height-2, ui.ColBlue, true, -1)
+}
@@ -0,0 +1,54 @@
+package emqutiti
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/marang/emqutiti/clientopts"
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/mqttclient"
+)
+
+func TestPendingViewListsAndDiscards(t *testing.T) {
+	m := clientModel(t, connections.Profile{PersistInflight: true}, &fakeClient{})
+	store, err := mqttclient.OpenInflightStore(clientopts.InflightDir("p"), "")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	pub := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	pub.TopicName, pub.Qos, pub.MessageID, pub.Payload = "sensors/1", 1, 4, []byte("21.5")
+	store.Put("o.4", pub)
+
+	m.HandleClientKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}, Alt: true})
+	if m.CurrentMode() != constants.ModePending {
+		t.Fatalf("expected pending view, got %v", m.CurrentMode())
+	}
+	if v := m.viewPending(); !strings.Contains(v, "sensors/1") || !strings.Contains(v, "21.5") {
+		t.Fatalf("expected pending publish listed, got %q", v)
+	}
+	m.updatePending(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	if got, _ := store.Pending(); len(got) != 0 {
+		t.Fatalf("expected packet discarded, got %+v", got)
+	}
+	if lastLog(m) != "Discarded pending publish #4 sensors/1" {
+		t.Fatalf("unexpected log %q", lastLog(m))
+	}
+	if !strings.Contains(m.viewPending(), "No unacknowledged outbound packets.") {
+		t.Fatalf("expected empty view")
+	}
+}
+
+func TestPendingViewRequiresPersistence(t *testing.T) {
+	m := clientModel(t, connections.Profile{}, &fakeClient{})
+	m.handlePendingKey()
+	if m.CurrentMode() == constants.ModePending {
+		t.Fatalf("pending view should need persist_inflight")
+	}
+	if !strings.Contains(lastLog(m), "persist_inflight") {
+		t.Fatalf("expected hint, got %q", lastLog(m))
+	}
+}
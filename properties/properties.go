@@ -0,0 +1,147 @@
+// Package properties models the MQTT 5 PUBLISH properties shared by the
+// client, the message editor and the stored history.
+package properties
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Names of the standard PUBLISH properties as used by Lookup and Lines.
+const (
+	PropContentType     = "content-type"
+	PropPayloadFormat   = "payload-format"
+	PropMessageExpiry   = "message-expiry"
+	PropResponseTopic   = "response-topic"
+	PropCorrelationData = "correlation-data"
+)
+
+// UserProperty is a single MQTT 5 user property. Keys may repeat.
+type UserProperty struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Properties holds the MQTT 5 PUBLISH properties sent from the message
+// editor or received from the broker.
+type Properties struct {
+	ContentType     string         `json:"content_type,omitempty"`
+	PayloadFormat   *byte          `json:"payload_format,omitempty"`
+	MessageExpiry   *uint32        `json:"message_expiry,omitempty"`
+	ResponseTopic   string         `json:"response_topic,omitempty"`
+	CorrelationData []byte         `json:"correlation_data,omitempty"`
+	User            []UserProperty `json:"user,omitempty"`
+}
+
+// IsEmpty reports whether p carries no properties. A nil p is empty.
+func (p *Properties) IsEmpty() bool {
+	return p == nil || (p.ContentType == "" && p.PayloadFormat == nil && p.MessageExpiry == nil &&
+		p.ResponseTopic == "" && len(p.CorrelationData) == 0 && len(p.User) == 0)
+}
+
+// Lookup returns the value of a user property, falling back to the standard
+// property names such as "content-type". The first matching user property
+// wins when keys repeat.
+func (p *Properties) Lookup(key string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	for _, u := range p.User {
+		if u.Key == key {
+			return u.Value, true
+		}
+	}
+	switch key {
+	case PropContentType:
+		return p.ContentType, p.ContentType != ""
+	case PropPayloadFormat:
+		if p.PayloadFormat != nil {
+			return strconv.Itoa(int(*p.PayloadFormat)), true
+		}
+	case PropMessageExpiry:
+		if p.MessageExpiry != nil {
+			return strconv.FormatUint(uint64(*p.MessageExpiry), 10), true
+		}
+	case PropResponseTopic:
+		return p.ResponseTopic, p.ResponseTopic != ""
+	case PropCorrelationData:
+		return FormatCorrelationData(p.CorrelationData), len(p.CorrelationData) > 0
+	}
+	return "", false
+}
+
+// Lines renders the properties as "name: value" lines for display.
+func (p *Properties) Lines() []string {
+	if p.IsEmpty() {
+		return nil
+	}
+	var lines []string
+	if p.ContentType != "" {
+		lines = append(lines, fmt.Sprintf("%s: %s", PropContentType, p.ContentType))
+	}
+	if p.PayloadFormat != nil {
+		label := "unspecified bytes"
+		if *p.PayloadFormat == 1 {
+			label = "UTF-8"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %d (%s)", PropPayloadFormat, *p.PayloadFormat, label))
+	}
+	if p.MessageExpiry != nil {
+		lines = append(lines, fmt.Sprintf("%s: %ds", PropMessageExpiry, *p.MessageExpiry))
+	}
+	if p.ResponseTopic != "" {
+		lines = append(lines, fmt.Sprintf("%s: %s", PropResponseTopic, p.ResponseTopic))
+	}
+	if len(p.CorrelationData) > 0 {
+		lines = append(lines, fmt.Sprintf("%s: %s", PropCorrelationData, FormatCorrelationData(p.CorrelationData)))
+	}
+	for _, u := range p.User {
+		lines = append(lines, fmt.Sprintf("user %s=%s", u.Key, u.Value))
+	}
+	return lines
+}
+
+// FormatCorrelationData returns printable correlation data as text and
+// anything else as "0x"-prefixed hex.
+func FormatCorrelationData(b []byte) string {
+	if !utf8.Valid(b) {
+		return "0x" + hex.EncodeToString(b)
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) {
+			return "0x" + hex.EncodeToString(b)
+		}
+	}
+	return string(b)
+}
+
+// FormatUserProperties joins user properties as "key=value, key=value".
+func FormatUserProperties(props []UserProperty) string {
+	parts := make([]string, len(props))
+	for i, u := range props {
+		parts[i] = u.Key + "=" + u.Value
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseUserProperties parses a comma separated list of key=value pairs.
+func ParseUserProperties(s string) ([]UserProperty, error) {
+	var out []UserProperty
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid user property %q: want key=value", part)
+		}
+		out = append(out, UserProperty{Key: k, Value: strings.TrimSpace(v)})
+	}
+	return out, nil
+}
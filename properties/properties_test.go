@@ -0,0 +1,63 @@
+package properties
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseUserProperties(t *testing.T) {
+	got, err := ParseUserProperties(" tenant=abc , region = eu,tenant=def,")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []UserProperty{{"tenant", "abc"}, {"region", "eu"}, {"tenant", "def"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if s := FormatUserProperties(got); s != "tenant=abc, region=eu, tenant=def" {
+		t.Fatalf("unexpected format %q", s)
+	}
+	if _, err := ParseUserProperties("novalue"); err == nil {
+		t.Fatalf("expected error for missing '='")
+	}
+}
+
+func TestPropertiesLookup(t *testing.T) {
+	pf := byte(1)
+	exp := uint32(60)
+	p := &Properties{
+		ContentType:     "application/json",
+		PayloadFormat:   &pf,
+		MessageExpiry:   &exp,
+		ResponseTopic:   "reply/1",
+		CorrelationData: []byte{0x01, 0xff},
+		User:            []UserProperty{{"tenant", "abc"}, {"content-type", "shadow"}},
+	}
+	cases := map[string]string{
+		"tenant":            "abc",
+		PropContentType:     "shadow",
+		PropPayloadFormat:   "1",
+		PropMessageExpiry:   "60",
+		PropResponseTopic:   "reply/1",
+		PropCorrelationData: "0x01ff",
+	}
+	for k, want := range cases {
+		if got, ok := p.Lookup(k); !ok || got != want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q", k, got, ok, want)
+		}
+	}
+	if _, ok := p.Lookup("missing"); ok {
+		t.Fatalf("unexpected match for missing key")
+	}
+	var nilProps *Properties
+	if _, ok := nilProps.Lookup("tenant"); ok || !nilProps.IsEmpty() {
+		t.Fatalf("nil properties should be empty")
+	}
+	lines := strings.Join(p.Lines(), "\n")
+	for _, want := range []string{"content-type: application/json", "payload-format: 1 (UTF-8)", "message-expiry: 60s", "response-topic: reply/1", "correlation-data: 0x01ff", "user tenant=abc"} {
+		if !strings.Contains(lines, want) {
+			t.Fatalf("expected %q in %q", want, lines)
+		}
+	}
+}
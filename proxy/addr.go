@@ -1,4 +1,18 @@
 package proxy
 
-// DefaultAddr is the default address for the DB proxy when none is configured.
-const DefaultAddr = "127.0.0.1:54321"
+import (
+	"path/filepath"
+
+	"github.com/marang/emqutiti/internal/files"
+)
+
+// DefaultTCPAddr is the loopback address that proxies used before the
+// socket of DefaultAddr. Configurations still naming it are moved to the
+// socket once nothing answers there.
+const DefaultTCPAddr = "127.0.0.1:54321"
+
+// DefaultAddr returns the address of the DB proxy when none is configured:
+// a Unix domain socket next to config.toml that only its owner may use.
+func DefaultAddr() string {
+	return "unix:" + filepath.Join(files.BaseDir(), "proxy.sock")
+}
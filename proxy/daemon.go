@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/marang/emqutiti/internal/files"
+)
+
+// ErrRunning reports that a live daemon holds the lock file.
+var ErrRunning = errors.New("proxy already running")
+
+// probeTimeout bounds Probe and RequestShutdown.
+const probeTimeout = time.Second
+
+// Ping reports the process serving the proxy.
+func (p *Proxy) Ping(context.Context, *PingRequest) (*PingResponse, error) {
+	return &PingResponse{Pid: int64(os.Getpid())}, nil
+}
+
+// Shutdown stops the proxy after replying, closing its databases so that
+// another proxy can take them over.
+func (p *Proxy) Shutdown(context.Context, *ShutdownRequest) (*ShutdownResponse, error) {
+	go p.Stop()
+	return &ShutdownResponse{}, nil
+}
+
+// StopWhenIdle stops the proxy once no client has been connected for d.
+func (p *Proxy) StopWhenIdle(d time.Duration) {
+	tick := max(d/4, 10*time.Millisecond)
+	go func() {
+		t := time.NewTicker(tick)
+		defer t.Stop()
+		for {
+			select {
+			case <-p.stopping:
+				return
+			case <-t.C:
+			}
+			active := time.Unix(0, atomic.LoadInt64(&p.active))
+			if atomic.LoadInt64(&p.clients) == 0 && time.Since(active) >= d {
+				p.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Probe returns the process ID of the proxy answering at addr, or an error
+// when nothing or something other than a proxy is listening there.
+func Probe(addr string) (int64, error) {
+	cl, conn, err := NewClient(addr)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	resp, err := cl.Ping(ctx, &PingRequest{})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetPid(), nil
+}
+
+// RequestShutdown asks the proxy at addr to stop.
+func RequestShutdown(addr string) error {
+	cl, conn, err := NewClient(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	_, err = cl.Shutdown(ctx, &ShutdownRequest{})
+	return err
+}
+
+// LockInfo is the content of the PID/lock file of a daemon.
+type LockInfo struct {
+	PID  int    `json:"pid"`
+	Addr string `json:"addr"`
+}
+
+// LockPath returns the PID/lock file of the proxy daemon.
+func LockPath() string { return filepath.Join(files.BaseDir(), "proxy.pid") }
+
+// ReadLock returns the daemon recorded in the lock file at path.
+func ReadLock(path string) (LockInfo, error) {
+	var info LockInfo
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(b, &info)
+	return info, err
+}
+
+// Live reports whether the daemon of info still serves its address.
+func (info LockInfo) Live() bool {
+	pid, err := Probe(info.Addr)
+	return err == nil && pid == int64(info.PID)
+}
+
+// Lock records info in the lock file at path and returns a function that
+// removes it. A lock left by a daemon that no longer serves its address is
+// replaced; a live one yields ErrRunning.
+func Lock(path string, info LockInfo) (func(), error) {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	for range 2 {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			old, rerr := ReadLock(path)
+			if rerr == nil && old.Live() {
+				return nil, fmt.Errorf("%w: pid %d at %s", ErrRunning, old.PID, old.Addr)
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.Write(append(b, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		return func() {
+			if cur, err := ReadLock(path); err == nil && cur == info {
+				os.Remove(path)
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("lock %s: %w", path, os.ErrExist)
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShutdownStopsProxy(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	p, err := StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	pid, err := Probe(p.Addr())
+	if err != nil || pid != int64(os.Getpid()) {
+		t.Fatalf("probe = %d, %v", pid, err)
+	}
+	if err := RequestShutdown(p.Addr()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	select {
+	case <-p.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy did not stop")
+	}
+	if _, err := Probe(p.Addr()); err == nil {
+		t.Fatal("stopped proxy still answers")
+	}
+}
+
+func TestStopWhenIdleWaitsForClients(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	p, err := StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	cl, conn, err := NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	if _, err := cl.Ping(context.Background(), &PingRequest{}); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	p.StopWhenIdle(100 * time.Millisecond)
+	select {
+	case <-p.Done():
+		t.Fatal("proxy stopped with a connected client")
+	case <-time.After(400 * time.Millisecond):
+	}
+	conn.Close()
+	select {
+	case <-p.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle proxy did not stop")
+	}
+}
+
+func TestLockReplacesStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.pid")
+	p, err := StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	info := LockInfo{PID: os.Getpid(), Addr: p.Addr()}
+	unlock, err := Lock(path, info)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if _, err := Lock(path, LockInfo{PID: 1, Addr: "127.0.0.1:1"}); !errors.Is(err, ErrRunning) {
+		t.Fatalf("second lock err = %v, want ErrRunning", err)
+	}
+	unlock()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("lock file left after unlock: %v", err)
+	}
+
+	// A lock whose daemon no longer answers is taken over.
+	stale := LockInfo{PID: 1, Addr: "127.0.0.1:1"}
+	if _, err := Lock(path, stale); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	unlock, err = Lock(path, info)
+	if err != nil {
+		t.Fatalf("lock over stale: %v", err)
+	}
+	defer unlock()
+	if got, err := ReadLock(path); err != nil || got != info {
+		t.Fatalf("lock = %+v, %v; want %+v", got, err, info)
+	}
+}
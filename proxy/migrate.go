@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// payloadSchema is the payload encoding of stored messages; see
+// storedMessage.payload.
+const payloadSchema = 2
+
+// payloadBuckets hold messages whose payloads migratePayloads upgrades.
+var payloadBuckets = map[string]bool{"history": true, "traces": true}
+
+// migratePayloads rewrites the messages of db stored before payload schema
+// 2 and records completion in the file marker, so later opens skip the
+// scan. It returns the number of messages rewritten.
+func migratePayloads(db *badger.DB, marker string) (int, error) {
+	if _, err := os.Stat(marker); err == nil {
+		return 0, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+	n := 0
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			up, ok := upgradePayload(val)
+			if !ok {
+				continue
+			}
+			if err := wb.Set(item.KeyCopy(nil), up); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+	return n, os.WriteFile(marker, []byte(strconv.Itoa(payloadSchema)+"\n"), 0o600)
+}
+
+// upgradePayload re-encodes the payload of a message stored as a JSON
+// string as base64 bytes. Other fields are kept; values that are not such
+// messages are left alone.
+func upgradePayload(val []byte) ([]byte, bool) {
+	var m storedMessage
+	if err := json.Unmarshal(val, &m); err != nil || m.Schema >= payloadSchema {
+		return nil, false
+	}
+	if !bytes.HasPrefix(m.Payload, []byte(`"`)) {
+		return nil, false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(val, &fields); err != nil {
+		return nil, false
+	}
+	var err error
+	if fields["Payload"], err = json.Marshal(m.payload()); err != nil {
+		return nil, false
+	}
+	fields["Schema"] = json.RawMessage(strconv.Itoa(payloadSchema))
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
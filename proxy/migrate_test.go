@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/marang/emqutiti/internal/files"
+)
+
+func TestProxyMigratesLegacyPayloadsOnce(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(files.DataDir("p1"), "history")
+	db, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	legacy := map[string]string{
+		"a/1":   `{"Topic":"a","Payload":"héllo","Kind":"sub","Retained":true}`,
+		"b/1":   `{"Topic":"b","Payload":"AP8=","Kind":"sub","Schema":2}`,
+		"other": `"not a message"`,
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		for k, v := range legacy {
+			if err := txn.Set([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	p, err := StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	client, conn, err := NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	read := func(key string) []byte {
+		t.Helper()
+		resp, err := client.Read(context.Background(), &ReadRequest{Profile: "p1", Bucket: "history", Key: key})
+		if err != nil || len(resp.GetValues()) != 1 {
+			t.Fatalf("read %s: %v %v", key, resp, err)
+		}
+		return resp.GetValues()[0]
+	}
+	var m struct {
+		Payload  []byte
+		Retained bool
+		Schema   int
+	}
+	if err := json.Unmarshal(read("a/"), &m); err != nil || string(m.Payload) != "héllo" || !m.Retained || m.Schema != payloadSchema {
+		t.Fatalf("legacy message not migrated: %s", read("a/"))
+	}
+	if got := string(read("b/")); got != legacy["b/1"] {
+		t.Fatalf("current message rewritten: %s", got)
+	}
+	if got := string(read("other")); got != legacy["other"] {
+		t.Fatalf("non-message rewritten: %s", got)
+	}
+	if _, err := os.Stat(path + ".schema"); err != nil {
+		t.Fatalf("migration not recorded: %v", err)
+	}
+
+	// Completed migrations are not repeated.
+	if _, err := client.Write(context.Background(), &WriteRequest{Profile: "p1", Bucket: "history", Key: "c/1", Value: []byte(`{"Topic":"c","Payload":"x"}`)}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	p.Stop()
+	p, err = StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("restart proxy: %v", err)
+	}
+	defer p.Stop()
+	client, conn2, err := NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn2.Close()
+	if got := string(read("c/")); got != `{"Topic":"c","Payload":"x"}` {
+		t.Fatalf("migration ran again: %s", got)
+	}
+}
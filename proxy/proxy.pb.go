@@ -21,12 +21,59 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type WatchEvent_Op int32
+
+const (
+	WatchEvent_PUT    WatchEvent_Op = 0
+	WatchEvent_DELETE WatchEvent_Op = 1
+)
+
+// Enum value maps for WatchEvent_Op.
+var (
+	WatchEvent_Op_name = map[int32]string{
+		0: "PUT",
+		1: "DELETE",
+	}
+	WatchEvent_Op_value = map[string]int32{
+		"PUT":    0,
+		"DELETE": 1,
+	}
+)
+
+func (x WatchEvent_Op) Enum() *WatchEvent_Op {
+	p := new(WatchEvent_Op)
+	*p = x
+	return p
+}
+
+func (x WatchEvent_Op) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WatchEvent_Op) Descriptor() protoreflect.EnumDescriptor {
+	return file_proxy_proxy_proto_enumTypes[0].Descriptor()
+}
+
+func (WatchEvent_Op) Type() protoreflect.EnumType {
+	return &file_proxy_proxy_proto_enumTypes[0]
+}
+
+func (x WatchEvent_Op) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WatchEvent_Op.Descriptor instead.
+func (WatchEvent_Op) EnumDescriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{15, 0}
+}
+
 type WriteRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Profile       string                 `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
 	Bucket        string                 `protobuf:"bytes,2,opt,name=bucket,proto3" json:"bucket,omitempty"`
 	Key           string                 `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
 	Value         []byte                 `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	Origin        string                 `protobuf:"bytes,5,opt,name=origin,proto3" json:"origin,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -89,6 +136,13 @@ func (x *WriteRequest) GetValue() []byte {
 	return nil
 }
 
+func (x *WriteRequest) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
 type WriteResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -125,6 +179,126 @@ func (*WriteResponse) Descriptor() ([]byte, []int) {
 	return file_proxy_proxy_proto_rawDescGZIP(), []int{1}
 }
 
+type WriteBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Profile       string                 `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
+	Bucket        string                 `protobuf:"bytes,2,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	Records       []*QueryRecord         `protobuf:"bytes,3,rep,name=records,proto3" json:"records,omitempty"`
+	Origin        string                 `protobuf:"bytes,4,opt,name=origin,proto3" json:"origin,omitempty"`
+	IfAbsent      bool                   `protobuf:"varint,5,opt,name=if_absent,json=ifAbsent,proto3" json:"if_absent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteBatchRequest) Reset() {
+	*x = WriteBatchRequest{}
+	mi := &file_proxy_proxy_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteBatchRequest) ProtoMessage() {}
+
+func (x *WriteBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteBatchRequest.ProtoReflect.Descriptor instead.
+func (*WriteBatchRequest) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WriteBatchRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *WriteBatchRequest) GetBucket() string {
+	if x != nil {
+		return x.Bucket
+	}
+	return ""
+}
+
+func (x *WriteBatchRequest) GetRecords() []*QueryRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *WriteBatchRequest) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *WriteBatchRequest) GetIfAbsent() bool {
+	if x != nil {
+		return x.IfAbsent
+	}
+	return false
+}
+
+type WriteBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Existing      []string               `protobuf:"bytes,1,rep,name=existing,proto3" json:"existing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteBatchResponse) Reset() {
+	*x = WriteBatchResponse{}
+	mi := &file_proxy_proxy_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteBatchResponse) ProtoMessage() {}
+
+func (x *WriteBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteBatchResponse.ProtoReflect.Descriptor instead.
+func (*WriteBatchResponse) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WriteBatchResponse) GetExisting() []string {
+	if x != nil {
+		return x.Existing
+	}
+	return nil
+}
+
 type ReadRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Profile       string                 `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
@@ -136,7 +310,7 @@ type ReadRequest struct {
 
 func (x *ReadRequest) Reset() {
 	*x = ReadRequest{}
-	mi := &file_proxy_proxy_proto_msgTypes[2]
+	mi := &file_proxy_proxy_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -148,7 +322,7 @@ func (x *ReadRequest) String() string {
 func (*ReadRequest) ProtoMessage() {}
 
 func (x *ReadRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proxy_proxy_proto_msgTypes[2]
+	mi := &file_proxy_proxy_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -161,7 +335,7 @@ func (x *ReadRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReadRequest.ProtoReflect.Descriptor instead.
 func (*ReadRequest) Descriptor() ([]byte, []int) {
-	return file_proxy_proxy_proto_rawDescGZIP(), []int{2}
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *ReadRequest) GetProfile() string {
@@ -194,7 +368,7 @@ type ReadResponse struct {
 
 func (x *ReadResponse) Reset() {
 	*x = ReadResponse{}
-	mi := &file_proxy_proxy_proto_msgTypes[3]
+	mi := &file_proxy_proxy_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -206,7 +380,7 @@ func (x *ReadResponse) String() string {
 func (*ReadResponse) ProtoMessage() {}
 
 func (x *ReadResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proxy_proxy_proto_msgTypes[3]
+	mi := &file_proxy_proxy_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -219,7 +393,7 @@ func (x *ReadResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReadResponse.ProtoReflect.Descriptor instead.
 func (*ReadResponse) Descriptor() ([]byte, []int) {
-	return file_proxy_proxy_proto_rawDescGZIP(), []int{3}
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ReadResponse) GetValues() [][]byte {
@@ -234,13 +408,14 @@ type DeleteRequest struct {
 	Profile       string                 `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
 	Bucket        string                 `protobuf:"bytes,2,opt,name=bucket,proto3" json:"bucket,omitempty"`
 	Key           string                 `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	Origin        string                 `protobuf:"bytes,4,opt,name=origin,proto3" json:"origin,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *DeleteRequest) Reset() {
 	*x = DeleteRequest{}
-	mi := &file_proxy_proxy_proto_msgTypes[4]
+	mi := &file_proxy_proxy_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -252,7 +427,7 @@ func (x *DeleteRequest) String() string {
 func (*DeleteRequest) ProtoMessage() {}
 
 func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proxy_proxy_proto_msgTypes[4]
+	mi := &file_proxy_proxy_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -265,7 +440,7 @@ func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
 func (*DeleteRequest) Descriptor() ([]byte, []int) {
-	return file_proxy_proxy_proto_rawDescGZIP(), []int{4}
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *DeleteRequest) GetProfile() string {
@@ -289,6 +464,13 @@ func (x *DeleteRequest) GetKey() string {
 	return ""
 }
 
+func (x *DeleteRequest) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
 type DeleteResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -297,7 +479,7 @@ type DeleteResponse struct {
 
 func (x *DeleteResponse) Reset() {
 	*x = DeleteResponse{}
-	mi := &file_proxy_proxy_proto_msgTypes[5]
+	mi := &file_proxy_proxy_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -309,7 +491,7 @@ func (x *DeleteResponse) String() string {
 func (*DeleteResponse) ProtoMessage() {}
 
 func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proxy_proxy_proto_msgTypes[5]
+	mi := &file_proxy_proxy_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -322,7 +504,7 @@ func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
 func (*DeleteResponse) Descriptor() ([]byte, []int) {
-	return file_proxy_proxy_proto_rawDescGZIP(), []int{5}
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{7}
 }
 
 type StatusRequest struct {
@@ -333,7 +515,7 @@ type StatusRequest struct {
 
 func (x *StatusRequest) Reset() {
 	*x = StatusRequest{}
-	mi := &file_proxy_proxy_proto_msgTypes[6]
+	mi := &file_proxy_proxy_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -345,7 +527,7 @@ func (x *StatusRequest) String() string {
 func (*StatusRequest) ProtoMessage() {}
 
 func (x *StatusRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proxy_proxy_proto_msgTypes[6]
+	mi := &file_proxy_proxy_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -358,7 +540,7 @@ func (x *StatusRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
 func (*StatusRequest) Descriptor() ([]byte, []int) {
-	return file_proxy_proxy_proto_rawDescGZIP(), []int{6}
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{8}
 }
 
 type DBInfo struct {
@@ -367,13 +549,15 @@ type DBInfo struct {
 	Bucket        string                 `protobuf:"bytes,2,opt,name=bucket,proto3" json:"bucket,omitempty"`
 	Size          uint64                 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
 	Entries       uint64                 `protobuf:"varint,4,opt,name=entries,proto3" json:"entries,omitempty"`
+	Pruned        uint64                 `protobuf:"varint,5,opt,name=pruned,proto3" json:"pruned,omitempty"`
+	Reclaimed     uint64                 `protobuf:"varint,6,opt,name=reclaimed,proto3" json:"reclaimed,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *DBInfo) Reset() {
 	*x = DBInfo{}
-	mi := &file_proxy_proxy_proto_msgTypes[7]
+	mi := &file_proxy_proxy_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -385,7 +569,7 @@ func (x *DBInfo) String() string {
 func (*DBInfo) ProtoMessage() {}
 
 func (x *DBInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_proxy_proxy_proto_msgTypes[7]
+	mi := &file_proxy_proxy_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -398,7 +582,7 @@ func (x *DBInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DBInfo.ProtoReflect.Descriptor instead.
 func (*DBInfo) Descriptor() ([]byte, []int) {
-	return file_proxy_proxy_proto_rawDescGZIP(), []int{7}
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *DBInfo) GetProfile() string {
@@ -429,6 +613,20 @@ func (x *DBInfo) GetEntries() uint64 {
 	return 0
 }
 
+func (x *DBInfo) GetPruned() uint64 {
+	if x != nil {
+		return x.Pruned
+	}
+	return 0
+}
+
+func (x *DBInfo) GetReclaimed() uint64 {
+	if x != nil {
+		return x.Reclaimed
+	}
+	return 0
+}
+
 type StatusResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Dbs           []*DBInfo              `protobuf:"bytes,1,rep,name=dbs,proto3" json:"dbs,omitempty"`
@@ -442,7 +640,7 @@ type StatusResponse struct {
 
 func (x *StatusResponse) Reset() {
 	*x = StatusResponse{}
-	mi := &file_proxy_proxy_proto_msgTypes[8]
+	mi := &file_proxy_proxy_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -454,7 +652,7 @@ func (x *StatusResponse) String() string {
 func (*StatusResponse) ProtoMessage() {}
 
 func (x *StatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proxy_proxy_proto_msgTypes[8]
+	mi := &file_proxy_proxy_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -467,7 +665,7 @@ func (x *StatusResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
 func (*StatusResponse) Descriptor() ([]byte, []int) {
-	return file_proxy_proxy_proto_rawDescGZIP(), []int{8}
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *StatusResponse) GetDbs() []*DBInfo {
@@ -505,45 +703,629 @@ func (x *StatusResponse) GetClients() int64 {
 	return 0
 }
 
-var File_proxy_proxy_proto protoreflect.FileDescriptor
+type QueryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Profile       string                 `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
+	Bucket        string                 `protobuf:"bytes,2,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	Prefix        string                 `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Topics        []string               `protobuf:"bytes,4,rep,name=topics,proto3" json:"topics,omitempty"`
+	Start         int64                  `protobuf:"varint,5,opt,name=start,proto3" json:"start,omitempty"`
+	End           int64                  `protobuf:"varint,6,opt,name=end,proto3" json:"end,omitempty"`
+	Payload       string                 `protobuf:"bytes,7,opt,name=payload,proto3" json:"payload,omitempty"`
+	Kinds         []string               `protobuf:"bytes,8,rep,name=kinds,proto3" json:"kinds,omitempty"`
+	Archived      bool                   `protobuf:"varint,9,opt,name=archived,proto3" json:"archived,omitempty"`
+	Cursor        string                 `protobuf:"bytes,10,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Limit         uint32                 `protobuf:"varint,11,opt,name=limit,proto3" json:"limit,omitempty"`
+	CountOnly     bool                   `protobuf:"varint,12,opt,name=count_only,json=countOnly,proto3" json:"count_only,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_proxy_proxy_proto_rawDesc = "" +
-	"\n" +
-	"\x11proxy/proxy.proto\x12\x05proxy\"h\n" +
-	"\fWriteRequest\x12\x18\n" +
-	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
-	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x10\n" +
-	"\x03key\x18\x03 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x04 \x01(\fR\x05value\"\x0f\n" +
-	"\rWriteResponse\"Q\n" +
-	"\vReadRequest\x12\x18\n" +
-	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
-	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x10\n" +
-	"\x03key\x18\x03 \x01(\tR\x03key\"&\n" +
-	"\fReadResponse\x12\x16\n" +
-	"\x06values\x18\x01 \x03(\fR\x06values\"S\n" +
-	"\rDeleteRequest\x12\x18\n" +
-	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
-	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x10\n" +
-	"\x03key\x18\x03 \x01(\tR\x03key\"\x10\n" +
-	"\x0eDeleteResponse\"\x0f\n" +
-	"\rStatusRequest\"h\n" +
-	"\x06DBInfo\x12\x18\n" +
-	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
-	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x12\n" +
-	"\x04size\x18\x03 \x01(\x04R\x04size\x12\x18\n" +
-	"\aentries\x18\x04 \x01(\x04R\aentries\"\x93\x01\n" +
-	"\x0eStatusResponse\x12\x1f\n" +
-	"\x03dbs\x18\x01 \x03(\v2\r.proxy.DBInfoR\x03dbs\x12\x14\n" +
-	"\x05reads\x18\x02 \x01(\x04R\x05reads\x12\x16\n" +
-	"\x06writes\x18\x03 \x01(\x04R\x06writes\x12\x18\n" +
-	"\adeletes\x18\x04 \x01(\x04R\adeletes\x12\x18\n" +
-	"\aclients\x18\x05 \x01(\x03R\aclients2\xdc\x01\n" +
-	"\aDBProxy\x122\n" +
-	"\x05Write\x12\x13.proxy.WriteRequest\x1a\x14.proxy.WriteResponse\x12/\n" +
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_proxy_proxy_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRequest.ProtoReflect.Descriptor instead.
+func (*QueryRequest) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *QueryRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetBucket() string {
+	if x != nil {
+		return x.Bucket
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetTopics() []string {
+	if x != nil {
+		return x.Topics
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *QueryRequest) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *QueryRequest) GetPayload() string {
+	if x != nil {
+		return x.Payload
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetKinds() []string {
+	if x != nil {
+		return x.Kinds
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetArchived() bool {
+	if x != nil {
+		return x.Archived
+	}
+	return false
+}
+
+func (x *QueryRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetLimit() uint32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *QueryRequest) GetCountOnly() bool {
+	if x != nil {
+		return x.CountOnly
+	}
+	return false
+}
+
+type QueryRecord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         []byte                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryRecord) Reset() {
+	*x = QueryRecord{}
+	mi := &file_proxy_proxy_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRecord) ProtoMessage() {}
+
+func (x *QueryRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRecord.ProtoReflect.Descriptor instead.
+func (*QueryRecord) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *QueryRecord) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *QueryRecord) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type QueryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       []*QueryRecord         `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	Count         uint64                 `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResponse) Reset() {
+	*x = QueryResponse{}
+	mi := &file_proxy_proxy_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResponse) ProtoMessage() {}
+
+func (x *QueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResponse.ProtoReflect.Descriptor instead.
+func (*QueryResponse) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *QueryResponse) GetRecords() []*QueryRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *QueryResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+func (x *QueryResponse) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type WatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Profile       string                 `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
+	Bucket        string                 `protobuf:"bytes,2,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	Prefix        string                 `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_proxy_proxy_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *WatchRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetBucket() string {
+	if x != nil {
+		return x.Bucket
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+type WatchEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Op            WatchEvent_Op          `protobuf:"varint,1,opt,name=op,proto3,enum=proxy.WatchEvent_Op" json:"op,omitempty"`
+	Key           string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value         []byte                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Origin        string                 `protobuf:"bytes,4,opt,name=origin,proto3" json:"origin,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEvent) Reset() {
+	*x = WatchEvent{}
+	mi := &file_proxy_proxy_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEvent) ProtoMessage() {}
+
+func (x *WatchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEvent.ProtoReflect.Descriptor instead.
+func (*WatchEvent) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *WatchEvent) GetOp() WatchEvent_Op {
+	if x != nil {
+		return x.Op
+	}
+	return WatchEvent_PUT
+}
+
+func (x *WatchEvent) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *WatchEvent) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+type PingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	mi := &file_proxy_proxy_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{16}
+}
+
+type PingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pid           int64                  `protobuf:"varint,1,opt,name=pid,proto3" json:"pid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	mi := &file_proxy_proxy_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *PingResponse) GetPid() int64 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+type ShutdownRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShutdownRequest) Reset() {
+	*x = ShutdownRequest{}
+	mi := &file_proxy_proxy_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShutdownRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShutdownRequest) ProtoMessage() {}
+
+func (x *ShutdownRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShutdownRequest.ProtoReflect.Descriptor instead.
+func (*ShutdownRequest) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{18}
+}
+
+type ShutdownResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShutdownResponse) Reset() {
+	*x = ShutdownResponse{}
+	mi := &file_proxy_proxy_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShutdownResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShutdownResponse) ProtoMessage() {}
+
+func (x *ShutdownResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_proxy_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShutdownResponse.ProtoReflect.Descriptor instead.
+func (*ShutdownResponse) Descriptor() ([]byte, []int) {
+	return file_proxy_proxy_proto_rawDescGZIP(), []int{19}
+}
+
+var File_proxy_proxy_proto protoreflect.FileDescriptor
+
+const file_proxy_proxy_proto_rawDesc = "" +
+	"\n" +
+	"\x11proxy/proxy.proto\x12\x05proxy\"\x80\x01\n" +
+	"\fWriteRequest\x12\x18\n" +
+	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
+	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x10\n" +
+	"\x03key\x18\x03 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\fR\x05value\x12\x16\n" +
+	"\x06origin\x18\x05 \x01(\tR\x06origin\"\x0f\n" +
+	"\rWriteResponse\"\xa8\x01\n" +
+	"\x11WriteBatchRequest\x12\x18\n" +
+	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
+	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12,\n" +
+	"\arecords\x18\x03 \x03(\v2\x12.proxy.QueryRecordR\arecords\x12\x16\n" +
+	"\x06origin\x18\x04 \x01(\tR\x06origin\x12\x1b\n" +
+	"\tif_absent\x18\x05 \x01(\bR\bifAbsent\"0\n" +
+	"\x12WriteBatchResponse\x12\x1a\n" +
+	"\bexisting\x18\x01 \x03(\tR\bexisting\"Q\n" +
+	"\vReadRequest\x12\x18\n" +
+	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
+	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x10\n" +
+	"\x03key\x18\x03 \x01(\tR\x03key\"&\n" +
+	"\fReadResponse\x12\x16\n" +
+	"\x06values\x18\x01 \x03(\fR\x06values\"k\n" +
+	"\rDeleteRequest\x12\x18\n" +
+	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
+	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x10\n" +
+	"\x03key\x18\x03 \x01(\tR\x03key\x12\x16\n" +
+	"\x06origin\x18\x04 \x01(\tR\x06origin\"\x10\n" +
+	"\x0eDeleteResponse\"\x0f\n" +
+	"\rStatusRequest\"\x9e\x01\n" +
+	"\x06DBInfo\x12\x18\n" +
+	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
+	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x04R\x04size\x12\x18\n" +
+	"\aentries\x18\x04 \x01(\x04R\aentries\x12\x16\n" +
+	"\x06pruned\x18\x05 \x01(\x04R\x06pruned\x12\x1c\n" +
+	"\treclaimed\x18\x06 \x01(\x04R\treclaimed\"\x93\x01\n" +
+	"\x0eStatusResponse\x12\x1f\n" +
+	"\x03dbs\x18\x01 \x03(\v2\r.proxy.DBInfoR\x03dbs\x12\x14\n" +
+	"\x05reads\x18\x02 \x01(\x04R\x05reads\x12\x16\n" +
+	"\x06writes\x18\x03 \x01(\x04R\x06writes\x12\x18\n" +
+	"\adeletes\x18\x04 \x01(\x04R\adeletes\x12\x18\n" +
+	"\aclients\x18\x05 \x01(\x03R\aclients\"\xb1\x02\n" +
+	"\fQueryRequest\x12\x18\n" +
+	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
+	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x16\n" +
+	"\x06prefix\x18\x03 \x01(\tR\x06prefix\x12\x16\n" +
+	"\x06topics\x18\x04 \x03(\tR\x06topics\x12\x14\n" +
+	"\x05start\x18\x05 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x06 \x01(\x03R\x03end\x12\x18\n" +
+	"\apayload\x18\a \x01(\tR\apayload\x12\x14\n" +
+	"\x05kinds\x18\b \x03(\tR\x05kinds\x12\x1a\n" +
+	"\barchived\x18\t \x01(\bR\barchived\x12\x16\n" +
+	"\x06cursor\x18\n" +
+	" \x01(\tR\x06cursor\x12\x14\n" +
+	"\x05limit\x18\v \x01(\rR\x05limit\x12\x1d\n" +
+	"\n" +
+	"count_only\x18\f \x01(\bR\tcountOnly\"5\n" +
+	"\vQueryRecord\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value\"t\n" +
+	"\rQueryResponse\x12,\n" +
+	"\arecords\x18\x01 \x03(\v2\x12.proxy.QueryRecordR\arecords\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x04R\x05count\"X\n" +
+	"\fWatchRequest\x12\x18\n" +
+	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x16\n" +
+	"\x06bucket\x18\x02 \x01(\tR\x06bucket\x12\x16\n" +
+	"\x06prefix\x18\x03 \x01(\tR\x06prefix\"\x8d\x01\n" +
+	"\n" +
+	"WatchEvent\x12$\n" +
+	"\x02op\x18\x01 \x01(\x0e2\x14.proxy.WatchEvent.OpR\x02op\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\fR\x05value\x12\x16\n" +
+	"\x06origin\x18\x04 \x01(\tR\x06origin\"\x19\n" +
+	"\x02Op\x12\a\n" +
+	"\x03PUT\x10\x00\x12\n" +
+	"\n" +
+	"\x06DELETE\x10\x01\"\r\n" +
+	"\vPingRequest\" \n" +
+	"\fPingResponse\x12\x10\n" +
+	"\x03pid\x18\x01 \x01(\x03R\x03pid\"\x11\n" +
+	"\x0fShutdownRequest\"\x12\n" +
+	"\x10ShutdownResponse2\xf6\x03\n" +
+	"\aDBProxy\x122\n" +
+	"\x05Write\x12\x13.proxy.WriteRequest\x1a\x14.proxy.WriteResponse\x12A\n" +
+	"\n" +
+	"WriteBatch\x12\x18.proxy.WriteBatchRequest\x1a\x19.proxy.WriteBatchResponse\x12/\n" +
 	"\x04Read\x12\x12.proxy.ReadRequest\x1a\x13.proxy.ReadResponse\x125\n" +
 	"\x06Delete\x12\x14.proxy.DeleteRequest\x1a\x15.proxy.DeleteResponse\x125\n" +
-	"\x06Status\x12\x14.proxy.StatusRequest\x1a\x15.proxy.StatusResponseB(Z&github.com/marang/emqutiti/proxy;proxyb\x06proto3"
+	"\x06Status\x12\x14.proxy.StatusRequest\x1a\x15.proxy.StatusResponse\x124\n" +
+	"\x05Query\x12\x13.proxy.QueryRequest\x1a\x14.proxy.QueryResponse0\x01\x121\n" +
+	"\x05Watch\x12\x13.proxy.WatchRequest\x1a\x11.proxy.WatchEvent0\x01\x12/\n" +
+	"\x04Ping\x12\x12.proxy.PingRequest\x1a\x13.proxy.PingResponse\x12;\n" +
+	"\bShutdown\x12\x16.proxy.ShutdownRequest\x1a\x17.proxy.ShutdownResponseB(Z&github.com/marang/emqutiti/proxy;proxyb\x06proto3"
 
 var (
 	file_proxy_proxy_proto_rawDescOnce sync.Once
@@ -557,33 +1339,59 @@ func file_proxy_proxy_proto_rawDescGZIP() []byte {
 	return file_proxy_proxy_proto_rawDescData
 }
 
-var file_proxy_proxy_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_proxy_proxy_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proxy_proxy_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
 var file_proxy_proxy_proto_goTypes = []any{
-	(*WriteRequest)(nil),   // 0: proxy.WriteRequest
-	(*WriteResponse)(nil),  // 1: proxy.WriteResponse
-	(*ReadRequest)(nil),    // 2: proxy.ReadRequest
-	(*ReadResponse)(nil),   // 3: proxy.ReadResponse
-	(*DeleteRequest)(nil),  // 4: proxy.DeleteRequest
-	(*DeleteResponse)(nil), // 5: proxy.DeleteResponse
-	(*StatusRequest)(nil),  // 6: proxy.StatusRequest
-	(*DBInfo)(nil),         // 7: proxy.DBInfo
-	(*StatusResponse)(nil), // 8: proxy.StatusResponse
+	(WatchEvent_Op)(0),         // 0: proxy.WatchEvent.Op
+	(*WriteRequest)(nil),       // 1: proxy.WriteRequest
+	(*WriteResponse)(nil),      // 2: proxy.WriteResponse
+	(*WriteBatchRequest)(nil),  // 3: proxy.WriteBatchRequest
+	(*WriteBatchResponse)(nil), // 4: proxy.WriteBatchResponse
+	(*ReadRequest)(nil),        // 5: proxy.ReadRequest
+	(*ReadResponse)(nil),       // 6: proxy.ReadResponse
+	(*DeleteRequest)(nil),      // 7: proxy.DeleteRequest
+	(*DeleteResponse)(nil),     // 8: proxy.DeleteResponse
+	(*StatusRequest)(nil),      // 9: proxy.StatusRequest
+	(*DBInfo)(nil),             // 10: proxy.DBInfo
+	(*StatusResponse)(nil),     // 11: proxy.StatusResponse
+	(*QueryRequest)(nil),       // 12: proxy.QueryRequest
+	(*QueryRecord)(nil),        // 13: proxy.QueryRecord
+	(*QueryResponse)(nil),      // 14: proxy.QueryResponse
+	(*WatchRequest)(nil),       // 15: proxy.WatchRequest
+	(*WatchEvent)(nil),         // 16: proxy.WatchEvent
+	(*PingRequest)(nil),        // 17: proxy.PingRequest
+	(*PingResponse)(nil),       // 18: proxy.PingResponse
+	(*ShutdownRequest)(nil),    // 19: proxy.ShutdownRequest
+	(*ShutdownResponse)(nil),   // 20: proxy.ShutdownResponse
 }
 var file_proxy_proxy_proto_depIdxs = []int32{
-	7, // 0: proxy.StatusResponse.dbs:type_name -> proxy.DBInfo
-	0, // 1: proxy.DBProxy.Write:input_type -> proxy.WriteRequest
-	2, // 2: proxy.DBProxy.Read:input_type -> proxy.ReadRequest
-	4, // 3: proxy.DBProxy.Delete:input_type -> proxy.DeleteRequest
-	6, // 4: proxy.DBProxy.Status:input_type -> proxy.StatusRequest
-	1, // 5: proxy.DBProxy.Write:output_type -> proxy.WriteResponse
-	3, // 6: proxy.DBProxy.Read:output_type -> proxy.ReadResponse
-	5, // 7: proxy.DBProxy.Delete:output_type -> proxy.DeleteResponse
-	8, // 8: proxy.DBProxy.Status:output_type -> proxy.StatusResponse
-	5, // [5:9] is the sub-list for method output_type
-	1, // [1:5] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	13, // 0: proxy.WriteBatchRequest.records:type_name -> proxy.QueryRecord
+	10, // 1: proxy.StatusResponse.dbs:type_name -> proxy.DBInfo
+	13, // 2: proxy.QueryResponse.records:type_name -> proxy.QueryRecord
+	0,  // 3: proxy.WatchEvent.op:type_name -> proxy.WatchEvent.Op
+	1,  // 4: proxy.DBProxy.Write:input_type -> proxy.WriteRequest
+	3,  // 5: proxy.DBProxy.WriteBatch:input_type -> proxy.WriteBatchRequest
+	5,  // 6: proxy.DBProxy.Read:input_type -> proxy.ReadRequest
+	7,  // 7: proxy.DBProxy.Delete:input_type -> proxy.DeleteRequest
+	9,  // 8: proxy.DBProxy.Status:input_type -> proxy.StatusRequest
+	12, // 9: proxy.DBProxy.Query:input_type -> proxy.QueryRequest
+	15, // 10: proxy.DBProxy.Watch:input_type -> proxy.WatchRequest
+	17, // 11: proxy.DBProxy.Ping:input_type -> proxy.PingRequest
+	19, // 12: proxy.DBProxy.Shutdown:input_type -> proxy.ShutdownRequest
+	2,  // 13: proxy.DBProxy.Write:output_type -> proxy.WriteResponse
+	4,  // 14: proxy.DBProxy.WriteBatch:output_type -> proxy.WriteBatchResponse
+	6,  // 15: proxy.DBProxy.Read:output_type -> proxy.ReadResponse
+	8,  // 16: proxy.DBProxy.Delete:output_type -> proxy.DeleteResponse
+	11, // 17: proxy.DBProxy.Status:output_type -> proxy.StatusResponse
+	14, // 18: proxy.DBProxy.Query:output_type -> proxy.QueryResponse
+	16, // 19: proxy.DBProxy.Watch:output_type -> proxy.WatchEvent
+	18, // 20: proxy.DBProxy.Ping:output_type -> proxy.PingResponse
+	20, // 21: proxy.DBProxy.Shutdown:output_type -> proxy.ShutdownResponse
+	13, // [13:22] is the sub-list for method output_type
+	4,  // [4:13] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_proxy_proxy_proto_init() }
@@ -596,13 +1404,14 @@ func file_proxy_proxy_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proxy_proxy_proto_rawDesc), len(file_proxy_proxy_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   9,
+			NumEnums:      1,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_proxy_proxy_proto_goTypes,
 		DependencyIndexes: file_proxy_proxy_proto_depIdxs,
+		EnumInfos:         file_proxy_proxy_proto_enumTypes,
 		MessageInfos:      file_proxy_proxy_proto_msgTypes,
 	}.Build()
 	File_proxy_proxy_proto = out.File
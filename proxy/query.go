@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/sahilm/fuzzy"
+	"google.golang.org/grpc"
+)
+
+// queryBatchBytes caps the values sent in one QueryResponse so large pages
+// stay well below the gRPC message limit.
+const queryBatchBytes = 1 << 20
+
+// storedMessage holds the fields of stored history and trace messages that
+// queries filter on.
+type storedMessage struct {
+	Timestamp time.Time
+	Topic     string
+	Payload   json.RawMessage
+	Kind      string
+	Archived  bool
+	Schema    int
+}
+
+// payload decodes the stored payload. Messages written before payload
+// schema 2 hold it as a JSON string, newer ones as base64 bytes.
+func (m storedMessage) payload() []byte {
+	if len(m.Payload) == 0 || string(m.Payload) == "null" {
+		return nil
+	}
+	if m.Schema < payloadSchema {
+		var s string
+		json.Unmarshal(m.Payload, &s)
+		return []byte(s)
+	}
+	var b []byte
+	json.Unmarshal(m.Payload, &b)
+	return b
+}
+
+// MatchTopic reports whether topic equals or fuzzy-matches any of the
+// patterns. Empty patterns are ignored and no patterns match all topics.
+func MatchTopic(topic string, patterns []string) bool {
+	filtered := false
+	for _, p := range patterns {
+		if p == "" {
+			continue
+		}
+		filtered = true
+		if topic == p || len(fuzzy.Find(p, []string{topic})) > 0 {
+			return true
+		}
+	}
+	return !filtered
+}
+
+// matches reports whether a stored value satisfies the filters of req.
+// Values that are not messages never match.
+func (req *QueryRequest) matches(val []byte) bool {
+	var m storedMessage
+	if err := json.Unmarshal(val, &m); err != nil {
+		return false
+	}
+	if m.Archived != req.GetArchived() {
+		return false
+	}
+	if kinds := req.GetKinds(); len(kinds) > 0 && !slices.Contains(kinds, m.Kind) {
+		return false
+	}
+	ts := m.Timestamp.UnixNano()
+	if req.GetStart() != 0 && ts < req.GetStart() {
+		return false
+	}
+	if req.GetEnd() != 0 && ts > req.GetEnd() {
+		return false
+	}
+	if !MatchTopic(m.Topic, req.GetTopics()) {
+		return false
+	}
+	if p := req.GetPayload(); p != "" && !bytes.Contains(m.payload(), []byte(p)) {
+		return false
+	}
+	return true
+}
+
+// Query streams the messages under the key prefix that match the request
+// filters, in key order. Results start after Cursor and stop after Limit
+// matches, in which case the last response carries the cursor of the next
+// page. CountOnly returns just the number of matches.
+func (p *Proxy) Query(req *QueryRequest, stream grpc.ServerStreamingServer[QueryResponse]) error {
+	db, err := p.getDB(req.GetProfile(), req.GetBucket())
+	if err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	limit := int(req.GetLimit())
+	resp := &QueryResponse{}
+	size := 0
+	err = db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(req.GetPrefix())
+		cursor := []byte(req.GetCursor())
+		if len(cursor) > 0 {
+			it.Seek(cursor)
+			if it.Valid() && bytes.Equal(it.Item().Key(), cursor) {
+				it.Next()
+			}
+		} else {
+			it.Seek(prefix)
+		}
+		var last string
+		matched := 0
+		for ; it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if !req.matches(val) {
+				continue
+			}
+			if limit > 0 && matched == limit {
+				resp.NextCursor = last
+				return nil
+			}
+			matched++
+			last = string(it.Item().KeyCopy(nil))
+			if req.GetCountOnly() {
+				resp.Count++
+				continue
+			}
+			resp.Records = append(resp.Records, &QueryRecord{Key: last, Value: val})
+			size += len(last) + len(val)
+			if size >= queryBatchBytes {
+				if err := stream.Send(resp); err != nil {
+					return err
+				}
+				resp, size = &QueryResponse{}, 0
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&p.reads, 1)
+	return stream.Send(resp)
+}
@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"bytes"
+	"cmp"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// retentionOrigin tags the deletes of the pruning loop for watchers.
+const retentionOrigin = "retention"
+
+// Retention limits the messages kept under a key prefix of a bucket. The
+// oldest messages are removed first; zero values impose no limit.
+type Retention struct {
+	Bucket string
+	// Prefix selects the messages limited together, e.g. those of one
+	// trace. Empty covers the whole bucket.
+	Prefix   string
+	MaxAge   time.Duration
+	MaxCount int
+	// MaxBytes bounds the stored size of keys and values.
+	MaxBytes int64
+}
+
+// RetentionFunc returns the limits to enforce by profile. It is called on
+// every pass so that configuration changes apply without a restart.
+type RetentionFunc func() map[string][]Retention
+
+// pruneStats are the totals of the pruning loop for a database.
+type pruneStats struct {
+	pruned    uint64
+	reclaimed uint64
+}
+
+// EnforceRetention prunes the databases to their limits now and then every
+// interval until the proxy stops. Pruned databases have their value logs
+// garbage collected; Status reports the messages removed and the bytes
+// reclaimed.
+func (p *Proxy) EnforceRetention(limits RetentionFunc, every time.Duration) {
+	p.pruning.Add(1)
+	go func() {
+		defer p.pruning.Done()
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			p.prune(limits())
+			select {
+			case <-p.stopping:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// prune applies limits and collects the garbage of the databases it
+// shrank.
+func (p *Proxy) prune(limits map[string][]Retention) {
+	for profile, rs := range limits {
+		shrunk := map[string]*badger.DB{}
+		for _, r := range rs {
+			if p.isStopping() {
+				return
+			}
+			db, err := p.getDB(profile, r.Bucket)
+			if err != nil {
+				log.Printf("retention %s/%s: %v", profile, r.Bucket, err)
+				continue
+			}
+			n, err := p.pruneDB(db, profile, r)
+			if err != nil {
+				log.Printf("retention %s/%s%s: %v", profile, r.Bucket, r.Prefix, err)
+			}
+			if n > 0 {
+				p.addPruneStats(profile, r.Bucket, pruneStats{pruned: uint64(n)})
+				shrunk[r.Bucket] = db
+			}
+		}
+		for bucket, db := range shrunk {
+			dir := db.Opts().Dir
+			before := dirSize(dir)
+			for !p.isStopping() && db.RunValueLogGC(0.5) == nil {
+			}
+			if after := dirSize(dir); after < before {
+				p.addPruneStats(profile, bucket, pruneStats{reclaimed: uint64(before - after)})
+			}
+		}
+	}
+}
+
+// pruneDB removes the oldest messages under r.Prefix that exceed r and
+// returns how many it removed. Keys not ending in a timestamp are kept.
+func (p *Proxy) pruneDB(db *badger.DB, profile string, r Retention) (int, error) {
+	if r.MaxAge <= 0 && r.MaxCount <= 0 && r.MaxBytes <= 0 {
+		return 0, nil
+	}
+	type entry struct {
+		key  []byte
+		ts   int64
+		size int64
+	}
+	var entries []entry
+	var total int64
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(r.Prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			key := item.Key()
+			ts, err := strconv.ParseInt(string(key[bytes.LastIndexByte(key, '/')+1:]), 10, 64)
+			if err != nil {
+				continue
+			}
+			size := item.EstimatedSize()
+			entries = append(entries, entry{key: item.KeyCopy(nil), ts: ts, size: size})
+			total += size
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return cmp.Compare(a.ts, b.ts) })
+	cut := 0
+	if r.MaxAge > 0 {
+		oldest := time.Now().Add(-r.MaxAge).UnixNano()
+		for cut < len(entries) && entries[cut].ts < oldest {
+			total -= entries[cut].size
+			cut++
+		}
+	}
+	if r.MaxCount > 0 {
+		for len(entries)-cut > r.MaxCount {
+			total -= entries[cut].size
+			cut++
+		}
+	}
+	if r.MaxBytes > 0 {
+		for cut < len(entries) && total > r.MaxBytes {
+			total -= entries[cut].size
+			cut++
+		}
+	}
+	if cut == 0 {
+		return 0, nil
+	}
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+	events := make([]*WatchEvent, 0, cut)
+	for _, e := range entries[:cut] {
+		if err := wb.Delete(e.key); err != nil {
+			return 0, err
+		}
+		events = append(events, &WatchEvent{Op: WatchEvent_DELETE, Key: string(e.key), Origin: retentionOrigin})
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+	p.notify(profile, r.Bucket, events...)
+	return cut, nil
+}
+
+func (p *Proxy) isStopping() bool {
+	select {
+	case <-p.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Proxy) addPruneStats(profile, bucket string, s pruneStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.pruneStats[p.dbKey(profile, bucket)]
+	st.pruned += s.pruned
+	st.reclaimed += s.reclaimed
+	p.pruneStats[p.dbKey(profile, bucket)] = st
+}
+
+// dirSize returns the bytes of the files below dir.
+func dirSize(dir string) int64 {
+	var n int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if fi, err := d.Info(); err == nil {
+			n += fi.Size()
+		}
+		return nil
+	})
+	return n
+}
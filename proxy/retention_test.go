@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPruneEnforcesRetention(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	p, err := StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	cl, conn, err := NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	write := func(bucket, key string) {
+		if _, err := cl.Write(ctx, &WriteRequest{Profile: "p", Bucket: bucket, Key: key, Value: make([]byte, 100)}); err != nil {
+			t.Fatalf("write %s: %v", key, err)
+		}
+	}
+	now := time.Now()
+	for i := range 5 {
+		write("history", fmt.Sprintf("a/%020d", now.Add(-time.Duration(i)*48*time.Hour).UnixNano()))
+	}
+	for _, trace := range []string{"t1", "t2"} {
+		for i := range 4 {
+			write("traces", fmt.Sprintf("trace/%s/a/%020d", trace, now.Add(-time.Duration(i)*time.Minute).UnixNano()))
+		}
+	}
+	watch, err := cl.Watch(ctx, &WatchRequest{Profile: "p", Bucket: "history"})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	// Wait until the watcher is registered before pruning.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		p.watchMu.Lock()
+		n := len(p.watchers)
+		p.watchMu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("watch not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p.prune(map[string][]Retention{"p": {
+		{Bucket: "history", MaxAge: 72 * time.Hour},
+		{Bucket: "traces", Prefix: "trace/t1/", MaxCount: 3},
+		{Bucket: "traces", Prefix: "trace/t2/", MaxBytes: 1},
+	}})
+
+	count := func(bucket, prefix string) int {
+		resp, err := cl.Read(ctx, &ReadRequest{Profile: "p", Bucket: bucket, Key: prefix})
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return len(resp.GetValues())
+	}
+	if n := count("history", ""); n != 2 {
+		t.Fatalf("history keeps %d messages, want the 2 younger than 72h", n)
+	}
+	if n := count("traces", "trace/t1/"); n != 3 {
+		t.Fatalf("trace t1 keeps %d messages, want 3", n)
+	}
+	if n := count("traces", "trace/t2/"); n != 0 {
+		t.Fatalf("trace t2 keeps %d messages, want 0", n)
+	}
+	ev, err := watch.Recv()
+	if err != nil || ev.GetOp() != WatchEvent_DELETE || ev.GetOrigin() != retentionOrigin {
+		t.Fatalf("watch event = %v, %v; want a retention delete", ev, err)
+	}
+
+	st, err := cl.Status(ctx, &StatusRequest{})
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	pruned := map[string]uint64{}
+	for _, db := range st.GetDbs() {
+		pruned[db.GetBucket()] = db.GetPruned()
+	}
+	if pruned["history"] != 3 || pruned["traces"] != 5 {
+		t.Fatalf("pruned = %v, want history 3 and traces 5", pruned)
+	}
+}
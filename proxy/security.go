@@ -0,0 +1,246 @@
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/marang/emqutiti/internal/files"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Security configures authentication and encryption of proxy
+// connections. The zero value allows any client that can reach the
+// address.
+type Security struct {
+	// Token is a shared secret that clients send with every call.
+	Token string
+	// CertFile and KeyFile hold the certificate presented to the peer and
+	// CAFile the authority that verifies the peer's. On TCP addresses they
+	// enable TLS; a server with CAFile requires client certificates.
+	CertFile, KeyFile, CAFile string
+	// ServerName overrides the host name clients verify.
+	ServerName string
+}
+
+var (
+	securityMu sync.Mutex
+	security   Security
+)
+
+// SetSecurity sets the Security used by StartProxy and NewClient.
+func SetSecurity(s Security) {
+	securityMu.Lock()
+	security = s
+	securityMu.Unlock()
+}
+
+func currentSecurity() Security {
+	securityMu.Lock()
+	defer securityMu.Unlock()
+	return security
+}
+
+// tlsEnabled reports whether TCP connections use TLS.
+func (s Security) tlsEnabled() bool { return s.CertFile != "" || s.CAFile != "" }
+
+// splitAddr returns the network and address of a proxy address: "unix:PATH"
+// (or "unix://PATH") for a Unix domain socket, otherwise a TCP "host:port".
+func splitAddr(addr string) (network, address string) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return "unix", strings.TrimPrefix(path, "//")
+	}
+	return "tcp", addr
+}
+
+// IsLocal reports whether addr is served on this machine, so that a proxy
+// may be started there.
+func IsLocal(addr string) bool {
+	network, address := splitAddr(addr)
+	if network == "unix" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "" || host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// listen opens the listener of addr. Sockets are readable by their owner
+// only; one left behind by a proxy that no longer runs is replaced.
+func listen(addr string) (net.Listener, error) {
+	network, address := splitAddr(addr)
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+	if err := files.EnsureDir(filepath.Dir(address)); err != nil {
+		return nil, err
+	}
+	if c, err := net.Dial("unix", address); err == nil {
+		c.Close()
+		return nil, fmt.Errorf("listen unix %s: socket in use", address)
+	}
+	if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return listenPrivate(address)
+}
+
+// listenPrivate binds a Unix socket at address that only its owner can
+// connect to. The socket is created inside a fresh 0700 directory and
+// moved into place once restricted, so it is never reachable with the
+// permissions of the process umask.
+func listenPrivate(address string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(address), ".s")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	lis, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	ul := lis.(*net.UnixListener)
+	ul.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		ul.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, address); err != nil {
+		ul.Close()
+		return nil, err
+	}
+	return &unixListener{UnixListener: ul, path: address}, nil
+}
+
+// unixListener removes its socket file when closed.
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *unixListener) Addr() net.Addr { return &net.UnixAddr{Name: l.path, Net: "unix"} }
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// serverOptions returns the gRPC options enforcing s on a listener of
+// network.
+func (s Security) serverOptions(network string) ([]grpc.ServerOption, error) {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := s.authorize(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := s.authorize(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+	if network == "unix" || !s.tlsEnabled() {
+		return opts, nil
+	}
+	cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("proxy certificate: %w", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if s.CAFile != "" {
+		if cfg.ClientCAs, err = loadCAs(s.CAFile); err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return append(opts, grpc.Creds(credentials.NewTLS(cfg))), nil
+}
+
+// authorize checks the token of a call. Ping is open to every client so
+// that they can tell a proxy from a stale address.
+func (s Security) authorize(ctx context.Context, method string) error {
+	if s.Token == "" || method == DBProxy_Ping_FullMethodName {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	want := []byte("Bearer " + s.Token)
+	for _, v := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(v), want) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "proxy: invalid or missing token")
+}
+
+// dialOptions returns the gRPC options presenting s to a proxy on network.
+func (s Security) dialOptions(network string) ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+	if s.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials(s.Token)))
+	}
+	if network == "unix" || !s.tlsEnabled() {
+		return append(opts, grpc.WithTransportCredentials(insecure.NewCredentials())), nil
+	}
+	cfg := &tls.Config{ServerName: s.ServerName, MinVersion: tls.VersionTLS12}
+	if s.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("proxy client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if s.CAFile != "" {
+		pool, err := loadCAs(s.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	return append(opts, grpc.WithTransportCredentials(credentials.NewTLS(cfg))), nil
+}
+
+func loadCAs(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("proxy CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("proxy CA: no certificates in %s", file)
+	}
+	return pool, nil
+}
+
+// tokenCredentials sends the shared secret with every call.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity allows tokens on sockets and plain loopback TCP;
+// configure TLS when the proxy is reached over a network.
+func (tokenCredentials) RequireTransportSecurity() bool { return false }
@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnixSocketIsPrivate(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "proxy.sock")
+	// A socket file left by a crashed proxy is replaced.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	p, err := StartProxyWith("unix:"+path, Security{})
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	if p.Addr() != "unix:"+path {
+		t.Fatalf("addr = %q", p.Addr())
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("socket mode = %o, want 600", perm)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".s") {
+			t.Fatalf("private socket directory %s left behind", e.Name())
+		}
+	}
+	cl, conn, err := NewClientWith(p.Addr(), Security{})
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	if _, err := cl.Write(context.Background(), &WriteRequest{Bucket: "b", Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatalf("write over socket: %v", err)
+	}
+}
+
+func TestTokenRequired(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	p, err := StartProxyWith("127.0.0.1:0", Security{Token: "s3cret"})
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	for _, tc := range []struct {
+		token string
+		want  codes.Code
+	}{{"", codes.Unauthenticated}, {"wrong", codes.Unauthenticated}, {"s3cret", codes.OK}} {
+		cl, conn, err := NewClientWith(p.Addr(), Security{Token: tc.token})
+		if err != nil {
+			t.Fatalf("client: %v", err)
+		}
+		if _, err := cl.Ping(context.Background(), &PingRequest{}); err != nil {
+			t.Fatalf("ping with token %q: %v", tc.token, err)
+		}
+		_, err = cl.Status(context.Background(), &StatusRequest{})
+		if got := status.Code(err); got != tc.want {
+			t.Fatalf("status with token %q: %v, want %v", tc.token, err, tc.want)
+		}
+		stream, err := cl.Watch(context.Background(), &WatchRequest{Bucket: "b"})
+		if err == nil && tc.want != codes.OK {
+			_, err = stream.Recv()
+			if got := status.Code(err); got != tc.want {
+				t.Fatalf("watch with token %q: %v, want %v", tc.token, err, tc.want)
+			}
+		}
+		conn.Close()
+	}
+}
+
+func TestLoopbackNeedsToken(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	defer SetSecurity(Security{})
+	SetSecurity(Security{})
+	if p, err := StartLoopback(); err == nil {
+		p.Stop()
+		t.Fatal("loopback proxy started without a token")
+	}
+	SetSecurity(Security{Token: "s3cret"})
+	p, err := StartLoopback()
+	if err != nil {
+		t.Fatalf("start loopback: %v", err)
+	}
+	defer p.Stop()
+	cl, conn, err := NewClientWith(p.Addr(), Security{})
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	if _, err := cl.Status(context.Background(), &StatusRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("status without token: %v", err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", nil, nil)
+	server := Security{CAFile: filepath.Join(dir, "ca.pem")}
+	server.CertFile, server.KeyFile = certFiles(t, dir, "server", ca, caKey)
+	client := Security{CAFile: server.CAFile, ServerName: "localhost"}
+	client.CertFile, client.KeyFile = certFiles(t, dir, "client", ca, caKey)
+
+	p, err := StartProxyWith("127.0.0.1:0", server)
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+
+	cl, conn, err := NewClientWith(p.Addr(), client)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	if _, err := cl.Status(context.Background(), &StatusRequest{}); err != nil {
+		t.Fatalf("status with client certificate: %v", err)
+	}
+
+	anon := Security{CAFile: server.CAFile, ServerName: "localhost"}
+	for name, sec := range map[string]Security{"no certificate": anon, "plaintext": {}} {
+		cl, conn, err := NewClientWith(p.Addr(), sec)
+		if err != nil {
+			t.Fatalf("client: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if _, err := cl.Ping(ctx, &PingRequest{}); err == nil {
+			t.Errorf("%s: ping succeeded", name)
+		}
+		cancel()
+		conn.Close()
+	}
+}
+
+// certFiles writes a certificate for localhost signed by ca and returns
+// its certificate and key files.
+func certFiles(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (string, string) {
+	writeCert(t, dir, name, ca, caKey)
+	return filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+}
+
+// writeCert writes NAME.pem and NAME-key.pem to dir: a CA when ca is nil,
+// otherwise a localhost certificate signed by it.
+func writeCert(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if ca == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		ca, caKey = tmpl, key
+	} else {
+		tmpl.DNSNames = []string{"localhost"}
+		tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed, key
+}
+
+func TestListenRemovesSocketOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.sock")
+	lis, err := listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if err := lis.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket not removed: %v", err)
+	}
+}
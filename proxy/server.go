@@ -2,17 +2,19 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net"
 	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/marang/emqutiti/internal/files"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/stats"
 )
 
@@ -24,11 +26,27 @@ type Proxy struct {
 
 	mu  sync.Mutex
 	dbs map[string]*badger.DB
+	// pruneStats counts what retention removed, by database.
+	pruneStats map[string]pruneStats
+
+	watchMu  sync.Mutex
+	watchers map[*watcher]struct{}
+	// stopping ends open Watch streams so Stop does not wait for them;
+	// done is closed once Stop has finished.
+	stopping chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	// pruning tracks the retention loop, which Stop waits for before
+	// closing the databases.
+	pruning sync.WaitGroup
 
 	reads   uint64
 	writes  uint64
 	deletes uint64
 	clients int64
+	// active is the time in unix nanoseconds a client last connected or
+	// disconnected.
+	active int64
 }
 
 var (
@@ -41,21 +59,54 @@ const (
 	// on-disk footprint around 10MB while still allowing the database to grow
 	// as new segments are allocated.
 	valueLogFileSizeBytes = 10 * 1024 * 1024
+	// handoffWait bounds how long a database locked by another process is
+	// retried, as happens while a stopping proxy hands it over.
+	handoffWait = 2 * time.Second
 )
 
-// StartProxy starts the gRPC proxy on addr. Only one may run at a time.
+// StartProxy starts the gRPC proxy on addr with the Security set by
+// SetSecurity. Only one may run at a time.
 func StartProxy(addr string) (*Proxy, error) {
+	return StartProxyWith(addr, currentSecurity())
+}
+
+// StartLoopback starts the proxy on a free loopback port. Unlike a socket
+// such a port is open to every local user, so it requires the token set by
+// SetSecurity.
+func StartLoopback() (*Proxy, error) {
+	sec := currentSecurity()
+	if sec.Token == "" {
+		return nil, errors.New("a free loopback port needs a proxy token")
+	}
+	return StartProxyWith("127.0.0.1:0", sec)
+}
+
+// StartProxyWith starts the gRPC proxy on addr, a TCP "host:port" or a
+// "unix:PATH" socket, enforcing sec.
+func StartProxyWith(addr string, sec Security) (*Proxy, error) {
 	proxyMu.Lock()
 	defer proxyMu.Unlock()
 	if proxyRunning {
 		return nil, fmt.Errorf("proxy already running")
 	}
-	lis, err := net.Listen("tcp", addr)
+	network, _ := splitAddr(addr)
+	opts, err := sec.serverOptions(network)
 	if err != nil {
 		return nil, err
 	}
-	p := &Proxy{dbs: make(map[string]*badger.DB)}
-	p.srv = grpc.NewServer(grpc.StatsHandler(&proxyStats{p: p}))
+	lis, err := listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Proxy{
+		dbs:        make(map[string]*badger.DB),
+		pruneStats: make(map[string]pruneStats),
+		watchers:   make(map[*watcher]struct{}),
+		stopping:   make(chan struct{}),
+		done:       make(chan struct{}),
+		active:     time.Now().UnixNano(),
+	}
+	p.srv = grpc.NewServer(append(opts, grpc.StatsHandler(&proxyStats{p: p}))...)
 	p.lis = lis
 	RegisterDBProxyServer(p.srv, p)
 	proxyRunning = true
@@ -63,22 +114,38 @@ func StartProxy(addr string) (*Proxy, error) {
 	return p, nil
 }
 
-// Stop stops the proxy and closes all database handles.
+// Stop stops the proxy and closes all database handles. Only the first
+// call has an effect; Done reports when it finished.
 func (p *Proxy) Stop() {
-	p.srv.GracefulStop()
-	p.mu.Lock()
-	for _, db := range p.dbs {
-		db.Close()
-	}
-	p.dbs = make(map[string]*badger.DB)
-	p.mu.Unlock()
-	proxyMu.Lock()
-	proxyRunning = false
-	proxyMu.Unlock()
+	p.stopOnce.Do(func() {
+		close(p.stopping)
+		p.srv.GracefulStop()
+		p.pruning.Wait()
+		p.mu.Lock()
+		for _, db := range p.dbs {
+			db.Close()
+		}
+		p.dbs = make(map[string]*badger.DB)
+		p.mu.Unlock()
+		proxyMu.Lock()
+		proxyRunning = false
+		proxyMu.Unlock()
+		close(p.done)
+	})
 }
 
-// Addr returns the listening address.
-func (p *Proxy) Addr() string { return p.lis.Addr().String() }
+// Done is closed once the proxy has stopped, whether by Stop, the
+// Shutdown RPC or StopWhenIdle.
+func (p *Proxy) Done() <-chan struct{} { return p.done }
+
+// Addr returns the listening address in the form clients dial.
+func (p *Proxy) Addr() string {
+	a := p.lis.Addr()
+	if a.Network() == "unix" {
+		return "unix:" + a.String()
+	}
+	return a.String()
+}
 
 func (p *Proxy) dbKey(profile, bucket string) string {
 	return profile + "|" + bucket
@@ -102,13 +169,32 @@ func (p *Proxy) getDB(profile, bucket string) (*badger.DB, error) {
 		WithLogger(nil).
 		WithValueLogFileSize(valueLogFileSizeBytes)
 	db, err := badger.Open(opts)
+	// A proxy handing over its databases may still be closing them.
+	for deadline := time.Now().Add(handoffWait); dbLocked(err) && time.Now().Before(deadline); {
+		time.Sleep(50 * time.Millisecond)
+		db, err = badger.Open(opts)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if payloadBuckets[bucket] {
+		// Messages written before payload schema 2 are upgraded once.
+		if n, err := migratePayloads(db, path+".schema"); err != nil {
+			log.Printf("migrate %s/%s: %v", profile, bucket, err)
+		} else if n > 0 {
+			log.Printf("migrated %d messages of %s/%s to payload schema %d", n, profile, bucket, payloadSchema)
+		}
+	}
 	p.dbs[key] = db
 	return db, nil
 }
 
+// dbLocked reports whether err is Badger refusing a database that another
+// process holds.
+func dbLocked(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Another process is using this Badger database")
+}
+
 // Write stores a key/value pair.
 func (p *Proxy) Write(ctx context.Context, req *WriteRequest) (*WriteResponse, error) {
 	db, err := p.getDB(req.GetProfile(), req.GetBucket())
@@ -122,9 +208,58 @@ func (p *Proxy) Write(ctx context.Context, req *WriteRequest) (*WriteResponse, e
 		return nil, err
 	}
 	atomic.AddUint64(&p.writes, 1)
+	p.notify(req.GetProfile(), req.GetBucket(), &WatchEvent{Op: WatchEvent_PUT, Key: req.GetKey(), Value: req.GetValue(), Origin: req.GetOrigin()})
 	return &WriteResponse{}, nil
 }
 
+// WriteBatch stores the records of req in one write batch. With IfAbsent,
+// records whose keys already exist are left untouched and their keys are
+// returned.
+func (p *Proxy) WriteBatch(ctx context.Context, req *WriteBatchRequest) (*WriteBatchResponse, error) {
+	db, err := p.getDB(req.GetProfile(), req.GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	resp := &WriteBatchResponse{}
+	records := req.GetRecords()
+	if req.GetIfAbsent() {
+		var absent []*QueryRecord
+		err := db.View(func(txn *badger.Txn) error {
+			for _, r := range records {
+				_, err := txn.Get([]byte(r.GetKey()))
+				switch {
+				case err == nil:
+					resp.Existing = append(resp.Existing, r.GetKey())
+				case errors.Is(err, badger.ErrKeyNotFound):
+					absent = append(absent, r)
+				default:
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		records = absent
+	}
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+	events := make([]*WatchEvent, 0, len(records))
+	for _, r := range records {
+		if err := wb.Set([]byte(r.GetKey()), r.GetValue()); err != nil {
+			return nil, err
+		}
+		events = append(events, &WatchEvent{Op: WatchEvent_PUT, Key: r.GetKey(), Value: r.GetValue(), Origin: req.GetOrigin()})
+	}
+	if err := wb.Flush(); err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&p.writes, uint64(len(records)))
+	p.notify(req.GetProfile(), req.GetBucket(), events...)
+	return resp, nil
+}
+
 // Read returns all values with the given key prefix.
 func (p *Proxy) Read(ctx context.Context, req *ReadRequest) (*ReadResponse, error) {
 	db, err := p.getDB(req.GetProfile(), req.GetBucket())
@@ -153,27 +288,39 @@ func (p *Proxy) Read(ctx context.Context, req *ReadRequest) (*ReadResponse, erro
 	return &ReadResponse{Values: vals}, nil
 }
 
-// Delete removes all keys with the given prefix.
+// Delete removes all keys with the given prefix. The keys are removed in
+// a write batch so that large prefixes do not exceed a transaction.
 func (p *Proxy) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
 	db, err := p.getDB(req.GetProfile(), req.GetBucket())
 	if err != nil {
 		return nil, err
 	}
-	err = db.Update(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+	var events []*WatchEvent
+	err = db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
 		defer it.Close()
 		prefix := []byte(req.GetKey())
 		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			if err := txn.Delete(it.Item().KeyCopy(nil)); err != nil {
+			key := it.Item().KeyCopy(nil)
+			if err := wb.Delete(key); err != nil {
 				return err
 			}
+			events = append(events, &WatchEvent{Op: WatchEvent_DELETE, Key: string(key), Origin: req.GetOrigin()})
 		}
 		return nil
 	})
+	if err == nil {
+		err = wb.Flush()
+	}
 	if err != nil {
 		return nil, err
 	}
 	atomic.AddUint64(&p.deletes, 1)
+	p.notify(req.GetProfile(), req.GetBucket(), events...)
 	return &DeleteResponse{}, nil
 }
 
@@ -197,11 +344,14 @@ func (p *Proxy) Status(ctx context.Context, _ *StatusRequest) (*StatusResponse,
 		}); err != nil {
 			return nil, err
 		}
+		ps := p.pruneStats[k]
 		infos = append(infos, &DBInfo{
-			Profile: prof,
-			Bucket:  bucket,
-			Size:    uint64(lsm + vlog),
-			Entries: entries,
+			Profile:   prof,
+			Bucket:    bucket,
+			Size:      uint64(lsm + vlog),
+			Entries:   entries,
+			Pruned:    ps.pruned,
+			Reclaimed: ps.reclaimed,
 		})
 	}
 	return &StatusResponse{
@@ -225,11 +375,26 @@ func (ps *proxyStats) HandleConn(ctx context.Context, s stats.ConnStats) {
 	case *stats.ConnEnd:
 		atomic.AddInt64(&ps.p.clients, -1)
 	}
+	atomic.StoreInt64(&ps.p.active, time.Now().UnixNano())
 }
 
-// NewClient returns a client connected to the proxy at addr. Used in tests.
+// NewClient returns a client connected to the proxy at addr with the
+// Security set by SetSecurity. The connection is kept while it is open,
+// even without calls, so that an idle daemon does not stop under its
+// clients.
 func NewClient(addr string) (DBProxyClient, *grpc.ClientConn, error) {
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	return NewClientWith(addr, currentSecurity())
+}
+
+// NewClientWith returns a client connected to the proxy at addr that
+// presents sec.
+func NewClientWith(addr string, sec Security) (DBProxyClient, *grpc.ClientConn, error) {
+	network, _ := splitAddr(addr)
+	opts, err := sec.dialOptions(network)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := grpc.Dial(addr, append(opts, grpc.WithIdleTimeout(0))...)
 	if err != nil {
 		return nil, nil, err
 	}
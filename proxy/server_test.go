@@ -2,7 +2,11 @@ package proxy
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 )
 
 func TestWriteRead(t *testing.T) {
@@ -70,3 +74,244 @@ func TestProxySingleInstance(t *testing.T) {
 		t.Fatalf("expected error starting second proxy")
 	}
 }
+
+func TestQueryFiltersAndPages(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	p, err := StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	client, conn, err := NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	base := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		ts := base.Add(time.Duration(i) * time.Second)
+		val := fmt.Sprintf(`{"Timestamp":%q,"Topic":"sensor/temp","Payload":%q,"Kind":"sub","Schema":2}`,
+			ts.Format(time.RFC3339Nano), base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("v%d", i))))
+		key := fmt.Sprintf("sensor/temp/%020d", ts.UnixNano())
+		if _, err := client.Write(ctx, &WriteRequest{Profile: "p1", Bucket: "history", Key: key, Value: []byte(val)}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	legacy := fmt.Sprintf(`{"Timestamp":%q,"Topic":"door","Payload":"open","Kind":"pub","Archived":true}`, base.Format(time.RFC3339Nano))
+	if _, err := client.Write(ctx, &WriteRequest{Profile: "p1", Bucket: "history", Key: "door/1", Value: []byte(legacy)}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	query := func(req *QueryRequest) ([]string, string, uint64) {
+		t.Helper()
+		req.Profile, req.Bucket = "p1", "history"
+		stream, err := client.Query(ctx, req)
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		var keys []string
+		var next string
+		var count uint64
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				return keys, next, count
+			}
+			if err != nil {
+				t.Fatalf("recv: %v", err)
+			}
+			for _, r := range resp.GetRecords() {
+				keys = append(keys, r.GetKey())
+			}
+			next, count = resp.GetNextCursor(), count+resp.GetCount()
+		}
+	}
+
+	keys, next, _ := query(&QueryRequest{Topics: []string{"tmp"}, Limit: 2})
+	if len(keys) != 2 || next != keys[1] {
+		t.Fatalf("first page: %v next %q", keys, next)
+	}
+	page2, next, _ := query(&QueryRequest{Topics: []string{"tmp"}, Limit: 2, Cursor: next})
+	if len(page2) != 2 || page2[0] <= keys[1] {
+		t.Fatalf("second page: %v after %v", page2, keys)
+	}
+	last, next, _ := query(&QueryRequest{Topics: []string{"tmp"}, Limit: 2, Cursor: next})
+	if len(last) != 1 || next != "" {
+		t.Fatalf("last page: %v next %q", last, next)
+	}
+
+	start := base.Add(time.Second).UnixNano()
+	end := base.Add(3 * time.Second).UnixNano()
+	if keys, _, _ := query(&QueryRequest{Start: start, End: end, Payload: "v2"}); len(keys) != 1 {
+		t.Fatalf("time and payload filter: %v", keys)
+	}
+	if keys, _, _ := query(&QueryRequest{Archived: true, Kinds: []string{"pub"}, Payload: "pe"}); len(keys) != 1 || keys[0] != "door/1" {
+		t.Fatalf("legacy payload of archived message: %v", keys)
+	}
+	if keys, _, _ := query(&QueryRequest{Kinds: []string{"pub"}}); len(keys) != 0 {
+		t.Fatalf("kind filter: %v", keys)
+	}
+	if keys, _, count := query(&QueryRequest{CountOnly: true}); len(keys) != 0 || count != 5 {
+		t.Fatalf("count: %d %v", count, keys)
+	}
+}
+
+func TestWatchStreamsChangesUnderPrefix(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	p, err := StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	client, conn, err := NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &WatchRequest{Profile: "p1", Bucket: "traces", Prefix: "trace/k1/"})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	// The watch is registered asynchronously; write a probe until it is
+	// seen.
+	deadline := time.Now().Add(5 * time.Second)
+	var ev *WatchEvent
+	recv := make(chan *WatchEvent)
+	go func() {
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				close(recv)
+				return
+			}
+			recv <- ev
+		}
+	}()
+	for ev == nil && time.Now().Before(deadline) {
+		if _, err := client.Write(ctx, &WriteRequest{Profile: "p1", Bucket: "traces", Key: "trace/k1/probe", Value: []byte("x")}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		select {
+		case ev = <-recv:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	if ev == nil || ev.GetKey() != "trace/k1/probe" {
+		t.Fatalf("no probe event: %v", ev)
+	}
+
+	writes := []*WriteRequest{
+		{Profile: "p2", Bucket: "traces", Key: "trace/k1/a", Value: []byte("other profile")},
+		{Profile: "p1", Bucket: "history", Key: "trace/k1/a", Value: []byte("other bucket")},
+		{Profile: "p1", Bucket: "traces", Key: "trace/k2/a", Value: []byte("other prefix")},
+		{Profile: "p1", Bucket: "traces", Key: "trace/k1/a", Value: []byte("v"), Origin: "ui-1"},
+	}
+	for _, w := range writes {
+		if _, err := client.Write(ctx, w); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if _, err := client.Delete(ctx, &DeleteRequest{Profile: "p1", Bucket: "traces", Key: "trace/k1/", Origin: "ui-2"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case ev, ok := <-recv:
+			if !ok {
+				t.Fatalf("stream ended after %v", got)
+			}
+			if ev.GetKey() == "trace/k1/probe" && ev.GetOp() == WatchEvent_PUT {
+				continue
+			}
+			got = append(got, fmt.Sprintf("%s %s %s %s", ev.GetOp(), ev.GetKey(), ev.GetValue(), ev.GetOrigin()))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %v", got)
+		}
+	}
+	want := []string{"PUT trace/k1/a v ui-1", "DELETE trace/k1/a  ui-2", "DELETE trace/k1/probe  ui-2"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+}
+
+func TestDeleteLargePrefix(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	db, err := p.getDB("p1", "spill")
+	if err != nil {
+		t.Fatalf("db: %v", err)
+	}
+	// More keys than one Badger transaction can delete.
+	wb := db.NewWriteBatch()
+	for i := 0; i < 200000; i++ {
+		if err := wb.Set([]byte(fmt.Sprintf("s/%08d", i)), []byte("x")); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	client, conn, err := NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	if _, err := client.Delete(context.Background(), &DeleteRequest{Profile: "p1", Bucket: "spill", Key: "s/"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	resp, err := client.Read(context.Background(), &ReadRequest{Profile: "p1", Bucket: "spill", Key: "s/"})
+	if err != nil || len(resp.GetValues()) != 0 {
+		t.Fatalf("keys left after delete: %d %v", len(resp.GetValues()), err)
+	}
+}
+
+func TestWriteBatchKeepsExistingKeys(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer p.Stop()
+	client, conn, err := NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	if _, err := client.Write(ctx, &WriteRequest{Profile: "p1", Bucket: "b1", Key: "a", Value: []byte("old")}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	records := []*QueryRecord{{Key: "a", Value: []byte("new")}, {Key: "b", Value: []byte("b")}}
+	resp, err := client.WriteBatch(ctx, &WriteBatchRequest{Profile: "p1", Bucket: "b1", Records: records, IfAbsent: true})
+	if err != nil {
+		t.Fatalf("write batch: %v", err)
+	}
+	if fmt.Sprint(resp.GetExisting()) != "[a]" {
+		t.Fatalf("existing = %v, want [a]", resp.GetExisting())
+	}
+	read := func(key string) string {
+		r, err := client.Read(ctx, &ReadRequest{Profile: "p1", Bucket: "b1", Key: key})
+		if err != nil || len(r.GetValues()) != 1 {
+			t.Fatalf("read %s: %v %v", key, r.GetValues(), err)
+		}
+		return string(r.GetValues()[0])
+	}
+	if read("a") != "old" || read("b") != "b" {
+		t.Fatalf("if_absent replaced a stored value")
+	}
+	if _, err := client.WriteBatch(ctx, &WriteBatchRequest{Profile: "p1", Bucket: "b1", Records: records}); err != nil {
+		t.Fatalf("write batch: %v", err)
+	}
+	if read("a") != "new" {
+		t.Fatalf("batch without if_absent must overwrite")
+	}
+}
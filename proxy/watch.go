@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// watchBuffer is the number of events a watcher may fall behind before
+// its stream is ended.
+const watchBuffer = 1024
+
+// watcher is an open Watch stream.
+type watcher struct {
+	db     string
+	prefix string
+	events chan *WatchEvent
+}
+
+// Watch streams writes and deletes of keys under the prefix until the
+// client goes away. Watchers that fall too far behind are ended with
+// ResourceExhausted and should read the data again before watching anew.
+func (p *Proxy) Watch(req *WatchRequest, stream grpc.ServerStreamingServer[WatchEvent]) error {
+	w := &watcher{
+		db:     p.dbKey(defaultProfile(req.GetProfile()), req.GetBucket()),
+		prefix: req.GetPrefix(),
+		events: make(chan *WatchEvent, watchBuffer),
+	}
+	p.watchMu.Lock()
+	p.watchers[w] = struct{}{}
+	p.watchMu.Unlock()
+	defer p.unwatch(w)
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-p.stopping:
+			return status.Error(codes.Unavailable, "watch: proxy stopping")
+		case ev, ok := <-w.events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watch: client fell behind")
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// unwatch removes w unless notify already dropped it.
+func (p *Proxy) unwatch(w *watcher) {
+	p.watchMu.Lock()
+	defer p.watchMu.Unlock()
+	if _, ok := p.watchers[w]; ok {
+		delete(p.watchers, w)
+		close(w.events)
+	}
+}
+
+// notify passes events of a database to its watchers.
+func (p *Proxy) notify(profile, bucket string, events ...*WatchEvent) {
+	db := p.dbKey(defaultProfile(profile), bucket)
+	p.watchMu.Lock()
+	defer p.watchMu.Unlock()
+	for w := range p.watchers {
+		if w.db != db {
+			continue
+		}
+		for _, ev := range events {
+			if !strings.HasPrefix(ev.GetKey(), w.prefix) {
+				continue
+			}
+			select {
+			case w.events <- ev:
+			default:
+				delete(p.watchers, w)
+				close(w.events)
+			}
+			if _, ok := p.watchers[w]; !ok {
+				break
+			}
+		}
+	}
+}
+
+func defaultProfile(profile string) string {
+	if profile == "" {
+		return "default"
+	}
+	return profile
+}
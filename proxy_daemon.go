@@ -0,0 +1,138 @@
+package emqutiti
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	connections "github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/proxy"
+)
+
+// handoffWait bounds how long the daemon waits for a proxy it took over to
+// release the address.
+const handoffWait = 5 * time.Second
+
+// runProxyDaemon serves the DB proxy until it is interrupted, shut down
+// through the Shutdown RPC or idle for the configured time, pruning to the
+// retention limits of the profiles. A proxy that an
+// instance runs embedded at the same address is asked to shut down and its
+// databases are taken over; its clients reconnect transparently.
+func runProxyDaemon(d *appDeps) error {
+	o := d.proxyDaemon
+	lock := proxy.LockPath()
+	if o.Stop {
+		return stopProxyDaemon(lock)
+	}
+	if info, err := proxy.ReadLock(lock); err == nil && info.Live() {
+		return fmt.Errorf("%w: pid %d at %s", proxy.ErrRunning, info.PID, info.Addr)
+	}
+	addr := o.Addr
+	if addr == "" {
+		addr = connections.LoadProxyAddr()
+	}
+	if addr == "" {
+		addr = proxy.DefaultAddr()
+	}
+	wait := time.Duration(0)
+	if pid, err := proxy.Probe(addr); err == nil {
+		log.Printf("taking over the proxy of pid %d at %s", pid, addr)
+		if err := proxy.RequestShutdown(addr); err != nil {
+			return fmt.Errorf("hand over proxy: %w", err)
+		}
+		wait = handoffWait
+	} else if addr == proxy.DefaultTCPAddr && o.Addr == "" {
+		addr = proxy.DefaultAddr()
+	}
+	if s, _ := connections.LoadProxySettings(); exposed(addr) && (s.Token == "" || s.TLSCert == "") {
+		log.Printf("warning: %s is reachable from the network; set a token and TLS in the [proxy] table of config.toml", addr)
+	}
+	p, err := startDaemonProxy(addr, wait)
+	if err != nil {
+		return err
+	}
+	unlock, err := proxy.Lock(lock, proxy.LockInfo{PID: os.Getpid(), Addr: p.Addr()})
+	if err != nil {
+		p.Stop()
+		return err
+	}
+	defer unlock()
+	if p.Addr() == addr {
+		if err := connections.SaveProxyAddr(addr); err != nil {
+			log.Printf("save proxy addr: %v", err)
+		}
+	}
+	log.Printf("DB proxy listening on %s (pid %d)", p.Addr(), os.Getpid())
+	if o.IdleTimeout > 0 {
+		p.StopWhenIdle(o.IdleTimeout)
+	}
+	every := o.PruneInterval
+	if every <= 0 {
+		every = retentionInterval
+	}
+	p.EnforceRetention(retentionLimits, every)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case <-ctx.Done():
+		p.Stop()
+	case <-p.Done():
+	}
+	<-p.Done()
+	log.Printf("DB proxy stopped")
+	return nil
+}
+
+// startDaemonProxy listens on addr, retrying for up to wait while a
+// previous proxy releases it. It falls back to a free loopback port only
+// when a token protects it.
+func startDaemonProxy(addr string, wait time.Duration) (*proxy.Proxy, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		p, err := proxy.StartProxy(addr)
+		if err == nil {
+			return p, nil
+		}
+		if !time.Now().Before(deadline) {
+			p, lerr := proxy.StartLoopback()
+			if lerr != nil {
+				return nil, fmt.Errorf("listen on %s: %w (%v)", addr, err, lerr)
+			}
+			log.Printf("listen on %s: %v; using %s", addr, err, p.Addr())
+			return p, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// stopProxyDaemon asks the daemon recorded in the lock file to shut down.
+func stopProxyDaemon(lock string) error {
+	info, err := proxy.ReadLock(lock)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.Live()) {
+		return errors.New("no proxy daemon running")
+	}
+	if err != nil {
+		return err
+	}
+	return proxy.RequestShutdown(info.Addr)
+}
+
+// exposed reports whether addr accepts connections from other machines.
+func exposed(addr string) bool {
+	if strings.HasPrefix(addr, "unix:") {
+		return false
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
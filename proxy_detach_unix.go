@@ -0,0 +1,14 @@
+//go:build !windows
+
+package emqutiti
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach runs cmd in its own session so it outlives the terminal and the
+// signals of its parent.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
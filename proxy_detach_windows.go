@@ -0,0 +1,17 @@
+//go:build windows
+
+package emqutiti
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess starts a process without a console.
+const detachedProcess = 0x00000008
+
+// detach runs cmd without a console in its own process group so it
+// outlives its parent.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
@@ -1,38 +1,163 @@
 package emqutiti
 
 import (
+	"fmt"
 	"log"
 	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"time"
 
 	connections "github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/internal/files"
 	"github.com/marang/emqutiti/proxy"
 )
 
-// realInitProxy ensures a DB proxy is running and returns its address.
-// If no proxy is reachable, it starts one on the configured or default address.
+const (
+	// spawnIdleTimeout stops a daemon spawned on demand once no instance
+	// has used it for this long.
+	spawnIdleTimeout = 10 * time.Minute
+	// spawnWait bounds how long a client waits for a spawned daemon.
+	spawnWait = 5 * time.Second
+)
+
+// realInitProxy ensures a DB proxy is running and returns its address. It
+// uses the proxy at the configured address, else the daemon recorded in the
+// lock file, and spawns a daemon when neither answers. A configured local
+// address that nothing or something other than a proxy serves is stale and
+// gets replaced; the former TCP default and TCP addresses held by another
+// program move to the socket. Remote
+// addresses are left alone. Only if no daemon starts is a proxy run inside
+// this process; a free loopback port is used instead of addr only with a
+// token and is not recorded.
 func realInitProxy() (string, *proxy.Proxy) {
-	addr := connections.LoadProxyAddr()
+	configured := connections.LoadProxyAddr()
+	addr := configured
 	if addr == "" {
-		addr = proxy.DefaultAddr
+		addr = proxy.DefaultAddr()
+	}
+	if _, err := proxy.Probe(addr); err == nil {
+		return addr, nil
 	}
-	if conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond); err == nil {
-		conn.Close()
+	if !proxy.IsLocal(addr) {
+		log.Printf("proxy at %s unreachable", addr)
 		return addr, nil
 	}
+	if addr == proxy.DefaultTCPAddr || occupied(addr) {
+		addr = proxy.DefaultAddr()
+	}
+	live := ""
+	if info, err := proxy.ReadLock(proxy.LockPath()); err == nil && info.Live() {
+		live = info.Addr
+	} else if a, err := spawnProxy(addr); err == nil {
+		live = a
+	} else {
+		log.Printf("proxy daemon: %v", err)
+	}
+	if live != "" {
+		// A daemon on a free port is a fallback; later runs try addr again.
+		if live != configured && (live == addr || live == proxy.DefaultAddr()) {
+			if err := connections.SaveProxyAddr(live); err != nil {
+				log.Printf("save proxy addr: %v", err)
+			}
+		}
+		return live, nil
+	}
 	p, err := proxy.StartProxy(addr)
 	if err != nil {
-		p, err = proxy.StartProxy("127.0.0.1:0")
-		if err != nil {
+		log.Printf("proxy start on %s: %v", addr, err)
+		if p, err = proxy.StartLoopback(); err != nil {
 			log.Printf("proxy start failed: %v", err)
 			return "", nil
 		}
+		p.EnforceRetention(retentionLimits, retentionInterval)
+		return p.Addr(), p
 	}
-	addr = p.Addr()
-	if err := connections.SaveProxyAddr(addr); err != nil {
-		log.Printf("save proxy addr: %v", err)
+	p.EnforceRetention(retentionLimits, retentionInterval)
+	if addr != configured {
+		if err := connections.SaveProxyAddr(addr); err != nil {
+			log.Printf("save proxy addr: %v", err)
+		}
 	}
 	return addr, p
 }
 
+// occupied reports whether a program answers at the TCP address addr.
+func occupied(addr string) bool {
+	if strings.HasPrefix(addr, "unix:") {
+		return false
+	}
+	c, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+// loadProxySecurity applies the proxy settings of config.toml to the DB
+// proxy and its clients.
+func loadProxySecurity() {
+	s, err := connections.LoadProxySettings()
+	if err != nil {
+		log.Printf("proxy token: %v", err)
+	}
+	proxy.SetSecurity(proxy.Security{
+		Token:      s.Token,
+		CertFile:   s.TLSCert,
+		KeyFile:    s.TLSKey,
+		CAFile:     s.TLSCA,
+		ServerName: s.TLSServerName,
+	})
+}
+
 var initProxy = realInitProxy
+
+// realSpawnProxy starts "emqutiti proxy" in the background, preferably on
+// addr, and returns the address it serves once it answers. Its output goes
+// to proxy.log next to config.toml.
+func realSpawnProxy(addr string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if err := files.EnsureDir(files.BaseDir()); err != nil {
+		return "", err
+	}
+	out, err := os.OpenFile(filepath.Join(files.BaseDir(), "proxy.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(exe, "proxy", "--addr", addr, "--idle-timeout", spawnIdleTimeout.String())
+	cmd.Stdout, cmd.Stderr = out, out
+	detach(cmd)
+	err = cmd.Start()
+	out.Close()
+	if err != nil {
+		return "", err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	deadline := time.After(spawnWait)
+	for {
+		// Another client may have spawned a daemon at the same time; any
+		// live one will do.
+		if info, err := proxy.ReadLock(proxy.LockPath()); err == nil && info.Live() {
+			return info.Addr, nil
+		}
+		select {
+		case err := <-exited:
+			if info, rerr := proxy.ReadLock(proxy.LockPath()); rerr == nil && info.Live() {
+				return info.Addr, nil
+			}
+			return "", fmt.Errorf("daemon exited: %v", err)
+		case <-deadline:
+			return "", fmt.Errorf("daemon did not start within %s", spawnWait)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+var spawnProxy = realSpawnProxy
@@ -1,12 +1,16 @@
 package emqutiti
 
 import (
+	"errors"
 	"net"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	cfg "github.com/marang/emqutiti/cmd"
 	connections "github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/proxy"
 )
 
 func TestInitProxyWritesConfig(t *testing.T) {
@@ -18,27 +22,189 @@ func TestInitProxyWritesConfig(t *testing.T) {
 	cfgDir := filepath.Join(dir, ".config", "emqutiti")
 	os.Setenv("EMQUTITI_HOME", cfgDir)
 	defer os.Setenv("EMQUTITI_HOME", oldCfg)
+	origSpawn := spawnProxy
+	spawnProxy = func(string) (string, error) { return "", errors.New("no daemon") }
+	defer func() { spawnProxy = origSpawn }()
 
 	addr, p := initProxy()
 	if addr == "" {
 		t.Fatalf("no addr returned")
 	}
 	if p == nil {
-		conn, err := net.Dial("tcp", addr)
-		if err != nil {
+		if _, err := proxy.Probe(addr); err != nil {
 			t.Fatalf("expected reachable proxy at %s: %v", addr, err)
 		}
-		conn.Close()
 		t.Skip("proxy already running; config persistence not verified")
 	}
 	defer p.Stop()
 	if got := connections.LoadProxyAddr(); got != addr {
 		t.Fatalf("config addr %q != %q", got, addr)
 	}
-	// ensure port is listening
-	conn, err := net.Dial("tcp", addr)
+	if addr != proxy.DefaultAddr() {
+		t.Fatalf("addr = %q, want the default socket %q", addr, proxy.DefaultAddr())
+	}
+	if _, err := proxy.Probe(addr); err != nil {
+		t.Fatalf("probe proxy: %v", err)
+	}
+}
+
+func TestInitProxyReplacesStaleAddr(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	// Something other than a proxy holds the configured address.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Fatalf("dial proxy: %v", err)
+		t.Fatalf("listen: %v", err)
+	}
+	defer lis.Close()
+	if err := connections.SaveProxyAddr(lis.Addr().String()); err != nil {
+		t.Fatalf("save addr: %v", err)
+	}
+	origSpawn := spawnProxy
+	spawnProxy = func(addr string) (string, error) {
+		if addr != proxy.DefaultAddr() {
+			t.Fatalf("spawned on %q, want the default socket", addr)
+		}
+		return addr, nil
+	}
+	defer func() { spawnProxy = origSpawn }()
+
+	addr, p := initProxy()
+	if p != nil {
+		p.Stop()
+		t.Fatal("proxy started in process despite daemon")
+	}
+	if addr != proxy.DefaultAddr() {
+		t.Fatalf("addr = %q, want %q", addr, proxy.DefaultAddr())
+	}
+	if got := connections.LoadProxyAddr(); got != addr {
+		t.Fatalf("config addr %q != %q", got, addr)
+	}
+}
+
+func TestInitProxyKeepsFallbackUnrecorded(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	var daemon *proxy.Proxy
+	origSpawn := spawnProxy
+	spawnProxy = func(string) (string, error) {
+		var err error
+		daemon, err = proxy.StartProxy("127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		return daemon.Addr(), nil
+	}
+	defer func() { spawnProxy = origSpawn }()
+
+	addr, p := initProxy()
+	if daemon == nil {
+		t.Fatal("no daemon spawned")
+	}
+	defer daemon.Stop()
+	if p != nil {
+		p.Stop()
+	}
+	if addr != daemon.Addr() {
+		t.Fatalf("addr = %q, want %q", addr, daemon.Addr())
+	}
+	if got := connections.LoadProxyAddr(); got != "" {
+		t.Fatalf("fallback address %q recorded", got)
+	}
+}
+
+func TestInitProxyMovesLegacyAddrToSocket(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	if _, err := proxy.Probe(proxy.DefaultTCPAddr); err == nil {
+		t.Skip("a proxy answers at the former default address")
+	}
+	if err := connections.SaveProxyAddr(proxy.DefaultTCPAddr); err != nil {
+		t.Fatalf("save addr: %v", err)
+	}
+	origSpawn := spawnProxy
+	spawnProxy = func(addr string) (string, error) { return addr, nil }
+	defer func() { spawnProxy = origSpawn }()
+
+	addr, p := initProxy()
+	if p != nil {
+		p.Stop()
+	}
+	if addr != proxy.DefaultAddr() {
+		t.Fatalf("addr = %q, want %q", addr, proxy.DefaultAddr())
+	}
+	if got := connections.LoadProxyAddr(); got != addr {
+		t.Fatalf("config addr %q != %q", got, addr)
+	}
+}
+
+func TestInitProxyKeepsRemoteAddr(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	remote := "192.0.2.1:54321"
+	if err := connections.SaveProxyAddr(remote); err != nil {
+		t.Fatalf("save addr: %v", err)
+	}
+	origSpawn := spawnProxy
+	spawnProxy = func(string) (string, error) { t.Fatal("daemon spawned for a remote proxy"); return "", nil }
+	defer func() { spawnProxy = origSpawn }()
+
+	addr, p := initProxy()
+	if p != nil {
+		p.Stop()
+		t.Fatal("proxy started in process for a remote address")
+	}
+	if addr != remote || connections.LoadProxyAddr() != remote {
+		t.Fatalf("addr = %q, config %q; want %q", addr, connections.LoadProxyAddr(), remote)
+	}
+}
+
+func TestRunProxyDaemonTakesOverEmbeddedProxy(t *testing.T) {
+	t.Setenv("EMQUTITI_HOME", t.TempDir())
+	embedded, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	defer embedded.Stop()
+	addr := embedded.Addr()
+	if err := connections.SaveProxyAddr(addr); err != nil {
+		t.Fatalf("save addr: %v", err)
+	}
+
+	d := newAppDeps()
+	d.proxyDaemon = cfg.ProxyConfig{}
+	done := make(chan error, 1)
+	go func() { done <- runProxyDaemon(d) }()
+	select {
+	case <-embedded.Done():
+	case err := <-done:
+		t.Fatalf("daemon ended: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("embedded proxy was not shut down")
+	}
+	var info proxy.LockInfo
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if info, err = proxy.ReadLock(proxy.LockPath()); err == nil && info.Live() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no live daemon lock: %+v, %v", info, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if info.Addr != addr || info.PID != os.Getpid() {
+		t.Fatalf("lock = %+v, want pid %d at %s", info, os.Getpid(), addr)
+	}
+
+	d.proxyDaemon.Stop = true
+	if err := runProxyDaemon(d); err != nil {
+		t.Fatalf("stop daemon: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("daemon: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not stop")
+	}
+	if _, err := os.Stat(proxy.LockPath()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("lock file left: %v", err)
 	}
-	conn.Close()
 }
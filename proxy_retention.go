@@ -0,0 +1,42 @@
+package emqutiti
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	connections "github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/proxy"
+	"github.com/marang/emqutiti/traces"
+)
+
+// retentionInterval is how often a proxy prunes histories and traces to
+// their retention limits unless the proxy command sets another interval.
+const retentionInterval = 10 * time.Minute
+
+// retentionLimits returns the limits of config.toml for the DB proxy: the
+// history of each profile and each of its traces are limited on their own.
+func retentionLimits() map[string][]proxy.Retention {
+	profiles, err := connections.LoadRetention()
+	if err != nil {
+		log.Printf("retention: %v", err)
+	}
+	out := map[string][]proxy.Retention{}
+	for name, r := range profiles {
+		if !r.History.IsZero() {
+			out[name] = append(out[name], proxyRetention("history", "", r.History))
+		}
+	}
+	for key, tc := range (traces.FileStore{}).LoadTraces() {
+		r, ok := profiles[tc.Profile]
+		if !ok || r.Trace.IsZero() {
+			continue
+		}
+		out[tc.Profile] = append(out[tc.Profile], proxyRetention("traces", fmt.Sprintf("trace/%s/", key), r.Trace))
+	}
+	return out
+}
+
+func proxyRetention(bucket, prefix string, r connections.Retention) proxy.Retention {
+	return proxy.Retention{Bucket: bucket, Prefix: prefix, MaxAge: r.MaxAge, MaxCount: r.MaxMessages, MaxBytes: r.MaxBytes}
+}
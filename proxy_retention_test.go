@@ -0,0 +1,43 @@
+package emqutiti
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marang/emqutiti/proxy"
+)
+
+func TestRetentionLimitsFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("EMQUTITI_HOME", dir)
+	cfg := `[[profiles]]
+name = "a"
+history_max_messages = 100
+trace_max_age = "1d"
+
+[[profiles]]
+name = "b"
+
+[traces.run1]
+profile = "a"
+topics = ["x"]
+
+[traces.run2]
+profile = "b"
+topics = ["y"]
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := retentionLimits()
+	want := map[string][]proxy.Retention{"a": {
+		{Bucket: "history", MaxCount: 100},
+		{Bucket: "traces", Prefix: "trace/run1/", MaxAge: 24 * time.Hour},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("limits = %+v, want %+v", got, want)
+	}
+}
@@ -10,8 +10,6 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/marang/emqutiti/proxy"
 	"github.com/marang/emqutiti/ui"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 // startProxyStatusLogger logs proxy status immediately and at each interval.
@@ -39,14 +37,13 @@ func logProxyStatus(addr string) {
 		log.Println(lipgloss.NewStyle().Foreground(ui.ColWarn).Render(msg))
 		return
 	}
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	client, conn, err := proxy.NewClient(addr)
 	if err != nil {
 		msg := fmt.Sprintf("%s proxy unreachable: %v", time.Now().Format(time.RFC3339), err)
 		log.Println(lipgloss.NewStyle().Foreground(ui.ColRed).Render(msg))
 		return
 	}
 	defer conn.Close()
-	client := proxy.NewDBProxyClient(conn)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	st, err := client.Status(ctx, &proxy.StatusRequest{})
@@ -57,7 +54,11 @@ func logProxyStatus(addr string) {
 	}
 	var infos []string
 	for _, db := range st.GetDbs() {
-		infos = append(infos, fmt.Sprintf("%s/%s=%dB/%d", db.GetProfile(), db.GetBucket(), db.GetSize(), db.GetEntries()))
+		info := fmt.Sprintf("%s/%s=%dB/%d", db.GetProfile(), db.GetBucket(), db.GetSize(), db.GetEntries())
+		if db.GetPruned() > 0 {
+			info += fmt.Sprintf(" pruned:%d reclaimed:%dB", db.GetPruned(), db.GetReclaimed())
+		}
+		infos = append(infos, info)
 	}
 	msg := fmt.Sprintf("%s clients:%d published:%d subscribed:%d deletes:%d %s", time.Now().Format(time.RFC3339), st.GetClients(), st.GetWrites(), st.GetReads(), st.GetDeletes(), strings.Join(infos, " "))
 	log.Println(lipgloss.NewStyle().Foreground(ui.ColCyan).Render(msg))
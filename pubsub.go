@@ -0,0 +1,266 @@
+package emqutiti
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	cfg "github.com/marang/emqutiti/cmd"
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/mqttclient"
+)
+
+// Output formats of the sub command.
+const (
+	subFormatPlain  = "plain"
+	subFormatTopic  = "topic"
+	subFormatNDJSON = "ndjson"
+)
+
+// errConnectionLost ends a sub command whose broker went away.
+var errConnectionLost = errors.New("connection lost")
+
+// loadCLIProfile loads the profile of a pub or sub command and applies the
+// same environment and password fallbacks as the UI.
+func loadCLIProfile(d *appDeps) (connections.Profile, error) {
+	p, err := d.loadProfile(d.profileName, d.configFile)
+	if err != nil {
+		return connections.Profile{}, fmt.Errorf("error loading profile: %w", err)
+	}
+	if p.FromEnv {
+		connections.ApplyEnvVars(p)
+	}
+	connections.ApplyDefaultPassword(p)
+	return *p, nil
+}
+
+// cliContext stops a command on SIGINT, SIGTERM or the timeout.
+func cliContext(d *appDeps) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if d.timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	return ctx, func() { cancel(); stop() }
+}
+
+// maxPubPayload caps a payload read from stdin at the MQTT limit.
+const maxPubPayload = 256 << 20
+
+// checkPubSource reports an error unless the pub options select exactly one
+// payload source.
+func checkPubSource(o cfg.PubSubConfig) error {
+	sources := 0
+	for _, set := range []bool{o.Message != "", o.File != "", o.Stdin, o.Lines, o.Null} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("pub: use exactly one of -m, -f, -s, -l or -n")
+	}
+	return nil
+}
+
+// pubPayloads passes the payloads selected by the pub options to publish as
+// they are read, so that -l publishes each line of a stream as it arrives.
+// Reading stdin stops when ctx ends.
+func pubPayloads(ctx context.Context, o cfg.PubSubConfig, stdin io.Reader, publish func([]byte) error) error {
+	switch {
+	case o.Message != "":
+		return publish([]byte(o.Message))
+	case o.Null:
+		return publish(nil)
+	case o.File != "" && o.File != "-":
+		data, err := os.ReadFile(o.File)
+		if err != nil {
+			return fmt.Errorf("pub: %w", err)
+		}
+		return publish(data)
+	}
+	lines := make(chan []byte)
+	read := make(chan error, 1)
+	go func() {
+		if !o.Lines {
+			data, err := io.ReadAll(io.LimitReader(stdin, maxPubPayload+1))
+			if err == nil && len(data) > maxPubPayload {
+				err = fmt.Errorf("payload exceeds %d bytes", maxPubPayload)
+			}
+			if err == nil {
+				select {
+				case lines <- data:
+				case <-ctx.Done():
+					return
+				}
+			}
+			read <- err
+			return
+		}
+		sc := bufio.NewScanner(stdin)
+		sc.Buffer(make([]byte, 64*1024), maxPubPayload)
+		for sc.Scan() {
+			select {
+			case lines <- append([]byte(nil), sc.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		read <- sc.Err()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-read:
+			if err != nil {
+				return fmt.Errorf("pub: read stdin: %w", err)
+			}
+			return nil
+		case payload := <-lines:
+			if err := publish(payload); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// runPub publishes the payloads given on the command line, in a file or
+// on stdin to a single topic. It connects before reading stdin and stops
+// on Ctrl+C, SIGTERM or after the timeout.
+func runPub(d *appDeps) error {
+	o := d.pubsub
+	if len(o.Topics) != 1 || o.Topics[0] == "" {
+		return errors.New("pub: exactly one -t topic is required")
+	}
+	if o.QoS < 0 || o.QoS > 2 {
+		return fmt.Errorf("pub: invalid QoS %d", o.QoS)
+	}
+	if err := checkPubSource(o); err != nil {
+		return err
+	}
+	p, err := loadCLIProfile(d)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := cliContext(d)
+	defer cancel()
+	client, err := d.newMQTTClient(p, nil)
+	if err != nil {
+		return fmt.Errorf("connect error: %w", err)
+	}
+	defer client.Disconnect()
+	err = pubPayloads(ctx, o, d.stdin, func(payload []byte) error {
+		if err := client.Publish(o.Topics[0], byte(o.QoS), o.Retain, payload); err != nil {
+			return fmt.Errorf("pub: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Interrupted, like mosquitto_pub -l on Ctrl+C.
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("pub: timeout after %s", d.timeout)
+	}
+	return err
+}
+
+// subLine formats a received message for the sub output. The plain
+// formats print the payload bytes unchanged, like mosquitto_sub.
+func subLine(format string, r history.Record) ([]byte, error) {
+	if format == subFormatNDJSON {
+		return json.Marshal(r)
+	}
+	payload, err := r.PayloadBytes()
+	if err != nil {
+		return nil, err
+	}
+	if format == subFormatTopic {
+		return append([]byte(r.Topic+" "), payload...), nil
+	}
+	return payload, nil
+}
+
+// runSub prints the messages matching the given filters until the count
+// is reached, the command is interrupted or the timeout expires.
+func runSub(d *appDeps) error {
+	o := d.pubsub
+	if len(o.Topics) == 0 {
+		return errors.New("sub: at least one -t filter is required")
+	}
+	if o.QoS < 0 || o.QoS > 2 {
+		return fmt.Errorf("sub: invalid QoS %d", o.QoS)
+	}
+	switch o.Format {
+	case "":
+		o.Format = subFormatPlain
+	case subFormatPlain, subFormatTopic, subFormatNDJSON:
+	default:
+		return fmt.Errorf("sub: invalid format %q (want plain, topic or ndjson)", o.Format)
+	}
+	p, err := loadCLIProfile(d)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := cliContext(d)
+	defer cancel()
+
+	lost := make(chan struct{}, 1)
+	status := func(s string) {
+		if strings.HasPrefix(s, "Connection lost") {
+			select {
+			case lost <- struct{}{}:
+			default:
+			}
+		}
+	}
+	records := make(chan history.Record, 256)
+	done := make(chan struct{})
+	handler := func(_ mqtt.Client, msg mqtt.Message) {
+		r := history.NewRecord(time.Now(), msg.Topic(), msg.Payload(), msg.Qos(), msg.Retained(), mqttclient.MessageProperties(msg))
+		select {
+		case records <- r:
+		case <-done:
+		}
+	}
+	client, err := d.newMQTTClient(p, status)
+	if err != nil {
+		return fmt.Errorf("connect error: %w", err)
+	}
+	defer client.Disconnect()
+	// Release blocked handlers before disconnecting.
+	defer close(done)
+	for _, t := range o.Topics {
+		if err := client.Subscribe(t, byte(o.QoS), handler); err != nil {
+			return fmt.Errorf("sub: %s: %w", t, err)
+		}
+	}
+	for n := 0; o.Count <= 0 || n < o.Count; n++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-lost:
+			return fmt.Errorf("sub: %w", errConnectionLost)
+		case r := <-records:
+			line, err := subLine(o.Format, r)
+			if err != nil {
+				return fmt.Errorf("sub: %w", err)
+			}
+			if _, err := d.stdout.Write(append(line, '\n')); err != nil {
+				return fmt.Errorf("sub: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package emqutiti
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cfg "github.com/marang/emqutiti/cmd"
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/proxy"
+)
+
+type recordingClient struct {
+	stubMQTTClient
+	published []bridgePublish
+}
+
+func (c *recordingClient) Publish(topic string, qos byte, retained bool, payload interface{}) error {
+	c.published = append(c.published, bridgePublish{topic, qos, retained, string(payload.([]byte))})
+	return nil
+}
+
+// collectPayloads runs pubPayloads and returns what it published.
+func collectPayloads(o cfg.PubSubConfig, stdin io.Reader) ([][]byte, error) {
+	var got [][]byte
+	err := pubPayloads(context.Background(), o, stdin, func(b []byte) error {
+		got = append(got, b)
+		return nil
+	})
+	return got, err
+}
+
+func TestPubPayloadSources(t *testing.T) {
+	got, err := collectPayloads(cfg.PubSubConfig{Lines: true}, strings.NewReader("a\nb\n"))
+	if err != nil || len(got) != 2 || string(got[1]) != "b" {
+		t.Fatalf("lines: %q %v", got, err)
+	}
+	file := filepath.Join(t.TempDir(), "payload.json")
+	os.WriteFile(file, []byte(`{"on":true}`), 0o600)
+	if got, err := collectPayloads(cfg.PubSubConfig{File: file}, nil); err != nil || string(got[0]) != `{"on":true}` {
+		t.Fatalf("file: %q %v", got, err)
+	}
+	if got, err := collectPayloads(cfg.PubSubConfig{File: "-"}, strings.NewReader("raw")); err != nil || string(got[0]) != "raw" {
+		t.Fatalf("stdin file: %q %v", got, err)
+	}
+	if err := checkPubSource(cfg.PubSubConfig{Message: "a", Stdin: true}); err == nil {
+		t.Fatalf("expected error for two payload sources")
+	}
+	if err := checkPubSource(cfg.PubSubConfig{}); err == nil {
+		t.Fatalf("expected error without a payload source")
+	}
+}
+
+func TestPubLinesPublishesAsRead(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	published := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- pubPayloads(ctx, cfg.PubSubConfig{Lines: true}, r, func(b []byte) error {
+			published <- string(b)
+			return nil
+		})
+	}()
+	w.Write([]byte("first\n"))
+	select {
+	case got := <-published:
+		if got != "first" {
+			t.Fatalf("published %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("line not published before end of input")
+	}
+	// The stream stays open; cancelling ends the command.
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected cancellation, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("pub did not stop on cancel")
+	}
+}
+
+func TestRunPub(t *testing.T) {
+	client := &recordingClient{}
+	var profile string
+	d := &appDeps{
+		profileName: "prod",
+		pubsub:      cfg.PubSubConfig{Topics: []string{"a/b"}, QoS: 1, Retain: true, Lines: true},
+		stdin:       strings.NewReader("1\n2\n"),
+		loadProfile: func(name, _ string) (*connections.Profile, error) {
+			profile = name
+			return &connections.Profile{Name: name}, nil
+		},
+		newMQTTClient: func(connections.Profile, statusFunc) (mqttClient, error) { return client, nil },
+	}
+	if err := runPub(d); err != nil {
+		t.Fatalf("runPub: %v", err)
+	}
+	want := []bridgePublish{{"a/b", 1, true, "1"}, {"a/b", 1, true, "2"}}
+	if profile != "prod" || len(client.published) != 2 || client.published[0] != want[0] || client.published[1] != want[1] {
+		t.Fatalf("unexpected publishes %+v from profile %q", client.published, profile)
+	}
+	if !client.disconnected {
+		t.Fatalf("expected client disconnected")
+	}
+	d.pubsub.Topics = nil
+	if err := runPub(d); err == nil {
+		t.Fatalf("expected error without topic")
+	}
+}
+
+func TestSubLineFormats(t *testing.T) {
+	r := history.NewRecord(time.Unix(0, 0).UTC(), "a/b", []byte{0xff, 0x00}, 1, true, nil)
+	if line, _ := subLine(subFormatPlain, r); !bytes.Equal(line, []byte{0xff, 0x00}) {
+		t.Fatalf("plain should print raw bytes, got %q", line)
+	}
+	if line, _ := subLine(subFormatTopic, r); !bytes.Equal(line, append([]byte("a/b "), 0xff, 0x00)) {
+		t.Fatalf("unexpected topic line %q", line)
+	}
+	line, _ := subLine(subFormatNDJSON, r)
+	want := `{"time":"1970-01-01T00:00:00Z","topic":"a/b","qos":1,"retained":true,"payload":"/wA=","encoding":"base64"}`
+	if string(line) != want {
+		t.Fatalf("unexpected ndjson %s", line)
+	}
+}
+
+func TestRunPubSubWithSandbox(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	p := connections.SandboxProfile()
+	p.Port = ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	d := newAppDeps()
+	d.loadProfile = func(string, string) (*connections.Profile, error) { cp := p; return &cp, nil }
+	for _, topic := range []string{"site/1", "site/2"} {
+		d.pubsub = cfg.PubSubConfig{Topics: []string{topic}, QoS: 1, Retain: true, Message: "v-" + topic}
+		if err := runPub(d); err != nil {
+			t.Fatalf("runPub: %v", err)
+		}
+	}
+	var out bytes.Buffer
+	d.stdout = &out
+	d.timeout = 10 * time.Second
+	d.pubsub = cfg.PubSubConfig{Topics: []string{"site/#"}, QoS: 1, Format: subFormatNDJSON, Count: 2}
+	if err := runSub(d); err != nil {
+		t.Fatalf("runSub: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", out.String())
+	}
+	seen := map[string]string{}
+	for _, l := range lines {
+		var r history.Record
+		if err := json.Unmarshal([]byte(l), &r); err != nil {
+			t.Fatalf("invalid ndjson %q: %v", l, err)
+		}
+		if !r.Retained {
+			t.Fatalf("expected retained message, got %q", l)
+		}
+		seen[r.Topic] = r.Payload
+	}
+	if seen["site/1"] != "v-site/1" || seen["site/2"] != "v-site/2" {
+		t.Fatalf("unexpected messages %v", seen)
+	}
+}
+
+func TestMainDispatchPubExitsOnError(t *testing.T) {
+	orig := initProxy
+	initProxy = func() (string, *proxy.Proxy) { t.Fatalf("proxy started for pub"); return "", nil }
+	defer func() { initProxy = orig }()
+	d := newAppDeps()
+	code := -1
+	d.exit = func(c int) { code = c }
+	d.runners["pub"] = func(ad *appDeps) error {
+		if ad.pubsub.Topics[0] != "a/b" {
+			t.Fatalf("unexpected options %+v", ad.pubsub)
+		}
+		return errors.New("publish failed")
+	}
+	runMain(d, cfg.AppConfig{Command: "pub", PubSub: cfg.PubSubConfig{Topics: []string{"a/b"}}})
+	if code != 1 {
+		t.Fatalf("expected exit status 1, got %d", code)
+	}
+}
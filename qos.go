@@ -0,0 +1,56 @@
+package emqutiti
+
+import "fmt"
+
+// defaultQoS returns the QoS configured on the active profile.
+func (m *model) defaultQoS() int {
+	if p, ok := m.activeProfile(); ok {
+		return clampQoS(p.QoS)
+	}
+	return 0
+}
+
+// topicQoS resolves the QoS for topic from its override or the profile
+// default.
+func (m *model) topicQoS(topic string) byte {
+	def := m.defaultQoS()
+	if i := m.topicIndexByName(topic); i >= 0 {
+		return byte(clampQoS(m.topics.Items[i].EffectiveQoS(def)))
+	}
+	return byte(def)
+}
+
+// publishQoS resolves the QoS for a publish to topic. The message editor
+// override wins over the topic override and the profile default.
+func (m *model) publishQoS(topic string) byte {
+	if q := m.message.QoS(); q != nil {
+		return byte(clampQoS(*q))
+	}
+	return m.topicQoS(topic)
+}
+
+// subscribeTopic subscribes to topic at its resolved QoS and records the
+// QoS granted by the broker on the topic chip.
+func (m *model) subscribeTopic(topic string) (byte, error) {
+	if m.mqttClient == nil {
+		return 0, fmt.Errorf("no mqtt client")
+	}
+	qos := m.topicQoS(topic)
+	err := m.mqttClient.Subscribe(topic, qos, nil)
+	if code, ok := m.mqttClient.GrantedQoS(topic); ok {
+		m.topics.SetGranted(topic, int(code))
+	} else {
+		m.topics.SetGranted(topic, -1)
+	}
+	return qos, err
+}
+
+func clampQoS(q int) int {
+	if q < 0 {
+		return 0
+	}
+	if q > 2 {
+		return 2
+	}
+	return q
+}
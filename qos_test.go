@@ -0,0 +1,109 @@
+package emqutiti
+
+import (
+	"strings"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/topics"
+)
+
+// grantToken reports SUBACK codes like paho's SubscribeToken.
+type grantToken struct {
+	dummyToken
+	result map[string]byte
+}
+
+func (t *grantToken) Result() map[string]byte { return t.result }
+
+// qosClient records the QoS of each request and grants up to grant.
+type qosClient struct {
+	fakeClient
+	grant  byte
+	subQoS map[string]byte
+	pubQoS map[string]byte
+}
+
+func newQoSClient(grant byte) *qosClient {
+	return &qosClient{grant: grant, subQoS: map[string]byte{}, pubQoS: map[string]byte{}}
+}
+
+func (c *qosClient) Subscribe(topic string, qos byte, _ mqtt.MessageHandler) mqtt.Token {
+	c.subQoS[topic] = qos
+	g := qos
+	if c.grant < g || c.grant >= 0x80 {
+		g = c.grant
+	}
+	return &grantToken{result: map[string]byte{topic: g}}
+}
+
+func (c *qosClient) Publish(topic string, qos byte, _ bool, _ interface{}) mqtt.Token {
+	c.pubQoS[topic] = qos
+	return &dummyToken{}
+}
+
+func TestSubscribeUsesTopicOrProfileQoS(t *testing.T) {
+	fc := newQoSClient(2)
+	m := clientModel(t, connections.Profile{QoS: 1}, fc)
+	two := 2
+	m.topics.Items = []topics.Item{{Name: "a", Subscribed: true}, {Name: "b", Subscribed: true, QoS: &two}}
+	m.SubscribeActiveTopics()
+	if fc.subQoS["a"] != 1 || fc.subQoS["b"] != 2 {
+		t.Fatalf("unexpected subscribe QoS: %v", fc.subQoS)
+	}
+	for _, it := range m.topics.Items {
+		if it.Granted == nil {
+			t.Fatalf("granted QoS not recorded for %s", it.Name)
+		}
+	}
+}
+
+func TestSubscribeRecordsDowngrade(t *testing.T) {
+	fc := newQoSClient(0)
+	m := clientModel(t, connections.Profile{QoS: 2}, fc)
+	m.topics.Items = []topics.Item{{Name: "a", Subscribed: true}}
+	m.handleTopicToggle(topics.ToggleMsg{Topic: "a", Subscribed: true})
+	if g := m.topics.Items[0].Granted; g == nil || *g != 0 {
+		t.Fatalf("expected granted QoS 0, got %v", g)
+	}
+	if l := m.topics.Items[0].QoSLabel(); l != "Q0" {
+		t.Fatalf("unexpected chip label %q", l)
+	}
+	items := m.history.Items()
+	if len(items) != 2 || !strings.Contains(items[1].Payload, "granted QoS 0") {
+		t.Fatalf("expected downgrade log, got %+v", items)
+	}
+}
+
+func TestSubscribeRejectedReturnsError(t *testing.T) {
+	fc := newQoSClient(0x80)
+	m := clientModel(t, connections.Profile{QoS: 1}, fc)
+	m.topics.Items = []topics.Item{{Name: "a", Subscribed: true}}
+	if _, err := m.subscribeTopic("a"); err == nil {
+		t.Fatalf("expected error for rejected subscription")
+	}
+	if l := m.topics.Items[0].QoSLabel(); l != "Q✗" {
+		t.Fatalf("unexpected chip label %q", l)
+	}
+}
+
+func TestPublishQoSPrecedence(t *testing.T) {
+	fc := newQoSClient(2)
+	m := clientModel(t, connections.Profile{QoS: 1}, fc)
+	zero := 0
+	m.topics.Items = []topics.Item{{Name: "a", Publish: true}, {Name: "b", Publish: true, QoS: &zero}}
+	m.message.SetPayload("x")
+	m.SetFocus(idMessage)
+	m.publishMessage(false)
+	if fc.pubQoS["a"] != 1 || fc.pubQoS["b"] != 0 {
+		t.Fatalf("unexpected publish QoS: %v", fc.pubQoS)
+	}
+	m.message.CycleQoS() // 0
+	m.message.CycleQoS() // 1
+	m.message.CycleQoS() // 2
+	m.publishMessage(false)
+	if fc.pubQoS["a"] != 2 || fc.pubQoS["b"] != 2 {
+		t.Fatalf("editor override not applied: %v", fc.pubQoS)
+	}
+}
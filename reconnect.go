@@ -0,0 +1,208 @@
+package emqutiti
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/marang/emqutiti/connections"
+)
+
+const (
+	defaultReconnectPeriod      = time.Second
+	defaultReconnectMaxInterval = time.Minute
+)
+
+// reconnectState tracks automatic reconnection after the broker connection
+// was lost.
+type reconnectState struct {
+	// client is the connection being restored; nil when idle.
+	client  *MQTTClient
+	profile connections.Profile
+	attempt int
+	next    time.Time
+	dialing bool
+	lastErr error
+	// gen invalidates ticks and results of an earlier reconnect run.
+	gen int
+}
+
+// reconnectTickMsg updates the countdown to the next reconnect attempt.
+type reconnectTickMsg struct{ gen int }
+
+// reconnectResultMsg reports the outcome of a reconnect attempt.
+type reconnectResultMsg struct {
+	gen int
+	err error
+}
+
+// reconnectBackoff returns the delay before the given attempt. The delay
+// starts at the profile's reconnect period and doubles up to its maximum
+// interval.
+func reconnectBackoff(p connections.Profile, attempt int) time.Duration {
+	d := defaultReconnectPeriod
+	if p.ReconnectPeriod > 0 {
+		d = time.Duration(p.ReconnectPeriod) * time.Second
+	}
+	limit := defaultReconnectMaxInterval
+	if p.ReconnectMaxInterval > 0 {
+		limit = time.Duration(p.ReconnectMaxInterval) * time.Second
+	}
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	return d
+}
+
+func reconnectTick(gen int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return reconnectTickMsg{gen: gen} })
+}
+
+// startReconnect schedules reconnect attempts for the active profile when
+// auto reconnect is enabled.
+func (m *model) startReconnect() tea.Cmd {
+	p, ok := m.activeProfile()
+	if !ok || !p.AutoReconnect || m.mqttClient == nil {
+		return nil
+	}
+	r := &m.reconnect
+	if r.client == m.mqttClient {
+		return nil
+	}
+	r.gen++
+	*r = reconnectState{client: m.mqttClient, profile: p, gen: r.gen}
+	m.scheduleReconnect()
+	return reconnectTick(r.gen)
+}
+
+// stopReconnect abandons a running reconnect.
+func (m *model) stopReconnect() {
+	m.reconnect.client = nil
+	m.reconnect.gen++
+}
+
+// scheduleReconnect sets up the next attempt and updates the status.
+func (m *model) scheduleReconnect() {
+	r := &m.reconnect
+	r.attempt++
+	r.next = time.Now().Add(reconnectBackoff(r.profile, r.attempt))
+	m.showReconnectStatus()
+}
+
+// showReconnectStatus reports the attempt and countdown in the broker
+// manager and status line.
+func (m *model) showReconnectStatus() {
+	r := &m.reconnect
+	var detail string
+	if r.dialing {
+		detail = fmt.Sprintf("attempt %d: connecting…", r.attempt)
+	} else {
+		wait := time.Until(r.next).Round(time.Second)
+		if wait < 0 {
+			wait = 0
+		}
+		detail = fmt.Sprintf("attempt %d in %s", r.attempt, wait)
+	}
+	if r.lastErr != nil {
+		detail += fmt.Sprintf(" (last error: %v)", r.lastErr)
+	}
+	m.connections.SetReconnecting(r.profile.Name, detail)
+	m.connections.Connection = fmt.Sprintf("Reconnecting to %s: %s", brokerLabel(r.profile), detail)
+	m.connections.RefreshConnectionItems()
+}
+
+// handleReconnectTick refreshes the countdown and starts the attempt once it
+// is due.
+func (m *model) handleReconnectTick(msg reconnectTickMsg) tea.Cmd {
+	r := &m.reconnect
+	if msg.gen != r.gen || r.client == nil || r.dialing {
+		return nil
+	}
+	if r.client != m.mqttClient {
+		m.stopReconnect()
+		return nil
+	}
+	if time.Now().Before(r.next) {
+		m.showReconnectStatus()
+		return reconnectTick(r.gen)
+	}
+	r.dialing = true
+	m.showReconnectStatus()
+	client, gen := r.client, r.gen
+	return func() tea.Msg { return reconnectResultMsg{gen: gen, err: client.Reconnect()} }
+}
+
+// handleReconnectResult schedules another attempt after a failure or
+// restores subscriptions after success.
+func (m *model) handleReconnectResult(msg reconnectResultMsg) tea.Cmd {
+	r := &m.reconnect
+	if msg.gen != r.gen || r.client == nil {
+		return nil
+	}
+	r.dialing = false
+	if r.client != m.mqttClient {
+		// Disconnected by the user while the attempt was running.
+		if msg.err == nil {
+			r.client.Disconnect()
+		}
+		m.stopReconnect()
+		return nil
+	}
+	if msg.err != nil {
+		r.lastErr = msg.err
+		m.history.Append("", "", "log", false, fmt.Sprintf("Reconnect attempt %d failed: %v", r.attempt, msg.err))
+		m.scheduleReconnect()
+		return reconnectTick(r.gen)
+	}
+	p, attempts := r.profile, r.attempt
+	ep := r.client.ActiveBroker()
+	if ep == "" {
+		ep = p.BrokerURL()
+	}
+	m.stopReconnect()
+	m.connections.Connection = "Connected to " + ep
+	m.markConnected(p.Name)
+	m.connections.RefreshConnectionItems()
+	m.history.Append("", "", "log", false, fmt.Sprintf("Reconnected to %s after %d attempt(s)", ep, attempts))
+	m.resubscribe()
+	return tea.Batch(m.updateClientStatus()...)
+}
+
+// resubscribe restores the topic and request reply subscriptions after a
+// reconnect and logs the result.
+func (m *model) resubscribe() {
+	var done []string
+	for _, t := range m.topics.Items {
+		if !t.Subscribed {
+			continue
+		}
+		if _, err := m.subscribeTopic(t.Name); err != nil {
+			m.history.Append(t.Name, "", "log", false, fmt.Sprintf("Resubscribe to %s failed: %v", t.Name, err))
+			continue
+		}
+		done = append(done, t.Name)
+	}
+	if m.requests.client == m.mqttClient {
+		filters := make([]string, 0, len(m.requests.subs))
+		for f := range m.requests.subs {
+			filters = append(filters, f)
+		}
+		sort.Strings(filters)
+		for _, f := range filters {
+			if err := m.mqttClient.Subscribe(f, byte(m.defaultQoS()), nil); err != nil {
+				m.history.Append(f, "", "log", false, fmt.Sprintf("Resubscribe to %s failed: %v", f, err))
+				continue
+			}
+			done = append(done, f)
+		}
+	}
+	if len(done) > 0 {
+		m.history.Append("", "", "log", false, fmt.Sprintf("Resubscribed to %d topic(s): %s", len(done), strings.Join(done, ", ")))
+	}
+}
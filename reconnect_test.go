@@ -0,0 +1,133 @@
+package emqutiti
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/topics"
+)
+
+// reconnectClient fails the first connects and records subscriptions.
+type reconnectClient struct {
+	fakeClient
+	failures int
+	connects int
+	subs     []string
+}
+
+func (c *reconnectClient) Connect() mqtt.Token {
+	c.connects++
+	if c.connects <= c.failures {
+		return &dummyToken{err: errors.New("connection refused")}
+	}
+	return &dummyToken{}
+}
+
+func (c *reconnectClient) Subscribe(topic string, _ byte, _ mqtt.MessageHandler) mqtt.Token {
+	c.subs = append(c.subs, topic)
+	return &dummyToken{}
+}
+
+// reconnectTopics are the topics of the reconnect tests; a and c are
+// subscribed.
+var reconnectTopics = []topics.Item{{Name: "a", Subscribed: true}, {Name: "b"}, {Name: "c", Subscribed: true}}
+
+// dueReconnect makes the pending attempt due and runs it.
+func dueReconnect(t *testing.T, m *model) {
+	t.Helper()
+	m.reconnect.next = time.Now().Add(-time.Millisecond)
+	cmd := m.handleReconnectTick(reconnectTickMsg{gen: m.reconnect.gen})
+	if cmd == nil {
+		t.Fatalf("expected reconnect attempt")
+	}
+	m.Update(cmd())
+}
+
+func lastLog(m *model) string {
+	items := m.history.Items()
+	if len(items) == 0 {
+		return ""
+	}
+	return items[len(items)-1].Payload
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	p := connections.Profile{}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	for i, w := range want {
+		if got := reconnectBackoff(p, i+1); got != w {
+			t.Fatalf("attempt %d: expected %v, got %v", i+1, w, got)
+		}
+	}
+	if got := reconnectBackoff(p, 20); got != defaultReconnectMaxInterval {
+		t.Fatalf("expected default cap, got %v", got)
+	}
+	p = connections.Profile{ReconnectPeriod: 5, ReconnectMaxInterval: 12}
+	for i, w := range []time.Duration{5 * time.Second, 10 * time.Second, 12 * time.Second, 12 * time.Second} {
+		if got := reconnectBackoff(p, i+1); got != w {
+			t.Fatalf("attempt %d: expected %v, got %v", i+1, w, got)
+		}
+	}
+}
+
+func TestReconnectResubscribesAfterConnectionLost(t *testing.T) {
+	fc := &reconnectClient{failures: 1}
+	m := clientModel(t, connections.Profile{AutoReconnect: true}, fc, reconnectTopics...)
+	m.handleStatusMessage(connections.StatusMessage("Connection lost: EOF"))
+	if m.reconnect.client == nil {
+		t.Fatalf("expected reconnect to start")
+	}
+	if st := m.connections.Manager.Statuses["p"]; st != "reconnecting" {
+		t.Fatalf("expected reconnecting status, got %q", st)
+	}
+	if d := m.connections.Manager.Errors["p"]; !strings.HasPrefix(d, "attempt 1 in ") {
+		t.Fatalf("expected countdown detail, got %q", d)
+	}
+
+	dueReconnect(t, m)
+	if !strings.Contains(lastLog(m), "Reconnect attempt 1 failed: connection refused") {
+		t.Fatalf("expected failed attempt log, got %q", lastLog(m))
+	}
+	if d := m.connections.Manager.Errors["p"]; !strings.HasPrefix(d, "attempt 2 in ") || !strings.Contains(d, "connection refused") {
+		t.Fatalf("expected second attempt with last error, got %q", d)
+	}
+
+	dueReconnect(t, m)
+	if m.reconnect.client != nil {
+		t.Fatalf("expected reconnect to finish")
+	}
+	if st := m.connections.Manager.Statuses["p"]; st != "connected" {
+		t.Fatalf("expected connected status, got %q", st)
+	}
+	if strings.Join(fc.subs, ",") != "a,c" {
+		t.Fatalf("expected subscribed topics restored, got %v", fc.subs)
+	}
+	items := m.history.Items()
+	if !strings.Contains(items[len(items)-2].Payload, "Reconnected to") {
+		t.Fatalf("expected reconnected log, got %q", items[len(items)-2].Payload)
+	}
+	if lastLog(m) != "Resubscribed to 2 topic(s): a, c" {
+		t.Fatalf("expected resubscribed log, got %q", lastLog(m))
+	}
+}
+
+func TestReconnectDisabledOrAbandoned(t *testing.T) {
+	fc := &reconnectClient{}
+	m := clientModel(t, connections.Profile{}, fc, reconnectTopics...)
+	if cmd := m.handleStatusMessage(connections.StatusMessage("Connection lost: EOF")); m.reconnect.client != nil {
+		t.Fatalf("reconnect should require auto_reconnect, got %v", cmd)
+	}
+
+	m = clientModel(t, connections.Profile{AutoReconnect: true}, fc, reconnectTopics...)
+	m.handleStatusMessage(connections.StatusMessage("Connection lost: EOF"))
+	gen := m.reconnect.gen
+	m.DisconnectActive()
+	if cmd := m.handleReconnectTick(reconnectTickMsg{gen: gen}); cmd != nil || fc.connects != 0 {
+		t.Fatalf("disconnect should abandon the reconnect")
+	}
+}
@@ -0,0 +1,256 @@
+package emqutiti
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/properties"
+)
+
+const (
+	defaultReplyTopic       = "{topic}/reply"
+	defaultCorrelationField = "correlation_id"
+	defaultRequestTimeout   = 30 * time.Second
+	defaultResponsePrefix   = "emqutiti/reply"
+)
+
+// pendingRequest is a request awaiting its reply.
+type pendingRequest struct {
+	topic   string
+	replyTo string
+	// filter is the MQTT 3.1.1 reply subscription, released once no
+	// pending request uses it. MQTT 5 replies share one filter per
+	// connection.
+	filter string
+	sent   time.Time
+}
+
+// requestState tracks outstanding requests and the reply subscriptions made
+// for them on the current client.
+type requestState struct {
+	pending map[string]pendingRequest
+	// client is the connection the reply subscriptions were made on.
+	client  *MQTTClient
+	session string
+	subs    map[string]bool
+}
+
+// requestTimeoutMsg expires a request that received no reply.
+type requestTimeoutMsg struct{ id string }
+
+// newCorrelationID returns a short random identifier for a request.
+func newCorrelationID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// activeProfile returns the profile of the active connection.
+func (m *model) activeProfile() (connections.Profile, bool) {
+	for _, p := range m.connections.Manager.Profiles {
+		if p.Name == m.connections.Active {
+			return p, true
+		}
+	}
+	return connections.Profile{}, false
+}
+
+// resetRequestsForClient drops reply subscriptions made on a previous
+// connection.
+func (m *model) resetRequestsForClient() {
+	r := &m.requests
+	if r.client == m.mqttClient && r.subs != nil {
+		return
+	}
+	r.client = m.mqttClient
+	r.session = newCorrelationID()
+	r.subs = map[string]bool{}
+	if r.pending == nil {
+		r.pending = map[string]pendingRequest{}
+	}
+}
+
+// ensureReplySubscription subscribes to filter unless it is already
+// subscribed on this connection.
+func (m *model) ensureReplySubscription(filter string) error {
+	if m.requests.subs[filter] {
+		return nil
+	}
+	if err := m.mqttClient.Subscribe(filter, byte(m.defaultQoS()), nil); err != nil {
+		return err
+	}
+	m.requests.subs[filter] = true
+	return nil
+}
+
+// releaseReplySubscription unsubscribes the reply filter of req once no
+// other pending request or subscribed topic uses it.
+func (m *model) releaseReplySubscription(req pendingRequest) {
+	r := &m.requests
+	if req.filter == "" || !r.subs[req.filter] || r.client != m.mqttClient || m.mqttClient == nil {
+		return
+	}
+	for _, other := range r.pending {
+		if other.filter == req.filter {
+			return
+		}
+	}
+	for _, t := range m.topics.Items {
+		if t.Subscribed && t.Name == req.filter {
+			return
+		}
+	}
+	delete(r.subs, req.filter)
+	if err := m.mqttClient.Unsubscribe(req.filter); err != nil {
+		m.history.Append(req.filter, "", "log", false, fmt.Sprintf("Unsubscribe from reply topic %s failed: %v", req.filter, err))
+	}
+}
+
+// replyTopic expands the profile's MQTT 3.1.1 reply topic template.
+func replyTopic(p connections.Profile, topic, id string) string {
+	tmpl := p.ReplyTopic
+	if tmpl == "" {
+		tmpl = defaultReplyTopic
+	}
+	return strings.NewReplacer("{topic}", topic, "{id}", id).Replace(tmpl)
+}
+
+func correlationField(p connections.Profile) string {
+	if p.CorrelationField != "" {
+		return p.CorrelationField
+	}
+	return defaultCorrelationField
+}
+
+func requestTimeout(p connections.Profile) time.Duration {
+	if p.RequestTimeout > 0 {
+		return time.Duration(p.RequestTimeout) * time.Second
+	}
+	return defaultRequestTimeout
+}
+
+// embedCorrelationID adds the correlation ID to a JSON object payload.
+func embedCorrelationID(payload []byte, field, id string) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &obj); err != nil || obj == nil {
+		return nil, fmt.Errorf("request payload must be a JSON object on MQTT 3.1.1")
+	}
+	v, _ := json.Marshal(id)
+	obj[field] = v
+	return json.Marshal(obj)
+}
+
+// sendRequest publishes the message as a request to each publish target
+// and waits for replies. MQTT 5 requests carry a generated response topic
+// and correlation data; MQTT 3.1.1 requests use the profile's reply topic
+// convention with the correlation ID embedded in the JSON payload.
+func (m *model) sendRequest() tea.Cmd {
+	if m.ui.focusOrder[m.ui.focusIndex] != idMessage {
+		return nil
+	}
+	if m.mqttClient == nil {
+		m.history.Append("", "", "log", false, "Request failed: not connected")
+		return nil
+	}
+	data, err := m.message.Payload()
+	if err != nil {
+		m.history.Append("", "", "log", false, fmt.Sprintf("Request failed: %v", err))
+		return nil
+	}
+	m.resetRequestsForClient()
+	p, _ := m.activeProfile()
+	v5 := m.mqttClient.SupportsProperties()
+	var cmds []tea.Cmd
+	for _, topic := range m.publishTargets() {
+		id := newCorrelationID()
+		payload := data
+		var props *properties.Properties
+		var replyTo, filter string
+		if v5 {
+			prefix := m.mqttClient.ResponseInformation()
+			if prefix == "" {
+				prefix = defaultResponsePrefix
+			}
+			base := strings.TrimSuffix(prefix, "/") + "/" + m.requests.session
+			replyTo, filter = base+"/"+id, base+"/#"
+			props = &properties.Properties{}
+			if cur := m.message.Properties(); cur != nil {
+				*props = *cur
+			}
+			props.ResponseTopic = replyTo
+			props.CorrelationData = []byte(id)
+		} else {
+			replyTo = replyTopic(p, topic, id)
+			filter = replyTo
+			var err error
+			if payload, err = embedCorrelationID(payload, correlationField(p), id); err != nil {
+				m.history.Append(topic, "", "log", false, fmt.Sprintf("Request to %s failed: %v", topic, err))
+				continue
+			}
+		}
+		if err := m.ensureReplySubscription(filter); err != nil {
+			m.history.Append(filter, "", "log", false, fmt.Sprintf("Request to %s failed: reply subscription: %v", topic, err))
+			continue
+		}
+		req := pendingRequest{topic: topic, replyTo: replyTo, sent: time.Now()}
+		if !v5 {
+			req.filter = filter
+		}
+		m.requests.pending[id] = req
+		m.history.AppendMessage(history.Message{Topic: topic, Payload: payload, Kind: "pub", Properties: props, CorrelationID: id},
+			fmt.Sprintf("Request %s to %s, reply on %s", id, topic, replyTo))
+		if err := m.mqttClient.PublishWithProperties(topic, m.publishQoS(topic), false, payload, props); err != nil {
+			delete(m.requests.pending, id)
+			m.releaseReplySubscription(req)
+			m.history.Append(topic, "", "log", false, fmt.Sprintf("Request %s to %s failed: %v", id, topic, err))
+			continue
+		}
+		cmds = append(cmds, tea.Tick(requestTimeout(p), func(time.Time) tea.Msg { return requestTimeoutMsg{id: id} }))
+	}
+	return tea.Batch(cmds...)
+}
+
+// matchReply pairs msg with a pending request and returns its correlation
+// ID and round-trip time. Matched requests are no longer pending.
+func (m *model) matchReply(msg MQTTMessage) (string, time.Duration, bool) {
+	if len(m.requests.pending) == 0 {
+		return "", 0, false
+	}
+	id := ""
+	if msg.Properties != nil && len(msg.Properties.CorrelationData) > 0 {
+		id = string(msg.Properties.CorrelationData)
+	} else {
+		p, _ := m.activeProfile()
+		var obj map[string]any
+		if json.Unmarshal(msg.Payload, &obj) == nil {
+			id, _ = obj[correlationField(p)].(string)
+		}
+	}
+	req, ok := m.requests.pending[id]
+	if !ok || req.replyTo != msg.Topic {
+		return "", 0, false
+	}
+	delete(m.requests.pending, id)
+	m.releaseReplySubscription(req)
+	return id, time.Since(req.sent), true
+}
+
+// handleRequestTimeout logs requests that received no reply in time.
+func (m *model) handleRequestTimeout(msg requestTimeoutMsg) tea.Cmd {
+	req, ok := m.requests.pending[msg.id]
+	if !ok {
+		return nil
+	}
+	delete(m.requests.pending, msg.id)
+	m.releaseReplySubscription(req)
+	m.history.Append(req.topic, "", "log", false, fmt.Sprintf("No reply to request %s on %s within %s", msg.id, req.replyTo, time.Since(req.sent).Round(time.Second)))
+	return nil
+}
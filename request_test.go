@@ -0,0 +1,193 @@
+package emqutiti
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/properties"
+	"github.com/marang/emqutiti/topics"
+)
+
+// requestClient records subscriptions and publishes made for requests.
+type requestClient struct {
+	fakeClient
+	subs     []string
+	unsubs   []string
+	payloads map[string]string
+	props    *properties.Properties
+}
+
+func (c *requestClient) Subscribe(topic string, _ byte, _ mqtt.MessageHandler) mqtt.Token {
+	c.subs = append(c.subs, topic)
+	return &dummyToken{}
+}
+
+func (c *requestClient) Unsubscribe(topics ...string) mqtt.Token {
+	c.unsubs = append(c.unsubs, topics...)
+	return &dummyToken{}
+}
+
+func (c *requestClient) Publish(topic string, _ byte, _ bool, payload interface{}) mqtt.Token {
+	b, _ := payload.([]byte)
+	c.payloads[topic] = string(b)
+	return &dummyToken{}
+}
+
+// requestClientV5 additionally accepts MQTT 5 properties.
+type requestClientV5 struct{ requestClient }
+
+func (c *requestClientV5) PublishWithProperties(topic string, qos byte, retained bool, payload interface{}, props *properties.Properties) mqtt.Token {
+	c.props = props
+	return c.Publish(topic, qos, retained, payload)
+}
+
+func requestModel(t *testing.T, p connections.Profile, fc mqtt.Client) *model {
+	t.Helper()
+	m := clientModel(t, p, fc, topics.Item{Name: "dev/1/rpc"})
+	m.topics.SetSelected(0)
+	m.SetFocus(idMessage)
+	return m
+}
+
+func onlyPending(t *testing.T, m *model) (string, pendingRequest) {
+	t.Helper()
+	if len(m.requests.pending) != 1 {
+		t.Fatalf("expected one pending request, got %v", m.requests.pending)
+	}
+	for id, req := range m.requests.pending {
+		return id, req
+	}
+	return "", pendingRequest{}
+}
+
+func TestRequestV5PairsReplyByCorrelationData(t *testing.T) {
+	fc := &requestClientV5{requestClient{payloads: map[string]string{}}}
+	m := requestModel(t, connections.Profile{}, fc)
+	m.message.SetPayload("ping")
+	if cmd := m.sendRequest(); cmd == nil {
+		t.Fatalf("expected timeout command")
+	}
+	id, req := onlyPending(t, m)
+	if fc.props == nil || fc.props.ResponseTopic != req.replyTo || string(fc.props.CorrelationData) != id {
+		t.Fatalf("unexpected request properties %+v", fc.props)
+	}
+	if !strings.HasPrefix(req.replyTo, defaultResponsePrefix+"/") || len(fc.subs) != 1 || !strings.HasSuffix(fc.subs[0], "/#") {
+		t.Fatalf("expected wildcard reply subscription, got %v for %s", fc.subs, req.replyTo)
+	}
+	if fc.payloads["dev/1/rpc"] != "ping" {
+		t.Fatalf("v5 payload should be unchanged, got %q", fc.payloads["dev/1/rpc"])
+	}
+
+	m.sendRequest()
+	if len(fc.subs) != 1 {
+		t.Fatalf("reply subscription should be reused, got %v", fc.subs)
+	}
+
+	m.handleMQTTMessage(MQTTMessage{Topic: req.replyTo, Payload: []byte("pong"), Properties: &properties.Properties{CorrelationData: []byte(id)}})
+	if _, ok := m.requests.pending[id]; ok {
+		t.Fatalf("reply should resolve the request")
+	}
+	if len(fc.unsubs) != 0 {
+		t.Fatalf("shared reply subscription should stay, got unsubscribes %v", fc.unsubs)
+	}
+	items := m.history.Items()
+	last := items[len(items)-1]
+	if last.Kind != "sub" || last.CorrelationID != id {
+		t.Fatalf("reply not paired with request: %+v", last)
+	}
+	var found bool
+	for _, it := range items {
+		if it.Kind == "pub" && it.CorrelationID == id {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("request not recorded with correlation ID")
+	}
+}
+
+func TestRequestV311EmbedsCorrelationID(t *testing.T) {
+	fc := &requestClient{payloads: map[string]string{}}
+	m := requestModel(t, connections.Profile{ReplyTopic: "{topic}/reply/{id}", CorrelationField: "cid"}, fc)
+	m.message.SetPayload(`{"method":"ping"}`)
+	m.sendRequest()
+	id, req := onlyPending(t, m)
+	if req.replyTo != "dev/1/rpc/reply/"+id || len(fc.subs) != 1 || fc.subs[0] != req.replyTo {
+		t.Fatalf("unexpected reply topic %q subs %v", req.replyTo, fc.subs)
+	}
+	var body map[string]string
+	if err := json.Unmarshal([]byte(fc.payloads["dev/1/rpc"]), &body); err != nil || body["cid"] != id || body["method"] != "ping" {
+		t.Fatalf("correlation ID not embedded: %q", fc.payloads["dev/1/rpc"])
+	}
+
+	m.handleMQTTMessage(MQTTMessage{Topic: "elsewhere", Payload: []byte(`{"cid":"` + id + `"}`)})
+	if len(m.requests.pending) != 1 {
+		t.Fatalf("reply on another topic should not match")
+	}
+	m.handleMQTTMessage(MQTTMessage{Topic: req.replyTo, Payload: []byte(`{"cid":"` + id + `","ok":true}`)})
+	if len(m.requests.pending) != 0 {
+		t.Fatalf("reply should resolve the request")
+	}
+	if len(fc.unsubs) != 1 || fc.unsubs[0] != req.replyTo || len(m.requests.subs) != 0 {
+		t.Fatalf("per-request reply subscription not released: unsubs %v subs %v", fc.unsubs, m.requests.subs)
+	}
+	if last := m.history.Items()[len(m.history.Items())-1]; last.CorrelationID != id {
+		t.Fatalf("reply not paired: %+v", last)
+	}
+}
+
+func TestRequestV311RequiresJSONObject(t *testing.T) {
+	fc := &requestClient{payloads: map[string]string{}}
+	m := requestModel(t, connections.Profile{}, fc)
+	m.message.SetPayload("ping")
+	m.sendRequest()
+	if len(m.requests.pending) != 0 || len(fc.payloads) != 0 {
+		t.Fatalf("non-JSON request should not be sent")
+	}
+	items := m.history.Items()
+	if !strings.Contains(items[len(items)-1].Payload, "JSON object") {
+		t.Fatalf("expected error log, got %+v", items[len(items)-1])
+	}
+}
+
+func TestRequestTimeoutLogsMissingReply(t *testing.T) {
+	fc := &requestClient{payloads: map[string]string{}}
+	m := requestModel(t, connections.Profile{}, fc)
+	m.message.SetPayload(`{}`)
+	m.sendRequest()
+	first, _ := onlyPending(t, m)
+	m.sendRequest()
+	m.Update(requestTimeoutMsg{id: first})
+	if len(fc.unsubs) != 0 {
+		t.Fatalf("reply topic still used by another request was unsubscribed")
+	}
+	id, req := onlyPending(t, m)
+	m.Update(requestTimeoutMsg{id: id})
+	if len(m.requests.pending) != 0 {
+		t.Fatalf("timed out request should be dropped")
+	}
+	if len(fc.unsubs) != 1 || fc.unsubs[0] != req.replyTo {
+		t.Fatalf("reply subscription not released after timeout: %v", fc.unsubs)
+	}
+	items := m.history.Items()
+	if !strings.Contains(items[len(items)-1].Payload, "No reply to request "+id) {
+		t.Fatalf("expected timeout log, got %+v", items[len(items)-1])
+	}
+}
+
+func TestReplyTopicTemplate(t *testing.T) {
+	if got := replyTopic(connections.Profile{}, "a/b", "x"); got != "a/b/reply" {
+		t.Fatalf("unexpected default reply topic %q", got)
+	}
+	if got := replyTopic(connections.Profile{ReplyTopic: "rpc/{id}/{topic}"}, "a", "x"); got != "rpc/x/a" {
+		t.Fatalf("unexpected reply topic %q", got)
+	}
+	if got := requestTimeout(connections.Profile{RequestTimeout: 2}); got != 2*time.Second {
+		t.Fatalf("unexpected timeout %v", got)
+	}
+}
@@ -3,19 +3,25 @@ package emqutiti
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	connections "github.com/marang/emqutiti/connections"
 	history "github.com/marang/emqutiti/history"
 
 	tea "github.com/charmbracelet/bubbletea"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 
+	"github.com/marang/emqutiti/broker"
 	cfg "github.com/marang/emqutiti/cmd"
 	"github.com/marang/emqutiti/constants"
 	"github.com/marang/emqutiti/importer"
@@ -47,6 +53,7 @@ type program interface{ Run() (tea.Model, error) }
 
 type mqttClient interface {
 	steps.Publisher
+	Subscribe(topic string, qos byte, callback mqtt.MessageHandler) error
 	Disconnect()
 }
 
@@ -62,6 +69,8 @@ type appDeps struct {
 
 	traceStore traces.Store
 	traceRun   func(context.Context, string, string, string, string, string) error
+	// traceOutput configures the sinks of the default traceRun.
+	traceOutput traces.Output
 
 	loadProfile   func(string, string) (*connections.Profile, error)
 	newMQTTClient func(connections.Profile, statusFunc) (mqttClient, error)
@@ -77,12 +86,38 @@ type appDeps struct {
 
 	proxyAddr string
 	timeout   time.Duration
+
+	// serve configures the embedded broker of the serve mode.
+	serve broker.Config
+
+	// bridgeProfiles names the source and target profiles of the bridge
+	// mode as "SOURCE,TARGET".
+	bridgeProfiles  string
+	bridgeRewrite   string
+	bridge          bridgeConfig
+	newBridgeClient bridgeConnect
+
+	// pubsub holds the options of the pub and sub commands, which read
+	// stdin and write stdout.
+	pubsub cfg.PubSubConfig
+	stdin  io.Reader
+	stdout io.Writer
+	// exit ends the process with a status for scripts.
+	exit func(int)
+
+	// export and capture hold the options of the export and load
+	// commands.
+	export      cfg.ExportConfig
+	capture     cfg.CaptureConfig
+	openHistory func(string) (history.Store, error)
+
+	// proxyDaemon holds the options of the proxy command.
+	proxyDaemon cfg.ProxyConfig
 }
 
 func newAppDeps() *appDeps {
 	d := &appDeps{
 		traceStore:    traces.FileStore{},
-		traceRun:      traces.Run,
 		loadProfile:   connections.LoadProfile,
 		newMQTTClient: func(p connections.Profile, fn statusFunc) (mqttClient, error) { return NewMQTTClient(p, fn) },
 		newImporter:   importer.New,
@@ -93,11 +128,27 @@ func newAppDeps() *appDeps {
 		selectProfile: promptProfileSelection,
 		profileIn:     os.Stdin,
 		profileOut:    os.Stdout,
+
+		newBridgeClient: connectBridgeClient,
+		stdin:           os.Stdin,
+		stdout:          os.Stdout,
+		exit:            os.Exit,
+		openHistory:     history.OpenStore,
+	}
+	d.traceRun = func(ctx context.Context, key, topics, profile, start, end string) error {
+		return traces.RunWithOutput(ctx, key, topics, profile, start, end, d.traceOutput)
 	}
 	d.runners = map[string]ModeRunner{
 		"trace":  runTrace,
 		"import": runImport,
 		"ui":     runUI,
+		"serve":  runServe,
+		"bridge": runBridge,
+		"pub":    runPub,
+		"sub":    runSub,
+		"export": runExport,
+		"load":   runLoad,
+		"proxy":  runProxyDaemon,
 	}
 	return d
 }
@@ -133,29 +184,73 @@ func runMain(d *appDeps, c cfg.AppConfig) {
 	d.traceStart = c.TraceStart
 	d.traceEnd = c.TraceEnd
 	d.timeout = c.Timeout
+	d.traceOutput = traces.Output{
+		Format:       c.TraceFormat,
+		File:         c.TraceFile,
+		FileFormat:   c.TraceFileFormat,
+		FileMaxBytes: int64(c.TraceFileMaxMB) << 20,
+		FileKeep:     c.TraceFileKeep,
+	}
+	if c.TraceProgress > 0 {
+		d.traceOutput.ProgressEvery, d.traceOutput.Progress = c.TraceProgress, os.Stderr
+	}
+	d.serve = broker.Config{TCPAddr: c.ServeTCP, WSAddr: c.ServeWS, Username: c.ServeUser, Password: c.ServePassword}
+	d.bridgeProfiles = c.Bridge
+	d.bridgeRewrite = c.BridgeRewrite
+	d.bridge = bridgeConfig{Topics: splitList(c.TraceTopics), QoS: c.BridgeQoS, Retain: c.BridgeRetain, Bidirectional: c.BridgeBoth}
 
-	addr, _ := initProxy()
-	history.SetProxyAddr(addr)
-	traces.SetProxyAddr(addr)
-	d.proxyAddr = addr
+	d.pubsub = c.PubSub
+	d.export = c.Export
+	d.capture = c.Capture
+	d.proxyDaemon = c.Proxy
 
 	mode := "ui"
-	if d.traceKey != "" {
+	if c.Command != "" {
+		mode = c.Command
+	} else if c.Serve {
+		mode = "serve"
+	} else if d.bridgeProfiles != "" {
+		mode = "bridge"
+	} else if d.traceKey != "" {
 		mode = "trace"
 	} else if d.importFile != "" {
 		mode = "import"
 	}
 
+	// The broker and the pub/sub commands keep no history, so they do not
+	// need the DB proxy; the proxy command runs it itself.
+	if mode != "serve" && mode != "pub" && mode != "sub" {
+		loadProxySecurity()
+	}
+	if mode != "serve" && mode != "pub" && mode != "sub" && mode != "proxy" {
+		addr, _ := initProxy()
+		history.SetProxyAddr(addr)
+		traces.SetProxyAddr(addr)
+		spillProxyAddr = addr
+		d.proxyAddr = addr
+	}
+
 	if runner, ok := d.runners[mode]; ok {
 		if err := runner(d); err != nil {
 			if mode == "ui" {
 				log.Fatalf("Error running program: %v", err)
 			}
 			log.Println(err)
+			d.exit(exitCode(err))
 		}
 	}
 }
 
+// exitCode returns the process status for err: the code carried by errors
+// such as traces.ExitError, and 1 otherwise.
+func exitCode(err error) int {
+	var ec interface{ ExitCode() int }
+	if errors.As(err, &ec) {
+		return ec.ExitCode()
+	}
+	return 1
+}
+
 func listProfiles(w io.Writer, file string) error {
 	cfg, err := connections.LoadConfig(file)
 	if err != nil {
@@ -348,3 +443,84 @@ func runUI(d *appDeps) error {
 	}
 	return nil
 }
+
+// runServe runs the embedded broker until it is interrupted or the timeout
+// expires.
+func runServe(d *appDeps) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	c := d.serve
+	c.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	srv, err := broker.Start(c)
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+	if addr := srv.TCPAddr(); addr != "" {
+		log.Printf("MQTT broker listening on tcp://%s", addr)
+	}
+	if addr := srv.WSAddr(); addr != "" {
+		log.Printf("MQTT broker listening on ws://%s", addr)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// splitList splits a comma-separated flag value and drops empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// runBridge forwards messages between two profiles until it is interrupted
+// or the timeout expires.
+func runBridge(d *appDeps) error {
+	names := splitList(d.bridgeProfiles)
+	if len(names) != 2 {
+		return fmt.Errorf("bridge: want SOURCE,TARGET profiles, got %q", d.bridgeProfiles)
+	}
+	cfg := d.bridge
+	var err error
+	if cfg.FromPrefix, cfg.ToPrefix, err = parseBridgeRewrite(d.bridgeRewrite); err != nil {
+		return err
+	}
+	var profiles [2]connections.Profile
+	for i, name := range names {
+		p, err := d.loadProfile(name, "")
+		if err != nil {
+			return fmt.Errorf("error loading profile: %w", err)
+		}
+		connections.ApplyDefaultPassword(p)
+		profiles[i] = *p
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	var running *bridge
+	err = runBridgeSession(ctx, profiles[0], profiles[1], cfg, d.newBridgeClient, func(b *bridge) {
+		running = b
+		arrow := "->"
+		if cfg.Bidirectional {
+			arrow = "<->"
+		}
+		log.Printf("Bridging %s %s %s for %s", names[0], arrow, names[1], strings.Join(cfg.Topics, ", "))
+	})
+	if running != nil {
+		log.Printf("Bridge stopped: %s", running.Stats())
+	}
+	return err
+}
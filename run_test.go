@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 	cfg "github.com/marang/emqutiti/cmd"
 	connections "github.com/marang/emqutiti/connections"
 	"github.com/marang/emqutiti/help"
@@ -60,13 +62,15 @@ func (s *stubMQTTClient) Publish(topic string, qos byte, retained bool, payload
 	return nil
 }
 
+func (s *stubMQTTClient) Subscribe(string, byte, mqtt.MessageHandler) error { return nil }
+
 func (s *stubMQTTClient) Disconnect() { s.disconnected = true }
 
 type stubHistoryStore struct{ closed bool }
 
 func (s *stubHistoryStore) Append(history.Message) error { return nil }
-func (s *stubHistoryStore) Search(bool, []string, time.Time, time.Time, string) []history.Message {
-	return nil
+func (s *stubHistoryStore) Query(history.Query) (history.Page, error) {
+	return history.Page{}, nil
 }
 func (s *stubHistoryStore) Delete(string) error  { return nil }
 func (s *stubHistoryStore) Archive(string) error { return nil }
@@ -250,6 +254,81 @@ func TestMainDispatchUI(t *testing.T) {
 	initProxy = orig
 }
 
+func TestMainDispatchServeSkipsProxy(t *testing.T) {
+	orig := initProxy
+	initProxy = func() (string, *proxy.Proxy) { t.Fatalf("proxy started for --serve"); return "", nil }
+	defer func() { initProxy = orig }()
+	called := false
+	d := newAppDeps()
+	d.runners["serve"] = func(ad *appDeps) error {
+		called = true
+		if ad.serve.TCPAddr != "127.0.0.1:0" || ad.serve.Username != "u" {
+			t.Fatalf("unexpected broker config %+v", ad.serve)
+		}
+		return nil
+	}
+	d.runners["ui"] = func(*appDeps) error { t.Fatalf("runUI called"); return nil }
+	runMain(d, cfg.AppConfig{Serve: true, ServeTCP: "127.0.0.1:0", ServeUser: "u"})
+	if !called {
+		t.Fatalf("runServe not called")
+	}
+}
+
+func TestRunServeStopsAtTimeout(t *testing.T) {
+	d := &appDeps{timeout: 50 * time.Millisecond}
+	d.serve.TCPAddr = "127.0.0.1:0"
+	d.serve.WSAddr = "127.0.0.1:0"
+	if err := runServe(d); err != nil {
+		t.Fatalf("runServe: %v", err)
+	}
+	d.serve.TCPAddr, d.serve.WSAddr = "", ""
+	if err := runServe(d); err == nil {
+		t.Fatalf("expected an error without listeners")
+	}
+}
+
+func TestRunBridge(t *testing.T) {
+	clients := map[string]*fakeBridgeClient{}
+	d := &appDeps{
+		bridgeProfiles: "prod, staging",
+		bridgeRewrite:  "prod/=staging/",
+		bridge:         bridgeConfig{Topics: []string{"prod/#"}, QoS: -1},
+		timeout:        50 * time.Millisecond,
+		loadProfile: func(name, _ string) (*connections.Profile, error) {
+			return &connections.Profile{Name: name}, nil
+		},
+		newBridgeClient: func(p connections.Profile, _ statusFunc) (bridgeClient, error) {
+			c := &fakeBridgeClient{}
+			clients[p.Name] = c
+			return c, nil
+		},
+	}
+	if err := runBridge(d); err != nil {
+		t.Fatalf("runBridge: %v", err)
+	}
+	if _, ok := clients["prod"].subs["prod/#"]; ok || len(clients) != 2 {
+		t.Fatalf("expected both profiles connected and unsubscribed, got %v", clients)
+	}
+	if !clients["prod"].disconnected || !clients["staging"].disconnected {
+		t.Fatalf("expected clients disconnected")
+	}
+
+	d.bridgeProfiles = "prod"
+	if err := runBridge(d); err == nil {
+		t.Fatalf("expected error for a single profile")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	lost := &traces.ExitError{Code: traces.ExitConnectionLost, Err: errors.New("lost")}
+	if got := exitCode(fmt.Errorf("trace: %w", lost)); got != traces.ExitConnectionLost {
+		t.Fatalf("expected wrapped trace status, got %d", got)
+	}
+	if got := exitCode(errors.New("boom")); got != 1 {
+		t.Fatalf("expected 1 for other errors, got %d", got)
+	}
+}
+
 func TestRunImport(t *testing.T) {
 	t.Setenv("EMQUTITI_DEFAULT_PASSWORD", "pw")
 
@@ -0,0 +1,229 @@
+package emqutiti
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	connections "github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/proxy"
+)
+
+const (
+	spillBucket     = "spill"
+	spillRPCTimeout = 5 * time.Second
+	// spillLeasePrefix holds a lease per open queue. Queues renew their
+	// lease every spillLeaseInterval; sessions whose lease is older than
+	// spillLeaseTTL belong to clients that are gone and are swept when a
+	// queue opens.
+	spillLeasePrefix   = "lease/"
+	spillLeaseInterval = time.Minute
+	spillLeaseTTL      = 5 * time.Minute
+)
+
+// spillProxyAddr is the DB proxy used for spilled messages. It falls back to
+// the address recorded in config.toml.
+var spillProxyAddr string
+
+func spillAddr() string {
+	if spillProxyAddr != "" {
+		return spillProxyAddr
+	}
+	return connections.LoadProxyAddr()
+}
+
+// spillQueue persists inbound messages that do not fit the message buffer
+// in the DB proxy so the UI can catch up instead of dropping them. Keys
+// start with a session ID so that instances sharing a profile and proxy
+// keep separate queues.
+type spillQueue struct {
+	cl      proxy.DBProxyClient
+	conn    *grpc.ClientConn
+	profile string
+	session string
+	// stop ends the lease renewal.
+	stop chan struct{}
+
+	mu         sync.Mutex
+	head, tail uint64
+}
+
+// spillLease records that the queue of a session is in use.
+type spillLease struct {
+	Session string
+	Renewed time.Time
+}
+
+// openSpillQueue connects to the DB proxy and starts a queue under a new
+// session ID. Queues left behind by clients that did not close them are
+// removed first.
+func openSpillQueue(addr, profile string) (*spillQueue, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("no DB proxy address")
+	}
+	cl, conn, err := proxy.NewClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	q := &spillQueue{cl: cl, conn: conn, profile: profile, session: newSpillSession(), stop: make(chan struct{})}
+	if err := q.sweep(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := q.renew(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go q.keepLease()
+	return q, nil
+}
+
+// newSpillSession returns a random key prefix for the queue of one client.
+func newSpillSession() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (q *spillQueue) prefix() string { return q.session + "/" }
+
+func (q *spillQueue) key(seq uint64) string { return fmt.Sprintf("%s%020d", q.prefix(), seq) }
+
+func (q *spillQueue) leaseKey() string { return spillLeasePrefix + q.session }
+
+// renew stamps the lease of this session.
+func (q *spillQueue) renew() error {
+	val, err := json.Marshal(spillLease{Session: q.session, Renewed: time.Now()})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), spillRPCTimeout)
+	defer cancel()
+	_, err = q.cl.Write(ctx, &proxy.WriteRequest{Profile: q.profile, Bucket: spillBucket, Key: q.leaseKey(), Value: val})
+	return err
+}
+
+// keepLease renews the lease until the queue is closed.
+func (q *spillQueue) keepLease() {
+	t := time.NewTicker(spillLeaseInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-t.C:
+			_ = q.renew()
+		}
+	}
+}
+
+// sweep deletes the queues of the profile whose lease expired.
+func (q *spillQueue) sweep() error {
+	ctx, cancel := context.WithTimeout(context.Background(), spillRPCTimeout)
+	defer cancel()
+	resp, err := q.cl.Read(ctx, &proxy.ReadRequest{Profile: q.profile, Bucket: spillBucket, Key: spillLeasePrefix})
+	if err != nil {
+		return err
+	}
+	for _, v := range resp.Values {
+		var l spillLease
+		if err := json.Unmarshal(v, &l); err != nil || l.Session == "" || time.Since(l.Renewed) < spillLeaseTTL {
+			continue
+		}
+		stale := &spillQueue{cl: q.cl, profile: q.profile, session: l.Session}
+		if err := stale.clear(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clear deletes the messages of this session and then its lease, so that
+// a failed delete is retried by a later sweep.
+func (q *spillQueue) clear() error {
+	ctx, cancel := context.WithTimeout(context.Background(), spillRPCTimeout)
+	defer cancel()
+	if _, err := q.cl.Delete(ctx, &proxy.DeleteRequest{Profile: q.profile, Bucket: spillBucket, Key: q.prefix()}); err != nil {
+		return err
+	}
+	_, err := q.cl.Delete(ctx, &proxy.DeleteRequest{Profile: q.profile, Bucket: spillBucket, Key: q.leaseKey()})
+	return err
+}
+
+// push appends msg to the queue.
+func (q *spillQueue) push(msg MQTTMessage) error {
+	val, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), spillRPCTimeout)
+	defer cancel()
+	if _, err := q.cl.Write(ctx, &proxy.WriteRequest{Profile: q.profile, Bucket: spillBucket, Key: q.key(q.tail), Value: val}); err != nil {
+		return err
+	}
+	q.tail++
+	return nil
+}
+
+// peek returns the oldest queued message without removing it.
+func (q *spillQueue) peek() (MQTTMessage, bool, error) {
+	q.mu.Lock()
+	head, tail := q.head, q.tail
+	q.mu.Unlock()
+	if head == tail {
+		return MQTTMessage{}, false, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), spillRPCTimeout)
+	defer cancel()
+	resp, err := q.cl.Read(ctx, &proxy.ReadRequest{Profile: q.profile, Bucket: spillBucket, Key: q.key(head)})
+	if err != nil {
+		return MQTTMessage{}, false, err
+	}
+	if len(resp.Values) == 0 {
+		return MQTTMessage{}, false, fmt.Errorf("spilled message %d missing", head)
+	}
+	var msg MQTTMessage
+	if err := json.Unmarshal(resp.Values[0], &msg); err != nil {
+		return MQTTMessage{}, false, err
+	}
+	return msg, true, nil
+}
+
+// advance removes the oldest queued message.
+func (q *spillQueue) advance() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.head == q.tail {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), spillRPCTimeout)
+	defer cancel()
+	_, err := q.cl.Delete(ctx, &proxy.DeleteRequest{Profile: q.profile, Bucket: spillBucket, Key: q.key(q.head)})
+	q.head++
+	return err
+}
+
+// len reports the number of queued messages.
+func (q *spillQueue) len() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int(q.tail - q.head)
+}
+
+// close discards the messages of this session and closes the proxy connection.
+func (q *spillQueue) close() {
+	close(q.stop)
+	_ = q.clear()
+	q.conn.Close()
+}
@@ -3,34 +3,53 @@ package emqutiti
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	connections "github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/history"
 )
 
 // handleStatusMessage processes broker status updates.
 func (m *model) handleStatusMessage(msg connections.StatusMessage) tea.Cmd {
 	m.ui.listeners.status = false
 	m.history.Append("", string(msg), "log", false, string(msg))
+	cmds := m.updateClientStatus()
 	if strings.HasPrefix(string(msg), "Connected") && m.connections.Active != "" {
-		m.connections.SetConnected(m.connections.Active)
-		m.connections.Connection = string(msg)
+		// Subscriptions are made by HandleConnectResult on connect and by
+		// the reconnect manager after a lost connection.
+		if m.reconnect.client == nil {
+			m.connections.Connection = string(msg)
+			m.markConnected(m.connections.Active)
+			m.connections.RefreshConnectionItems()
+		}
+	} else if strings.HasPrefix(string(msg), "SSH tunnel") && m.mqttClient != nil {
+		m.syncTunnel(m.connections.Active)
 		m.connections.RefreshConnectionItems()
-		m.SubscribeActiveTopics()
 	} else if strings.HasPrefix(string(msg), "Connection lost") && m.connections.Active != "" {
 		m.connections.SetDisconnected(m.connections.Active, "")
 		m.connections.Connection = string(msg)
 		m.connections.RefreshConnectionItems()
+		if cmd := m.startReconnect(); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
 	}
-	return tea.Batch(m.updateClientStatus()...)
+	return tea.Batch(cmds...)
 }
 
 // handleMQTTMessage appends received MQTT messages to history.
 func (m *model) handleMQTTMessage(msg MQTTMessage) tea.Cmd {
 	m.ui.listeners.mqtt = false
 	oldScroll := m.rawHistoryScrollPercent()
-	m.history.Append(msg.Topic, msg.Payload, "sub", msg.Retained, fmt.Sprintf("Received on %s: %s", msg.Topic, msg.Payload))
+	hm := history.Message{Topic: msg.Topic, Payload: msg.Payload, Kind: "sub", Retained: msg.Retained, Properties: msg.Properties}
+	text := fmt.Sprintf("Received on %s: %s", msg.Topic, history.PayloadText(msg.Payload))
+	if id, rtt, ok := m.matchReply(msg); ok {
+		hm.CorrelationID, hm.RTT = id, rtt
+		text = fmt.Sprintf("Reply to request %s on %s after %s: %s", id, msg.Topic, rtt.Round(time.Millisecond), history.PayloadText(msg.Payload))
+	}
+	m.history.AppendMessage(hm, text)
+	m.mqttClient.markRendered()
 	cmds := append(m.updateClientStatus(),
 		m.startHistoryPulse(),
 		m.startHistoryScrollAnimation(oldScroll, m.rawHistoryScrollPercent()),
@@ -13,6 +13,8 @@ type API interface {
 	SortTopics()
 	RebuildActiveTopicList()
 	ToggleTopic(index int) tea.Cmd
+	CycleQoS(index int) tea.Cmd
+	SetGranted(topic string, code int)
 	RemoveTopic(index int) tea.Cmd
 	IndexForPane(pane, idx int) int
 	SubscribedItems() []list.Item
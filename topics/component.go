@@ -94,6 +94,11 @@ func (c *Component) Update(msg tea.Msg) tea.Cmd {
 			if i >= 0 && i < len(c.Items) {
 				c.TogglePublish(i)
 			}
+		case constants.KeyQ:
+			i := c.selected
+			if i >= 0 && i < len(c.Items) {
+				tcmd = c.CycleQoS(i)
+			}
 		}
 	case tea.MouseMsg:
 		if msg.Action == tea.MouseActionPress {
@@ -120,7 +125,7 @@ func (c *Component) View() string {
 	c.api.ResetElemPos()
 	c.api.SetElemPos(idTopicsSubscribed, 1)
 	c.api.SetElemPos(idTopicsUnsubscribed, 1)
-	help := ui.InfoStyle.Render("[space] toggle  [p] publish  [del] delete  [esc] back")
+	help := ui.InfoStyle.Render("[space] toggle  [p] publish  [q] qos  [del] delete  [esc] back")
 	activeView := c.list.View()
 	var left, right string
 	if c.panes.active == 0 {
@@ -293,6 +298,9 @@ func (c *Component) ToggleTopic(index int) tea.Cmd {
 	name := c.Items[index].Name
 	subscribed := !c.Items[index].Subscribed
 	c.Items[index].Subscribed = subscribed
+	if !subscribed {
+		c.Items[index].Granted = nil
+	}
 	c.SortTopics()
 	c.RebuildActiveTopicList()
 	for i, it := range c.Items {
@@ -315,6 +323,50 @@ func (c *Component) TogglePublish(index int) {
 	c.RebuildActiveTopicList()
 }
 
+// CycleQoS advances the QoS override of the topic at index through
+// default, 0, 1 and 2. Subscribed topics emit a ToggleMsg so the
+// subscription is renewed with the new QoS.
+func (c *Component) CycleQoS(index int) tea.Cmd {
+	if index < 0 || index >= len(c.Items) {
+		return nil
+	}
+	t := &c.Items[index]
+	switch {
+	case t.QoS == nil:
+		q := 0
+		t.QoS = &q
+	case *t.QoS >= 2:
+		t.QoS = nil
+	default:
+		q := *t.QoS + 1
+		t.QoS = &q
+	}
+	t.Granted = nil
+	c.SetSelected(index)
+	c.RebuildActiveTopicList()
+	if !t.Subscribed {
+		return nil
+	}
+	name := t.Name
+	return func() tea.Msg { return ToggleMsg{Topic: name, Subscribed: true} }
+}
+
+// SetGranted records the SUBACK result for topic. A negative code clears it.
+func (c *Component) SetGranted(topic string, code int) {
+	for i := range c.Items {
+		if c.Items[i].Name != topic {
+			continue
+		}
+		if code < 0 {
+			c.Items[i].Granted = nil
+		} else {
+			g := code
+			c.Items[i].Granted = &g
+		}
+		return
+	}
+}
+
 // RemoveTopic deletes the topic at index and emits an unsubscribe event.
 func (c *Component) RemoveTopic(index int) tea.Cmd {
 	if index < 0 || index >= len(c.Items) {
@@ -367,7 +419,7 @@ func (c *Component) EnsureVisible(width int) {
 		case !t.Subscribed:
 			st = ui.ChipInactive
 		}
-		chips = append(chips, st.Render(t.Name))
+		chips = append(chips, st.Render(t.ChipLabel()))
 	}
 	_, bounds := LayoutChips(chips, width)
 	if sel >= len(bounds) {
@@ -10,3 +10,37 @@ func TestToggleTopic(t *testing.T) {
 		t.Fatalf("topic not toggled: %#v", c.Items[0])
 	}
 }
+
+func TestCycleQoS(t *testing.T) {
+	c := newTestComponent()
+	c.Items = []Item{{Name: "foo"}}
+	want := []string{"q0", "q1", "q2", ""}
+	for _, w := range want {
+		if cmd := c.CycleQoS(0); cmd != nil {
+			t.Fatalf("unsubscribed topic should not resubscribe")
+		}
+		if got := c.Items[0].QoSLabel(); got != w {
+			t.Fatalf("expected label %q, got %q", w, got)
+		}
+	}
+}
+
+func TestCycleQoSResubscribes(t *testing.T) {
+	c := newTestComponent()
+	g := 0
+	c.Items = []Item{{Name: "foo", Subscribed: true, Granted: &g}}
+	cmd := c.CycleQoS(0)
+	if cmd == nil {
+		t.Fatalf("expected resubscribe command")
+	}
+	msg, ok := cmd().(ToggleMsg)
+	if !ok || msg.Topic != "foo" || !msg.Subscribed {
+		t.Fatalf("unexpected msg %#v", msg)
+	}
+	if c.Items[0].Granted != nil {
+		t.Fatalf("granted QoS should reset until the new SUBACK")
+	}
+	if c.Items[0].EffectiveQoS(2) != 0 {
+		t.Fatalf("override should win over default")
+	}
+}
@@ -15,3 +15,18 @@ func TestSnapshotRoundTripPublish(t *testing.T) {
 		t.Fatalf("publish flag not restored: %#v", c.Items)
 	}
 }
+
+func TestSnapshotRoundTripQoS(t *testing.T) {
+	c := newTestComponent()
+	one := 1
+	c.Items = []Item{{Name: "foo", QoS: &one}, {Name: "bar"}}
+	snap := c.Snapshot()
+	if snap[0].QoS == nil || *snap[0].QoS != 1 || snap[1].QoS != nil {
+		t.Fatalf("qos not saved: %#v", snap)
+	}
+	c.Items = nil
+	c.SetSnapshot(snap)
+	if c.Items[0].QoS == nil || *c.Items[0].QoS != 1 || c.Items[1].QoS != nil {
+		t.Fatalf("qos not restored: %#v", c.Items)
+	}
+}
@@ -1,5 +1,7 @@
 package topics
 
+import "fmt"
+
 const (
 	idTopicsSubscribed   = "topics-subscribed"
 	idTopicsUnsubscribed = "topics-unsubscribed"
@@ -11,6 +13,10 @@ type Item struct {
 	Name       string
 	Subscribed bool
 	Publish    bool
+	// QoS overrides the profile default QoS when set.
+	QoS *int
+	// Granted holds the QoS or failure code returned in the SUBACK.
+	Granted *int
 }
 
 func (t Item) FilterValue() string { return t.Name }
@@ -23,9 +29,53 @@ func (t Item) Description() string {
 	if t.Publish {
 		status += ", publish"
 	}
+	if t.QoS != nil {
+		status += fmt.Sprintf(", qos %d", *t.QoS)
+	}
+	if t.Granted != nil {
+		status += ", granted " + grantedText(*t.Granted)
+	}
 	return status
 }
 
+// EffectiveQoS returns the topic override or def when none is set.
+func (t Item) EffectiveQoS(def int) int {
+	if t.QoS != nil {
+		return *t.QoS
+	}
+	return def
+}
+
+// QoSLabel returns a short chip suffix: the granted QoS for subscribed topics
+// ("Q1", or "Q✗" when rejected) and the override otherwise ("q2").
+func (t Item) QoSLabel() string {
+	if t.Subscribed && t.Granted != nil {
+		if *t.Granted >= 0x80 {
+			return "Q✗"
+		}
+		return fmt.Sprintf("Q%d", *t.Granted)
+	}
+	if t.QoS != nil {
+		return fmt.Sprintf("q%d", *t.QoS)
+	}
+	return ""
+}
+
+// ChipLabel returns the topic name with its QoS suffix, if any.
+func (t Item) ChipLabel() string {
+	if l := t.QoSLabel(); l != "" {
+		return t.Name + " " + l
+	}
+	return t.Name
+}
+
+func grantedText(code int) string {
+	if code >= 0x80 {
+		return fmt.Sprintf("rejected (0x%02X)", code)
+	}
+	return fmt.Sprintf("qos %d", code)
+}
+
 type ChipBound struct {
 	XPos, YPos    int
 	Width, Height int
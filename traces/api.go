@@ -4,6 +4,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/marang/emqutiti/confirm"
 	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/history"
 )
 
 // IDList identifies the trace list focusable element.
@@ -34,6 +35,8 @@ type API interface {
 	Width() int
 	Height() int
 	NewClient(connections.Profile) (Client, error)
+	// StartExport opens the export dialog for records.
+	StartExport(title, name string, records []history.Record) tea.Cmd
 }
 
 // Store defines persistence and messaging operations for traces.
@@ -46,4 +49,6 @@ type Store interface {
 	HasData(profile, key string) (bool, error)
 	ClearData(profile, key string) error
 	LoadCounts(profile, key string, topics []string) (map[string]int, error)
+	// AddMessages stores messages recorded elsewhere under the trace key.
+	AddMessages(profile, key string, msgs []TracerMessage) error
 }
@@ -20,6 +20,9 @@ func (FileStore) HasData(profile, key string) (bool, error) {
 func (FileStore) ClearData(profile, key string) error {
 	return tracerClearData(profile, key)
 }
+func (FileStore) AddMessages(profile, key string, msgs []TracerMessage) error {
+	return tracerAddAll(profile, key, msgs)
+}
 func (FileStore) LoadCounts(profile, key string, topics []string) (map[string]int, error) {
 	return tracerLoadCounts(profile, key, topics)
 }
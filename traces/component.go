@@ -171,10 +171,13 @@ func NewComponent(api API, ts State, store Store) *Component {
 		constants.KeyV: func(tea.KeyMsg) tea.Cmd {
 			i := c.list.Index()
 			if i >= 0 && i < len(c.items) {
-				c.loadTraceMessages(i)
+				return c.loadTraceMessages(i)
 			}
 			return nil
 		},
+		constants.KeyE: func(tea.KeyMsg) tea.Cmd {
+			return c.exportTrace(c.list.Index())
+		},
 		constants.KeyDelete: func(tea.KeyMsg) tea.Cmd {
 			i := c.list.Index()
 			if i >= 0 && i < len(c.items) {
@@ -257,6 +260,7 @@ func (t *Component) startFilter() tea.Cmd {
 		topics = append(topics, t.items[idx].cfg.Topics...)
 	}
 	var topic, payload string
+	var props map[string]string
 	var start, end time.Time
 	if t.FilterQuery() != "" {
 		ts, s, e, p := history.ParseQuery(t.FilterQuery())
@@ -264,11 +268,12 @@ func (t *Component) startFilter() tea.Cmd {
 			topic = ts[0]
 		}
 		start, end, payload = s, e, p
+		props = history.ParseProperties(t.FilterQuery())
 	} else {
 		end = time.Now()
 		start = end.Add(-time.Hour)
 	}
-	hf := history.NewFilterForm(topics, topic, payload, start, end, t.ShowArchived())
+	hf := history.NewFilterForm(topics, topic, payload, props, start, end, t.ShowArchived())
 	t.SetFilterForm(&hf)
 	return t.hmodel.SetModeTraceFilter()
 }
@@ -381,6 +386,7 @@ func (t *Component) UpdateView(msg tea.Msg) tea.Cmd {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case constants.KeyEsc:
+			t.Component.StopWatch()
 			return t.api.SetModeTracer()
 		case constants.KeyCtrlD:
 			return tea.Quit
@@ -396,6 +402,8 @@ func (t *Component) UpdateView(msg tea.Msg) tea.Cmd {
 			return nil
 		case constants.KeySlash:
 			return t.startFilter()
+		case constants.KeyE:
+			return t.exportView()
 		}
 	}
 	return t.Component.Update(msg)
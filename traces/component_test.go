@@ -2,13 +2,19 @@ package traces
 
 import (
 	"testing"
+	"time"
 
+	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	connections "github.com/marang/emqutiti/connections"
 	"github.com/marang/emqutiti/constants"
+	"github.com/marang/emqutiti/history"
 )
 
-type testAPI struct{ mode constants.AppMode }
+type testAPI struct {
+	mode     constants.AppMode
+	exported []history.Record
+}
 
 func (t *testAPI) StartConfirm(string, string, func() tea.Cmd, func() tea.Cmd, func()) {}
 func (t *testAPI) SetModeClient() tea.Cmd                                              { t.mode = constants.ModeClient; return nil }
@@ -30,6 +36,7 @@ func (t *testAPI) SetTraceHeight(int)
 func (t *testAPI) Width() int                                                          { return 80 }
 func (t *testAPI) Height() int                                                         { return 24 }
 func (t *testAPI) NewClient(connections.Profile) (Client, error)                       { return nil, nil }
+func (t *testAPI) StartExport(_, _ string, r []history.Record) tea.Cmd                 { t.exported = r; return nil }
 
 type noopStore struct{}
 
@@ -41,6 +48,7 @@ func (noopStore) Messages(string, string) ([]TracerMessage, error)            {
 func (noopStore) HasData(string, string) (bool, error)                        { return false, nil }
 func (noopStore) ClearData(string, string) error                              { return nil }
 func (noopStore) LoadCounts(string, string, []string) (map[string]int, error) { return nil, nil }
+func (noopStore) AddMessages(string, string, []TracerMessage) error           { return nil }
 
 func TestEscSetsClientMode(t *testing.T) {
 	t.Setenv("HOME", t.TempDir())
@@ -51,3 +59,30 @@ func TestEscSetsClientMode(t *testing.T) {
 		t.Fatalf("expected mode %v, got %v", constants.ModeClient, api.mode)
 	}
 }
+
+type exportStore struct {
+	noopStore
+	msgs []TracerMessage
+}
+
+func (s exportStore) Messages(string, string) ([]TracerMessage, error) { return s.msgs, nil }
+
+func TestExportKeyExportsWholeTrace(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	api := &testAPI{}
+	ts := time.Unix(1700000000, 0)
+	st := exportStore{msgs: []TracerMessage{
+		{Timestamp: ts, Topic: "a", Payload: []byte("1"), Kind: "sub"},
+		{Timestamp: ts.Add(time.Second), Topic: "b", Payload: []byte{0xff}, Kind: "sub"},
+	}}
+	c := NewComponent(api, Init(), st)
+	c.items = []*traceItem{{key: "run1", cfg: TracerConfig{Profile: "p", Key: "run1"}}}
+	c.list.SetItems([]list.Item{c.items[0]})
+	c.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	if len(api.exported) != 2 {
+		t.Fatalf("expected both messages exported, got %+v", api.exported)
+	}
+	if r := api.exported[1]; r.Topic != "b" || r.Encoding != "base64" || r.Kind != "sub" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+}
@@ -2,67 +2,80 @@ package traces
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
+	broker "github.com/marang/emqutiti/broker"
+	"github.com/marang/emqutiti/clientopts"
 	connections "github.com/marang/emqutiti/connections"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// Exit statuses of a headless trace, for scripts and service managers.
+const (
+	ExitNoMessages      = 2
+	ExitSubscribeFailed = 3
+	ExitConnectionLost  = 4
+)
+
+// ExitError is a headless trace failure with its process exit status.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// ExitCode returns the process exit status.
+func (e *ExitError) ExitCode() int { return e.Code }
+
 // mqttClient wraps the MQTT connection for the tracer.
-type mqttClient struct{ client mqtt.Client }
+type mqttClient struct {
+	client mqtt.Client
+	opts   *clientopts.Options
+	// lost receives the error when the connection drops and the profile
+	// does not reconnect automatically.
+	lost chan error
+}
 
 // newMQTTClient establishes an MQTT connection using the provided profile.
+// Progress is logged.
 func newMQTTClient(p connections.Profile) (*mqttClient, error) {
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(p.BrokerURL())
-	cid := p.ClientID
-	if p.RandomIDSuffix {
-		cid = fmt.Sprintf("%s-%d", cid, time.Now().UnixNano())
-	}
-	opts.SetClientID(cid)
-	opts.SetUsername(p.Username)
-	if p.Password != "" {
-		opts.SetPassword(p.Password)
-	}
-	if p.MQTTVersion != "" {
-		ver, err := strconv.Atoi(p.MQTTVersion)
-		if err != nil {
-			return nil, fmt.Errorf("invalid MQTT version %q: %w", p.MQTTVersion, err)
-		}
-		if ver != 0 {
-			opts.SetProtocolVersion(uint(ver))
+	if p.Preset == connections.PresetSandbox {
+		if err := broker.EnsureLocal(net.JoinHostPort(p.Host, strconv.Itoa(p.Port)), p.Username, p.Password); err != nil {
+			return nil, err
 		}
 	}
-	if p.ConnectTimeout > 0 {
-		opts.SetConnectTimeout(time.Duration(p.ConnectTimeout) * time.Second)
-	}
-	if p.KeepAlive > 0 {
-		opts.SetKeepAlive(time.Duration(p.KeepAlive) * time.Second)
-	}
-	opts.SetAutoReconnect(p.AutoReconnect)
-	if p.CleanStart {
-		opts.SetCleanSession(true)
-	} else {
-		opts.SetCleanSession(false)
-	}
-	if p.LastWillEnabled && p.LastWillTopic != "" {
-		opts.SetWill(p.LastWillTopic, p.LastWillPayload, byte(p.LastWillQos), p.LastWillRetain)
-	}
-	if p.SSL {
-		opts.SetTLSConfig(&tls.Config{InsecureSkipVerify: p.SkipTLSVerify})
+	o, err := clientopts.Build(p, func(s string) { log.Print(s) })
+	if err != nil {
+		return nil, err
 	}
-	client := mqtt.NewClient(opts)
+	lost := make(chan error, 1)
+	o.Options.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		if p.AutoReconnect {
+			log.Printf("Connection lost, reconnecting: %v", err)
+			return
+		}
+		select {
+		case lost <- err:
+		default:
+		}
+	})
+	client := o.NewClient()
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		o.Close()
 		return nil, fmt.Errorf("failed to connect: %w", token.Error())
 	}
-	return &mqttClient{client: client}, nil
+	return &mqttClient{client: client, opts: o, lost: lost}, nil
 }
 
 // Subscribe wraps the underlying client's Subscribe call.
@@ -84,10 +97,19 @@ func (m *mqttClient) Disconnect() {
 	if m.client != nil && m.client.IsConnected() {
 		m.client.Disconnect(250)
 	}
+	m.opts.Close()
 }
 
 // Run executes the tracer headlessly using configuration from config.toml.
 func Run(ctx context.Context, key, topics, profileName, startStr, endStr string) error {
+	return RunWithOutput(ctx, key, topics, profileName, startStr, endStr, Output{})
+}
+
+// RunWithOutput is Run with messages also written to the sinks of out. It
+// ends when the trace window closes, on SIGINT or SIGTERM, or when ctx is
+// done, and returns an ExitError when no message arrived, a subscription
+// failed or the broker connection was lost.
+func RunWithOutput(ctx context.Context, key, topics, profileName, startStr, endStr string, out Output) error {
 	if key == "" || topics == "" {
 		return fmt.Errorf("-trace and -topics are required")
 	}
@@ -105,6 +127,11 @@ func Run(ctx context.Context, key, topics, profileName, startStr, endStr string)
 			return fmt.Errorf("invalid end time: %w", err)
 		}
 	}
+	sinks, err := out.sinks()
+	if err != nil {
+		return err
+	}
+	defer closeSinks(sinks)
 	p, err := connections.LoadProfile(profileName, "")
 	if err != nil {
 		return err
@@ -120,11 +147,14 @@ func Run(ctx context.Context, key, topics, profileName, startStr, endStr string)
 	for i := range tlist {
 		tlist[i] = strings.TrimSpace(tlist[i])
 	}
-	cfg := TracerConfig{Profile: p.Name, Topics: tlist, Start: start, End: end, Key: key}
+	cfg := TracerConfig{Profile: p.Name, Topics: tlist, Start: start, End: end, Key: key, QoS: p.QoS}
 	if err := tracerClearData(cfg.Profile, cfg.Key); err != nil {
 		return fmt.Errorf("clear data: %w", err)
 	}
 	tr := newTracer(cfg, client)
+	if len(sinks) > 0 {
+		tr.sinks = &sinkSet{sinks: sinks}
+	}
 	if err := tr.Start(); err != nil {
 		return fmt.Errorf("trace start: %w", err)
 	}
@@ -133,21 +163,56 @@ func Run(ctx context.Context, key, topics, profileName, startStr, endStr string)
 	}
 
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 	defer signal.Stop(sig)
-	for tr.Planned() || tr.Running() {
+	var progress <-chan time.Time
+	if out.ProgressEvery > 0 && out.Progress != nil {
+		ticker := time.NewTicker(out.ProgressEvery)
+		defer ticker.Stop()
+		progress = ticker.C
+	}
+	var runErr error
+	// The tracer reports ready only after subscribing, so wait for it
+	// or for its first error before checking whether it still runs.
+	for runErr == nil && (tr.Planned() || tr.Running() || tr.starting()) {
 		select {
 		case <-sig:
 			tr.Stop()
 		case <-ctx.Done():
+			// --timeout ends a trace like its end time.
 			tr.Stop()
-			return ctx.Err()
+		case err := <-tr.report:
+			var exit *ExitError
+			if errors.As(err, &exit) {
+				runErr = err
+			} else {
+				log.Println(err)
+			}
+		case err := <-client.lost:
+			runErr = &ExitError{Code: ExitConnectionLost, Err: fmt.Errorf("connection lost: %w", err)}
+		case now := <-progress:
+			if err := writeProgress(out.Progress, key, now, tr.Counts()); err != nil {
+				log.Printf("progress: %v", err)
+			}
 		case <-time.After(500 * time.Millisecond):
 		}
 	}
+	tr.Stop()
 
-	for t, c := range tr.Counts() {
-		log.Printf("%s: %d", t, c)
+	counts := tr.Counts()
+	total := 0
+	for _, t := range sortedTopics(counts) {
+		log.Printf("%s: %d", t, counts[t])
+		total += counts[t]
+	}
+	if progress != nil {
+		writeProgress(out.Progress, key, time.Now(), counts)
+	}
+	if runErr != nil {
+		return runErr
+	}
+	if total == 0 {
+		return &ExitError{Code: ExitNoMessages, Err: errors.New("trace received no messages")}
 	}
 	return nil
 }
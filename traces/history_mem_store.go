@@ -1,51 +1,35 @@
 package traces
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/proxy"
 )
 
+// memStore holds the messages of one trace for the trace view.
 type memStore struct {
+	mu   sync.RWMutex
 	msgs []history.Message
+	// profile and key locate the trace in the proxy for Watch.
+	profile, key string
 }
 
-func newMemStore(msgs []history.Message) *memStore {
-	return &memStore{msgs: msgs}
+func newMemStore(profile, key string, msgs []history.Message) *memStore {
+	return &memStore{msgs: msgs, profile: profile, key: key}
 }
 
 func (m *memStore) Append(history.Message) error { return nil }
 
-func (m *memStore) Search(archived bool, topics []string, start, end time.Time, payload string) []history.Message {
-	var out []history.Message
-	topicSet := map[string]struct{}{}
-	for _, t := range topics {
-		if t != "" {
-			topicSet[t] = struct{}{}
-		}
-	}
-	for _, msg := range m.msgs {
-		if msg.Archived != archived {
-			continue
-		}
-		if len(topicSet) > 0 {
-			if _, ok := topicSet[msg.Topic]; !ok {
-				continue
-			}
-		}
-		if !start.IsZero() && msg.Timestamp.Before(start) {
-			continue
-		}
-		if !end.IsZero() && msg.Timestamp.After(end) {
-			continue
-		}
-		if payload != "" && !strings.Contains(msg.Payload, payload) {
-			continue
-		}
-		out = append(out, msg)
-	}
-	return out
+func (m *memStore) Query(q history.Query) (history.Page, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return q.Select(m.msgs), nil
 }
 
 func (m *memStore) Delete(string) error { return nil }
@@ -53,6 +37,8 @@ func (m *memStore) Delete(string) error { return nil }
 func (m *memStore) Archive(string) error { return nil }
 
 func (m *memStore) Count(archived bool) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	c := 0
 	for _, msg := range m.msgs {
 		if msg.Archived == archived {
@@ -64,4 +50,69 @@ func (m *memStore) Count(archived bool) int {
 
 func (m *memStore) Close() error { return nil }
 
+// Watch reports messages recorded into the trace, by this or another
+// emqutiti instance, and deletions of its data. Keys are given in the
+// history format "<topic>/<timestamp>".
+func (m *memStore) Watch(ctx context.Context) (<-chan history.Change, error) {
+	a := addr()
+	if m.key == "" || a == "" {
+		return nil, nil
+	}
+	cl, conn, err := proxy.NewClient(a)
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("trace/%s/", m.key)
+	stream, err := cl.Watch(ctx, &proxy.WatchRequest{Profile: m.profile, Bucket: "traces", Prefix: prefix})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ch := make(chan history.Change, 64)
+	go func() {
+		defer conn.Close()
+		defer close(ch)
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			c := history.Change{Key: strings.TrimPrefix(ev.GetKey(), prefix)}
+			if ev.GetOp() == proxy.WatchEvent_PUT {
+				var tm TracerMessage
+				if err := json.Unmarshal(ev.GetValue(), &tm); err != nil {
+					continue
+				}
+				c.Message = &history.Message{Timestamp: tm.Timestamp, Topic: tm.Topic, Payload: tm.Payload, Kind: tm.Kind, Retained: tm.Retained, Properties: tm.Properties}
+			}
+			m.apply(c)
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// apply keeps the messages in step with a watched change so filters see
+// it.
+func (m *memStore) apply(c history.Change) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.msgs, func(msg history.Message) bool {
+		return fmt.Sprintf("%s/%020d", msg.Topic, msg.Timestamp.UnixNano()) == c.Key
+	})
+	switch {
+	case c.Message == nil && i >= 0:
+		m.msgs = slices.Delete(m.msgs, i, i+1)
+	case c.Message != nil && i >= 0:
+		m.msgs[i] = *c.Message
+	case c.Message != nil:
+		m.msgs = append(m.msgs, *c.Message)
+	}
+}
+
 var _ history.Store = (*memStore)(nil)
+var _ history.Watcher = (*memStore)(nil)
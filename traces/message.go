@@ -1,12 +1,48 @@
 package traces
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/marang/emqutiti/history"
+	"github.com/marang/emqutiti/properties"
+)
 
 // Message holds a timestamped MQTT message used for traces.
 type TracerMessage struct {
 	Timestamp time.Time
 	Topic     string
-	Payload   string
+	Payload   []byte
 	Kind      string
 	Retained  bool
+	// Properties holds MQTT 5 publish properties, if any.
+	Properties *properties.Properties `json:",omitempty"`
+	// Schema is the payload encoding the message was stored with.
+	Schema int `json:",omitempty"`
+}
+
+// MarshalJSON stores the payload as base64 and stamps the current schema.
+func (m TracerMessage) MarshalJSON() ([]byte, error) {
+	type alias TracerMessage
+	a := alias(m)
+	a.Schema = history.PayloadSchema
+	return json.Marshal(a)
+}
+
+// UnmarshalJSON decodes trace messages of any payload schema.
+func (m *TracerMessage) UnmarshalJSON(b []byte) error {
+	type alias TracerMessage
+	aux := struct {
+		*alias
+		Payload json.RawMessage
+	}{alias: (*alias)(m)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	p, err := history.DecodePayload(aux.Payload, m.Schema)
+	if err != nil {
+		return err
+	}
+	m.Payload = p
+	return nil
 }
@@ -28,7 +28,9 @@ func (t *Component) forceStartTrace(index int) {
 		t.api.LogHistory("", err.Error(), "log", false, err.Error())
 		return
 	}
-	tr := newTracer(item.cfg, client)
+	cfg := item.cfg
+	cfg.QoS = p.QoS
+	tr := newTracer(cfg, client)
 	if err := tr.Start(); err != nil {
 		t.api.LogHistory("", err.Error(), "log", false, err.Error())
 		client.Disconnect()
@@ -109,29 +111,73 @@ func (t *Component) SavePlannedTraces() {
 }
 
 // loadTraceMessages loads messages for the trace at index and shows them.
-func (t *Component) loadTraceMessages(index int) {
+// The returned command follows messages recorded while the view is open.
+func (t *Component) loadTraceMessages(index int) tea.Cmd {
 	if index < 0 || index >= len(t.items) {
-		return
+		return nil
 	}
 	it := t.items[index]
 	msgs, err := tracerMessages(it.cfg.Profile, it.key)
 	if err != nil {
 		t.api.LogHistory("", err.Error(), "log", false, err.Error())
-		return
+		return nil
 	}
 	histItems := make([]history.Item, len(msgs))
 	listItems := make([]list.Item, len(msgs))
 	hmsgs := make([]history.Message, len(msgs))
 	for i, mmsg := range msgs {
-		hi := history.Item{Timestamp: mmsg.Timestamp, Topic: mmsg.Topic, Payload: mmsg.Payload, Kind: mmsg.Kind, Retained: mmsg.Retained}
+		hi := history.Item{Timestamp: mmsg.Timestamp, Topic: mmsg.Topic, Payload: string(mmsg.Payload), Kind: mmsg.Kind, Retained: mmsg.Retained, Properties: mmsg.Properties}
 		histItems[i] = hi
 		listItems[i] = hi
-		hmsgs[i] = history.Message{Timestamp: mmsg.Timestamp, Topic: mmsg.Topic, Payload: mmsg.Payload, Kind: mmsg.Kind, Archived: false, Retained: mmsg.Retained}
+		hmsgs[i] = history.Message{Timestamp: mmsg.Timestamp, Topic: mmsg.Topic, Payload: mmsg.Payload, Kind: mmsg.Kind, Archived: false, Retained: mmsg.Retained, Properties: mmsg.Properties}
 	}
 	t.Component.SetItems(histItems)
 	t.Component.List().SetItems(listItems)
-	t.Component.SetStore(newMemStore(hmsgs))
+	t.Component.SetStore(newMemStore(it.cfg.Profile, it.key, hmsgs))
 	t.Component.List().SetSize(t.api.Width()-4, t.api.TraceHeight())
 	t.viewKey = it.key
 	_ = t.api.SetModeViewTrace()
+	return t.Component.Watch()
+}
+
+// exportTrace opens the export dialog for all messages of the trace at
+// index.
+func (t *Component) exportTrace(index int) tea.Cmd {
+	if index < 0 || index >= len(t.items) {
+		return nil
+	}
+	it := t.items[index]
+	msgs, err := t.store.Messages(it.cfg.Profile, it.key)
+	if err != nil {
+		t.api.LogHistory("", err.Error(), "log", false, err.Error())
+		return nil
+	}
+	records := make([]history.Record, len(msgs))
+	for i, m := range msgs {
+		records[i] = record(m)
+		records[i].Kind = m.Kind
+	}
+	return t.api.StartExport(fmt.Sprintf("Trace %s", it.key), it.key, records)
+}
+
+// exportView opens the export dialog for the selected messages of the
+// trace being viewed, or for all messages matching its filter.
+func (t *Component) exportView() tea.Cmd {
+	var selected, all []history.Record
+	for _, it := range t.Component.Items() {
+		r := history.ItemRecord(it)
+		if it.IsSelected != nil && *it.IsSelected {
+			selected = append(selected, r)
+		}
+		all = append(all, r)
+	}
+	records := all
+	if len(selected) > 0 {
+		records = selected
+	}
+	title := fmt.Sprintf("Trace %s", t.viewKey)
+	if q := t.FilterQuery(); q != "" {
+		title += " (" + q + ")"
+	}
+	return t.api.StartExport(title, t.viewKey, records)
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/marang/emqutiti/mqttclient"
 	"github.com/marang/emqutiti/proxy"
 )
 
@@ -17,6 +18,8 @@ type TracerConfig struct {
 	Start   time.Time
 	End     time.Time
 	Key     string
+	// QoS is the subscription QoS, taken from the trace's profile.
+	QoS int
 }
 
 // Client abstracts the MQTT client used by the tracer.
@@ -37,6 +40,8 @@ type Tracer struct {
 	cancel  context.CancelFunc
 	done    chan struct{}
 	report  chan error
+	// sinks receive stored messages of headless traces; nil otherwise.
+	sinks *sinkSet
 }
 
 // newTracer creates a new Tracer with the given config.
@@ -117,7 +122,7 @@ func (t *Tracer) Start() error {
 		}
 
 		for _, topic := range t.cfg.Topics {
-			if err := client.Subscribe(topic, 0, func(_ mqtt.Client, m mqtt.Message) {
+			if err := client.Subscribe(topic, byte(t.cfg.QoS), func(_ mqtt.Client, m mqtt.Message) {
 				ts := time.Now()
 				if !t.cfg.End.IsZero() && ts.After(t.cfg.End) {
 					return
@@ -125,10 +130,16 @@ func (t *Tracer) Start() error {
 				if ts.Before(t.cfg.Start) {
 					return
 				}
-				if err := tracerAddClient(cl, t.cfg.Profile, t.cfg.Key, TracerMessage{Timestamp: ts, Topic: m.Topic(), Payload: string(m.Payload()), Kind: "trace", Retained: m.Retained()}); err != nil {
+				msg := TracerMessage{Timestamp: ts, Topic: m.Topic(), Payload: m.Payload(), Kind: "trace", Retained: m.Retained(), Properties: mqttclient.MessageProperties(m)}
+				if err := tracerAddClient(cl, t.cfg.Profile, t.cfg.Key, msg); err != nil {
 					t.reportErr(fmt.Errorf("tracerAdd: %w", err))
 					return
 				}
+				if t.sinks != nil {
+					if err := t.sinks.write(msg); err != nil {
+						t.reportErr(fmt.Errorf("trace output: %w", err))
+					}
+				}
 				t.mu.Lock()
 				for _, sub := range t.cfg.Topics {
 					if tracerMatch(sub, m.Topic()) {
@@ -137,7 +148,7 @@ func (t *Tracer) Start() error {
 				}
 				t.mu.Unlock()
 			}); err != nil {
-				fmt.Printf("subscribe %s: %v\n", topic, err)
+				t.reportErr(&ExitError{Code: ExitSubscribeFailed, Err: fmt.Errorf("subscribe %s: %w", topic, err)})
 				return
 			}
 		}
@@ -185,6 +196,14 @@ func (t *Tracer) Running() bool {
 	return t.running && t.ready && time.Now().After(t.cfg.Start) && (t.cfg.End.IsZero() || time.Now().Before(t.cfg.End))
 }
 
+// starting reports whether the trace waits for its start time or is still
+// subscribing.
+func (t *Tracer) starting() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.running && !t.ready
+}
+
 // Planned reports whether the trace start time is in the future.
 func (t *Tracer) Planned() bool { return time.Now().Before(t.cfg.Start) }
 
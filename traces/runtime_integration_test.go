@@ -1,17 +1,20 @@
 package traces
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net"
-	"strconv"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	connections "github.com/marang/emqutiti/connections"
 	"github.com/marang/emqutiti/proxy"
-	"github.com/mochi-co/mqtt/server"
-	"github.com/mochi-co/mqtt/server/listeners"
 )
 
 func TestTraceWithLiveBroker(t *testing.T) {
@@ -37,42 +40,17 @@ func TestTraceWithLiveBroker(t *testing.T) {
 	if err != nil {
 		t.Fatalf("reserve port: %v", err)
 	}
-	addr := ln.Addr().String()
+	port := ln.Addr().(*net.TCPAddr).Port
 	if err := ln.Close(); err != nil {
 		t.Fatalf("close temp listener: %v", err)
 	}
 
-	broker := server.New()
-	tcp := listeners.NewTCP("test", addr)
-	if err := broker.AddListener(tcp, nil); err != nil {
-		t.Fatalf("add listener: %v", err)
-	}
-	if err := broker.Serve(); err != nil {
-		t.Fatalf("serve broker: %v", err)
-	}
-	t.Cleanup(func() {
-		if err := broker.Close(); err != nil {
-			t.Logf("close broker: %v", err)
-		}
-	})
-
-	host, portStr, err := net.SplitHostPort(addr)
-	if err != nil {
-		t.Fatalf("split host port: %v", err)
-	}
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		t.Fatalf("parse port: %v", err)
-	}
-
-	profile := connections.Profile{
-		Name:       "integration",
-		Schema:     "tcp",
-		Host:       host,
-		Port:       port,
-		ClientID:   fmt.Sprintf("trace-client-%d", time.Now().UnixNano()),
-		CleanStart: true,
-	}
+	// The sandbox preset starts the embedded broker on connect.
+	profile := connections.SandboxProfile()
+	profile.Name = "integration"
+	profile.Port = port
+	profile.ClientID = fmt.Sprintf("trace-client-%d", time.Now().UnixNano())
+	profile.RandomIDSuffix = false
 
 	client, err := newMQTTClient(profile)
 	if err != nil {
@@ -146,7 +124,7 @@ func TestTraceWithLiveBroker(t *testing.T) {
 	}
 	found := false
 	for _, m := range msgs {
-		if m.Topic == topic && m.Payload == payload {
+		if m.Topic == topic && string(m.Payload) == payload {
 			found = true
 			break
 		}
@@ -155,3 +133,73 @@ func TestTraceWithLiveBroker(t *testing.T) {
 		t.Fatalf("expected payload %q on topic %q", payload, topic)
 	}
 }
+
+func TestRunWithOutputStreamsAndExitCodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	prevProxy := proxyAddr
+	px, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	SetProxyAddr(px.Addr())
+	t.Cleanup(func() {
+		px.Stop()
+		SetProxyAddr(prevProxy)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	cfgPath, err := connections.DefaultUserConfigFile()
+	if err != nil {
+		t.Fatalf("config path: %v", err)
+	}
+	os.MkdirAll(filepath.Dir(cfgPath), 0o755)
+	conf := fmt.Sprintf("[[profiles]]\nname = \"sandbox\"\npreset = \"sandbox\"\nschema = \"tcp\"\nhost = \"127.0.0.1\"\nport = %d\nclient_id = \"tracer\"\nrandom_id_suffix = true\n", port)
+	if err := os.WriteFile(cfgPath, []byte(conf), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	// A retained message is delivered as soon as the trace subscribes.
+	pub, err := newMQTTClient(connections.Profile{Name: "pub", Preset: connections.PresetSandbox, Schema: "tcp", Host: "127.0.0.1", Port: port, ClientID: "pub"})
+	if err != nil {
+		t.Fatalf("publisher: %v", err)
+	}
+	if tok := pub.client.Publish("plant/line1", 1, true, "42"); tok.Wait() && tok.Error() != nil {
+		t.Fatalf("publish: %v", tok.Error())
+	}
+	pub.Disconnect()
+
+	var stdout, progress bytes.Buffer
+	file := filepath.Join(dir, "out.ndjson")
+	out := Output{Format: "csv", Stdout: &stdout, File: file, ProgressEvery: 50 * time.Millisecond, Progress: &progress}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := RunWithOutput(ctx, "run1", "plant/#", "sandbox", "", "", out); err != nil {
+		t.Fatalf("RunWithOutput: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "plant/line1,0,true,42,") {
+		t.Fatalf("expected CSV row on stdout, got %q", stdout.String())
+	}
+	if data, _ := os.ReadFile(file); !strings.Contains(string(data), `"payload":"42"`) {
+		t.Fatalf("expected NDJSON in file, got %q", data)
+	}
+	if !strings.Contains(progress.String(), `"topics":{"plant/#":1}`) {
+		t.Fatalf("expected progress with counts, got %q", progress.String())
+	}
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel2()
+	err = RunWithOutput(ctx2, "run2", "empty/#", "sandbox", "", "", Output{})
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != ExitNoMessages {
+		t.Fatalf("expected no-messages exit, got %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/marang/emqutiti/properties"
 	"github.com/marang/emqutiti/proxy"
 )
 
@@ -25,17 +26,19 @@ func (f fakeMessage) Ack()              {}
 
 type fakeClient struct {
 	subs  map[string]mqtt.MessageHandler
+	qos   map[string]byte
 	subCh chan struct{}
 	wg    *sync.WaitGroup
 	mu    sync.RWMutex
 }
 
 func newFakeClient() *fakeClient {
-	return &fakeClient{subs: make(map[string]mqtt.MessageHandler), subCh: make(chan struct{}, 1)}
+	return &fakeClient{subs: make(map[string]mqtt.MessageHandler), qos: make(map[string]byte), subCh: make(chan struct{}, 1)}
 }
 
 func (f *fakeClient) Subscribe(topic string, qos byte, cb mqtt.MessageHandler) error {
 	f.mu.Lock()
+	f.qos[topic] = qos
 	f.subs[topic] = func(c mqtt.Client, m mqtt.Message) {
 		cb(c, m)
 		if f.wg != nil {
@@ -112,3 +115,90 @@ func TestTraceStartAndStore(t *testing.T) {
 		}
 	}
 }
+
+func TestTraceSubscribesWithConfigQoS(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("HOME", dir)
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	SetProxyAddr(p.Addr())
+	t.Cleanup(p.Stop)
+
+	cfg := TracerConfig{
+		Profile: "test",
+		Topics:  []string{"a"},
+		Start:   time.Now().Add(-time.Millisecond),
+		Key:     "qos",
+		QoS:     2,
+	}
+	fc := newFakeClient()
+	tr := newTracer(cfg, fc)
+	if err := tr.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	<-fc.subCh
+	tr.Stop()
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	if fc.qos["a"] != 2 {
+		t.Fatalf("expected QoS 2 subscription, got %d", fc.qos["a"])
+	}
+}
+
+// propsMessage is a fakeMessage carrying MQTT 5 properties.
+type propsMessage struct {
+	fakeMessage
+	props *properties.Properties
+}
+
+func (p propsMessage) Properties() *properties.Properties { return p.props }
+
+func TestTraceStoresProperties(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("HOME", dir)
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	SetProxyAddr(p.Addr())
+	t.Cleanup(p.Stop)
+
+	cfg := TracerConfig{
+		Profile: "test",
+		Topics:  []string{"a"},
+		Start:   time.Now().Add(-time.Millisecond),
+		End:     time.Now().Add(200 * time.Millisecond),
+		Key:     "k1",
+	}
+	fc := newFakeClient()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	fc.wg = &wg
+	tr := newTracer(cfg, fc)
+	if err := tr.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	<-fc.subCh
+	done := tr.done
+	fc.mu.RLock()
+	cb := fc.subs["a"]
+	fc.mu.RUnlock()
+	props := &properties.Properties{ContentType: "text/plain", User: []properties.UserProperty{{Key: "tenant", Value: "abc"}}}
+	cb(nil, propsMessage{fakeMessage{topic: "a", payload: []byte("one")}, props})
+	wg.Wait()
+	tr.Stop()
+	<-done
+
+	msgs, err := tracerMessages("test", "k1")
+	if err != nil {
+		t.Fatalf("messages: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Properties == nil {
+		t.Fatalf("expected one message with properties, got %+v", msgs)
+	}
+	if v, ok := msgs[0].Properties.Lookup("tenant"); !ok || v != "abc" || msgs[0].Properties.ContentType != "text/plain" {
+		t.Fatalf("properties not persisted: %+v", msgs[0].Properties)
+	}
+}
@@ -0,0 +1,233 @@
+package traces
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marang/emqutiti/history"
+)
+
+// Output selects where a headless trace writes messages besides the
+// store, and how it reports progress.
+type Output struct {
+	// Format writes every message to Stdout as "ndjson" or "csv". Empty
+	// writes nothing.
+	Format string
+	Stdout io.Writer
+	// File appends messages to a file, as NDJSON unless FileFormat is
+	// "csv". Once it reaches FileMaxBytes it is renamed to File.1 and a new
+	// one is started; FileKeep old files are kept.
+	File         string
+	FileFormat   string
+	FileMaxBytes int64
+	FileKeep     int
+	// ProgressEvery writes a JSON line with per-topic counts to Progress
+	// at this interval.
+	ProgressEvery time.Duration
+	Progress      io.Writer
+}
+
+// Sink receives the messages of a running trace.
+type Sink interface {
+	Write(TracerMessage) error
+	Close() error
+}
+
+// record converts a trace message for the output formats.
+func record(m TracerMessage) history.Record {
+	return history.NewRecord(m.Timestamp, m.Topic, m.Payload, 0, m.Retained, m.Properties)
+}
+
+// sinks opens the sinks configured in o.
+func (o Output) sinks() ([]Sink, error) {
+	var out []Sink
+	if o.Format != "" {
+		w := o.Stdout
+		if w == nil {
+			w = os.Stdout
+		}
+		rw, err := history.NewRecordWriter(w, o.Format, nil)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, &writerSink{rw: rw})
+	}
+	if o.File != "" {
+		format := o.FileFormat
+		if format == "" {
+			format = history.FormatNDJSON
+		}
+		fs, err := newFileSink(o.File, format, o.FileMaxBytes, o.FileKeep)
+		if err != nil {
+			closeSinks(out)
+			return nil, err
+		}
+		out = append(out, fs)
+	}
+	return out, nil
+}
+
+func closeSinks(sinks []Sink) error {
+	var first error
+	for _, s := range sinks {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// writerSink writes records to a stream and flushes after each message so
+// pipes see them immediately.
+type writerSink struct{ rw history.RecordWriter }
+
+func (s *writerSink) Write(m TracerMessage) error {
+	if err := s.rw.Write(record(m)); err != nil {
+		return err
+	}
+	return s.rw.Flush()
+}
+
+func (s *writerSink) Close() error { return s.rw.Flush() }
+
+// fileSink writes records to a size-rotated file.
+type fileSink struct {
+	path   string
+	format string
+	max    int64
+	keep   int
+
+	f    *os.File
+	size int64
+	rw   history.RecordWriter
+}
+
+func newFileSink(path, format string, max int64, keep int) (*fileSink, error) {
+	s := &fileSink{path: path, format: format, max: max, keep: keep}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open appends to the current file. CSV headers are only written to new
+// files.
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("trace file: %w", err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("trace file: %w", err)
+	}
+	s.f, s.size = f, st.Size()
+	s.rw, err = history.NewRecordWriter(countingWriter{s}, s.format, nil)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if cw, ok := s.rw.(interface{ SkipHeader() }); ok && s.size > 0 {
+		cw.SkipHeader()
+	}
+	return nil
+}
+
+// countingWriter tracks the size of the current file.
+type countingWriter struct{ s *fileSink }
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.s.f.Write(p)
+	c.s.size += int64(n)
+	return n, err
+}
+
+func (s *fileSink) Write(m TracerMessage) error {
+	if s.max > 0 && s.size >= s.max {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if err := s.rw.Write(record(m)); err != nil {
+		return err
+	}
+	return s.rw.Flush()
+}
+
+// rotate shifts path.N to path.N+1, drops the files beyond keep and
+// starts a new file.
+func (s *fileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("trace file: %w", err)
+	}
+	keep := s.keep
+	if keep < 1 {
+		keep = 1
+	}
+	os.Remove(fmt.Sprintf("%s.%d", s.path, keep))
+	for i := keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return fmt.Errorf("trace file: %w", err)
+	}
+	return s.open()
+}
+
+func (s *fileSink) Close() error {
+	if err := s.rw.Flush(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+// progressLine is the periodic JSON status of a headless trace.
+type progressLine struct {
+	Time   time.Time      `json:"time"`
+	Trace  string         `json:"trace"`
+	Total  int            `json:"total"`
+	Topics map[string]int `json:"topics"`
+}
+
+// writeProgress writes one progress line for counts.
+func writeProgress(w io.Writer, key string, now time.Time, counts map[string]int) error {
+	line := progressLine{Time: now, Trace: key, Topics: counts}
+	for _, c := range counts {
+		line.Total += c
+	}
+	return json.NewEncoder(w).Encode(line)
+}
+
+// sinkSet serialises writes to the sinks of a tracer.
+type sinkSet struct {
+	mu    sync.Mutex
+	sinks []Sink
+}
+
+func (s *sinkSet) write(m TracerMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sk := range s.sinks {
+		if err := sk.Write(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedTopics returns the keys of counts in order for stable summaries.
+func sortedTopics(counts map[string]int) []string {
+	out := make([]string, 0, len(counts))
+	for t := range counts {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
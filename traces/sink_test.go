@@ -0,0 +1,80 @@
+package traces
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSinkRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.csv")
+	s, err := newFileSink(path, "csv", 60, 2)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	ts := time.Unix(1700000000, 0).UTC()
+	for i := 0; i < 6; i++ {
+		if err := s.Write(TracerMessage{Timestamp: ts, Topic: "a/b", Payload: []byte("payload")}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+		if !strings.HasPrefix(string(data), "time,topic,qos,retained,payload,encoding\n") {
+			t.Fatalf("expected header in %s, got %q", name, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected only two rotated files to be kept")
+	}
+
+	// Reopening appends without a second header.
+	s, err = newFileSink(path, "csv", 0, 2)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	s.Write(TracerMessage{Timestamp: ts, Topic: "a/c", Payload: []byte("x")})
+	s.Close()
+	data, _ := os.ReadFile(path)
+	if n := strings.Count(string(data), "time,topic"); n != 1 {
+		t.Fatalf("expected one header, got %d in %q", n, data)
+	}
+}
+
+func TestOutputWritesNDJSONToStdout(t *testing.T) {
+	var buf bytes.Buffer
+	sinks, err := Output{Format: "ndjson", Stdout: &buf}.sinks()
+	if err != nil || len(sinks) != 1 {
+		t.Fatalf("sinks: %v %v", sinks, err)
+	}
+	sinks[0].Write(TracerMessage{Timestamp: time.Unix(0, 0).UTC(), Topic: "t", Payload: []byte("1"), Retained: true})
+	want := `{"time":"1970-01-01T00:00:00Z","topic":"t","retained":true,"payload":"1"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("got %q", buf.String())
+	}
+	if _, err := (Output{Format: "xml"}).sinks(); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
+
+func TestWriteProgress(t *testing.T) {
+	var buf bytes.Buffer
+	writeProgress(&buf, "k", time.Unix(0, 0).UTC(), map[string]int{"a/#": 2, "b": 1})
+	var line progressLine
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if line.Trace != "k" || line.Total != 3 || line.Topics["a/#"] != 2 {
+		t.Fatalf("unexpected progress %+v", line)
+	}
+}
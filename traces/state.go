@@ -3,8 +3,6 @@ package traces
 import (
 	"bytes"
 	"log"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -78,13 +76,7 @@ func saveTraces(data map[string]TracerConfig) error {
 	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
 		return err
 	}
-	if err := os.MkdirAll(filepath.Dir(fp), os.ModePerm); err != nil {
-		return err
-	}
-	if err := os.WriteFile(fp, buf.Bytes(), 0644); err != nil {
-		return err
-	}
-	return nil
+	return connections.WriteConfigFile(fp, buf.Bytes())
 }
 
 // addTrace merges a single trace configuration into the existing file.
@@ -25,7 +25,7 @@ func addr() string {
 }
 
 func tracerAddClient(cl proxy.DBProxyClient, profile, key string, msg TracerMessage) error {
-	dbKey := fmt.Sprintf("trace/%s/%s/%020d", key, msg.Topic, msg.Timestamp.UnixNano())
+	dbKey := tracerKey(key, msg)
 	val, err := jsonMarshal(msg)
 	if err != nil {
 		return err
@@ -39,6 +39,11 @@ func tracerAddClient(cl proxy.DBProxyClient, profile, key string, msg TracerMess
 	return err
 }
 
+// tracerKey returns the database key of msg within the trace key.
+func tracerKey(key string, msg TracerMessage) string {
+	return fmt.Sprintf("trace/%s/%s/%020d", key, msg.Topic, msg.Timestamp.UnixNano())
+}
+
 func tracerAdd(profile, key string, msg TracerMessage) error {
 	cl, conn, err := proxy.NewClient(addr())
 	if err != nil {
@@ -48,6 +53,46 @@ func tracerAdd(profile, key string, msg TracerMessage) error {
 	return tracerAddClient(cl, profile, key, msg)
 }
 
+// Limits of one WriteBatch request of tracerAddAll.
+const (
+	addBatchSize  = 500
+	addBatchBytes = 1 << 20
+)
+
+// tracerAddAll stores msgs over a single proxy connection in write
+// batches.
+func tracerAddAll(profile, key string, msgs []TracerMessage) error {
+	cl, conn, err := proxy.NewClient(addr())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	req := &proxy.WriteBatchRequest{Profile: profile, Bucket: "traces"}
+	size := 0
+	flush := func() error {
+		if len(req.Records) == 0 {
+			return nil
+		}
+		_, err := cl.WriteBatch(context.Background(), req)
+		req.Records, size = nil, 0
+		return err
+	}
+	for _, m := range msgs {
+		val, err := jsonMarshal(m)
+		if err != nil {
+			return err
+		}
+		req.Records = append(req.Records, &proxy.QueryRecord{Key: tracerKey(key, m), Value: val})
+		size += len(val)
+		if len(req.Records) >= addBatchSize || size >= addBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
 func tracerMessagesClient(cl proxy.DBProxyClient, profile, key string) ([]TracerMessage, error) {
 	prefix := fmt.Sprintf("trace/%s/", key)
 	resp, err := cl.Read(context.Background(), &proxy.ReadRequest{
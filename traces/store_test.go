@@ -1,10 +1,14 @@
 package traces
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/marang/emqutiti/history"
 	"github.com/marang/emqutiti/proxy"
 )
 
@@ -86,3 +90,85 @@ func TestTracerAddError(t *testing.T) {
 		t.Fatalf("expected error")
 	}
 }
+
+func TestTracerMessagesReadsLegacyPayloads(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	SetProxyAddr(p.Addr())
+	t.Cleanup(p.Stop)
+
+	cl, conn, err := proxy.NewClient(p.Addr())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer conn.Close()
+	ts := time.Now()
+	legacy := TracerMessage{Timestamp: ts, Topic: "a"}
+	val := fmt.Sprintf(`{"Timestamp":%q,"Topic":"a","Payload":"old","Kind":"trace"}`, ts.Format(time.RFC3339Nano))
+	if _, err := cl.Write(context.Background(), &proxy.WriteRequest{Profile: "test", Bucket: "traces", Key: tracerKey("k1", legacy), Value: []byte(val)}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	bin := TracerMessage{Timestamp: ts.Add(time.Second), Topic: "b", Payload: []byte{0xff, 0x00}, Kind: "trace"}
+	if err := tracerAddClient(cl, "test", "k1", bin); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	msgs, err := tracerMessagesClient(cl, "test", "k1")
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("messages: %v %v", msgs, err)
+	}
+	if string(msgs[0].Payload) != "old" || !bytes.Equal(msgs[1].Payload, bin.Payload) {
+		t.Fatalf("unexpected payloads: %q %x", msgs[0].Payload, msgs[1].Payload)
+	}
+}
+
+func TestMemStoreWatchFollowsTrace(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p, err := proxy.StartProxy("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	SetProxyAddr(p.Addr())
+	t.Cleanup(p.Stop)
+
+	ms := newMemStore("test", "k1", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := ms.Watch(ctx)
+	if err != nil || ch == nil {
+		t.Fatalf("watch: %v", err)
+	}
+	// The watch starts asynchronously; record until a change arrives.
+	base := time.Unix(1700000000, 0)
+	var c history.Change
+	for i := 0; c.Key == ""; i++ {
+		if i == 250 {
+			t.Fatal("no change received")
+		}
+		if err := tracerAdd("test", "k2", TracerMessage{Timestamp: base, Topic: "other", Payload: []byte("x"), Kind: "trace"}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		if err := tracerAdd("test", "k1", TracerMessage{Timestamp: base.Add(time.Duration(i)), Topic: "a", Payload: []byte("one"), Kind: "trace"}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		select {
+		case c = <-ch:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	want := fmt.Sprintf("a/%020d", c.Message.Timestamp.UnixNano())
+	if c.Key != want || string(c.Message.Payload) != "one" {
+		t.Fatalf("change = %s %+v, want key %s", c.Key, c.Message, want)
+	}
+	if pg, _ := ms.Query(history.Query{Topics: []string{"other"}}); len(pg.Messages) != 0 {
+		t.Fatalf("message of another trace added: %v", pg.Messages)
+	}
+	if ms.Count(false) == 0 {
+		t.Fatal("watched message not added to the store")
+	}
+}
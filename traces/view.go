@@ -11,7 +11,7 @@ func (t *Component) viewTraces() string {
 	t.api.ResetElemPos()
 	t.api.SetElemPos(IDList, 1)
 	listView := t.list.View()
-	help := ui.InfoStyle.Render("[a] add  [enter] start/stop  [v] view  [del] delete  [esc] back")
+	help := ui.InfoStyle.Render("[a] add  [enter] start/stop  [v] view  [e] export  [del] delete  [esc] back")
 	content := lipgloss.JoinVertical(lipgloss.Left, listView, help)
 	focused := t.api.FocusedID() == IDList
 	view := ui.LegendBox(content, "Traces", t.api.Width()-2, 0, ui.ColBlue, focused, -1)
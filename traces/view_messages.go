@@ -24,7 +24,7 @@ func (t *Component) ViewMessages() string {
 		filterLine = ansi.Truncate(filterLine, inner, "")
 		listLines = append([]string{filterLine}, listLines...)
 	}
-	help := ui.InfoStyle.Render("[esc] back")
+	help := ui.InfoStyle.Render("[e] export  [esc] back")
 	listLines = append(listLines, help)
 	content := strings.Join(listLines, "\n")
 	view := ui.LegendBox(content, title, t.api.Width()-2, t.api.TraceHeight(), ui.ColBlue, true, -1)
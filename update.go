@@ -4,6 +4,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/marang/emqutiti/connections"
+	"github.com/marang/emqutiti/history"
 	"github.com/marang/emqutiti/payloads"
 	"github.com/marang/emqutiti/topics"
 )
@@ -19,9 +20,21 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.handleStatusMessage(msg)
 	case MQTTMessage:
 		return m, m.handleMQTTMessage(msg)
+	case requestTimeoutMsg:
+		return m, m.handleRequestTimeout(msg)
+	case reconnectTickMsg:
+		return m, m.handleReconnectTick(msg)
+	case reconnectResultMsg:
+		return m, m.handleReconnectResult(msg)
 	case mqttListenClosedMsg:
 		m.ui.listeners.mqtt = false
 		return m, nil
+	case history.ChangeMsg:
+		if cmd, ok := m.history.HandleChange(msg); ok {
+			return m, cmd
+		}
+		cmd, _ := m.traces.HandleChange(msg)
+		return m, cmd
 	case topics.ToggleMsg:
 		cmds := []tea.Cmd{m.handleTopicToggle(msg)}
 		if m.topicIndexByName(msg.Topic) >= 0 {
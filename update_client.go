@@ -52,13 +52,17 @@ func (m *model) handleTopicToggle(msg topics.ToggleMsg) tea.Cmd {
 		return nil
 	}
 
-	var err error
-	if msg.Subscribed {
-		err = m.mqttClient.Subscribe(msg.Topic, 0, nil)
-	} else {
-		err = m.mqttClient.Unsubscribe(msg.Topic)
+	if !msg.Subscribed {
+		err := m.mqttClient.Unsubscribe(msg.Topic)
+		m.topics.SetGranted(msg.Topic, -1)
+		m.logTopicAction(msg.Topic, action, err)
+		return nil
 	}
+	qos, err := m.subscribeTopic(msg.Topic)
 	m.logTopicAction(msg.Topic, action, err)
+	if granted, ok := m.mqttClient.GrantedQoS(msg.Topic); ok && err == nil && granted != qos {
+		m.history.Append(msg.Topic, "", "log", false, fmt.Sprintf("Broker granted QoS %d for %s (requested %d)", granted, msg.Topic, qos))
+	}
 	return nil
 }
 
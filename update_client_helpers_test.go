@@ -3,6 +3,7 @@ package emqutiti
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -51,6 +52,20 @@ func (c *fakeClient) OptionsReader() mqtt.ClientOptionsReader {
 	return mqtt.NewOptionsReader(mqtt.NewClientOptions())
 }
 
+// clientModel returns a model connected through fc, with p as its active
+// profile "p" and items as its topics.
+func clientModel(t *testing.T, p connections.Profile, fc mqtt.Client, items ...topics.Item) *model {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	m, _ := initialModel(nil)
+	p.Name = "p"
+	m.connections.Manager.Profiles = []connections.Profile{p}
+	m.connections.Active = "p"
+	m.mqttClient = &MQTTClient{Client: fc}
+	m.topics.Items = slices.Clone(items)
+	return m
+}
+
 func TestHandleMouseScrollTopics(t *testing.T) {
 	m, _ := initialModel(nil)
 	m.Update(tea.WindowSizeMsg{Width: 40, Height: 20})
@@ -110,10 +125,10 @@ func TestFilterHistoryList(t *testing.T) {
 	hs := &historyStore{}
 	m.history.SetStore(hs)
 	ts := time.Now()
-	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: "hello", Kind: "pub", Retained: false}); err != nil {
+	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: []byte("hello"), Kind: "pub", Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
-	if err := hs.Append(history.Message{Timestamp: ts, Topic: "bar", Payload: "bye", Kind: "pub", Retained: false}); err != nil {
+	if err := hs.Append(history.Message{Timestamp: ts, Topic: "bar", Payload: []byte("bye"), Kind: "pub", Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
 
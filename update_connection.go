@@ -14,8 +14,7 @@ type statusFunc func(string)
 func connectBroker(p connections.Profile, fn statusFunc) tea.Cmd {
 	return func() tea.Msg {
 		if fn != nil {
-			brokerURL := p.BrokerURL()
-			fn(fmt.Sprintf("Connecting to %s", brokerURL))
+			fn(fmt.Sprintf("Connecting to %s", brokerLabel(p)))
 		}
 		client, err := NewMQTTClient(p, fn)
 		return connections.ConnectResult{Client: client, Profile: p, Err: err}
@@ -147,6 +147,12 @@ func (m *model) handleKeyNav(msg tea.KeyMsg) (tea.Cmd, bool) {
 		if m.CurrentMode() == constants.ModeHistoryFilter {
 			return m.history.UpdateFilter(msg), true
 		}
+		if m.CurrentMode() == constants.ModeBridge {
+			return m.updateBridge(msg), true
+		}
+		if m.CurrentMode() == constants.ModeExport {
+			return m.updateExport(msg), true
+		}
 		if m.CurrentMode() == constants.ModeEditConnection {
 			if m.connections.Form != nil {
 				m.connections.Form.CycleFocus(msg)
@@ -161,6 +167,12 @@ func (m *model) handleKeyNav(msg tea.KeyMsg) (tea.Cmd, bool) {
 		if m.CurrentMode() == constants.ModeHistoryFilter {
 			return m.history.UpdateFilter(msg), true
 		}
+		if m.CurrentMode() == constants.ModeBridge {
+			return m.updateBridge(msg), true
+		}
+		if m.CurrentMode() == constants.ModeExport {
+			return m.updateExport(msg), true
+		}
 		if m.CurrentMode() == constants.ModeEditConnection {
 			if m.connections.Form != nil {
 				m.connections.Form.CycleFocus(msg)
@@ -174,6 +186,7 @@ func (m *model) handleKeyNav(msg tea.KeyMsg) (tea.Cmd, bool) {
 	}
 
 	if m.CurrentMode() != constants.ModeHistoryFilter &&
+		m.CurrentMode() != constants.ModeMessageProps &&
 		(key == constants.KeyEnter || key == constants.KeySpaceBar || key == constants.KeySpace) &&
 		m.help.Focused() {
 		return m.SetMode(constants.ModeHelp), true
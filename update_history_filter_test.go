@@ -16,7 +16,7 @@ func TestUpdateHistoryFilter(t *testing.T) {
 	hs := &historyStore{}
 	m.history.SetStore(hs)
 	ts := time.Now()
-	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: "hello", Kind: "pub", Retained: false}); err != nil {
+	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: []byte("hello"), Kind: "pub", Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
 
@@ -40,10 +40,10 @@ func TestHistoryFilterPersists(t *testing.T) {
 	hs := &historyStore{}
 	m.history.SetStore(hs)
 	ts := time.Now()
-	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: "hello", Kind: "pub", Retained: false}); err != nil {
+	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: []byte("hello"), Kind: "pub", Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
-	if err := hs.Append(history.Message{Timestamp: ts, Topic: "bar", Payload: "bye", Kind: "pub", Retained: false}); err != nil {
+	if err := hs.Append(history.Message{Timestamp: ts, Topic: "bar", Payload: []byte("bye"), Kind: "pub", Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
 
@@ -75,10 +75,10 @@ func TestHistoryFilterUpdatesCounts(t *testing.T) {
 	hs := &historyStore{}
 	m.history.SetStore(hs)
 	ts := time.Now()
-	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: "hello", Kind: "pub", Retained: false}); err != nil {
+	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: []byte("hello"), Kind: "pub", Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
-	if err := hs.Append(history.Message{Timestamp: ts, Topic: "bar", Payload: "bye", Kind: "pub", Retained: false}); err != nil {
+	if err := hs.Append(history.Message{Timestamp: ts, Topic: "bar", Payload: []byte("bye"), Kind: "pub", Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
 
@@ -101,10 +101,10 @@ func TestHistoryFilterArchived(t *testing.T) {
 	hs := &historyStore{}
 	m.history.SetStore(hs)
 	ts := time.Now()
-	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: "hello", Kind: "pub", Retained: false}); err != nil {
+	if err := hs.Append(history.Message{Timestamp: ts, Topic: "foo", Payload: []byte("hello"), Kind: "pub", Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
-	if err := hs.Append(history.Message{Timestamp: ts, Topic: "bar", Payload: "bye", Kind: "pub", Archived: true, Retained: false}); err != nil {
+	if err := hs.Append(history.Message{Timestamp: ts, Topic: "bar", Payload: []byte("bye"), Kind: "pub", Archived: true, Retained: false}); err != nil {
 		t.Fatalf("Append failed: %v", err)
 	}
 
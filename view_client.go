@@ -21,6 +21,9 @@ func (m *model) clientInfoLine() string {
 		clientID = r.ClientID()
 	}
 	status := strings.TrimSpace(m.connections.Connection + " " + clientID)
+	if m.mqttClient != nil {
+		status += "  " + formatBufferStats(m.mqttClient.Stats())
+	}
 	st := ui.InfoSubtleStyle
 	if m.isConnected() {
 		st = st.Foreground(ui.ColGreen)
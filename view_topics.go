@@ -60,7 +60,7 @@ func renderTopicChipsWithPulses(items []topics.Item, selected, hovered, width in
 		if contentWidth > maxTopicChipWidth {
 			contentWidth = maxTopicChipWidth
 		}
-		label := t.Name
+		label := t.ChipLabel()
 		if i == selected && lipgloss.Width(label) > contentWidth {
 			wrapped := ansi.Hardwrap(label, contentWidth, false)
 			lines := strings.Split(wrapped, "\n")
@@ -74,7 +74,13 @@ func renderTopicChipsWithPulses(items []topics.Item, selected, hovered, width in
 			continue
 		}
 		if lipgloss.Width(label) > contentWidth {
-			label = ansi.Truncate(label, contentWidth, "…")
+			// Keep the QoS suffix visible and shorten the topic name instead.
+			suffix := strings.TrimPrefix(label, t.Name)
+			nameWidth := contentWidth - lipgloss.Width(suffix)
+			if nameWidth < 1 {
+				suffix, nameWidth = "", contentWidth
+			}
+			label = ansi.Truncate(t.Name, nameWidth, "…") + suffix
 		}
 		chips = append(chips, st.Render(label))
 	}
@@ -116,7 +122,7 @@ func (m *model) layoutTopicViewport(chips []string) (string, []topics.ChipBound,
 		topicsSP = m.topics.VP.ScrollPercent()
 	}
 	chipContent := m.topics.VP.View()
-	info := topicStateLegend() + " | [←/→] move  [enter] sub  [p] pub  [del] del"
+	info := topicStateLegend() + " | [←/→] move  [enter] sub  [p] pub  [q] qos  [del] del"
 	if working := m.topicsWorkingText(); working != "" {
 		info = working + " | " + info
 	}